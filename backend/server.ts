@@ -262,7 +262,7 @@ app.post('/api/createOwner', verifyToken, async (req: Request, res: Response) =>
     const { ownerID, name, address, age } = req.body;
     
     // Utilisation du contract "owner" pour créer un propriétaire
-    const result = await contract.submitTransaction('queryPrime', ownerID);
+    const result = await contract.evaluateTransaction('QueryPrime', ownerID);
     res.json({ result: result.toString() });
   } catch (error) {
     if (error instanceof Error) {
@@ -322,9 +322,10 @@ async function authenticateUser(enrollID: string, enrollSecret: string): Promise
 app.post('/api/addEncryptedTripData', verifyToken, async (req: Request, res: Response) => {
   try {
     const { contract } = await initContract(req.wallet!, req.enrollID!);
-    const { vehID, tripID, date, speeding, hard_accelerations, emergency_brakes, unsafe_distance, high_risk_zones, traffic_signal_compliance, night_driving, mileage } = req.body;
+    const { vehID, tripID, date, speeding, hard_accelerations, emergency_brakes, unsafe_distance, high_risk_zones, traffic_signal_compliance, night_driving, mileage, proofs } = req.body;
 
-    const result = await contract.submitTransaction('addEncryptedTripData', vehID, tripID, date, speeding, hard_accelerations, emergency_brakes, unsafe_distance, high_risk_zones, traffic_signal_compliance, night_driving, mileage );
+    // Les preuves de champ sont facultatives : une liste vide n'en joint aucune
+    const result = await contract.submitTransaction('AddEncryptedTripData', vehID, tripID, date, speeding, hard_accelerations, emergency_brakes, unsafe_distance, high_risk_zones, traffic_signal_compliance, night_driving, mileage, JSON.stringify(proofs ?? []));
     res.json({ success: true, message: 'Encrypted trip successfully added.', result: result.toString() });
   } catch (error) {
     console.error(`Erreur lors de l'ajout du trajet: ${error}`);
@@ -337,9 +338,10 @@ app.post('/api/addEncryptedTripData', verifyToken, async (req: Request, res: Res
 app.post('/api/addVerifier', verifyToken, async (req: Request, res: Response) => {
   try {
     const { contract } = await initContract(req.wallet!, req.enrollID!);
-    const { ownerID, n, nsquare } = req.body;
+    const { ownerID, n, nsquare, exponent } = req.body;
 
-    const result = await contract.submitTransaction('addVerifier', ownerID, n, nsquare);
+    // Un exposant nul déclare une clé de Paillier
+    const result = await contract.submitTransaction('AddVerifier', ownerID, n, nsquare, (exponent ?? 0).toString());
     res.json({ success: true, message: 'Verifier ajouté avec succès.', result: result.toString() });
   } catch (error) {
     console.error(`Erreur lors de l'ajout du vérificateur: ${error}`);
@@ -350,15 +352,16 @@ app.post('/api/addVerifier', verifyToken, async (req: Request, res: Response) =>
 app.post('/api/addEncryptedVehicleData', verifyToken, async (req: Request, res: Response) => {
   try {
     const { contract } = await initContract(req.wallet!, req.enrollID!);
-    const { vehicleID, age, vehicleType, purchaseMileage, year } = req.body;
+    const { vehicleID, vehicleType, purchaseMileage, year, ownerID, proofs } = req.body;
 
     const result = await contract.submitTransaction(
-      'addEncryptedVehicleData',
+      'AddEncryptedVehicleData',
       vehicleID,
-      age.toString(),
       vehicleType.toString(),
       purchaseMileage.toString(),
-      year.toString()
+      year.toString(),
+      ownerID,
+      JSON.stringify(proofs ?? [])
     );
     res.json({ success: true, message: 'Données de véhicule chiffrées ajoutées avec succès.', result: result.toString() });
   } catch (error) {
@@ -382,10 +385,11 @@ app.post('/api/addEncryptedTripData', verifyToken, async (req: Request, res: Res
       trafficSignalCompliance,
       nightDriving,
       mileage,
+      proofs,
     } = req.body;
 
     const result = await contract.submitTransaction(
-      'addEncryptedTripData',
+      'AddEncryptedTripData',
       vehicleID,
       tripID,
       date,
@@ -396,7 +400,8 @@ app.post('/api/addEncryptedTripData', verifyToken, async (req: Request, res: Res
       highRiskZones.toString(),
       trafficSignalCompliance.toString(),
       nightDriving.toString(),
-      mileage.toString()
+      mileage.toString(),
+      JSON.stringify(proofs ?? [])
     );
 
     res.json({ success: true, message: 'Données de trajet chiffrées ajoutées avec succès.', result: result.toString() });
@@ -443,12 +448,13 @@ app.post('/api/addVehicleData', verifyToken, async (req: Request, res: Response)
 
     // Soumission de la transaction au smart contract
     const result = await contract.submitTransaction(
-      'addEncryptedVehicleData',
+      'AddEncryptedVehicleData',
       vehicleID,
       vehicle_type.toString(),
       purchase_mileage.toString(),
       year.toString(),
-      ownerID
+      ownerID,
+      '[]'
     );
 
     res.json({ success: true, message: 'Données du véhicule ajoutées avec succès.', result: result.toString() });
@@ -465,7 +471,7 @@ app.post('/api/calculateInsurancePremium', verifyToken, async (req: Request, res
     const { vehicleID, tripID, criteriaWeightsID } = req.body;
 
     const calculationResult = await contract.submitTransaction(
-      'calculateInsurancePremium',
+      'CalculateInsurancePremium',
       vehicleID,
       tripID,
       criteriaWeightsID
@@ -521,7 +527,7 @@ app.post('/api/decryptInsurancePremiumAndUpdate', verifyToken, async (req: Reque
     console.log(resultID)
     console.log(rPrime)
     const decryptResult = await contract.submitTransaction(
-      'decryptInsurancePremiumAndUpdate',
+      'DecryptInsurancePremiumAndUpdate',
       resultID,
       rPrime
     );
@@ -544,7 +550,7 @@ app.get('/api/queryTripsByVehicleID/:vehicleID', verifyToken, async (req: Reques
     console.log(vehicleID)
 
     // Appeler la fonction `queryTripsByVehicleID` du smart contract
-    const result = await contract.evaluateTransaction('queryTripsByVehicleID', vehicleID);
+    const result = await contract.evaluateTransaction('QueryTripsByVehicleID', vehicleID);
 
     // Retourner les trips dans le même format que `queryVehicleData`
     res.json({ success: true, data: JSON.parse(result.toString()) });
@@ -560,7 +566,7 @@ app.get('/api/queryPrimesByVehicleID/:vehicleID', verifyToken, async (req: Reque
     const vehicleID = req.params.vehicleID;
 
     // Appel de la fonction du smart contract pour récupérer les primes
-    const result = await contract.evaluateTransaction('queryPrimesByVehicleID', vehicleID);
+    const result = await contract.evaluateTransaction('QueryPrimesByVehicleID', vehicleID);
 
     // Retourner les primes sous forme de JSON
     res.json({ success: true, primes: JSON.parse(result.toString()) });
@@ -577,7 +583,7 @@ app.get('/api/queryEncryptedResultsByVehicleID/:vehicleID', verifyToken, async (
     const vehicleID = req.params.vehicleID;
 
     // Appel de la fonction du smart contract pour récupérer les résultats chiffrés
-    const result = await contract.evaluateTransaction('queryEncryptedCalculationResultsByVehicleID', vehicleID);
+    const result = await contract.evaluateTransaction('QueryEncryptedCalculationResultsByVehicleID', vehicleID);
 
     // Retourner les résultats sous forme de JSON
     res.json({ success: true, encryptedResults: JSON.parse(result.toString()) });
@@ -592,7 +598,7 @@ app.get('/api/queryVehicleData/:vehicleID', verifyToken, async (req: Request, re
     const { contract } = await initContract(req.wallet!, req.enrollID!);
     const vehicleID = req.params.vehicleID;
 
-    const result = await contract.evaluateTransaction('queryVehicleData', vehicleID);
+    const result = await contract.evaluateTransaction('QueryVehicleData', vehicleID);
     res.json({ success: true, data: JSON.parse(result.toString()) });
   } catch (error) {
     console.error(`Erreur lors de la récupération des données de véhicule: ${error}`);
@@ -605,7 +611,7 @@ app.get('/api/addEncryptedVehicleData/:vehicleID', verifyToken, async (req: Requ
     const { contract } = await initContract(req.wallet!, req.enrollID!);
     const vehicleID = req.params.vehicleID;
 
-    const result = await contract.evaluateTransaction('addEncryptedVehicleData', vehicleID);
+    const result = await contract.evaluateTransaction('QueryVehicleData', vehicleID);
     res.json({ success: true, data: JSON.parse(result.toString()) });
   } catch (error) {
     console.error(`Erreur lors de la récupération des données de véhicule: ${error}`);
@@ -620,7 +626,7 @@ app.get('/api/queryVerifier/:ownerID', verifyToken, async (req: Request, res: Re
     const ownerID = req.params.ownerID;
 
     // Appel de la méthode 'queryVerifier' du smart contract
-    const result = await contract.evaluateTransaction('queryVerifier', ownerID);
+    const result = await contract.evaluateTransaction('QueryVerifier', ownerID);
 
     // Retourner le résultat au frontend
     res.json({ success: true, data: JSON.parse(result.toString()) });
@@ -636,7 +642,7 @@ app.get('/api/queryTrips/:vehicleID', verifyToken, async (req: Request, res: Res
     const { contract } = await initContract(req.wallet!, req.enrollID!);
     const vehicleID = req.params.vehicleID;
 
-    const result = await contract.evaluateTransaction('queryTrips', vehicleID); // Assurez-vous que votre smart contract supporte cette fonction.
+    const result = await contract.evaluateTransaction('QueryTripsByVehicleID', vehicleID);
     res.json({ success: true, trips: JSON.parse(result.toString()) });
   } catch (error) {
     console.error(`Erreur lors de la récupération des trajets: ${error}`);
@@ -649,7 +655,7 @@ app.get('/api/queryMonthPrime/:vehicleID/:month/:year', verifyToken, async (req:
     const { contract } = await initContract(req.wallet!, req.enrollID!);
     const { vehicleID, month, year } = req.params;
 
-    const result = await contract.evaluateTransaction('queryMonthPrime', vehicleID, month, year);
+    const result = await contract.evaluateTransaction('QueryMonthPrime', vehicleID, month, year);
     res.json({ success: true, monthPrime: JSON.parse(result.toString()) });
   } catch (error) {
     console.error(`Erreur lors de la récupération de la prime mensuelle: ${error}`);
@@ -662,7 +668,7 @@ app.get('/api/queryPrime/:tripID', verifyToken, async (req: Request, res: Respon
     const { contract } = await initContract(req.wallet!, req.enrollID!);
     const tripID = req.params.tripID;
 
-    const result = await contract.evaluateTransaction('queryPrime', tripID);
+    const result = await contract.evaluateTransaction('QueryPrime', tripID);
     res.json({ success: true, prime: JSON.parse(result.toString()) });
   } catch (error) {
     console.error(`Erreur lors de la récupération de la prime associée au trajet: ${error}`);
@@ -675,7 +681,7 @@ app.get('/api/queryEncryptedCalculationResult/:tripID', verifyToken, async (req:
     const { contract } = await initContract(req.wallet!, req.enrollID!);
     const resultID = req.params.resultID;
 
-    const result = await contract.evaluateTransaction('queryEncryptedCalculationResult', resultID);
+    const result = await contract.evaluateTransaction('QueryEncryptedCalculationResult', resultID);
     res.json({ success: true, prime: JSON.parse(result.toString()) });
   } catch (error) {
     console.error(`Erreur lors de la récupération de la prime chiffrée: ${error}`);
@@ -689,7 +695,7 @@ app.get('/api/queryOwnerDetails/:ownerID', verifyToken, async (req: Request, res
     const ownerID = req.params.ownerID;
 
     // Appel de la méthode 'queryOwnerDetails' du smart contract
-    const result = await contract.evaluateTransaction('queryOwnerDetails', ownerID);
+    const result = await contract.evaluateTransaction('QueryOwnerDetails', ownerID);
     console.log()
     // Retourner le résultat sous forme JSON
     res.json({ success: true, data: JSON.parse(result.toString()) });
@@ -703,7 +709,7 @@ app.get('/api/queryAllCriteriaWeights', verifyToken, async (req: Request, res: R
   try {
     const { contract } = await initContract(req.wallet!, req.enrollID!);
 
-    const result = await contract.evaluateTransaction('queryAllCriteriaWeights');
+    const result = await contract.evaluateTransaction('QueryAllCriteriaWeights');
     res.json({ success: true, data: JSON.parse(result.toString()) });
   } catch (error) {
     console.error(`Erreur lors de la récupération de tous les CriteriaWeights: ${error}`);
@@ -728,7 +734,7 @@ app.get('/api/getAllClientDetails', verifyToken, async (req: Request, res: Respo
       const ownerID = client.name; // On suppose que le `name` correspond à l'ownerID
 
       // Appel de `queryOwnerDetails` pour chaque utilisateur
-      const ownerDetailsResult = await contract.evaluateTransaction('queryOwnerDetails', ownerID);
+      const ownerDetailsResult = await contract.evaluateTransaction('QueryOwnerDetails', ownerID);
       const ownerDetails = JSON.parse(ownerDetailsResult.toString());
 
       // Fusionner les détails dans le client
@@ -763,7 +769,7 @@ app.post('/api/addInsuranceContract', verifyToken, async (req: Request, res: Res
     } = req.body;
 
     const result = await contract.submitTransaction(
-      'addInsuranceContract',
+      'AddInsuranceContract',
       contractID,
       ownerID,
       vehicleID,
@@ -798,7 +804,7 @@ app.post('/api/addCriteriaWeight', verifyToken, async (req: Request, res: Respon
     } = req.body;
 
     const result = await contract.submitTransaction(
-      'addCriteriaWeights',
+      'AddCriteriaWeights',
       criteriaWeightsID,
       weightTraffic.toString(),
       weightSpeed.toString(),
@@ -5,6 +5,20 @@ import JSBI from 'jsbi';
 
 const API_URL = 'http://localhost:3001/api';
 
+// Lit un entier persisté par la chaincode : format compact "<étiquette>:<base64>"
+// (big-endian, base64 standard sans remplissage) ou écriture décimale
+function parseBigInt(value) {
+  const s = String(value);
+  const i = s.indexOf(':');
+  if (i < 0) return JSBI.BigInt(s);
+  const bytes = atob(s.slice(i + 1));
+  let hex = '';
+  for (let k = 0; k < bytes.length; k++) {
+    hex += bytes.charCodeAt(k).toString(16).padStart(2, '0');
+  }
+  return JSBI.BigInt('0x' + (hex || '0'));
+}
+
 // Définition du déchiffreur
 function modPow(base, exponent, modulus) {
   base = JSBI.remainder(base, modulus);
@@ -53,7 +67,7 @@ class Decryptor {
   }
 
   decrypt(C) {
-    const u = modPow(JSBI.BigInt(C), this.lambda, this.nsquare);
+    const u = modPow(parseBigInt(C), this.lambda, this.nsquare);
     const L_u = JSBI.divide(JSBI.subtract(u, JSBI.BigInt(1)), this.n);
     return JSBI.remainder(JSBI.multiply(L_u, this.mu), this.n).toString();
   }
@@ -5,6 +5,20 @@ import { useNavigate } from 'react-router-dom';
 
 const API_URL = 'http://localhost:3001/api';
 
+// Lit un entier persisté par la chaincode : format compact "<étiquette>:<base64>"
+// (big-endian, base64 standard sans remplissage) ou écriture décimale
+function parseBigInt(value) {
+  const s = String(value);
+  const i = s.indexOf(':');
+  if (i < 0) return JSBI.BigInt(s);
+  const bytes = atob(s.slice(i + 1));
+  let hex = '';
+  for (let k = 0; k < bytes.length; k++) {
+    hex += bytes.charCodeAt(k).toString(16).padStart(2, '0');
+  }
+  return JSBI.BigInt('0x' + (hex || '0'));
+}
+
 const Home = ({ token, ownerID, logout }) => {
     const navigate = useNavigate();
     const [loading, setLoading] = useState(true);
@@ -70,7 +84,7 @@ const Home = ({ token, ownerID, logout }) => {
   
       computeRPrime(R) {
         const N_inverse_mod_lambda = this.modInverse(this.n, this.lambda);
-        return JSBI.remainder(JSBI.exponentiate(JSBI.BigInt(R), N_inverse_mod_lambda), this.n);
+        return JSBI.remainder(JSBI.exponentiate(parseBigInt(R), N_inverse_mod_lambda), this.n);
       }
       
   
@@ -78,7 +92,7 @@ const Home = ({ token, ownerID, logout }) => {
         // console.log( this.lambda)
         // console.log( this.C)
         // console.log( this.nsquare)
-        const u = modPow(JSBI.BigInt(C), this.lambda, this.nsquare);
+        const u = modPow(parseBigInt(C), this.lambda, this.nsquare);
         const L_u = JSBI.divide(JSBI.subtract(u, JSBI.BigInt(1)), this.n);
         return JSBI.remainder(JSBI.multiply(L_u, this.mu), this.n);
       }
@@ -1,21 +1,18 @@
 module simple
 
-go 1.12
+go 1.20
 
 require (
-	github.com/fsouza/go-dockerclient v1.6.5 // indirect
-	github.com/golang/protobuf v1.3.3 // indirect
-	github.com/grpc-ecosystem/go-grpc-middleware v1.2.1 // indirect
-	github.com/hyperledger/fabric v1.4.1
-	github.com/hyperledger/fabric-amcl v0.0.0-20200424173818-327c9e2cf77a // indirect
-	github.com/miekg/pkcs11 v1.1.1 // indirect
-	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
-	github.com/spf13/viper v1.7.1 // indirect
-	github.com/sykesm/zap-logfmt v0.0.3 // indirect
-	go.uber.org/zap v1.15.0 // indirect
-	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a // indirect
-	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
-	google.golang.org/grpc v1.31.1 // indirect
-	gopkg.in/yaml.v2 v2.3.0 // indirect
+	github.com/hyperledger/fabric-chaincode-go v0.0.0-20230731094759-d626e9ab09b9
+	github.com/hyperledger/fabric-protos-go v0.3.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.2 // indirect
+	golang.org/x/net v0.7.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	golang.org/x/text v0.7.0 // indirect
+	google.golang.org/genproto v0.0.0-20230110181048-76db0878b65f // indirect
+	google.golang.org/grpc v1.53.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
 )
@@ -6,8 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 )
 
 // AuthChaincode manages user authentication
@@ -266,7 +266,20 @@ func hashPassword(password string) string {
 }
 
 func main() {
-	err := shim.Start(new(AuthChaincode))
+	chaincode := new(AuthChaincode)
+
+	server, err := newChaincodeServer(chaincode)
+	if err != nil {
+		fmt.Printf("Error configuring AuthChaincode server: %s", err)
+		return
+	}
+
+	// Without CHAINCODE_SERVER_ADDRESS the peer launches the chaincode itself
+	if server != nil {
+		err = server.Start()
+	} else {
+		err = shim.Start(chaincode)
+	}
 	if err != nil {
 		fmt.Printf("Error starting AuthChaincode: %s", err)
 	}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+// clientHash reproduces the hash the backend applies to a password before
+// sending it to getRole and changePassword
+func clientHash(password string) string {
+	return hashPassword(password)
+}
+
+// invocation is a single chaincode call and its expected outcome: the payload
+// on success, or the error message on failure
+type invocation struct {
+	name        string
+	args        []string
+	wantPayload string
+	wantErr     string
+}
+
+func newTestStub(t *testing.T) *shimtest.MockStub {
+	stub := shimtest.NewMockStub("authentification", new(AuthChaincode))
+	if res := stub.MockInit("init", nil); res.Status != shim.OK {
+		t.Fatalf("Init: %s", res.Message)
+	}
+	return stub
+}
+
+func invoke(stub *shimtest.MockStub, txID int, args ...string) ([]byte, string, bool) {
+	callArgs := make([][]byte, len(args))
+	for i, arg := range args {
+		callArgs[i] = []byte(arg)
+	}
+	res := stub.MockInvoke(fmt.Sprintf("tx%d", txID), callArgs)
+	return res.Payload, res.Message, res.Status == shim.OK
+}
+
+// run executes the invocations in order on the same ledger
+func run(t *testing.T, stub *shimtest.MockStub, tests []invocation) {
+	for i, tt := range tests {
+		payload, message, ok := invoke(stub, i, tt.args...)
+		switch {
+		case tt.wantErr != "" && ok:
+			t.Fatalf("%s: expected error %q, got success", tt.name, tt.wantErr)
+		case tt.wantErr != "" && message != tt.wantErr:
+			t.Fatalf("%s: expected error %q, got %q", tt.name, tt.wantErr, message)
+		case tt.wantErr == "" && !ok:
+			t.Fatalf("%s: unexpected error %q", tt.name, message)
+		case tt.wantErr == "" && tt.wantPayload != "" && string(payload) != tt.wantPayload:
+			t.Fatalf("%s: expected payload %q, got %q", tt.name, tt.wantPayload, payload)
+		}
+	}
+}
+
+func registerUser(name, password, role string) []string {
+	return []string{"register", name, password, role, "1990-04-12", "12 rue de la Paix, Paris"}
+}
+
+func TestInit(t *testing.T) {
+	stub := shimtest.NewMockStub("authentification", new(AuthChaincode))
+	res := stub.MockInit("init", [][]byte{[]byte("init")})
+	if res.Status != shim.OK {
+		t.Fatalf("Init: %s", res.Message)
+	}
+}
+
+func TestInvalidFunction(t *testing.T) {
+	run(t, newTestStub(t), []invocation{
+		{name: "unknown function", args: []string{"getUser", "alice"}, wantErr: "Invalid function name"},
+		{name: "no function", args: nil, wantErr: "Invalid function name"},
+	})
+}
+
+func TestRegister(t *testing.T) {
+	stub := newTestStub(t)
+	run(t, stub, []invocation{
+		{name: "missing address", args: registerUser("alice", "secret", "client")[:5], wantErr: "Incorrect number of arguments. Expecting 5: name, password, role, age, address"},
+		{name: "valid user", args: registerUser("alice", "secret", "client"), wantPayload: "User registered successfully"},
+	})
+
+	var user User
+	err := json.Unmarshal(stub.State["alice"], &user)
+	if err != nil {
+		t.Fatalf("Unmarshal User: %v", err)
+	}
+	// The password is stored as the hash of the hash sent by the backend
+	want := User{Name: "alice", Password: hashPassword(clientHash("secret")), Role: "client", Dateofbirth: "1990-04-12", Address: "12 rue de la Paix, Paris"}
+	if user != want {
+		t.Fatalf("unexpected stored user %+v", user)
+	}
+}
+
+func TestGetRole(t *testing.T) {
+	run(t, newTestStub(t), []invocation{
+		{name: "register", args: registerUser("alice", "secret", "insurer")},
+		{name: "missing password", args: []string{"getRole", "alice"}, wantErr: "Incorrect number of arguments. Expecting 2: name, password"},
+		{name: "unknown user", args: []string{"getRole", "bob", clientHash("secret")}, wantErr: "User not found"},
+		{name: "wrong password", args: []string{"getRole", "alice", clientHash("wrong")}, wantErr: "Invalid password"},
+		{name: "unhashed password", args: []string{"getRole", "alice", "secret"}, wantErr: "Invalid password"},
+		{name: "valid password", args: []string{"getRole", "alice", clientHash("secret")}, wantPayload: "insurer"},
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	run(t, newTestStub(t), []invocation{
+		{name: "register", args: registerUser("alice", "secret", "client")},
+		{name: "missing new password", args: []string{"changePassword", "alice", clientHash("secret")}, wantErr: "Incorrect number of arguments. Expecting 3: name, current password, new password"},
+		{name: "unknown user", args: []string{"changePassword", "bob", clientHash("secret"), clientHash("updated")}, wantErr: "User not found"},
+		{name: "wrong current password", args: []string{"changePassword", "alice", clientHash("wrong"), clientHash("updated")}, wantErr: "Invalid current password"},
+		{name: "valid current password", args: []string{"changePassword", "alice", clientHash("secret"), clientHash("updated")}, wantPayload: "Password updated successfully"},
+		{name: "old password", args: []string{"getRole", "alice", clientHash("secret")}, wantErr: "Invalid password"},
+		{name: "new password", args: []string{"getRole", "alice", clientHash("updated")}, wantPayload: "client"},
+	})
+}
+
+func TestGetClients(t *testing.T) {
+	stub := newTestStub(t)
+	run(t, stub, []invocation{
+		{name: "no users", args: []string{"getClients"}, wantPayload: "null"},
+		{name: "register client", args: registerUser("alice", "secret", "client")},
+		{name: "register insurer", args: registerUser("bob", "secret", "insurer")},
+		{name: "register second client", args: registerUser("carol", "secret", "client")},
+		{name: "unexpected argument", args: []string{"getClients", "client"}, wantErr: "Incorrect number of arguments. Expecting 0"},
+	})
+
+	payload, message, ok := invoke(stub, 100, "getClients")
+	if !ok {
+		t.Fatalf("getClients: %s", message)
+	}
+	var clients []User
+	err := json.Unmarshal(payload, &clients)
+	if err != nil {
+		t.Fatalf("Unmarshal clients: %v", err)
+	}
+	if len(clients) != 2 || clients[0].Name != "alice" || clients[1].Name != "carol" {
+		t.Fatalf("unexpected clients %+v", clients)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	stub := newTestStub(t)
+	run(t, stub, []invocation{
+		{name: "register", args: registerUser("alice", "secret", "client")},
+		{name: "missing name", args: []string{"deleteUser"}, wantErr: "Incorrect number of arguments. Expected: 1 (name)"},
+		{name: "unknown user", args: []string{"deleteUser", "bob"}, wantErr: "User not found"},
+		{name: "existing user", args: []string{"deleteUser", "alice"}, wantPayload: "User successfully deleted"},
+		{name: "deleted user", args: []string{"getRole", "alice", clientHash("secret")}, wantErr: "User not found"},
+		{name: "deleted twice", args: []string{"deleteUser", "alice"}, wantErr: "User not found"},
+	})
+
+	if _, ok := stub.State["alice"]; ok {
+		t.Fatal("deleted user is still in the world state")
+	}
+}
+
+func TestUpdateAgeAndAddress(t *testing.T) {
+	stub := newTestStub(t)
+	run(t, stub, []invocation{
+		{name: "register", args: registerUser("alice", "secret", "client")},
+		{name: "missing address", args: []string{"updateAgeAndAddress", "alice", "1991-05-20"}, wantErr: "Incorrect number of arguments. Expecting 3: name, dateofbirth, address"},
+		{name: "unknown user", args: []string{"updateAgeAndAddress", "bob", "1991-05-20", "Lyon"}, wantErr: "User not found"},
+		{name: "existing user", args: []string{"updateAgeAndAddress", "alice", "1991-05-20", "Lyon"}, wantPayload: "Age and address updated successfully"},
+		{name: "role unchanged", args: []string{"getRole", "alice", clientHash("secret")}, wantPayload: "client"},
+	})
+
+	var user User
+	err := json.Unmarshal(stub.State["alice"], &user)
+	if err != nil {
+		t.Fatalf("Unmarshal User: %v", err)
+	}
+	if user.Dateofbirth != "1991-05-20" || user.Address != "Lyon" {
+		t.Fatalf("unexpected updated user %+v", user)
+	}
+}
@@ -0,0 +1,94 @@
+// Package billing calcule les primes chiffrées des trajets, en enregistre le
+// déchiffrement vérifiable et agrège les primes mensuelles des véhicules.
+package billing
+
+import (
+	"fmt"
+	"math/big"
+
+	"simple/paillier"
+)
+
+// EncryptedCalculationResult représente la prime chiffrée d'un trajet
+type EncryptedCalculationResult struct {
+	ResultID    string               `json:"resultID"`
+	PrimeTotale *paillier.Ciphertext `json:"prime_totale"` // Chiffré
+	R           *big.Int             `json:"r"`            // Calculé pour décryptage
+	TripID      string               `json:"tripID"`
+	Scale       int64                `json:"scale,omitempty"`       // Échelle de virgule fixe du clair (1 par défaut)
+	Bound       *big.Int             `json:"bound,omitempty"`       // Borne du pire cas sur la valeur absolue du clair
+	KeyVersion  int                  `json:"key_version,omitempty"` // Version de la clé sous laquelle la prime est chiffrée
+	SlotBits    int                  `json:"slot_bits,omitempty"`   // Trajet packé : largeur des slots du clair
+	Slot        int                  `json:"slot,omitempty"`        // Trajet packé : position du slot contenant la prime
+	SlotBound   *big.Int             `json:"slot_bound,omitempty"`  // Trajet packé : borne commune à tous les slots du clair
+	WeightsID   string               `json:"weightsID,omitempty"`   // Poids confidentiels (ConfidentialWeights) appliqués
+
+	KeyFingerprint string `json:"key_fingerprint,omitempty"` // Empreinte SHA-256 de la clé sous laquelle la prime est chiffrée
+}
+
+// WeightedTripData conserve la somme chiffrée des produits des métriques d'un
+// trajet par les coefficients confidentiels d'un assureur. Les produits par
+// champ, chiffrés sous la clé du conducteur, ne sont pas conservés : leur
+// déchiffrement révélerait chaque poids à qui connaît la métrique
+type WeightedTripData struct {
+	TripID    string               `json:"tripID"`
+	WeightsID string               `json:"weightsID"`
+	Weighted  *paillier.Ciphertext `json:"weighted"` // Chiffré de Σ coefficient * métrique, à l'échelle commune
+}
+
+// MonthPrime représente la prime mensuelle associée à un véhicule
+type MonthPrime struct {
+	VehicleID string `json:"vehicleID"` // Identifiant unique du véhicule
+	Month     int    `json:"month"`     // Mois (1-12)
+	Year      int    `json:"year"`      // Année (format YYYY)
+	Prime     int    `json:"month_prime"`
+
+	// Renseignés lorsque la prime est issue du déchiffrement d'un total
+	// mensuel chiffré (EncryptedMonthPrime)
+	Plaintext *big.Int                    `json:"plaintext,omitempty"` // Clair déchiffré avant décodage signé et mise à l'échelle
+	RPrime    *big.Int                    `json:"r_prime,omitempty"`   // Témoin de déchiffrement r′ fourni par le propriétaire
+	Shares    []*paillier.DecryptionShare `json:"shares,omitempty"`    // Parts de déchiffrement combinées (clé à seuil)
+}
+
+// EncryptedMonthPrime représente la somme homomorphe des primes chiffrées des
+// trajets d'un véhicule sur un mois : seul ce total est déchiffré, les primes
+// des trajets restent chiffrées
+type EncryptedMonthPrime struct {
+	VehicleID  string               `json:"vehicleID"`
+	Month      int                  `json:"month"`
+	Year       int                  `json:"year"`
+	Total      *paillier.Ciphertext `json:"total"`                 // Chiffré de la somme des primes
+	R          *big.Int             `json:"r"`                     // Calculé pour décryptage
+	ResultIDs  []string             `json:"resultIDs"`             // Résultats chiffrés agrégés
+	Scale      int64                `json:"scale,omitempty"`       // Échelle de virgule fixe du clair (1 par défaut)
+	Bound      *big.Int             `json:"bound,omitempty"`       // Borne du pire cas sur la valeur absolue de la somme
+	KeyVersion int                  `json:"key_version,omitempty"` // Version de la clé sous laquelle le total est chiffré
+	SlotBits   int                  `json:"slot_bits,omitempty"`   // Résultats packés : largeur des slots du clair
+	Slot       int                  `json:"slot,omitempty"`        // Résultats packés : position du slot contenant la somme
+
+	KeyFingerprint string `json:"key_fingerprint,omitempty"` // Empreinte SHA-256 de la clé sous laquelle le total est chiffré
+}
+
+// Prime représente la prime associée à un trajet
+type Prime struct {
+	TripID    string                      `json:"tripID"`              // Identifiant unique du trajet
+	Date      string                      `json:"date"`                // Date du calcul (format ISO 8601 : YYYY-MM-DD)
+	Prime     int                         `json:"prime"`               // Valeur de la prime
+	Plaintext *big.Int                    `json:"plaintext,omitempty"` // Clair déchiffré avant décodage signé et mise à l'échelle
+	ResultID  string                      `json:"resultID,omitempty"`  // Résultat chiffré dont la prime est issue
+	RPrime    *big.Int                    `json:"r_prime,omitempty"`   // Témoin de déchiffrement r′ fourni par le propriétaire
+	Shares    []*paillier.DecryptionShare `json:"shares,omitempty"`    // Parts de déchiffrement combinées (clé à seuil)
+	Proof     *paillier.DecryptionProof   `json:"proof,omitempty"`     // Preuve de déchiffrement à divulgation nulle
+}
+
+// ResultID retourne l'identifiant du résultat chiffré d'un trajet, qui est
+// aussi sa clé dans le world state
+func ResultID(tripID string) string {
+	return "result_" + tripID
+}
+
+// MonthID retourne l'identifiant du total mensuel chiffré d'un véhicule, qui
+// est aussi sa clé dans le world state
+func MonthID(vehicleID string, month, year int) string {
+	return fmt.Sprintf("encryptedmonthprime_%s_%d_%d", vehicleID, month, year)
+}
@@ -0,0 +1,133 @@
+package billing
+
+import (
+	"encoding/json"
+	"errors"
+
+	"simple/compact"
+	"simple/paillier"
+)
+
+// Les témoins de déchiffrement (r, r′) et les clairs déchiffrés sont écrits au
+// format compact paillier.WitnessTag. Les enregistrements antérieurs, où ils
+// sont des nombres JSON décimaux, restent lisibles.
+
+func (r EncryptedCalculationResult) MarshalJSON() ([]byte, error) {
+	type plain EncryptedCalculationResult
+	fields, err := compact.MarshalFields(paillier.WitnessTag, r.R)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		plain
+		R json.RawMessage `json:"r"`
+	}{plain(r), fields[0]})
+}
+
+func (r *EncryptedCalculationResult) UnmarshalJSON(data []byte) error {
+	type plain EncryptedCalculationResult
+	encoded := struct {
+		*plain
+		R json.RawMessage `json:"r"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	values, err := compact.UnmarshalFields(paillier.WitnessTag, encoded.R)
+	if err != nil {
+		return errors.New("Failed to unmarshal EncryptedCalculationResult: " + err.Error())
+	}
+	r.R = values[0]
+	return nil
+}
+
+func (p EncryptedMonthPrime) MarshalJSON() ([]byte, error) {
+	type plain EncryptedMonthPrime
+	fields, err := compact.MarshalFields(paillier.WitnessTag, p.R)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		plain
+		R json.RawMessage `json:"r"`
+	}{plain(p), fields[0]})
+}
+
+func (p *EncryptedMonthPrime) UnmarshalJSON(data []byte) error {
+	type plain EncryptedMonthPrime
+	encoded := struct {
+		*plain
+		R json.RawMessage `json:"r"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	values, err := compact.UnmarshalFields(paillier.WitnessTag, encoded.R)
+	if err != nil {
+		return errors.New("Failed to unmarshal EncryptedMonthPrime: " + err.Error())
+	}
+	p.R = values[0]
+	return nil
+}
+
+func (p MonthPrime) MarshalJSON() ([]byte, error) {
+	type plain MonthPrime
+	fields, err := compact.MarshalFields(paillier.WitnessTag, p.Plaintext, p.RPrime)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		plain
+		Plaintext json.RawMessage `json:"plaintext,omitempty"`
+		RPrime    json.RawMessage `json:"r_prime,omitempty"`
+	}{plain(p), fields[0], fields[1]})
+}
+
+func (p *MonthPrime) UnmarshalJSON(data []byte) error {
+	type plain MonthPrime
+	encoded := struct {
+		*plain
+		Plaintext json.RawMessage `json:"plaintext,omitempty"`
+		RPrime    json.RawMessage `json:"r_prime,omitempty"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	values, err := compact.UnmarshalFields(paillier.WitnessTag, encoded.Plaintext, encoded.RPrime)
+	if err != nil {
+		return errors.New("Failed to unmarshal MonthPrime: " + err.Error())
+	}
+	p.Plaintext, p.RPrime = values[0], values[1]
+	return nil
+}
+
+func (p Prime) MarshalJSON() ([]byte, error) {
+	type plain Prime
+	fields, err := compact.MarshalFields(paillier.WitnessTag, p.Plaintext, p.RPrime)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		plain
+		Plaintext json.RawMessage `json:"plaintext,omitempty"`
+		RPrime    json.RawMessage `json:"r_prime,omitempty"`
+	}{plain(p), fields[0], fields[1]})
+}
+
+func (p *Prime) UnmarshalJSON(data []byte) error {
+	type plain Prime
+	encoded := struct {
+		*plain
+		Plaintext json.RawMessage `json:"plaintext,omitempty"`
+		RPrime    json.RawMessage `json:"r_prime,omitempty"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	values, err := compact.UnmarshalFields(paillier.WitnessTag, encoded.Plaintext, encoded.RPrime)
+	if err != nil {
+		return errors.New("Failed to unmarshal Prime: " + err.Error())
+	}
+	p.Plaintext, p.RPrime = values[0], values[1]
+	return nil
+}
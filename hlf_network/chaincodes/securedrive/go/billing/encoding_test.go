@@ -0,0 +1,89 @@
+package billing
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"simple/paillier"
+)
+
+func TestWitnessJSON(t *testing.T) {
+	r, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	rPrime, _ := new(big.Int).SetString("98765432109876543210", 10)
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		decoded interface{}
+		fields  []string
+		legacy  string
+	}{
+		{
+			"EncryptedCalculationResult",
+			&EncryptedCalculationResult{ResultID: "result_trip1", R: r, TripID: "trip1"},
+			&EncryptedCalculationResult{},
+			[]string{"r"},
+			`{"resultID":"result_trip1","prime_totale":null,"r":` + r.String() + `,"tripID":"trip1"}`,
+		},
+		{
+			"EncryptedMonthPrime",
+			&EncryptedMonthPrime{VehicleID: "vehicle1", Month: 3, Year: 2024, R: r, ResultIDs: []string{"result_trip1"}},
+			&EncryptedMonthPrime{},
+			[]string{"r"},
+			`{"vehicleID":"vehicle1","month":3,"year":2024,"total":null,"r":` + r.String() + `,"resultIDs":["result_trip1"]}`,
+		},
+		{
+			"MonthPrime",
+			&MonthPrime{VehicleID: "vehicle1", Month: 3, Year: 2024, Prime: 42, Plaintext: big.NewInt(0), RPrime: rPrime},
+			&MonthPrime{},
+			[]string{"plaintext", "r_prime"},
+			`{"vehicleID":"vehicle1","month":3,"year":2024,"month_prime":42,"plaintext":0,"r_prime":` + rPrime.String() + `}`,
+		},
+		{
+			"Prime",
+			&Prime{TripID: "trip1", Date: "2024-03-01", Prime: 42, Plaintext: big.NewInt(42), RPrime: rPrime},
+			&Prime{},
+			[]string{"plaintext", "r_prime"},
+			`{"tripID":"trip1","date":"2024-03-01","prime":42,"plaintext":42,"r_prime":` + rPrime.String() + `}`,
+		},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(test.value)
+		if err != nil {
+			t.Fatalf("%s: Marshal: %v", test.name, err)
+		}
+		for _, field := range test.fields {
+			if !strings.Contains(string(data), `"`+field+`":"`+paillier.WitnessTag+`:`) {
+				t.Fatalf("%s: field %s is not in the compact format: %s", test.name, field, data)
+			}
+		}
+
+		// Format compact et nombres décimaux des enregistrements antérieurs
+		for _, input := range []string{string(data), test.legacy} {
+			if err := json.Unmarshal([]byte(input), test.decoded); err != nil {
+				t.Fatalf("%s: Unmarshal: %v", test.name, err)
+			}
+			again, err := json.Marshal(test.decoded)
+			if err != nil || string(again) != string(data) {
+				t.Fatalf("%s: round trip of %s returned %s, %v", test.name, input, again, err)
+			}
+		}
+	}
+}
+
+func TestWitnessJSONOmitsEmpty(t *testing.T) {
+	data, err := json.Marshal(Prime{TripID: "trip1", Prime: 42})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(data), "plaintext") || strings.Contains(string(data), "r_prime") {
+		t.Fatalf("empty witnesses are not omitted: %s", data)
+	}
+
+	var prime Prime
+	if err := json.Unmarshal([]byte(`{"tripID":"trip1","r_prime":"pc1:AQ"}`), &prime); err == nil {
+		t.Error("Unmarshal accepted a witness with another tag")
+	}
+}
@@ -0,0 +1,271 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"simple/paillier"
+	"simple/policy"
+	"simple/telematics"
+)
+
+// commonScale retourne le plus petit multiple commun des échelles, vers lequel
+// tous les champs sont alignés avant d'être additionnés
+func commonScale(scales map[string]int64) *big.Int {
+	result := big.NewInt(1)
+	for _, scale := range scales {
+		s := big.NewInt(scale)
+		gcd := new(big.Int).GCD(nil, nil, result, s)
+		result.Mul(result.Div(result, gcd), s)
+	}
+	return result
+}
+
+// premiumCoefficients retourne le coefficient de chaque champ dans le clair de
+// la prime totale calculée par CalculatePremium :
+// Σ véhicule + Alpha * kilométrage + Beta * (Σ poids * critère - poids * respect des feux),
+// chaque champ étant aligné sur l'échelle commune
+func premiumCoefficients(weights *policy.CriteriaWeights, scales map[string]int64, scale *big.Int) map[string]*big.Int {
+	beta := big.NewInt(int64(weights.Beta))
+	behaviour := func(weight int) *big.Int {
+		return new(big.Int).Mul(beta, big.NewInt(int64(weight)))
+	}
+
+	factors := map[string]*big.Int{
+		"vehicle_type":              big.NewInt(1),
+		"purchase_mileage":          big.NewInt(1),
+		"year":                      big.NewInt(1),
+		"mileage":                   big.NewInt(int64(weights.Alpha)),
+		"speeding":                  behaviour(weights.WeightSpeed),
+		"hard_accelerations":        behaviour(weights.WeightAcceleration),
+		"emergency_brakes":          behaviour(weights.WeightBraking),
+		"unsafe_distance":           behaviour(weights.WeightDistance),
+		"high_risk_zones":           behaviour(weights.WeightZone),
+		"night_driving":             behaviour(weights.WeightTime),
+		"traffic_signal_compliance": behaviour(-weights.WeightTraffic),
+	}
+
+	coefficients := make(map[string]*big.Int, len(factors))
+	for field, factor := range factors {
+		coefficient := new(big.Int).Div(scale, big.NewInt(scales[field]))
+		coefficients[field] = coefficient.Mul(coefficient, factor)
+	}
+	return coefficients
+}
+
+// premiumBound calcule Σ |coefficient| * max(|Min|, |Max|) sur les champs de la
+// prime : aucune valeur conforme aux FieldSpec ne peut produire un clair dont
+// la valeur absolue dépasse cette borne
+func (s *Service) premiumBound(coefficients map[string]*big.Int) (*big.Int, error) {
+	bound := new(big.Int)
+	for field, coefficient := range coefficients {
+		maxAbs, err := s.Telematics.MaxAbs(field)
+		if err != nil {
+			return nil, err
+		}
+
+		term := new(big.Int).Abs(coefficient)
+		bound.Add(bound, term.Mul(term, maxAbs))
+	}
+	return bound, nil
+}
+
+// packedPremium calcule la prime d'un trajet packé. Les coefficients des
+// métriques forment le scalaire W = Σ coefficient_j * 2^(j*SlotBits) : une
+// seule exponentiation du chiffré packé place la somme pondérée dans le slot
+// k-1, auquel s'ajoute la part du véhicule décalée d'autant. Retourne le
+// chiffré, la position du slot contenant la prime et la borne commune à tous
+// les slots du clair
+func (s *Service) packedPremium(publicKey *paillier.PublicKey, trip *telematics.EncryptedTripData, cVehicle *paillier.Ciphertext, coefficients map[string]*big.Int) (*paillier.Ciphertext, int, *big.Int, error) {
+	slotBits := uint(trip.SlotBits)
+	slot := len(telematics.TripFields) - 1
+
+	// Chaque diagonale Σ_{i-j=d} coefficient_i * m_j du produit, augmentée de
+	// la part du véhicule, doit tenir dans un slot équilibré
+	maxCoefficient := new(big.Int)
+	valuesBound := new(big.Int)
+	weights := make([]*big.Int, len(telematics.TripFields))
+	for j, field := range telematics.TripFields {
+		maxAbs, err := s.Telematics.MaxAbs(field)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		valuesBound.Add(valuesBound, maxAbs)
+
+		weights[j] = coefficients[field]
+		if coefficient := new(big.Int).Abs(weights[j]); coefficient.Cmp(maxCoefficient) > 0 {
+			maxCoefficient = coefficient
+		}
+	}
+
+	vehicleCoefficients := make(map[string]*big.Int, len(telematics.VehicleFields))
+	for _, field := range telematics.VehicleFields {
+		vehicleCoefficients[field] = coefficients[field]
+	}
+	diagonal, err := s.premiumBound(vehicleCoefficients)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	diagonal.Add(diagonal, valuesBound.Mul(valuesBound, maxCoefficient))
+
+	if diagonal.BitLen() > int(slotBits)-2 {
+		return nil, 0, nil, fmt.Errorf("Packing slots of %d bits are too narrow for the declared field ranges and weights", slotBits)
+	}
+	// Le produit compte 2k-1 diagonales : |T| < diagonal * 2^((2k-2)*SlotBits + 1)
+	if !publicKey.FitsSigned(new(big.Int).Lsh(diagonal, uint(2*slot)*slotBits+1)) {
+		return nil, 0, nil, errors.New("Packed premium calculation could wrap modulo N")
+	}
+
+	cWeighted, err := publicKey.MulConst(trip.Packed, paillier.PackedWeights(weights, slotBits))
+	if err != nil {
+		return nil, 0, nil, errors.New("Failed to multiply packed trip data")
+	}
+	cShifted, err := publicKey.MulConst(cVehicle, new(big.Int).Lsh(big.NewInt(1), uint(slot)*slotBits))
+	if err != nil {
+		return nil, 0, nil, errors.New("Failed to shift vehicle data")
+	}
+
+	return publicKey.Add(cWeighted, cShifted), slot, diagonal, nil
+}
+
+// decodePremium interprète le clair d'une prime, d'un trajet ou d'un mois,
+// comme un entier signé, en extrait le slot de la prime si le clair est packé
+// et le ramène à l'unité selon l'échelle de virgule fixe
+func decodePremium(publicKey *paillier.PublicKey, plaintext *big.Int, scale int64, bound *big.Int, slotBits, slot int) (int, error) {
+	if scale == 0 {
+		scale = 1
+	}
+
+	signed := publicKey.DecodeSigned(plaintext)
+	if slotBits > 0 {
+		// Clair packé : la prime occupe un slot du clair
+		signed = paillier.ExtractSlot(signed, slot, uint(slotBits))
+	}
+
+	// Un clair hors de la borne calculée révèle un dépassement modulo N
+	if bound != nil && new(big.Int).Abs(signed).Cmp(bound) > 0 {
+		return 0, errors.New("Decrypted prime exceeds the worst-case bound of the calculation")
+	}
+
+	value, err := paillier.RoundDiv(signed, scale)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to decode prime: %s", err.Error())
+	}
+	if !fitsInt(value) {
+		return 0, errors.New("Decrypted prime does not fit in an integer")
+	}
+	return int(value.Int64()), nil
+}
+
+// sumPremiums calcule le chiffré de la somme des primes des résultats, alignées
+// sur leur échelle commune. Si des résultats sont packés, les primes non
+// packées sont décalées dans le slot de la prime : la somme occupe alors ce
+// slot, dont l'extraction reste exacte tant que la somme des bornes des slots
+// tient dans un slot équilibré
+func sumPremiums(publicKey *paillier.PublicKey, results []EncryptedCalculationResult) (*EncryptedMonthPrime, error) {
+	scales := make(map[string]int64, len(results))
+	slotBits, slot := 0, 0
+	for _, result := range results {
+		err := publicKey.ValidateCiphertext(result.PrimeTotale)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ciphertext for EncryptedCalculationResult %s: %s", result.ResultID, err)
+		}
+		// Les résultats calculés avant l'encodage signé ne portent pas de borne
+		if result.Bound == nil {
+			return nil, fmt.Errorf("EncryptedCalculationResult %s carries no worst-case bound: recalculate the premium", result.ResultID)
+		}
+
+		scales[result.ResultID] = result.Scale
+		if result.Scale == 0 {
+			scales[result.ResultID] = 1
+		}
+
+		if result.SlotBits > 0 {
+			if result.SlotBound == nil {
+				return nil, fmt.Errorf("Packed EncryptedCalculationResult %s carries no slot bound: recalculate the premium", result.ResultID)
+			}
+			if slotBits != 0 && (result.SlotBits != slotBits || result.Slot != slot) {
+				return nil, errors.New("Packed EncryptedCalculationResults of the month use different slot layouts")
+			}
+			slotBits, slot = result.SlotBits, result.Slot
+		}
+	}
+
+	scale := commonScale(scales)
+	if !scale.IsInt64() {
+		return nil, errors.New("Common fixed-point scale of the calculation results is too large")
+	}
+
+	bound := new(big.Int)
+	slotTotal := new(big.Int)
+	ciphertexts := make([]*paillier.Ciphertext, 0, len(results))
+	resultIDs := make([]string, 0, len(results))
+	for _, result := range results {
+		factor := new(big.Int).Div(scale, big.NewInt(scales[result.ResultID]))
+		bound.Add(bound, new(big.Int).Mul(result.Bound, factor))
+
+		multiplier := factor
+		if result.SlotBits > 0 {
+			slotTotal.Add(slotTotal, new(big.Int).Mul(result.SlotBound, factor))
+		} else {
+			// Une prime non packée n'occupe que le slot de la prime une fois décalée
+			slotTotal.Add(slotTotal, new(big.Int).Mul(result.Bound, factor))
+			multiplier = new(big.Int).Lsh(factor, uint(slot*slotBits))
+		}
+
+		c, err := publicKey.MulConst(result.PrimeTotale, multiplier)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scale EncryptedCalculationResult %s", result.ResultID)
+		}
+		ciphertexts = append(ciphertexts, c)
+		resultIDs = append(resultIDs, result.ResultID)
+	}
+
+	if slotBits > 0 {
+		if slotTotal.BitLen() > slotBits-1 {
+			return nil, errors.New("Monthly premium could overflow the packing slots: the month has too many packed trips for their slot width")
+		}
+		// Le clair compte 2k-1 slots : |T| < slotTotal * 2^((2k-2)*SlotBits + 1)
+		if !publicKey.FitsSigned(new(big.Int).Lsh(slotTotal, uint(2*slot*slotBits+1))) {
+			return nil, errors.New("Monthly premium could wrap modulo N")
+		}
+	} else if !publicKey.FitsSigned(bound) {
+		return nil, errors.New("Monthly premium could wrap modulo N for the worst-case bounds of the trips")
+	}
+	// Les bornes des résultats calculés avec des poids confidentiels ne
+	// garantissent pas d'avance que la somme tient dans un int : le décodage
+	// le vérifie après déchiffrement
+
+	return &EncryptedMonthPrime{
+		Total:     publicKey.Sum(ciphertexts...),
+		ResultIDs: resultIDs,
+		Scale:     scale.Int64(),
+		Bound:     bound,
+		SlotBits:  slotBits,
+		Slot:      slot,
+	}, nil
+}
+
+// fitsInt indique si value est représentable par le type int d'une Prime
+func fitsInt(value *big.Int) bool {
+	return value.IsInt64() && int64(int(value.Int64())) == value.Int64()
+}
+
+// CheckMonth vérifie le mois (1-12) d'une prime mensuelle
+func CheckMonth(month int) error {
+	if month < 1 || month > 12 {
+		return errors.New("Invalid month value. Expecting a number between 1 and 12")
+	}
+	return nil
+}
+
+// extractYearAndMonth extrait l'année et le mois à partir d'une date au format YYYY-MM-DD
+func extractYearAndMonth(date string) (int, int, error) {
+	var year, month int
+	_, err := fmt.Sscanf(date, "%d-%d", &year, &month)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse date: %s", date)
+	}
+	return year, month, nil
+}
@@ -0,0 +1,241 @@
+package billing
+
+import (
+	"fmt"
+
+	"simple/ledger"
+	"simple/paillier"
+)
+
+// Les lectures des dépôts de ce paquet retournent nil, sans erreur, pour un
+// actif absent.
+
+// ResultRepository conserve les primes chiffrées des trajets
+type ResultRepository interface {
+	Get(resultID string) (*EncryptedCalculationResult, error)
+	Put(result *EncryptedCalculationResult) error
+}
+
+// PrimeRepository conserve les primes déchiffrées des trajets
+type PrimeRepository interface {
+	Get(tripID string) (*Prime, error)
+	Put(prime *Prime) error
+}
+
+// MonthPrimeRepository conserve les primes mensuelles des véhicules
+type MonthPrimeRepository interface {
+	Get(vehicleID string, month, year int) (*MonthPrime, error)
+	Put(monthPrime *MonthPrime) error
+
+	// GetCumulative et PutCumulative accèdent au cumul des primes des trajets
+	// déchiffrées une à une. Il est historiquement enregistré sous
+	// monthprime_<VehicleID>_<Year>_<Month>, l'année et le mois étant inversés
+	// par rapport aux primes mensuelles : la clé est conservée pour ne pas
+	// fusionner les cumuls existants avec les totaux déchiffrés
+	GetCumulative(vehicleID string, month, year int) (*MonthPrime, error)
+	PutCumulative(monthPrime *MonthPrime) error
+}
+
+// EncryptedMonthPrimeRepository conserve les totaux mensuels chiffrés
+type EncryptedMonthPrimeRepository interface {
+	Get(vehicleID string, month, year int) (*EncryptedMonthPrime, error)
+	Put(monthPrime *EncryptedMonthPrime) error
+}
+
+// ShareRepository conserve les parts de déchiffrement soumises pour la prime
+// d'un trajet ou pour le total mensuel chiffré d'un véhicule
+type ShareRepository interface {
+	Get(tripID string, index int) (*paillier.DecryptionShare, error)
+	Put(tripID string, share *paillier.DecryptionShare) error
+	GetMonth(vehicleID string, month, year, index int) (*paillier.DecryptionShare, error)
+	PutMonth(vehicleID string, month, year int, share *paillier.DecryptionShare) error
+}
+
+// WeightedTripRepository conserve les produits pondérés des trajets tarifés
+// avec des poids confidentiels
+type WeightedTripRepository interface {
+	Get(tripID string) (*WeightedTripData, error)
+	Put(weighted *WeightedTripData) error
+}
+
+// get charge l'actif JSON enregistré sous key dans v et indique s'il existe
+func get(state ledger.State, key, name string, v interface{}) (bool, error) {
+	found, err := ledger.GetJSON(state, key, v)
+	if err != nil {
+		return false, fmt.Errorf("Failed to get %s: %s", name, err)
+	}
+	return found, nil
+}
+
+// put enregistre v sous key
+func put(state ledger.State, key, name string, v interface{}) error {
+	err := ledger.PutJSON(state, key, v)
+	if err != nil {
+		return fmt.Errorf("Failed to store %s: %s", name, err)
+	}
+	return nil
+}
+
+// NewResultRepository retourne le dépôt des primes chiffrées dans le world
+// state, sous leur ResultID
+func NewResultRepository(state ledger.State) ResultRepository {
+	return &resultStore{state: state}
+}
+
+type resultStore struct {
+	state ledger.State
+}
+
+func (r *resultStore) Get(resultID string) (*EncryptedCalculationResult, error) {
+	var result EncryptedCalculationResult
+	found, err := get(r.state, resultID, "EncryptedCalculationResult", &result)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *resultStore) Put(result *EncryptedCalculationResult) error {
+	return put(r.state, result.ResultID, "EncryptedCalculationResult", result)
+}
+
+// NewPrimeRepository retourne le dépôt des primes des trajets dans le world
+// state, sous prime_<TripID>
+func NewPrimeRepository(state ledger.State) PrimeRepository {
+	return &primeStore{state: state}
+}
+
+type primeStore struct {
+	state ledger.State
+}
+
+func (r *primeStore) Get(tripID string) (*Prime, error) {
+	var prime Prime
+	found, err := get(r.state, "prime_"+tripID, "Prime", &prime)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &prime, nil
+}
+
+func (r *primeStore) Put(prime *Prime) error {
+	return put(r.state, "prime_"+prime.TripID, "Prime", prime)
+}
+
+// NewMonthPrimeRepository retourne le dépôt des primes mensuelles dans le
+// world state, sous monthprime_<VehicleID>_<Month>_<Year>
+func NewMonthPrimeRepository(state ledger.State) MonthPrimeRepository {
+	return &monthPrimeStore{state: state}
+}
+
+type monthPrimeStore struct {
+	state ledger.State
+}
+
+func (r *monthPrimeStore) load(key string) (*MonthPrime, error) {
+	var monthPrime MonthPrime
+	found, err := get(r.state, key, "MonthPrime", &monthPrime)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &monthPrime, nil
+}
+
+func (r *monthPrimeStore) Get(vehicleID string, month, year int) (*MonthPrime, error) {
+	return r.load(fmt.Sprintf("monthprime_%s_%d_%d", vehicleID, month, year))
+}
+
+func (r *monthPrimeStore) Put(monthPrime *MonthPrime) error {
+	return put(r.state, fmt.Sprintf("monthprime_%s_%d_%d", monthPrime.VehicleID, monthPrime.Month, monthPrime.Year), "MonthPrime", monthPrime)
+}
+
+func (r *monthPrimeStore) GetCumulative(vehicleID string, month, year int) (*MonthPrime, error) {
+	return r.load(fmt.Sprintf("monthprime_%s_%d_%d", vehicleID, year, month))
+}
+
+func (r *monthPrimeStore) PutCumulative(monthPrime *MonthPrime) error {
+	return put(r.state, fmt.Sprintf("monthprime_%s_%d_%d", monthPrime.VehicleID, monthPrime.Year, monthPrime.Month), "MonthPrime", monthPrime)
+}
+
+// NewEncryptedMonthPrimeRepository retourne le dépôt des totaux mensuels
+// chiffrés dans le world state, sous encryptedmonthprime_<VehicleID>_<Month>_<Year>
+func NewEncryptedMonthPrimeRepository(state ledger.State) EncryptedMonthPrimeRepository {
+	return &encryptedMonthPrimeStore{state: state}
+}
+
+type encryptedMonthPrimeStore struct {
+	state ledger.State
+}
+
+func (r *encryptedMonthPrimeStore) Get(vehicleID string, month, year int) (*EncryptedMonthPrime, error) {
+	var monthPrime EncryptedMonthPrime
+	found, err := get(r.state, MonthID(vehicleID, month, year), "EncryptedMonthPrime", &monthPrime)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &monthPrime, nil
+}
+
+func (r *encryptedMonthPrimeStore) Put(monthPrime *EncryptedMonthPrime) error {
+	return put(r.state, MonthID(monthPrime.VehicleID, monthPrime.Month, monthPrime.Year), "EncryptedMonthPrime", monthPrime)
+}
+
+// NewShareRepository retourne le dépôt des parts de déchiffrement dans le
+// world state, sous decryptionshare_<TripID>_<Index> pour un trajet et sous
+// monthdecryptionshare_<VehicleID>_<Month>_<Year>_<Index> pour un mois
+func NewShareRepository(state ledger.State) ShareRepository {
+	return &shareStore{state: state}
+}
+
+type shareStore struct {
+	state ledger.State
+}
+
+func (r *shareStore) Get(tripID string, index int) (*paillier.DecryptionShare, error) {
+	var share paillier.DecryptionShare
+	found, err := get(r.state, fmt.Sprintf("decryptionshare_%s_%d", tripID, index), "DecryptionShare", &share)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *shareStore) Put(tripID string, share *paillier.DecryptionShare) error {
+	return put(r.state, fmt.Sprintf("decryptionshare_%s_%d", tripID, share.Index), "DecryptionShare", share)
+}
+
+func (r *shareStore) GetMonth(vehicleID string, month, year, index int) (*paillier.DecryptionShare, error) {
+	var share paillier.DecryptionShare
+	found, err := get(r.state, fmt.Sprintf("monthdecryptionshare_%s_%d_%d_%d", vehicleID, month, year, index), "DecryptionShare", &share)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *shareStore) PutMonth(vehicleID string, month, year int, share *paillier.DecryptionShare) error {
+	return put(r.state, fmt.Sprintf("monthdecryptionshare_%s_%d_%d_%d", vehicleID, month, year, share.Index), "DecryptionShare", share)
+}
+
+// NewWeightedTripRepository retourne le dépôt des produits pondérés dans le
+// world state, sous weightedtrip_<TripID>
+func NewWeightedTripRepository(state ledger.State) WeightedTripRepository {
+	return &weightedTripStore{state: state}
+}
+
+type weightedTripStore struct {
+	state ledger.State
+}
+
+func (r *weightedTripStore) Get(tripID string) (*WeightedTripData, error) {
+	var weighted WeightedTripData
+	found, err := get(r.state, "weightedtrip_"+tripID, "WeightedTripData", &weighted)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &weighted, nil
+}
+
+func (r *weightedTripStore) Put(weighted *WeightedTripData) error {
+	return put(r.state, "weightedtrip_"+weighted.TripID, "WeightedTripData", weighted)
+}
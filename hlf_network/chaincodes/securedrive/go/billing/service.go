@@ -0,0 +1,1161 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"simple/crypto"
+	"simple/ledger"
+	"simple/paillier"
+	"simple/pedersen"
+	"simple/policy"
+	"simple/telematics"
+)
+
+// Service regroupe le calcul, le déchiffrement et l'agrégation des primes. Les
+// dépôts sont exposés afin que les tests puissent les remplacer.
+type Service struct {
+	Results              ResultRepository
+	Primes               PrimeRepository
+	MonthPrimes          MonthPrimeRepository
+	EncryptedMonthPrimes EncryptedMonthPrimeRepository
+	Shares               ShareRepository
+	WeightedTrips        WeightedTripRepository
+
+	Telematics *telematics.Service
+	Policy     *policy.Service
+}
+
+// NewService construit le service sur les dépôts du world state
+func NewService(state ledger.State, telematicsService *telematics.Service, policyService *policy.Service) *Service {
+	return &Service{
+		Results:              NewResultRepository(state),
+		Primes:               NewPrimeRepository(state),
+		MonthPrimes:          NewMonthPrimeRepository(state),
+		EncryptedMonthPrimes: NewEncryptedMonthPrimeRepository(state),
+		Shares:               NewShareRepository(state),
+		WeightedTrips:        NewWeightedTripRepository(state),
+		Telematics:           telematicsService,
+		Policy:               policyService,
+	}
+}
+
+// pricedTrip regroupe les actifs chiffrés d'un trajet à tarifer et la clé
+// active du propriétaire du véhicule
+type pricedTrip struct {
+	vehicle   *telematics.EncryptedVehicleData
+	trip      *telematics.EncryptedTripData
+	verifier  *crypto.Verifier
+	publicKey *paillier.PublicKey
+
+	// Empreinte de la clé du Verifier, reprise par le résultat
+	fingerprint string
+}
+
+// loadPricedTrip charge un trajet et son véhicule, et vérifie qu'ils sont
+// chiffrés sous la clé active du propriétaire
+func (s *Service) loadPricedTrip(vehicleID, tripID string) (*pricedTrip, error) {
+	vehicle, err := s.Telematics.Vehicle(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := s.Telematics.Keys.Verifier(vehicle.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	fingerprint, err := verifier.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	trip, err := s.Telematics.Trip(tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if trip.VehicleID != vehicleID {
+		return nil, errors.New("Trip data does not belong to the given VehicleID")
+	}
+
+	return &pricedTrip{
+		vehicle:   vehicle,
+		trip:      trip,
+		verifier:  verifier,
+		publicKey: publicKey,
+
+		fingerprint: fingerprint,
+	}, nil
+}
+
+// checkKeys vérifie que le véhicule et le trajet sont chiffrés sous la clé
+// active : un calcul ne mélange pas de chiffrés sous des versions de clé
+// différentes. Les chiffrés enregistrés par d'anciennes versions du chaincode
+// n'ayant pas été validés, ils le sont ici
+func (s *Service) checkKeys(priced *pricedTrip, tripFields []string) error {
+	version := priced.verifier.Version()
+	if crypto.KeyVersion(priced.vehicle.KeyVersion) != version || crypto.KeyVersion(priced.trip.KeyVersion) != version {
+		return fmt.Errorf("Vehicle and trip data must be encrypted under the active key version %d: re-encrypt records encrypted under a retired key first", version)
+	}
+	err := crypto.CheckKeyFingerprint(priced.verifier, priced.vehicle.KeyFingerprint, priced.trip.KeyFingerprint)
+	if err != nil {
+		return err
+	}
+
+	err = s.Telematics.VerifyEncryptedFields(priced.publicKey, telematics.VehicleKey(priced.vehicle.VehicleID), telematics.VehicleFields, priced.vehicle.Ciphertexts(), nil)
+	if err != nil {
+		return err
+	}
+	return s.Telematics.VerifyEncryptedFields(priced.publicKey, telematics.TripKey(priced.trip.TripID), tripFields, priced.trip.Ciphertexts(), nil)
+}
+
+// checkBounded vérifie que chaque clair du véhicule et du trajet respecte les
+// bornes de son FieldSpec : la borne de la prime, calculée sur ces bornes, ne
+// garantit rien pour des chiffrés enregistrés sans preuve d'intervalle
+func checkBounded(priced *pricedTrip) error {
+	if !priced.vehicle.Bounded || !priced.trip.Bounded {
+		return errors.New("Vehicle and trip data must carry a range proof for every field to be priced")
+	}
+	return nil
+}
+
+// fieldScales retourne l'échelle de chaque champ du véhicule et du trajet et
+// leur échelle commune. Les échelles conservées par les enregistrements, avec
+// lesquelles leurs clairs ont été encodés, doivent être celles des FieldSpec
+func (s *Service) fieldScales(priced *pricedTrip) (map[string]int64, *big.Int, error) {
+	scales, err := s.Telematics.Scales(append(append([]string{}, telematics.VehicleFields...), telematics.TripFields...))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, recorded := range []map[string]int64{priced.vehicle.Scales, priced.trip.Scales} {
+		for field, recordedScale := range recorded {
+			if scale, ok := scales[field]; ok && scale != recordedScale {
+				return nil, nil, fmt.Errorf("Field '%s' was encoded with scale %d, but its FieldSpec declares scale %d", field, recordedScale, scale)
+			}
+		}
+	}
+	scale := commonScale(scales)
+	if !scale.IsInt64() {
+		return nil, nil, errors.New("Common fixed-point scale of the encrypted fields is too large")
+	}
+	return scales, scale, nil
+}
+
+// CalculatePremium calcule homomorphiquement la prime chiffrée d'un trajet avec
+// des poids publics et l'enregistre. Une graine seed non nulle re-randomise la
+// prime chiffrée : sans elle, le chiffré est une fonction publique des chiffrés
+// du trajet et des poids
+func (s *Service) CalculatePremium(vehicleID, tripID, criteriaWeightsID string, seed []byte) (*EncryptedCalculationResult, error) {
+	weights, err := s.Policy.CriteriaWeights.Get(criteriaWeightsID)
+	if err != nil {
+		return nil, err
+	}
+	if weights == nil {
+		return nil, errors.New("Criteria weights not found for the given CriteriaWeightsID")
+	}
+
+	priced, err := s.loadPricedTrip(vehicleID, tripID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkKeys(priced, priced.trip.Fields())
+	if err != nil {
+		return nil, err
+	}
+	publicKey := priced.publicKey
+	vehicle, trip := priced.vehicle, priced.trip
+
+	// Les champs sont encodés en virgule fixe avec des échelles éventuellement
+	// différentes : chaque terme est aligné sur l'échelle commune avant la somme
+	scales, scale, err := s.fieldScales(priced)
+	if err != nil {
+		return nil, err
+	}
+
+	// Borne du pire cas sur le clair de la prime totale : au-delà de N/2, le
+	// résultat serait réduit modulo N et déchiffré en une valeur erronée
+	coefficients := premiumCoefficients(weights, scales, scale)
+	bound, err := s.premiumBound(coefficients)
+	if err != nil {
+		return nil, err
+	}
+	if !publicKey.FitsSigned(bound) {
+		return nil, errors.New("Premium calculation could wrap modulo N for the declared field ranges and weights")
+	}
+	err = checkBounded(priced)
+	if err != nil {
+		return nil, err
+	}
+	maxPrime, err := paillier.RoundDiv(bound, scale.Int64())
+	if err != nil || !fitsInt(maxPrime) {
+		return nil, errors.New("Premium calculation could exceed the range of the prime value for the declared field ranges and weights")
+	}
+
+	// weighted calcule le chiffré de weight * m * (scale / échelle du champ)
+	weighted := func(field string, c *paillier.Ciphertext, weight int) (*paillier.Ciphertext, error) {
+		factor := new(big.Int).Div(scale, big.NewInt(scales[field]))
+		return publicKey.MulConst(c, factor.Mul(factor, big.NewInt(int64(weight))))
+	}
+
+	cVehicleType, err := weighted("vehicle_type", vehicle.VehicleType, 1)
+	if err != nil {
+		return nil, errors.New("Failed to scale vehicle type")
+	}
+	cPurchaseMileage, err := weighted("purchase_mileage", vehicle.PurchaseMileage, 1)
+	if err != nil {
+		return nil, errors.New("Failed to scale purchase mileage")
+	}
+	cYear, err := weighted("year", vehicle.Year, 1)
+	if err != nil {
+		return nil, errors.New("Failed to scale year")
+	}
+
+	cVehicle := publicKey.Sum(cVehicleType, cPurchaseMileage, cYear)
+
+	var cPrimeTotale *paillier.Ciphertext
+	var slotBits, slot int
+	var slotBound *big.Int
+	if trip.Packed != nil {
+		// Trajet packé : une seule multiplication homomorphe pour toutes les métriques
+		cPrimeTotale, slot, slotBound, err = s.packedPremium(publicKey, trip, cVehicle, coefficients)
+		if err != nil {
+			return nil, err
+		}
+		slotBits = trip.SlotBits
+	} else {
+		// Calcul homomorphique des primes PAYD et PHYD
+		cTrafficSignalCompliance, err := weighted("traffic_signal_compliance", trip.TrafficSignalCompliance, weights.WeightTraffic)
+		if err != nil {
+			return nil, errors.New("Failed to multiply traffic signal compliance")
+		}
+		cSpeeding, err := weighted("speeding", trip.Speeding, weights.WeightSpeed)
+		if err != nil {
+			return nil, errors.New("Failed to multiply speeding")
+		}
+		cHardAccelerations, err := weighted("hard_accelerations", trip.HardAccelerations, weights.WeightAcceleration)
+		if err != nil {
+			return nil, errors.New("Failed to multiply hard accelerations")
+		}
+		cEmergencyBrakes, err := weighted("emergency_brakes", trip.EmergencyBrakes, weights.WeightBraking)
+		if err != nil {
+			return nil, errors.New("Failed to multiply emergency brakes")
+		}
+		cUnsafeDistance, err := weighted("unsafe_distance", trip.UnsafeDistance, weights.WeightDistance)
+		if err != nil {
+			return nil, errors.New("Failed to multiply unsafe distance")
+		}
+		cHighRiskZones, err := weighted("high_risk_zones", trip.HighRiskZones, weights.WeightZone)
+		if err != nil {
+			return nil, errors.New("Failed to multiply high-risk zones")
+		}
+		cNightDriving, err := weighted("night_driving", trip.NightDriving, weights.WeightTime)
+		if err != nil {
+			return nil, errors.New("Failed to multiply night driving")
+		}
+
+		cIndiceComportement := publicKey.Sum(
+			cSpeeding,
+			cHardAccelerations,
+			cEmergencyBrakes,
+			cUnsafeDistance,
+			cHighRiskZones,
+			cNightDriving,
+		)
+
+		cIndiceComportement, err = publicKey.Sub(cIndiceComportement, cTrafficSignalCompliance)
+		if err != nil {
+			return nil, errors.New("Failed to perform homomorphic subtraction for compliance")
+		}
+
+		cPrimePAYD, err := weighted("mileage", trip.Mileage, weights.Alpha)
+		if err != nil {
+			return nil, errors.New("Failed to calculate PAYD premium")
+		}
+		cPrimePHYD, err := publicKey.MulConst(cIndiceComportement, big.NewInt(int64(weights.Beta)))
+		if err != nil {
+			return nil, errors.New("Failed to calculate PHYD premium")
+		}
+
+		// Calcul de la prime totale
+		cPrimeTotale = publicKey.Sum(cVehicle, cPrimePAYD, cPrimePHYD)
+	}
+
+	resultID := ResultID(tripID)
+
+	if seed != nil {
+		rerandomized, err := s.Telematics.Keys.Rerandomize(publicKey, resultID, []string{"prime_totale"}, map[string]*paillier.Ciphertext{"prime_totale": cPrimeTotale}, seed)
+		if err != nil {
+			return nil, err
+		}
+		cPrimeTotale = rerandomized["prime_totale"]
+	}
+
+	result := &EncryptedCalculationResult{
+		ResultID:    resultID,
+		PrimeTotale: cPrimeTotale,
+		R:           publicKey.ComputeR(cPrimeTotale),
+		TripID:      tripID,
+		Scale:       scale.Int64(),
+		Bound:       bound,
+		KeyVersion:  priced.verifier.Version(),
+		SlotBits:    slotBits,
+		Slot:        slot,
+		SlotBound:   slotBound,
+
+		KeyFingerprint: priced.fingerprint,
+	}
+
+	err = s.Results.Put(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CalculateConfidentialPremium calcule la prime chiffrée d'un trajet avec les
+// poids confidentiels d'un assureur. products contient le produit chiffré de
+// chaque métrique par son coefficient engagé, et proofs la preuve que ce
+// coefficient a été utilisé : le chaincode additionne les produits sans voir
+// ni les métriques ni les poids. Seule leur somme est enregistrée dans le
+// world state ; les produits restent lisibles dans les arguments de la
+// transaction, inscrits dans le bloc
+func (s *Service) CalculateConfidentialPremium(vehicleID, tripID, weightsID string, products map[string]*paillier.Ciphertext, proofs map[string]*paillier.CommittedScalarProof) (*EncryptedCalculationResult, error) {
+	weights, err := s.Policy.ConfidentialWeights.Get(weightsID)
+	if err != nil {
+		return nil, err
+	}
+	if weights == nil {
+		return nil, errors.New("ConfidentialWeights not found for the given WeightsID")
+	}
+
+	priced, err := s.loadPricedTrip(vehicleID, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Un produit par métrique est nécessaire : un trajet packé n'en a qu'un
+	if priced.trip.Packed != nil {
+		return nil, errors.New("Packed trip data cannot be priced with confidential weights")
+	}
+
+	err = s.checkKeys(priced, telematics.TripFields)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := priced.publicKey
+
+	// Chaque produit doit être celui de la métrique par le coefficient engagé
+	weightedKey := "weightedtrip_" + tripID
+	params := pedersen.DefaultParams()
+	tripCiphertexts := priced.trip.Ciphertexts()
+	for _, field := range telematics.TripFields {
+		context := crypto.FieldContext(weightedKey+"/"+weightsID, field)
+		err = publicKey.VerifyCommittedScalar(params, tripCiphertexts[field], products[field], weights.Commitments[field], proofs[field], context)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid weighted product for field '%s': %s", field, err)
+		}
+	}
+
+	scales, scale, err := s.fieldScales(priced)
+	if err != nil {
+		return nil, err
+	}
+
+	// Les coefficients étant inconnus, la borne utilise celle que les preuves
+	// garantissent sur chacun d'eux. Elle suffit à exclure une réduction modulo
+	// N, mais pas à garantir d'avance que la prime tient dans un int : le
+	// décodage le vérifie après déchiffrement
+	coefficients := make(map[string]*big.Int, len(telematics.VehicleFields)+len(telematics.TripFields))
+	for _, field := range telematics.VehicleFields {
+		coefficients[field] = new(big.Int).Div(scale, big.NewInt(scales[field]))
+	}
+	for _, field := range telematics.TripFields {
+		factor := new(big.Int).Div(scale, big.NewInt(scales[field]))
+		coefficients[field] = factor.Mul(factor, paillier.CommittedScalarBound())
+	}
+	bound, err := s.premiumBound(coefficients)
+	if err != nil {
+		return nil, err
+	}
+	if !publicKey.FitsSigned(bound) {
+		return nil, errors.New("Premium calculation could wrap modulo N for the declared field ranges")
+	}
+	err = checkBounded(priced)
+	if err != nil {
+		return nil, err
+	}
+
+	var vehicleTerms, tripTerms []*paillier.Ciphertext
+	vehicleCiphertexts := priced.vehicle.Ciphertexts()
+	for _, field := range telematics.VehicleFields {
+		term, err := publicKey.MulConst(vehicleCiphertexts[field], new(big.Int).Div(scale, big.NewInt(scales[field])))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scale field '%s'", field)
+		}
+		vehicleTerms = append(vehicleTerms, term)
+	}
+	for _, field := range telematics.TripFields {
+		term, err := publicKey.MulConst(products[field], new(big.Int).Div(scale, big.NewInt(scales[field])))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scale weighted product for field '%s'", field)
+		}
+		tripTerms = append(tripTerms, term)
+	}
+	cWeighted := publicKey.Sum(tripTerms...)
+	cPrimeTotale := publicKey.Sum(append(vehicleTerms, cWeighted)...)
+
+	err = s.WeightedTrips.Put(&WeightedTripData{
+		TripID:    tripID,
+		WeightsID: weightsID,
+		Weighted:  cWeighted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &EncryptedCalculationResult{
+		ResultID:    ResultID(tripID),
+		PrimeTotale: cPrimeTotale,
+		R:           publicKey.ComputeR(cPrimeTotale),
+		TripID:      tripID,
+		Scale:       scale.Int64(),
+		Bound:       bound,
+		KeyVersion:  priced.verifier.Version(),
+		WeightsID:   weightsID,
+
+		KeyFingerprint: priced.fingerprint,
+	}
+
+	err = s.Results.Put(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// encryptedPremium regroupe le résultat chiffré d'un trajet, le trajet et la
+// clé publique du propriétaire du véhicule
+type encryptedPremium struct {
+	result    *EncryptedCalculationResult
+	trip      *telematics.EncryptedTripData
+	verifier  *crypto.Verifier
+	publicKey *paillier.PublicKey
+}
+
+// ownerVerifierVersion charge la clé du propriétaire d'un véhicule dans la
+// version, active ou retirée, sous laquelle un actif a été chiffré
+func (s *Service) ownerVerifierVersion(vehicleID string, version int) (*crypto.Verifier, error) {
+	verifier, err := s.Telematics.OwnerVerifier(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	if verifier.Version() != crypto.KeyVersion(version) {
+		return s.Telematics.Keys.VerifierVersion(verifier.OwnerID, version)
+	}
+	return verifier, nil
+}
+
+// loadEncryptedPremium charge les actifs nécessaires au déchiffrement de la
+// prime d'un trajet
+func (s *Service) loadEncryptedPremium(tripID string) (*encryptedPremium, error) {
+	result, err := s.Results.Get(ResultID(tripID))
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, errors.New("EncryptedCalculationResult not found for the given ResultID")
+	}
+
+	trip, err := s.Telematics.Trip(result.TripID)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := s.ownerVerifierVersion(trip.VehicleID, result.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	err = crypto.CheckKeyFingerprint(verifier, result.KeyFingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedPremium{
+		result:    result,
+		trip:      trip,
+		verifier:  verifier,
+		publicKey: publicKey,
+	}, nil
+}
+
+// decodePrime interprète le clair de la prime totale comme un entier signé et le
+// ramène à l'unité selon l'échelle de virgule fixe du résultat
+func (premium *encryptedPremium) decodePrime(plaintext *big.Int) (int, error) {
+	result := premium.result
+	return decodePremium(premium.publicKey, plaintext, result.Scale, result.Bound, result.SlotBits, result.Slot)
+}
+
+// newPrime construit la prime décodée d'un trajet à partir du clair déchiffré
+func (premium *encryptedPremium) newPrime(plaintext *big.Int) (*Prime, error) {
+	primeValue, err := premium.decodePrime(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Prime{
+		TripID:    premium.result.TripID,
+		Date:      premium.trip.Date,
+		Prime:     primeValue,
+		Plaintext: plaintext,
+		ResultID:  premium.result.ResultID,
+	}, nil
+}
+
+// checkSingleKey refuse les clés à seuil, déchiffrées par parts
+func (premium *encryptedPremium) checkSingleKey() error {
+	if premium.verifier.Threshold > 0 {
+		return errors.New("Verifier uses threshold decryption: submit decryption shares with 'SubmitDecryptionShare'")
+	}
+	return nil
+}
+
+// loadDecryptablePremium charge la prime chiffrée d'un trajet à déchiffrer.
+// Une fois le mois du trajet agrégé, seul le total mensuel peut être
+// déchiffré : les primes de ses trajets restent chiffrées
+func (s *Service) loadDecryptablePremium(tripID string) (*encryptedPremium, error) {
+	premium, err := s.loadEncryptedPremium(tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	year, month, err := extractYearAndMonth(premium.trip.Date)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid date format: %s", err.Error())
+	}
+	monthPrime, err := s.EncryptedMonthPrimes.Get(premium.trip.VehicleID, month, year)
+	if err != nil {
+		return nil, err
+	}
+	if monthPrime != nil {
+		return nil, errors.New("Trip premiums of an aggregated month can only be decrypted through the monthly total")
+	}
+	return premium, nil
+}
+
+// DecryptPremium déchiffre la prime d'un trajet avec le témoin r′ fourni par
+// le propriétaire de la clé et l'enregistre
+func (s *Service) DecryptPremium(tripID string, rPrime *big.Int) (*Prime, error) {
+	premium, err := s.loadDecryptablePremium(tripID)
+	if err != nil {
+		return nil, err
+	}
+	err = premium.checkSingleKey()
+	if err != nil {
+		return nil, err
+	}
+
+	decryptedPrime, err := premium.publicKey.VerifyAndDecrypt(premium.result.PrimeTotale, rPrime)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decrypt prime: %s", err.Error())
+	}
+
+	prime, err := premium.newPrime(decryptedPrime)
+	if err != nil {
+		return nil, err
+	}
+	prime.RPrime = rPrime
+
+	return prime, s.recordPrime(premium, prime)
+}
+
+// DecryptPremiumWithProof enregistre la prime d'un trajet déchiffrée hors
+// chaîne, accompagnée d'une preuve à divulgation nulle de déchiffrement liée
+// au ResultID et au chiffré
+func (s *Service) DecryptPremiumWithProof(tripID string, decryptedPrime *big.Int, proof *paillier.DecryptionProof) (*Prime, error) {
+	premium, err := s.loadDecryptablePremium(tripID)
+	if err != nil {
+		return nil, err
+	}
+	err = premium.checkSingleKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Un clair soumis sous forme signée est ramené dans Z_N avant la vérification
+	if decryptedPrime.Sign() < 0 {
+		decryptedPrime, err = premium.publicKey.EncodeSigned(decryptedPrime)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Prime value: %s", err.Error())
+		}
+	}
+
+	err = premium.publicKey.VerifyDecryption(premium.result.PrimeTotale, decryptedPrime, proof, []byte(premium.result.ResultID))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to verify decryption proof: %s", err.Error())
+	}
+
+	prime, err := premium.newPrime(decryptedPrime)
+	if err != nil {
+		return nil, err
+	}
+	prime.Proof = proof
+
+	return prime, s.recordPrime(premium, prime)
+}
+
+// VerifyPrime revérifie qu'une prime enregistrée provient bien du déchiffrement
+// du résultat chiffré du trajet, et la retourne
+func (s *Service) VerifyPrime(tripID string) (*Prime, error) {
+	prime, err := s.Primes.Get(tripID)
+	if err != nil {
+		return nil, err
+	}
+	if prime == nil {
+		return nil, errors.New("Prime not found for the given TripID")
+	}
+
+	premium, err := s.loadEncryptedPremium(prime.TripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if prime.ResultID != premium.result.ResultID {
+		return nil, errors.New("Prime is not bound to the current EncryptedCalculationResult")
+	}
+
+	// Les primes enregistrées avant l'encodage signé ne portent pas le clair brut
+	plaintext := prime.Plaintext
+	if plaintext == nil {
+		plaintext = big.NewInt(int64(prime.Prime))
+	}
+
+	switch {
+	case len(prime.Shares) > 0:
+		var thresholdKey *paillier.ThresholdPublicKey
+		thresholdKey, err = premium.verifier.ThresholdPublicKey()
+		if err == nil {
+			var combinedPrime *big.Int
+			combinedPrime, err = thresholdKey.Combine(premium.result.PrimeTotale, prime.Shares, []byte(prime.ResultID))
+			if err == nil && combinedPrime.Cmp(plaintext) != 0 {
+				err = errors.New("combined shares do not match the recorded prime")
+			}
+		}
+	case prime.Proof != nil:
+		err = premium.publicKey.VerifyDecryption(premium.result.PrimeTotale, plaintext, prime.Proof, []byte(prime.ResultID))
+	case prime.RPrime != nil:
+		var decryptedPrime *big.Int
+		decryptedPrime, err = premium.publicKey.VerifyAndDecrypt(premium.result.PrimeTotale, prime.RPrime)
+		if err == nil && decryptedPrime.Cmp(plaintext) != 0 {
+			err = errors.New("decrypted value does not match the recorded prime")
+		}
+	default:
+		err = errors.New("prime carries no decryption witness")
+	}
+	if err == nil {
+		var primeValue int
+		primeValue, err = premium.decodePrime(plaintext)
+		if err == nil && primeValue != prime.Prime {
+			err = errors.New("decoded value does not match the recorded prime")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Prime verification failed: %s", err.Error())
+	}
+
+	return prime, nil
+}
+
+// SubmitShare enregistre la part de déchiffrement d'un détenteur de la clé à
+// seuil pour le résultat chiffré d'un trajet. La part est vérifiée à sa
+// soumission : une part invalide ne peut pas bloquer la combinaison
+func (s *Service) SubmitShare(tripID string, share *paillier.DecryptionShare) error {
+	premium, err := s.loadDecryptablePremium(tripID)
+	if err != nil {
+		return err
+	}
+
+	thresholdKey, err := premium.verifier.ThresholdPublicKey()
+	if err != nil {
+		return err
+	}
+
+	err = thresholdKey.VerifyShare(premium.result.PrimeTotale, share, []byte(premium.result.ResultID))
+	if err != nil {
+		return fmt.Errorf("Failed to verify decryption share: %s", err.Error())
+	}
+
+	existingShare, err := s.Shares.Get(premium.result.TripID, share.Index)
+	if err != nil {
+		return err
+	}
+	if existingShare != nil {
+		return errors.New("DecryptionShare for this index has already been submitted")
+	}
+
+	return s.Shares.Put(premium.result.TripID, share)
+}
+
+// CombineShares combine les parts soumises pour le résultat chiffré d'un
+// trajet et enregistre la prime obtenue
+func (s *Service) CombineShares(tripID string) (*Prime, error) {
+	premium, err := s.loadDecryptablePremium(tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholdKey, err := premium.verifier.ThresholdPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Charger les parts soumises, dans l'ordre des index
+	var shares []*paillier.DecryptionShare
+	for index := 1; index <= thresholdKey.Parties; index++ {
+		share, err := s.Shares.Get(premium.result.TripID, index)
+		if err != nil {
+			return nil, err
+		}
+		if share != nil {
+			shares = append(shares, share)
+		}
+	}
+
+	if len(shares) < thresholdKey.Threshold {
+		return nil, fmt.Errorf("Not enough decryption shares: %d of %d submitted", len(shares), thresholdKey.Threshold)
+	}
+
+	decryptedPrime, err := thresholdKey.Combine(premium.result.PrimeTotale, shares, []byte(premium.result.ResultID))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to combine decryption shares: %s", err.Error())
+	}
+
+	prime, err := premium.newPrime(decryptedPrime)
+	if err != nil {
+		return nil, err
+	}
+	prime.Shares = shares
+
+	return prime, s.recordPrime(premium, prime)
+}
+
+// recordPrime enregistre la prime déchiffrée d'un trajet et l'ajoute au cumul
+// mensuel des primes du véhicule
+func (s *Service) recordPrime(premium *encryptedPremium, prime *Prime) error {
+	// Une prime déjà enregistrée serait comptée deux fois dans le cumul
+	existingPrime, err := s.Primes.Get(prime.TripID)
+	if err != nil {
+		return err
+	}
+	if existingPrime != nil {
+		return errors.New("Prime for this TripID has already been recorded")
+	}
+
+	err = s.Primes.Put(prime)
+	if err != nil {
+		return err
+	}
+
+	year, month, err := extractYearAndMonth(premium.trip.Date)
+	if err != nil {
+		return fmt.Errorf("Invalid date format: %s", err.Error())
+	}
+
+	monthPrime, err := s.MonthPrimes.GetCumulative(premium.trip.VehicleID, month, year)
+	if err != nil {
+		return err
+	}
+	if monthPrime == nil {
+		monthPrime = &MonthPrime{
+			VehicleID: premium.trip.VehicleID,
+			Month:     month,
+			Year:      year,
+		}
+	}
+	monthPrime.Prime += prime.Prime
+
+	return s.MonthPrimes.PutCumulative(monthPrime)
+}
+
+// AddMonthPrime enregistre une prime mensuelle
+func (s *Service) AddMonthPrime(monthPrime *MonthPrime) error {
+	err := s.checkNoMonthPrime(monthPrime.VehicleID, monthPrime.Month, monthPrime.Year)
+	if err != nil {
+		return err
+	}
+	return s.MonthPrimes.Put(monthPrime)
+}
+
+// checkNoMonthPrime vérifie que la prime d'un mois n'est pas déjà enregistrée
+func (s *Service) checkNoMonthPrime(vehicleID string, month, year int) error {
+	existingPrime, err := s.MonthPrimes.Get(vehicleID, month, year)
+	if err != nil {
+		return err
+	}
+	if existingPrime != nil {
+		return errors.New("MonthPrime for this VehicleID, Month, and Year already exists")
+	}
+	return nil
+}
+
+// AggregateMonth additionne homomorphiquement les primes chiffrées des trajets
+// d'un véhicule sur un mois et enregistre le total chiffré. Aucune prime de
+// trajet n'est déchiffrée, et les primes des trajets du mois ne peuvent plus
+// l'être individuellement. L'agrégation peut être relancée pour inclure de
+// nouveaux trajets tant que le total n'a pas été déchiffré et qu'aucune part
+// de déchiffrement n'a été soumise
+func (s *Service) AggregateMonth(vehicleID string, month, year int) (*EncryptedMonthPrime, error) {
+	err := CheckMonth(month)
+	if err != nil {
+		return nil, err
+	}
+
+	// Un total déjà déchiffré ne peut plus être modifié
+	err = s.checkNoMonthPrime(vehicleID, month, year)
+	if err != nil {
+		return nil, err
+	}
+
+	// Les parts déjà soumises portent sur le total enregistré
+	err = s.checkNoMonthShares(vehicleID, month, year)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.monthlyResults(vehicleID, month, year)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, errors.New("No EncryptedCalculationResult found for the given VehicleID, Month, and Year")
+	}
+
+	// Les chiffrés de versions de clé différentes ne s'additionnent pas
+	version := crypto.KeyVersion(results[0].KeyVersion)
+	for _, result := range results {
+		if crypto.KeyVersion(result.KeyVersion) != version {
+			return nil, errors.New("EncryptedCalculationResults of the month are encrypted under different key versions")
+		}
+	}
+
+	verifier, err := s.ownerVerifierVersion(vehicleID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+
+	fingerprints := make([]string, len(results))
+	for i, result := range results {
+		fingerprints[i] = result.KeyFingerprint
+	}
+	err = crypto.CheckKeyFingerprint(verifier, fingerprints...)
+	if err != nil {
+		return nil, err
+	}
+
+	monthPrime, err := sumPremiums(publicKey, results)
+	if err != nil {
+		return nil, err
+	}
+	monthPrime.VehicleID = vehicleID
+	monthPrime.Month = month
+	monthPrime.Year = year
+	monthPrime.R = publicKey.ComputeR(monthPrime.Total)
+	monthPrime.KeyVersion = version
+	monthPrime.KeyFingerprint, err = verifier.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.EncryptedMonthPrimes.Put(monthPrime)
+	if err != nil {
+		return nil, err
+	}
+	return monthPrime, nil
+}
+
+// encryptedMonth regroupe le total mensuel chiffré d'un véhicule et la clé
+// sous laquelle il est chiffré
+type encryptedMonth struct {
+	monthPrime *EncryptedMonthPrime
+	verifier   *crypto.Verifier
+}
+
+// loadEncryptedMonth charge le total mensuel chiffré d'un véhicule, qui ne
+// doit pas encore avoir été déchiffré
+func (s *Service) loadEncryptedMonth(vehicleID string, month, year int) (*encryptedMonth, error) {
+	err := CheckMonth(month)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkNoMonthPrime(vehicleID, month, year)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedMonthPrime, err := s.EncryptedMonthPrimes.Get(vehicleID, month, year)
+	if err != nil {
+		return nil, err
+	}
+	if encryptedMonthPrime == nil {
+		return nil, errors.New("EncryptedMonthPrime not found for the given VehicleID, Month, and Year: aggregate the month first")
+	}
+
+	verifier, err := s.ownerVerifierVersion(vehicleID, encryptedMonthPrime.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	err = crypto.CheckKeyFingerprint(verifier, encryptedMonthPrime.KeyFingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedMonth{monthPrime: encryptedMonthPrime, verifier: verifier}, nil
+}
+
+// newMonthPrime construit la prime mensuelle décodée à partir du clair
+// déchiffré du total
+func (m *encryptedMonth) newMonthPrime(publicKey *paillier.PublicKey, plaintext *big.Int) (*MonthPrime, error) {
+	total := m.monthPrime
+	primeValue, err := decodePremium(publicKey, plaintext, total.Scale, total.Bound, total.SlotBits, total.Slot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MonthPrime{
+		VehicleID: total.VehicleID,
+		Month:     total.Month,
+		Year:      total.Year,
+		Prime:     primeValue,
+		Plaintext: plaintext,
+	}, nil
+}
+
+// DecryptMonth déchiffre le total mensuel chiffré d'un véhicule avec le témoin
+// r′ fourni par le propriétaire et enregistre la prime mensuelle
+func (s *Service) DecryptMonth(vehicleID string, month, year int, rPrime *big.Int) (*MonthPrime, error) {
+	encrypted, err := s.loadEncryptedMonth(vehicleID, month, year)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted.verifier.Threshold > 0 {
+		return nil, errors.New("Verifier uses threshold decryption: submit decryption shares with 'SubmitMonthlyDecryptionShare'")
+	}
+
+	publicKey, err := encrypted.verifier.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+
+	decryptedPrime, err := publicKey.VerifyAndDecrypt(encrypted.monthPrime.Total, rPrime)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decrypt monthly prime: %s", err.Error())
+	}
+
+	monthPrime, err := encrypted.newMonthPrime(publicKey, decryptedPrime)
+	if err != nil {
+		return nil, err
+	}
+	monthPrime.RPrime = rPrime
+
+	err = s.MonthPrimes.Put(monthPrime)
+	if err != nil {
+		return nil, err
+	}
+	return monthPrime, nil
+}
+
+// SubmitMonthShare enregistre la part de déchiffrement d'un détenteur de la
+// clé à seuil pour le total mensuel chiffré d'un véhicule. Comme pour un
+// trajet, la part est vérifiée à sa soumission. Le total ne peut ensuite plus
+// être ré-agrégé
+func (s *Service) SubmitMonthShare(vehicleID string, month, year int, share *paillier.DecryptionShare) error {
+	encrypted, err := s.loadEncryptedMonth(vehicleID, month, year)
+	if err != nil {
+		return err
+	}
+
+	thresholdKey, err := encrypted.verifier.ThresholdPublicKey()
+	if err != nil {
+		return err
+	}
+
+	err = thresholdKey.VerifyShare(encrypted.monthPrime.Total, share, []byte(MonthID(vehicleID, month, year)))
+	if err != nil {
+		return fmt.Errorf("Failed to verify decryption share: %s", err.Error())
+	}
+
+	existingShare, err := s.Shares.GetMonth(vehicleID, month, year, share.Index)
+	if err != nil {
+		return err
+	}
+	if existingShare != nil {
+		return errors.New("DecryptionShare for this index has already been submitted")
+	}
+
+	return s.Shares.PutMonth(vehicleID, month, year, share)
+}
+
+// CombineMonthShares combine les parts soumises pour le total mensuel chiffré
+// d'un véhicule et enregistre la prime mensuelle obtenue
+func (s *Service) CombineMonthShares(vehicleID string, month, year int) (*MonthPrime, error) {
+	encrypted, err := s.loadEncryptedMonth(vehicleID, month, year)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholdKey, err := encrypted.verifier.ThresholdPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := s.monthShares(vehicleID, month, year, thresholdKey.Parties)
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) < thresholdKey.Threshold {
+		return nil, fmt.Errorf("Not enough decryption shares: %d of %d submitted", len(shares), thresholdKey.Threshold)
+	}
+
+	decryptedPrime, err := thresholdKey.Combine(encrypted.monthPrime.Total, shares, []byte(MonthID(vehicleID, month, year)))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to combine decryption shares: %s", err.Error())
+	}
+
+	monthPrime, err := encrypted.newMonthPrime(&thresholdKey.PublicKey, decryptedPrime)
+	if err != nil {
+		return nil, err
+	}
+	monthPrime.Shares = shares
+
+	err = s.MonthPrimes.Put(monthPrime)
+	if err != nil {
+		return nil, err
+	}
+	return monthPrime, nil
+}
+
+// monthShares charge les parts soumises pour le total mensuel d'un véhicule,
+// dans l'ordre des index
+func (s *Service) monthShares(vehicleID string, month, year, parties int) ([]*paillier.DecryptionShare, error) {
+	var shares []*paillier.DecryptionShare
+	for index := 1; index <= parties; index++ {
+		share, err := s.Shares.GetMonth(vehicleID, month, year, index)
+		if err != nil {
+			return nil, err
+		}
+		if share != nil {
+			shares = append(shares, share)
+		}
+	}
+	return shares, nil
+}
+
+// checkNoMonthShares vérifie qu'aucune part de déchiffrement n'a été soumise
+// pour le total mensuel enregistré d'un véhicule
+func (s *Service) checkNoMonthShares(vehicleID string, month, year int) error {
+	encryptedMonthPrime, err := s.EncryptedMonthPrimes.Get(vehicleID, month, year)
+	if err != nil || encryptedMonthPrime == nil {
+		return err
+	}
+
+	verifier, err := s.ownerVerifierVersion(vehicleID, encryptedMonthPrime.KeyVersion)
+	if err != nil {
+		return err
+	}
+	shares, err := s.monthShares(vehicleID, month, year, verifier.Parties)
+	if err != nil {
+		return err
+	}
+	if len(shares) > 0 {
+		return errors.New("Decryption shares have already been submitted for this month: the encrypted total can no longer be re-aggregated")
+	}
+	return nil
+}
+
+// monthlyResults charge les résultats chiffrés des trajets d'un véhicule datés
+// du mois donné, triés par ResultID. Les trajets dont la prime n'a pas encore
+// été calculée sont ignorés
+func (s *Service) monthlyResults(vehicleID string, month, year int) ([]EncryptedCalculationResult, error) {
+	trips, err := s.Telematics.Trips.ByVehicle(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []EncryptedCalculationResult
+	for _, trip := range trips {
+		tripYear, tripMonth, err := extractYearAndMonth(trip.Date)
+		if err != nil || tripYear != year || tripMonth != month {
+			continue
+		}
+
+		result, err := s.Results.Get(ResultID(trip.TripID))
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			results = append(results, *result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ResultID < results[j].ResultID
+	})
+	return results, nil
+}
+
+// PrimesByVehicle retourne les primes déchiffrées des trajets d'un véhicule
+func (s *Service) PrimesByVehicle(vehicleID string) ([]Prime, error) {
+	trips, err := s.Telematics.Trips.ByVehicle(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var primes []Prime
+	for _, trip := range trips {
+		prime, err := s.Primes.Get(trip.TripID)
+		if err != nil {
+			return nil, err
+		}
+		if prime != nil {
+			primes = append(primes, *prime)
+		}
+	}
+	return primes, nil
+}
+
+// ResultsByVehicle retourne les primes chiffrées des trajets d'un véhicule
+func (s *Service) ResultsByVehicle(vehicleID string) ([]EncryptedCalculationResult, error) {
+	trips, err := s.Telematics.Trips.ByVehicle(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []EncryptedCalculationResult
+	for _, trip := range trips {
+		result, err := s.Results.Get(ResultID(trip.TripID))
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			results = append(results, *result)
+		}
+	}
+	return results, nil
+}
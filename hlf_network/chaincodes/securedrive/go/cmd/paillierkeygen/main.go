@@ -0,0 +1,152 @@
+// Commande paillierkeygen : génère une paire de clés de Paillier et l'écrit en
+// JSON sur la sortie standard, dans le format attendu par AddVerifier.
+//
+// Avec -threshold et -parties, elle génère une clé à seuil (format attendu par
+// AddThresholdVerifier) et les parts de clé à remettre à chaque détenteur.
+// La commande est alors un distributeur de confiance : la factorisation de N
+// n'est ni écrite ni conservée, mais elle a été connue du processus, et la
+// sortie contient toutes les parts. Elle doit être exécutée dans un
+// environnement éphémère et hors ligne, détruit après la distribution des
+// parts (procédure décrite dans le README). Avec -out, la clé publique et
+// chaque part sont écrites dans des fichiers distincts du répertoire indiqué,
+// afin de remettre à chaque détenteur sa seule part.
+//
+// Avec -s supérieur à 1, elle génère une clé de Damgård–Jurik dont l'espace des
+// clairs est Z_{N^s} (argument S de AddVerifier).
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"simple/paillier"
+)
+
+// keyPair reprend les champs des actifs Verifier et Decryptor
+type keyPair struct {
+	P       string `json:"p"`
+	Q       string `json:"q"`
+	N       string `json:"n"`
+	NSquare string `json:"nsquare"`
+	Lambda  string `json:"lambda"`
+	Mu      string `json:"mu"`
+	S       int    `json:"s,omitempty"`
+}
+
+// thresholdKey reprend les champs d'un Verifier à seuil et les parts de clé
+type thresholdKey struct {
+	N                string     `json:"n"`
+	Threshold        int        `json:"threshold"`
+	Parties          int        `json:"parties"`
+	V                string     `json:"v"`
+	VerificationKeys []string   `json:"verification_keys"`
+	Shares           []keyShare `json:"shares,omitempty"`
+}
+
+type keyShare struct {
+	Index int    `json:"index"`
+	Share string `json:"share"`
+}
+
+func main() {
+	bits := flag.Int("bits", paillier.MinModulusBits, "taille du module N en bits")
+	threshold := flag.Int("threshold", 0, "nombre de parts requises pour déchiffrer (0 : clé non partagée)")
+	parties := flag.Int("parties", 0, "nombre de détenteurs de parts")
+	s := flag.Int("s", 1, "exposant de Damgård–Jurik (1 : Paillier)")
+	out := flag.String("out", "", "répertoire où écrire la clé à seuil (verifier.json) et chaque part (share-<i>.json) dans des fichiers distincts")
+	flag.Parse()
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	if *threshold > 0 {
+		if *s != 1 {
+			fmt.Fprintln(os.Stderr, "Threshold keys only support s = 1")
+			os.Exit(1)
+		}
+
+		publicKey, shares, err := paillier.GenerateThresholdKey(rand.Reader, *bits, *threshold, *parties)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to generate threshold key: %s\n", err)
+			os.Exit(1)
+		}
+
+		output := thresholdKey{
+			N:         publicKey.N.String(),
+			Threshold: publicKey.Threshold,
+			Parties:   publicKey.Parties,
+			V:         publicKey.V.String(),
+		}
+		for _, verificationKey := range publicKey.VerificationKeys {
+			output.VerificationKeys = append(output.VerificationKeys, verificationKey.String())
+		}
+		for _, share := range shares {
+			output.Shares = append(output.Shares, keyShare{Index: share.Index, Share: share.Share.String()})
+		}
+
+		if *out != "" {
+			if err := writeThresholdKey(*out, output); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write key: %s\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if err := encoder.Encode(output); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode key: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	privateKey, err := paillier.GenerateDamgardJurikKey(rand.Reader, *bits, *s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate key: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = encoder.Encode(keyPair{
+		P:       privateKey.P.String(),
+		Q:       privateKey.Q.String(),
+		N:       privateKey.N.String(),
+		NSquare: privateKey.NSquare.String(),
+		Lambda:  privateKey.Lambda.String(),
+		Mu:      privateKey.Mu.String(),
+		S:       privateKey.S,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode key: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeThresholdKey écrit la clé publique dans dir/verifier.json et la part de
+// chaque détenteur dans dir/share-<i>.json, lisible par le seul propriétaire
+func writeThresholdKey(dir string, key thresholdKey) error {
+	shares := key.Shares
+	key.Shares = nil
+	err := writeJSON(filepath.Join(dir, "verifier.json"), key, 0o644)
+	if err != nil {
+		return err
+	}
+
+	for _, share := range shares {
+		err = writeJSON(filepath.Join(dir, fmt.Sprintf("share-%d.json", share.Index)), share, 0o600)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(path string, v interface{}, perm os.FileMode) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), perm)
+}
@@ -0,0 +1,117 @@
+// Package compact définit le format compact versionné des entiers persistés :
+// "<étiquette>:<base64>", l'étiquette désignant le type de valeur et la
+// version du format, la charge utile étant l'encodage big-endian de l'entier
+// en base64 standard sans remplissage. Il est environ deux fois plus court que
+// l'écriture décimale et n'expose pas à CouchDB des littéraux numériques de
+// plusieurs centaines de chiffres. Les étiquettes sont déclarées par les
+// paquets qui définissent les valeurs (paillier, pedersen).
+package compact
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// ErrInvalidEncoding est retournée lorsqu'une valeur n'est ni dans le format
+// compact attendu ni un entier décimal.
+var ErrInvalidEncoding = errors.New("compact: invalid encoding")
+
+// Encode écrit l'entier positif x dans le format compact d'étiquette tag. Zéro
+// est écrit sur un octet nul, la charge utile n'étant jamais vide.
+func Encode(tag string, x *big.Int) string {
+	data := x.Bytes()
+	if len(data) == 0 {
+		data = []byte{0}
+	}
+	return tag + ":" + base64.RawStdEncoding.EncodeToString(data)
+}
+
+// Decode lit un entier positif écrit dans le format compact d'étiquette tag
+// ou, pour les valeurs enregistrées avant son introduction, en décimal.
+func Decode(tag, s string) (*big.Int, error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		x, ok := new(big.Int).SetString(s, 10)
+		if !ok || x.Sign() < 0 {
+			return nil, ErrInvalidEncoding
+		}
+		return x, nil
+	}
+
+	// Une étiquette inconnue (autre type ou version future) est refusée
+	if s[:i] != tag {
+		return nil, ErrInvalidEncoding
+	}
+	data, err := base64.RawStdEncoding.DecodeString(s[i+1:])
+	if err != nil || len(data) == 0 {
+		return nil, ErrInvalidEncoding
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// MarshalJSON encode l'entier positif x comme une chaîne JSON au format
+// compact, ou null si x est nil.
+func MarshalJSON(tag string, x *big.Int) ([]byte, error) {
+	if x == nil {
+		return []byte("null"), nil
+	}
+	if x.Sign() < 0 {
+		return nil, ErrInvalidEncoding
+	}
+	return json.Marshal(Encode(tag, x))
+}
+
+// UnmarshalJSON décode un entier écrit comme une chaîne JSON (format compact
+// ou décimal) ou, pour les enregistrements antérieurs, comme un nombre JSON
+// décimal. null, ou une valeur absente, est décodé en nil.
+func UnmarshalJSON(tag string, data []byte) (*big.Int, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, ErrInvalidEncoding
+		}
+		return Decode(tag, s)
+	}
+	x := new(big.Int)
+	if err := x.UnmarshalJSON(data); err != nil {
+		return nil, ErrInvalidEncoding
+	}
+	return x, nil
+}
+
+// MarshalFields encode chaque entier de values comme MarshalJSON, pour les
+// champs d'un objet JSON. Un entier nil donne un champ vide, omis par
+// l'option omitempty et écrit null sinon.
+func MarshalFields(tag string, values ...*big.Int) ([]json.RawMessage, error) {
+	fields := make([]json.RawMessage, len(values))
+	for i, x := range values {
+		if x == nil {
+			continue
+		}
+		data, err := MarshalJSON(tag, x)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = data
+	}
+	return fields, nil
+}
+
+// UnmarshalFields décode chaque champ d'un objet JSON comme UnmarshalJSON.
+func UnmarshalFields(tag string, fields ...json.RawMessage) ([]*big.Int, error) {
+	values := make([]*big.Int, len(fields))
+	for i, data := range fields {
+		x, err := UnmarshalJSON(tag, data)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = x
+	}
+	return values, nil
+}
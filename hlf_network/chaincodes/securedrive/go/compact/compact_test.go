@@ -0,0 +1,94 @@
+package compact
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, s := range []string{"0", "1", "255", "123456789012345678901234567890123456789"} {
+		x, _ := new(big.Int).SetString(s, 10)
+		encoded := Encode("t1", x)
+		if !strings.HasPrefix(encoded, "t1:") {
+			t.Fatalf("Encode(%s) = %q, expected the t1 tag", s, encoded)
+		}
+		decoded, err := Decode("t1", encoded)
+		if err != nil || decoded.Cmp(x) != 0 {
+			t.Fatalf("Decode(Encode(%s)) returned %v, %v", s, decoded, err)
+		}
+
+		// Écriture décimale des enregistrements antérieurs
+		decoded, err = Decode("t1", s)
+		if err != nil || decoded.Cmp(x) != 0 {
+			t.Fatalf("Decode(%q) returned %v, %v", s, decoded, err)
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	invalid := []string{
+		"",
+		"-5",
+		"12ab",
+		"t2:AQ",   // autre étiquette
+		"t1:",     // charge utile vide
+		"t1:AQ==", // remplissage
+		"t1:!!",
+	}
+	for _, s := range invalid {
+		if _, err := Decode("t1", s); err != ErrInvalidEncoding {
+			t.Errorf("Decode(%q): expected ErrInvalidEncoding, got %v", s, err)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	x, _ := new(big.Int).SetString("98765432109876543210987654321", 10)
+	data, err := MarshalJSON("t1", x)
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil || s != Encode("t1", x) {
+		t.Fatalf("MarshalJSON returned %s", data)
+	}
+
+	// Chaîne compacte, chaîne décimale et nombre JSON décimal
+	for _, input := range []string{string(data), `"` + x.String() + `"`, x.String()} {
+		decoded, err := UnmarshalJSON("t1", []byte(input))
+		if err != nil || decoded.Cmp(x) != 0 {
+			t.Fatalf("UnmarshalJSON(%s) returned %v, %v", input, decoded, err)
+		}
+	}
+
+	if data, err := MarshalJSON("t1", nil); err != nil || string(data) != "null" {
+		t.Errorf("MarshalJSON(nil) returned %s, %v", data, err)
+	}
+	if decoded, err := UnmarshalJSON("t1", []byte("null")); err != nil || decoded != nil {
+		t.Errorf("UnmarshalJSON(null) returned %v, %v", decoded, err)
+	}
+	if _, err := MarshalJSON("t1", big.NewInt(-1)); err != ErrInvalidEncoding {
+		t.Errorf("MarshalJSON(-1): expected ErrInvalidEncoding, got %v", err)
+	}
+	for _, input := range []string{`"t2:AQ"`, `true`, `1.5`} {
+		if _, err := UnmarshalJSON("t1", []byte(input)); err != ErrInvalidEncoding {
+			t.Errorf("UnmarshalJSON(%s): expected ErrInvalidEncoding, got %v", input, err)
+		}
+	}
+}
+
+func TestMarshalFields(t *testing.T) {
+	fields, err := MarshalFields("t1", big.NewInt(7), nil)
+	if err != nil {
+		t.Fatalf("MarshalFields: %v", err)
+	}
+	if string(fields[0]) != `"t1:Bw"` || fields[1] != nil {
+		t.Fatalf("MarshalFields returned %s, %s", fields[0], fields[1])
+	}
+	values, err := UnmarshalFields("t1", fields...)
+	if err != nil || values[0].Int64() != 7 || values[1] != nil {
+		t.Fatalf("UnmarshalFields returned %v, %v", values, err)
+	}
+}
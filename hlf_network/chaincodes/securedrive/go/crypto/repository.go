@@ -0,0 +1,175 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"simple/ledger"
+)
+
+// VerifierRepository conserve les versions des clés des propriétaires. Les
+// lectures retournent nil, sans erreur, pour une clé absente.
+type VerifierRepository interface {
+	// Get retourne la version active de la clé d'un propriétaire
+	Get(ownerID string) (*Verifier, error)
+	// GetVersion retourne une version, active ou retirée, de la clé
+	GetVersion(ownerID string, version int) (*Verifier, error)
+	// Put enregistre la version active de la clé
+	Put(verifier *Verifier) error
+	// Archive enregistre une version retirée de la clé
+	Archive(verifier *Verifier) error
+	// Delete supprime la version active de la clé
+	Delete(ownerID string) error
+}
+
+// NonceRepository conserve les graines d'aléa déjà utilisées, afin qu'aucune
+// ne serve deux fois.
+type NonceRepository interface {
+	Used(nonce []byte) (bool, error)
+	// MarkUsed enregistre l'utilisation de nonce pour l'actif key
+	MarkUsed(nonce []byte, key string) error
+}
+
+// NewVerifierRepository retourne le dépôt des clés dans le world state : la
+// version active est stockée sous verifier_<OwnerID>, les versions retirées
+// sous la clé composite (verifierVersion, OwnerID, KeyVersion). Les versions
+// archivées auparavant sous verifier_<OwnerID>_v<KeyVersion> restent lisibles
+func NewVerifierRepository(state ledger.State) VerifierRepository {
+	return &verifierStore{state: state}
+}
+
+type verifierStore struct {
+	state ledger.State
+}
+
+// versionKey retourne la clé d'une version retirée. Une clé simple
+// verifier_<OwnerID>_v<KeyVersion> serait aussi la clé active du propriétaire
+// nommé <OwnerID>_v<KeyVersion>
+func (r *verifierStore) versionKey(ownerID string, version int) (string, error) {
+	key, err := r.state.CreateCompositeKey("verifierVersion", []string{ownerID, strconv.Itoa(version)})
+	if err != nil {
+		return "", fmt.Errorf("Failed to create Verifier version key: %s", err)
+	}
+	return key, nil
+}
+
+func (r *verifierStore) Get(ownerID string) (*Verifier, error) {
+	return r.load("verifier_"+ownerID, ownerID)
+}
+
+func (r *verifierStore) GetVersion(ownerID string, version int) (*Verifier, error) {
+	verifier, err := r.Get(ownerID)
+	if err != nil || verifier == nil {
+		return nil, err
+	}
+	if verifier.Version() == KeyVersion(version) {
+		return verifier, nil
+	}
+
+	key, err := r.versionKey(ownerID, KeyVersion(version))
+	if err != nil {
+		return nil, err
+	}
+	verifier, err = r.load(key, ownerID)
+	if err != nil || verifier != nil {
+		return verifier, err
+	}
+
+	// Version archivée sous l'ancienne clé simple : elle n'est retenue que si
+	// elle appartient bien au propriétaire et porte la version demandée
+	verifier, err = r.load(fmt.Sprintf("verifier_%s_v%d", ownerID, KeyVersion(version)), ownerID)
+	if err != nil || verifier == nil {
+		return nil, err
+	}
+	if verifier.Status != StatusRetired || verifier.Version() != KeyVersion(version) {
+		return nil, nil
+	}
+	return verifier, nil
+}
+
+// load lit la clé enregistrée sous key. Une clé d'un autre propriétaire que
+// ownerID est ignorée
+func (r *verifierStore) load(key, ownerID string) (*Verifier, error) {
+	var verifier Verifier
+	found, err := ledger.GetJSON(r.state, key, &verifier)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get Verifier: %s", err)
+	}
+	if !found || verifier.OwnerID != ownerID {
+		return nil, nil
+	}
+	return &verifier, nil
+}
+
+func (r *verifierStore) Put(verifier *Verifier) error {
+	// La clé active ne doit pas écraser une version archivée sous l'ancienne
+	// clé simple par un autre propriétaire
+	var existing Verifier
+	found, err := ledger.GetJSON(r.state, "verifier_"+verifier.OwnerID, &existing)
+	if err != nil {
+		return fmt.Errorf("Failed to get Verifier: %s", err)
+	}
+	if found && existing.OwnerID != verifier.OwnerID {
+		return errors.New("Verifier key is used by another OwnerID")
+	}
+
+	err = ledger.PutJSON(r.state, "verifier_"+verifier.OwnerID, verifier)
+	if err != nil {
+		return fmt.Errorf("Failed to store Verifier: %s", err)
+	}
+	return nil
+}
+
+func (r *verifierStore) Archive(verifier *Verifier) error {
+	key, err := r.versionKey(verifier.OwnerID, verifier.Version())
+	if err != nil {
+		return err
+	}
+	err = ledger.PutJSON(r.state, key, verifier)
+	if err != nil {
+		return fmt.Errorf("Failed to store retired Verifier: %s", err)
+	}
+	return nil
+}
+
+func (r *verifierStore) Delete(ownerID string) error {
+	err := r.state.DelState("verifier_" + ownerID)
+	if err != nil {
+		return fmt.Errorf("Failed to delete Verifier: %s", err)
+	}
+	return nil
+}
+
+// NewNonceRepository retourne le dépôt des graines utilisées dans le world
+// state : seul un condensat de chaque graine est enregistré
+func NewNonceRepository(state ledger.State) NonceRepository {
+	return &nonceStore{state: state}
+}
+
+type nonceStore struct {
+	state ledger.State
+}
+
+func nonceKey(nonce []byte) string {
+	nonceHash := sha256.Sum256(nonce)
+	return "nonce_" + hex.EncodeToString(nonceHash[:])
+}
+
+func (r *nonceStore) Used(nonce []byte) (bool, error) {
+	usedNonce, err := r.state.GetState(nonceKey(nonce))
+	if err != nil {
+		return false, fmt.Errorf("Failed to check nonce usage: %s", err)
+	}
+	return usedNonce != nil, nil
+}
+
+func (r *nonceStore) MarkUsed(nonce []byte, key string) error {
+	err := r.state.PutState(nonceKey(nonce), []byte(key))
+	if err != nil {
+		return fmt.Errorf("Failed to record nonce usage: %s", err)
+	}
+	return nil
+}
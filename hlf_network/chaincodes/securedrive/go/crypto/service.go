@@ -0,0 +1,336 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"simple/ledger"
+	"simple/paillier"
+)
+
+// Service regroupe les opérations sur les clés et les chiffrés. Les dépôts
+// sont exposés afin que les tests puissent les remplacer.
+type Service struct {
+	Verifiers VerifierRepository
+	Nonces    NonceRepository
+}
+
+// NewService construit le service sur les dépôts du world state
+func NewService(state ledger.State) *Service {
+	return &Service{
+		Verifiers: NewVerifierRepository(state),
+		Nonces:    NewNonceRepository(state),
+	}
+}
+
+// Verifier charge la version active de la clé d'un propriétaire
+func (s *Service) Verifier(ownerID string) (*Verifier, error) {
+	verifier, err := s.Verifiers.Get(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if verifier == nil {
+		return nil, ErrVerifierNotFound
+	}
+	return verifier, nil
+}
+
+// VerifierVersion charge une version, active ou retirée, de la clé d'un propriétaire
+func (s *Service) VerifierVersion(ownerID string, version int) (*Verifier, error) {
+	verifier, err := s.Verifiers.GetVersion(ownerID, version)
+	if err != nil {
+		return nil, err
+	}
+	if verifier == nil {
+		return nil, ErrVerifierNotFound
+	}
+	return verifier, nil
+}
+
+// Authorize vérifie que caller peut agir pour ownerID : le propriétaire
+// lui-même, ou l'identité qui a enregistré sa clé active
+func (s *Service) Authorize(caller Caller, ownerID string) error {
+	verifier, err := s.Verifier(ownerID)
+	if err != nil {
+		return err
+	}
+	return verifier.authorize(caller)
+}
+
+// AddVerifier enregistre la première version de la clé d'un propriétaire. Un
+// exposant S non nul déclare une clé de Damgård–Jurik. caller en devient le
+// Controller
+func (s *Service) AddVerifier(caller Caller, ownerID, n, nSquare string, exponent int) error {
+	exponent, err := normalizeExponent(exponent)
+	if err != nil {
+		return err
+	}
+
+	existingVerifier, err := s.Verifiers.Get(ownerID)
+	if err != nil {
+		return err
+	}
+	if existingVerifier != nil {
+		return errors.New("Verifier with this OwnerID already exists")
+	}
+
+	verifier := Verifier{
+		OwnerID:    ownerID,
+		N:          n,
+		NSquare:    nSquare,
+		KeyVersion: 1,
+		Status:     StatusActive,
+		S:          exponent,
+		Controller: caller.ID,
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	verifier.encodeKey(publicKey, publicKey.Fingerprint())
+
+	return s.Verifiers.Put(&verifier)
+}
+
+// AddThresholdVerifier enregistre la clé publique à seuil d'un propriétaire
+func (s *Service) AddThresholdVerifier(caller Caller, ownerID, n string, threshold, parties int, v string, verificationKeys []string) error {
+	existingVerifier, err := s.Verifiers.Get(ownerID)
+	if err != nil {
+		return err
+	}
+	if existingVerifier != nil {
+		return errors.New("Verifier with this OwnerID already exists")
+	}
+
+	verifier := Verifier{
+		OwnerID:          ownerID,
+		N:                n,
+		Threshold:        threshold,
+		Parties:          parties,
+		V:                v,
+		VerificationKeys: verificationKeys,
+		KeyVersion:       1,
+		Status:           StatusActive,
+		Controller:       caller.ID,
+	}
+
+	publicKey, err := verifier.ThresholdPublicKey()
+	if err != nil {
+		return fmt.Errorf("Invalid threshold public key: %s", err)
+	}
+	verifier.encodeKey(&publicKey.PublicKey, publicKey.Fingerprint())
+	verifier.V = paillier.EncodeCompact(paillier.KeyTag, publicKey.V)
+	for i, verificationKey := range publicKey.VerificationKeys {
+		verifier.VerificationKeys[i] = paillier.EncodeCompact(paillier.KeyTag, verificationKey)
+	}
+
+	return s.Verifiers.Put(&verifier)
+}
+
+// RotateVerifier enregistre une nouvelle version de la clé d'un propriétaire
+// et archive la version active avec le statut "retired". Seuls le propriétaire
+// et le Controller de la clé active peuvent la renouveler
+func (s *Service) RotateVerifier(caller Caller, ownerID, n, nSquare string, exponent int) (*Verifier, error) {
+	exponent, err := normalizeExponent(exponent)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.Verifier(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	err = current.authorize(caller)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier := Verifier{
+		OwnerID:    ownerID,
+		N:          n,
+		NSquare:    nSquare,
+		KeyVersion: current.Version() + 1,
+		Status:     StatusActive,
+		S:          exponent,
+		Controller: current.Controller,
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	currentKey, err := current.PublicKey()
+	if err == nil && currentKey.N.Cmp(publicKey.N) == 0 {
+		return nil, errors.New("New key must differ from the active key")
+	}
+	verifier.encodeKey(publicKey, publicKey.Fingerprint())
+
+	// Archiver la version active
+	current.KeyVersion = current.Version()
+	current.Status = StatusRetired
+	err = s.Verifiers.Archive(current)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Verifiers.Put(&verifier)
+	if err != nil {
+		return nil, err
+	}
+	return &verifier, nil
+}
+
+// DeleteVerifier supprime la version active de la clé d'un propriétaire, à la
+// demande du propriétaire ou du Controller de la clé
+func (s *Service) DeleteVerifier(caller Caller, ownerID string) error {
+	verifier, err := s.Verifier(ownerID)
+	if err != nil {
+		return err
+	}
+	err = verifier.authorize(caller)
+	if err != nil {
+		return err
+	}
+	return s.Verifiers.Delete(ownerID)
+}
+
+// Reencrypt vérifie les chiffrés soumis sous la clé active du propriétaire,
+// ainsi que les preuves d'égalité avec les chiffrés current enregistrés sous
+// la version retirée, dont l'empreinte fingerprint doit correspondre. key
+// identifie l'actif re-chiffré. Retourne la version et l'empreinte de la clé
+// active
+func (s *Service) Reencrypt(ownerID, key string, version int, fingerprint string, fields []string, current, submitted map[string]*paillier.Ciphertext, proofs map[string]*paillier.EqualityProof) (int, string, error) {
+	if len(submitted) != len(fields) {
+		return 0, "", fmt.Errorf("Expecting exactly %d ciphertexts: %v", len(fields), fields)
+	}
+
+	active, err := s.Verifier(ownerID)
+	if err != nil {
+		return 0, "", err
+	}
+	if active.Version() == KeyVersion(version) {
+		return 0, "", errors.New("Record is already encrypted under the active key version")
+	}
+
+	retired, err := s.VerifierVersion(ownerID, version)
+	if err != nil {
+		return 0, "", err
+	}
+
+	from, err := retired.PublicKey()
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid retired Verifier public key: %s", err)
+	}
+	err = CheckKeyFingerprint(retired, fingerprint)
+	if err != nil {
+		return 0, "", err
+	}
+	to, err := active.PublicKey()
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+
+	for _, field := range fields {
+		c, ok := submitted[field]
+		if !ok {
+			return 0, "", fmt.Errorf("Missing ciphertext for field '%s'", field)
+		}
+
+		// La preuve est liée à l'actif, au champ et à la version cible
+		context := fmt.Sprintf("%s/v%d", FieldContext(key, field), active.Version())
+		err = paillier.VerifyEquality(from, current[field], to, c, proofs[field], []byte(context))
+		if err != nil {
+			return 0, "", fmt.Errorf("Invalid re-encryption of field '%s': %s", field, err)
+		}
+	}
+
+	activeFingerprint, err := active.Fingerprint()
+	if err != nil {
+		return 0, "", err
+	}
+	return active.Version(), activeFingerprint, nil
+}
+
+// EncryptFields chiffre le clair signé de chaque champ avec un aléa dérivé de
+// la graine secrète nonce et du champ. Les pairs endosseurs reçoivent la même
+// graine et produisent donc les mêmes chiffrés, mais l'aléa ne peut pas être
+// recalculé à partir des données du ledger. L'utilisation de la graine est
+// enregistrée pour en refuser toute réutilisation
+func (s *Service) EncryptFields(publicKey *paillier.PublicKey, key string, fields []string, values map[string]*big.Int, nonce []byte) (map[string]*paillier.Ciphertext, error) {
+	err := s.checkUnused(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := make(map[string]*paillier.Ciphertext, len(fields))
+	for _, field := range fields {
+		// Représentation signée dans Z_N
+		plaintext, err := publicKey.EncodeSigned(values[field])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode %s: %s", field, err)
+		}
+
+		r, err := publicKey.DeriveRandom(nonce, FieldContext(key, field))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive randomness for %s: %s", field, err)
+		}
+
+		encrypted[field], err = publicKey.EncryptWithRandom(plaintext, r)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encrypt %s: %s", field, err)
+		}
+	}
+
+	err = s.Nonces.MarkUsed(nonce, key)
+	if err != nil {
+		return nil, err
+	}
+	return encrypted, nil
+}
+
+// Rerandomize multiplie chaque chiffré par r^N, r étant dérivé de la graine et
+// du champ : les chiffrés stockés ne sont plus une fonction publique des
+// chiffrés dont ils proviennent. Un chiffré reçu en argument de la transaction
+// reste toutefois lisible dans le bloc, et celui qui peut le lire peut aussi
+// vérifier qu'il chiffre le même clair que le chiffré stocké : pour les rendre
+// non reliables, le client re-randomise avant la soumission. Comme pour le
+// chiffrement, la graine ne sert qu'une fois
+func (s *Service) Rerandomize(publicKey *paillier.PublicKey, key string, fields []string, ciphertexts map[string]*paillier.Ciphertext, seed []byte) (map[string]*paillier.Ciphertext, error) {
+	err := s.checkUnused(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	rerandomized := make(map[string]*paillier.Ciphertext, len(fields))
+	for _, field := range fields {
+		r, err := publicKey.DeriveRandom(seed, append([]byte("rerandomize/"), FieldContext(key, field)...))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive randomness for %s: %s", field, err)
+		}
+
+		rerandomized[field], err = publicKey.RerandomizeWithRandom(ciphertexts[field], r)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to re-randomize %s: %s", field, err)
+		}
+	}
+
+	err = s.Nonces.MarkUsed(seed, key)
+	if err != nil {
+		return nil, err
+	}
+	return rerandomized, nil
+}
+
+// checkUnused vérifie qu'une graine n'a jamais servi
+func (s *Service) checkUnused(nonce []byte) error {
+	used, err := s.Nonces.Used(nonce)
+	if err != nil {
+		return err
+	}
+	if used {
+		return errors.New("Nonce has already been used, generate a fresh one")
+	}
+	return nil
+}
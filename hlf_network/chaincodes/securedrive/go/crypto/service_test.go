@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"testing"
+
+	"simple/ledger"
+	"simple/ledger/ledgertest"
+	"simple/paillier"
+)
+
+// memoryState est un world state en mémoire, suffisant pour les dépôts
+type memoryState map[string][]byte
+
+func (s memoryState) GetState(key string) ([]byte, error) { return s[key], nil }
+
+func (s memoryState) PutState(key string, value []byte) error {
+	s[key] = value
+	return nil
+}
+
+func (s memoryState) DelState(key string) error {
+	delete(s, key)
+	return nil
+}
+
+func (s memoryState) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return "\x00" + objectType + "\x00" + strings.Join(attributes, "\x00") + "\x00", nil
+}
+
+func (s memoryState) Query(query string) ([]ledger.KV, error) {
+	return ledgertest.Select(s, query)
+}
+
+func testPublicKey(tb testing.TB) *paillier.PublicKey {
+	sk, err := paillier.GenerateKey(rand.Reader, paillier.MinModulusBits)
+	if err != nil {
+		tb.Fatalf("GenerateKey: %v", err)
+	}
+	return &sk.PublicKey
+}
+
+func TestNonceReuse(t *testing.T) {
+	pk := testPublicKey(t)
+	state := memoryState{}
+	s := NewService(state)
+	fields := []string{"speeding"}
+	values := map[string]*big.Int{"speeding": big.NewInt(3)}
+	nonce := bytes.Repeat([]byte{0x01}, paillier.MinSeedSize)
+	seed := bytes.Repeat([]byte{0x02}, paillier.MinSeedSize)
+
+	encrypted, err := s.EncryptFields(pk, "trip_trip1", fields, values, nonce)
+	if err != nil {
+		t.Fatalf("EncryptFields: %v", err)
+	}
+	if used, err := s.Nonces.Used(nonce); err != nil || !used {
+		t.Fatalf("nonce was not recorded: %v", err)
+	}
+	if _, err := s.Rerandomize(pk, "trip_trip1", fields, encrypted, seed); err != nil {
+		t.Fatalf("Rerandomize: %v", err)
+	}
+
+	// Une graine déjà utilisée est refusée, quel que soit l'actif ou l'opération
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"encrypt with the same nonce", func() error {
+			_, err := s.EncryptFields(pk, "trip_trip2", fields, values, nonce)
+			return err
+		}},
+		{"rerandomize with an encryption nonce", func() error {
+			_, err := s.Rerandomize(pk, "trip_trip2", fields, encrypted, nonce)
+			return err
+		}},
+		{"rerandomize with the same seed", func() error {
+			_, err := s.Rerandomize(pk, "trip_trip2", fields, encrypted, seed)
+			return err
+		}},
+		{"encrypt with a rerandomization seed", func() error {
+			_, err := s.EncryptFields(pk, "trip_trip2", fields, values, seed)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		err := tt.run()
+		if err == nil || err.Error() != "Nonce has already been used, generate a fresh one" {
+			t.Errorf("%s: expected nonce reuse error, got %v", tt.name, err)
+		}
+	}
+
+	// Seul un condensat de la graine est stocké
+	for key, value := range state {
+		if bytes.Contains([]byte(key), nonce) || bytes.Contains(value, nonce) {
+			t.Errorf("nonce stored in clear under %q", key)
+		}
+	}
+}
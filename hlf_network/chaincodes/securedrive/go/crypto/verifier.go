@@ -0,0 +1,228 @@
+// Package crypto gère les clés publiques des propriétaires (Verifier), leur
+// rotation, et les opérations de chiffrement effectuées sur la chaîne :
+// chiffrement des clairs transmis par le transient map, re-randomisation et
+// re-chiffrement sous une nouvelle version de clé.
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"simple/paillier"
+)
+
+// Verifier représente une instance Verifier
+type Verifier struct {
+	N          string `json:"n"`       // Format compact paillier.KeyTag (décimal pour les clés antérieures)
+	NSquare    string `json:"nsquare"` // Idem
+	OwnerID    string `json:"ownerID"`
+	KeyVersion int    `json:"key_version,omitempty"` // Version de la clé (1 pour les clés enregistrées avant la rotation)
+	Status     string `json:"status,omitempty"`      // "active" ou "retired"
+	S          int    `json:"s,omitempty"`           // Exposant de Damgård–Jurik : clairs modulo N^S (0 ou 1 : Paillier)
+
+	// Empreinte SHA-256 de la clé publique, reprise par chaque enregistrement
+	// chiffré sous cette clé
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+
+	// Identité (Caller.ID) du client qui a enregistré la clé : seuls lui et le
+	// propriétaire peuvent la renouveler ou la supprimer
+	Controller string `json:"controller,omitempty"`
+
+	// Clé à seuil : la clé de déchiffrement est partagée entre Parties
+	// détenteurs (ex. conducteur, assureur, régulateur), dont Threshold
+	// doivent soumettre une part pour déchiffrer. Threshold vaut 0 pour une
+	// clé détenue par le seul propriétaire
+	Threshold        int      `json:"threshold,omitempty"`
+	Parties          int      `json:"parties,omitempty"`
+	V                string   `json:"v,omitempty"`
+	VerificationKeys []string `json:"verification_keys,omitempty"`
+}
+
+// Statuts d'une version de clé
+const (
+	StatusActive  = "active"
+	StatusRetired = "retired"
+)
+
+// Caller identifie le client qui soumet une transaction
+type Caller struct {
+	ID           string // Identifiant unique du certificat : <MSPID>/<identifiant X.509>
+	MSPID        string // MSP de l'organisation du client
+	EnrollmentID string // Nom d'enrôlement du client auprès de la CA
+}
+
+// InsurerMSPID est le MSP de l'organisation de l'assureur (org1.insurance.com),
+// seule habilitée à administrer le schéma des données chiffrées
+const InsurerMSPID = "org1-insurance-com"
+
+// IsInsurer indique si le client appartient à l'organisation de l'assureur
+func (c Caller) IsInsurer() bool {
+	return c.MSPID == InsurerMSPID
+}
+
+// ErrNotAuthorized est retournée lorsque le client n'est ni le propriétaire
+// de la clé ni l'identité qui l'a enregistrée.
+var ErrNotAuthorized = errors.New("Caller is not allowed to manage the Verifier of this OwnerID")
+
+// ErrVerifierNotFound est retournée lorsqu'aucune clé n'est enregistrée pour
+// un propriétaire ou une version.
+var ErrVerifierNotFound = errors.New("Verifier not found for the given OwnerID")
+
+// Version retourne la version de la clé du Verifier
+func (v *Verifier) Version() int {
+	return KeyVersion(v.KeyVersion)
+}
+
+// KeyVersion normalise une version de clé : les actifs enregistrés avant la
+// rotation des clés ne portent pas de version et relèvent de la version 1
+func KeyVersion(version int) int {
+	if version == 0 {
+		return 1
+	}
+	return version
+}
+
+// PublicKey construit la clé publique de Paillier (de Damgård–Jurik si S > 1)
+// à partir du Verifier
+func (v *Verifier) PublicKey() (*paillier.PublicKey, error) {
+	n, err := paillier.DecodeCompact(paillier.KeyTag, v.N)
+	if err != nil {
+		return nil, paillier.ErrInvalidPublicKey
+	}
+
+	publicKey, err := paillier.NewDamgardJurikPublicKey(n, v.exponent())
+	if err != nil {
+		return nil, err
+	}
+
+	// NSquare est redondant : s'il est renseigné, il doit correspondre à N²
+	if v.NSquare != "" {
+		nSquare, err := paillier.DecodeCompact(paillier.KeyTag, v.NSquare)
+		if err != nil || nSquare.Cmp(publicKey.NSquare) != 0 {
+			return nil, paillier.ErrInvalidPublicKey
+		}
+	}
+
+	return publicKey, nil
+}
+
+// authorize vérifie que caller peut gérer la clé : le propriétaire lui-même,
+// ou l'identité qui l'a enregistrée. Les clés enregistrées avant le contrôle
+// d'accès n'ont pas de Controller : seul le propriétaire peut alors les gérer
+func (v *Verifier) authorize(caller Caller) error {
+	if caller.EnrollmentID != "" && caller.EnrollmentID == v.OwnerID {
+		return nil
+	}
+	if caller.ID != "" && caller.ID == v.Controller {
+		return nil
+	}
+	return ErrNotAuthorized
+}
+
+// encodeKey réécrit les éléments de la clé publique du Verifier dans le format
+// compact, quel que soit le format dans lequel ils ont été soumis, et en
+// enregistre l'empreinte
+func (v *Verifier) encodeKey(publicKey *paillier.PublicKey, fingerprint string) {
+	v.N = paillier.EncodeCompact(paillier.KeyTag, publicKey.N)
+	v.NSquare = paillier.EncodeCompact(paillier.KeyTag, publicKey.NSquare)
+	v.KeyFingerprint = fingerprint
+}
+
+// Fingerprint retourne l'empreinte de la clé du Verifier. Celle d'une clé à
+// seuil couvre aussi le seuil et les clés de vérification
+func (v *Verifier) Fingerprint() (string, error) {
+	if v.Threshold != 0 {
+		publicKey, err := v.ThresholdPublicKey()
+		if err != nil {
+			return "", fmt.Errorf("Invalid threshold public key: %s", err)
+		}
+		return publicKey.Fingerprint(), nil
+	}
+
+	publicKey, err := v.PublicKey()
+	if err != nil {
+		return "", fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	return publicKey.Fingerprint(), nil
+}
+
+// exponent retourne l'exposant de Damgård–Jurik de la clé : les Verifier
+// enregistrés avant son introduction sont des clés de Paillier (s = 1)
+func (v *Verifier) exponent() int {
+	if v.S == 0 {
+		return 1
+	}
+	return v.S
+}
+
+// normalizeExponent valide l'exposant S d'une clé de Damgård–Jurik. 0 désigne
+// une clé de Paillier (s = 1)
+func normalizeExponent(exponent int) (int, error) {
+	if exponent == 0 {
+		return 1, nil
+	}
+	if exponent < 1 || exponent > paillier.MaxS {
+		return 0, fmt.Errorf("Invalid S value. Expecting an integer between 1 and %d", paillier.MaxS)
+	}
+	return exponent, nil
+}
+
+// ThresholdPublicKey construit la clé publique à seuil à partir du Verifier
+func (v *Verifier) ThresholdPublicKey() (*paillier.ThresholdPublicKey, error) {
+	if v.Threshold == 0 {
+		return nil, errors.New("Verifier does not use threshold decryption")
+	}
+
+	publicKey, err := v.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	generator, err := paillier.DecodeCompact(paillier.KeyTag, v.V)
+	if err != nil {
+		return nil, paillier.ErrInvalidPublicKey
+	}
+
+	verificationKeys := make([]*big.Int, len(v.VerificationKeys))
+	for i, value := range v.VerificationKeys {
+		verificationKeys[i], err = paillier.DecodeCompact(paillier.KeyTag, value)
+		if err != nil {
+			return nil, paillier.ErrInvalidPublicKey
+		}
+	}
+
+	thresholdKey := &paillier.ThresholdPublicKey{
+		PublicKey:        *publicKey,
+		Threshold:        v.Threshold,
+		Parties:          v.Parties,
+		V:                generator,
+		VerificationKeys: verificationKeys,
+	}
+	if err := thresholdKey.Validate(); err != nil {
+		return nil, err
+	}
+	return thresholdKey, nil
+}
+
+// CheckKeyFingerprint vérifie que des enregistrements chiffrés désignent tous
+// la clé du Verifier. Les enregistrements antérieurs aux empreintes n'en
+// portent pas : seule leur version de clé est alors contrôlée
+func CheckKeyFingerprint(verifier *Verifier, fingerprints ...string) error {
+	expected, err := verifier.Fingerprint()
+	if err != nil {
+		return err
+	}
+	for _, fingerprint := range fingerprints {
+		if fingerprint != "" && fingerprint != expected {
+			return fmt.Errorf("Key fingerprint mismatch: record was encrypted under key %s, expected %s", fingerprint, expected)
+		}
+	}
+	return nil
+}
+
+// FieldContext lie une preuve ou un aléa dérivé à l'actif (ex. "trip_<TripID>")
+// et au champ qu'il concerne
+func FieldContext(key, field string) []byte {
+	return []byte(key + "/" + field)
+}
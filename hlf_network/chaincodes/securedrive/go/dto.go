@@ -0,0 +1,654 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"simple/billing"
+	"simple/paillier"
+	"simple/pedersen"
+	"simple/policy"
+	"simple/telematics"
+)
+
+// Types échangés avec les clients. Les actifs du world state portent des
+// *big.Int, que les métadonnées du contrat ne savent pas décrire : les
+// transactions reçoivent et retournent ces types, dont chaque entier est une
+// chaîne. Les chiffrés et les engagements sont écrits dans leur format compact
+// (décimal accepté en entrée), les autres entiers en décimal. Les noms JSON
+// sont ceux des actifs
+
+// VehicleData est la forme retournée d'un telematics.EncryptedVehicleData
+type VehicleData struct {
+	VehicleID       string           `json:"vehicleID"`
+	VehicleType     string           `json:"vehicle_type"`
+	PurchaseMileage string           `json:"purchase_mileage"`
+	Year            string           `json:"year"`
+	OwnerID         string           `json:"ownerID"`
+	KeyVersion      int              `json:"key_version,omitempty" metadata:",optional"`
+	KeyFingerprint  string           `json:"key_fingerprint,omitempty" metadata:",optional"`
+	Bounded         bool             `json:"bounded,omitempty" metadata:",optional"`
+	Scales          map[string]int64 `json:"scales,omitempty" metadata:",optional"`
+}
+
+// TripData est la forme retournée d'un telematics.EncryptedTripData. Les
+// métriques d'un trajet packé sont vides, Packed les regroupant
+type TripData struct {
+	VehicleID               string            `json:"vehicleID"`
+	TripID                  string            `json:"tripID"`
+	Date                    string            `json:"date"`
+	Speeding                string            `json:"speeding,omitempty" metadata:",optional"`
+	HardAccelerations       string            `json:"hard_accelerations,omitempty" metadata:",optional"`
+	EmergencyBrakes         string            `json:"emergency_brakes,omitempty" metadata:",optional"`
+	UnsafeDistance          string            `json:"unsafe_distance,omitempty" metadata:",optional"`
+	HighRiskZones           string            `json:"high_risk_zones,omitempty" metadata:",optional"`
+	TrafficSignalCompliance string            `json:"traffic_signal_compliance,omitempty" metadata:",optional"`
+	NightDriving            string            `json:"night_driving,omitempty" metadata:",optional"`
+	Mileage                 string            `json:"mileage,omitempty" metadata:",optional"`
+	KeyVersion              int               `json:"key_version,omitempty" metadata:",optional"`
+	KeyFingerprint          string            `json:"key_fingerprint,omitempty" metadata:",optional"`
+	Bounded                 bool              `json:"bounded,omitempty" metadata:",optional"`
+	Scales                  map[string]int64  `json:"scales,omitempty" metadata:",optional"`
+	Packed                  string            `json:"packed,omitempty" metadata:",optional"`
+	SlotBits                int               `json:"slot_bits,omitempty" metadata:",optional"`
+	Commitments             map[string]string `json:"commitments,omitempty" metadata:",optional"`
+}
+
+// TripFieldOpening est la forme retournée d'un telematics.TripFieldOpening
+type TripFieldOpening struct {
+	TripID         string `json:"tripID"`
+	Field          string `json:"field"`
+	ArbitratorID   string `json:"arbitratorID"`
+	Commitment     string `json:"commitment"`
+	TxID           string `json:"txID"`
+	Value          string `json:"value,omitempty" metadata:",optional"`
+	Opening        string `json:"opening,omitempty" metadata:",optional"`
+	KeyVersion     int    `json:"key_version,omitempty" metadata:",optional"`
+	KeyFingerprint string `json:"key_fingerprint,omitempty" metadata:",optional"`
+}
+
+// ConfidentialWeights est la forme retournée d'un policy.ConfidentialWeights
+type ConfidentialWeights struct {
+	WeightsID   string            `json:"weightsID"`
+	InsurerID   string            `json:"insurerID"`
+	Commitments map[string]string `json:"commitments"`
+}
+
+// CalculationResult est la forme retournée d'un billing.EncryptedCalculationResult
+type CalculationResult struct {
+	ResultID       string `json:"resultID"`
+	PrimeTotale    string `json:"prime_totale"`
+	R              string `json:"r"`
+	TripID         string `json:"tripID"`
+	Scale          int64  `json:"scale,omitempty" metadata:",optional"`
+	Bound          string `json:"bound,omitempty" metadata:",optional"`
+	KeyVersion     int    `json:"key_version,omitempty" metadata:",optional"`
+	SlotBits       int    `json:"slot_bits,omitempty" metadata:",optional"`
+	Slot           int    `json:"slot,omitempty" metadata:",optional"`
+	SlotBound      string `json:"slot_bound,omitempty" metadata:",optional"`
+	WeightsID      string `json:"weightsID,omitempty" metadata:",optional"`
+	KeyFingerprint string `json:"key_fingerprint,omitempty" metadata:",optional"`
+}
+
+// EncryptedMonthPrime est la forme retournée d'un billing.EncryptedMonthPrime
+type EncryptedMonthPrime struct {
+	VehicleID      string   `json:"vehicleID"`
+	Month          int      `json:"month"`
+	Year           int      `json:"year"`
+	Total          string   `json:"total"`
+	R              string   `json:"r"`
+	ResultIDs      []string `json:"resultIDs"`
+	Scale          int64    `json:"scale,omitempty" metadata:",optional"`
+	Bound          string   `json:"bound,omitempty" metadata:",optional"`
+	KeyVersion     int      `json:"key_version,omitempty" metadata:",optional"`
+	SlotBits       int      `json:"slot_bits,omitempty" metadata:",optional"`
+	Slot           int      `json:"slot,omitempty" metadata:",optional"`
+	KeyFingerprint string   `json:"key_fingerprint,omitempty" metadata:",optional"`
+}
+
+// Prime est la forme retournée d'un billing.Prime
+type Prime struct {
+	TripID    string             `json:"tripID"`
+	Date      string             `json:"date"`
+	Prime     int                `json:"prime"`
+	Plaintext string             `json:"plaintext,omitempty" metadata:",optional"`
+	ResultID  string             `json:"resultID,omitempty" metadata:",optional"`
+	RPrime    string             `json:"r_prime,omitempty" metadata:",optional"`
+	Shares    []*DecryptionShare `json:"shares,omitempty" metadata:",optional"`
+	Proof     *DecryptionProof   `json:"proof,omitempty" metadata:",optional"`
+}
+
+// MonthPrime est la forme retournée d'un billing.MonthPrime
+type MonthPrime struct {
+	VehicleID string             `json:"vehicleID"`
+	Month     int                `json:"month"`
+	Year      int                `json:"year"`
+	Prime     int                `json:"month_prime"`
+	Plaintext string             `json:"plaintext,omitempty" metadata:",optional"`
+	RPrime    string             `json:"r_prime,omitempty" metadata:",optional"`
+	Shares    []*DecryptionShare `json:"shares,omitempty" metadata:",optional"`
+}
+
+// OwnerDetails regroupe les véhicules, contrats et primes d'un propriétaire
+type OwnerDetails struct {
+	Vehicles []*OwnerVehicle `json:"Vehicles"`
+	Primes   []*PrimeSummary `json:"Primes"`
+}
+
+// OwnerVehicle est un véhicule d'un propriétaire et ses contrats
+type OwnerVehicle struct {
+	VehicleID      string             `json:"VehicleID"`
+	VehicleDetails *VehicleData       `json:"VehicleDetails"`
+	Contracts      []*ContractSummary `json:"Contracts,omitempty" metadata:",optional"`
+}
+
+// ContractSummary résume un contrat d'assurance et ses poids
+type ContractSummary struct {
+	ContractID        string                  `json:"ContractID"`
+	StartDate         string                  `json:"StartDate"` // MM-YYYY
+	EndDate           string                  `json:"EndDate"`   // MM-YYYY
+	CriteriaWeightsID string                  `json:"CriteriaWeightsID"`
+	CriteriaWeights   *CriteriaWeightsSummary `json:"CriteriaWeights,omitempty" metadata:",optional"`
+}
+
+// CriteriaWeightsSummary reprend les poids publics d'un contrat
+type CriteriaWeightsSummary struct {
+	WeightTraffic      int `json:"WeightTraffic"`
+	WeightSpeed        int `json:"WeightSpeed"`
+	WeightAcceleration int `json:"WeightAcceleration"`
+	WeightBraking      int `json:"WeightBraking"`
+	WeightDistance     int `json:"WeightDistance"`
+	WeightZone         int `json:"WeightZone"`
+	WeightTime         int `json:"WeightTime"`
+	Alpha              int `json:"Alpha"`
+	Beta               int `json:"Beta"`
+}
+
+// PrimeSummary résume la prime d'un trajet
+type PrimeSummary struct {
+	Date   string `json:"Date"`
+	Prime  int    `json:"Prime"`
+	TripID string `json:"TripID"`
+}
+
+// FieldProof regroupe les preuves soumises pour un champ chiffré. Une liste de
+// FieldProof est la forme soumise d'un telematics.EncryptedFieldProofs
+type FieldProof struct {
+	Field      string               `json:"field"`
+	Range      *RangeProof          `json:"range,omitempty" metadata:",optional"`
+	Knowledge  *KnowledgeProof      `json:"knowledge,omitempty" metadata:",optional"`
+	Commitment string               `json:"commitment,omitempty" metadata:",optional"`
+	Link       *CommitmentLinkProof `json:"link,omitempty" metadata:",optional"`
+}
+
+// RangeProof est la forme soumise d'une paillier.RangeProof
+type RangeProof struct {
+	Lower *BoundProof `json:"lower"`
+	Upper *BoundProof `json:"upper"`
+}
+
+// BoundProof est la forme soumise d'une paillier.BoundProof
+type BoundProof struct {
+	Bits []*BitProof `json:"bits"`
+	A    string      `json:"a"`
+	Z    string      `json:"z"`
+}
+
+// BitProof est la forme soumise d'une paillier.BitProof
+type BitProof struct {
+	C  string `json:"c"`
+	A0 string `json:"a0"`
+	A1 string `json:"a1"`
+	E0 string `json:"e0"`
+	E1 string `json:"e1"`
+	Z0 string `json:"z0"`
+	Z1 string `json:"z1"`
+}
+
+// KnowledgeProof est la forme soumise d'une paillier.KnowledgeProof
+type KnowledgeProof struct {
+	A string `json:"a"`
+	Z string `json:"z"`
+	W string `json:"w"`
+}
+
+// CommitmentLinkProof est la forme soumise d'une paillier.CommitmentLinkProof
+type CommitmentLinkProof struct {
+	A1 string `json:"a1"`
+	A2 string `json:"a2"`
+	Z  string `json:"z"`
+	T  string `json:"t"`
+	U  string `json:"u"`
+}
+
+// CommittedScalarProof est la forme soumise d'une paillier.CommittedScalarProof
+type CommittedScalarProof struct {
+	A1 string `json:"a1"`
+	A2 string `json:"a2"`
+	Z  string `json:"z"`
+	T  string `json:"t"`
+	U  string `json:"u"`
+}
+
+// EqualityProof est la forme soumise d'une paillier.EqualityProof
+type EqualityProof struct {
+	A1 string `json:"a1"`
+	A2 string `json:"a2"`
+	Z  string `json:"z"`
+	W1 string `json:"w1"`
+	W2 string `json:"w2"`
+}
+
+// DecryptionProof est la forme échangée d'une paillier.DecryptionProof
+type DecryptionProof struct {
+	A string `json:"a"`
+	Z string `json:"z"`
+}
+
+// DecryptionShare est la forme échangée d'une paillier.DecryptionShare
+type DecryptionShare struct {
+	Index int         `json:"index"`
+	Share string      `json:"share"`
+	Proof *ShareProof `json:"proof"`
+}
+
+// ShareProof est la forme échangée d'une paillier.ShareProof
+type ShareProof struct {
+	A string `json:"a"`
+	B string `json:"b"`
+	Z string `json:"z"`
+}
+
+// formatInt écrit un entier en décimal, ou une chaîne vide pour nil
+func formatInt(x *big.Int) string {
+	if x == nil {
+		return ""
+	}
+	return x.String()
+}
+
+// formatCiphertext écrit un chiffré dans son format compact, ou une chaîne
+// vide pour nil
+func formatCiphertext(c *paillier.Ciphertext) string {
+	if c == nil {
+		return ""
+	}
+	return c.Encode()
+}
+
+// formatCommitments écrit des engagements dans leur format compact
+func formatCommitments(commitments map[string]*pedersen.Commitment) map[string]string {
+	if commitments == nil {
+		return nil
+	}
+	formatted := make(map[string]string, len(commitments))
+	for field, commitment := range commitments {
+		if commitment != nil {
+			formatted[field] = commitment.Encode()
+		}
+	}
+	return formatted
+}
+
+// parseInts lit les entiers décimaux des champs d'un objet name
+func parseInts(name string, values ...string) ([]*big.Int, error) {
+	ints := make([]*big.Int, len(values))
+	for i, value := range values {
+		x, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			return nil, fmt.Errorf("Failed to parse %s. Expecting decimal integers", name)
+		}
+		ints[i] = x
+	}
+	return ints, nil
+}
+
+// parseCiphertexts lit des chiffrés indexés par nom de champ
+func parseCiphertexts(name string, ciphertexts map[string]string) (map[string]*paillier.Ciphertext, error) {
+	parsed := make(map[string]*paillier.Ciphertext, len(ciphertexts))
+	for field, s := range ciphertexts {
+		c, err := paillier.ParseCiphertext(s)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse %s ciphertext of field '%s'", name, field)
+		}
+		parsed[field] = c
+	}
+	return parsed, nil
+}
+
+// parseCommitments lit des engagements indexés par nom de champ
+func parseCommitments(commitments map[string]string) (map[string]*pedersen.Commitment, error) {
+	parsed := make(map[string]*pedersen.Commitment, len(commitments))
+	for field, s := range commitments {
+		commitment, err := pedersen.ParseCommitment(s)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse Commitment of field '%s'", field)
+		}
+		parsed[field] = commitment
+	}
+	return parsed, nil
+}
+
+func newVehicleData(v *telematics.EncryptedVehicleData) *VehicleData {
+	return &VehicleData{
+		VehicleID:       v.VehicleID,
+		VehicleType:     formatCiphertext(v.VehicleType),
+		PurchaseMileage: formatCiphertext(v.PurchaseMileage),
+		Year:            formatCiphertext(v.Year),
+		OwnerID:         v.OwnerID,
+		KeyVersion:      v.KeyVersion,
+		KeyFingerprint:  v.KeyFingerprint,
+		Bounded:         v.Bounded,
+		Scales:          v.Scales,
+	}
+}
+
+func newTripData(t *telematics.EncryptedTripData) *TripData {
+	return &TripData{
+		VehicleID:               t.VehicleID,
+		TripID:                  t.TripID,
+		Date:                    t.Date,
+		Speeding:                formatCiphertext(t.Speeding),
+		HardAccelerations:       formatCiphertext(t.HardAccelerations),
+		EmergencyBrakes:         formatCiphertext(t.EmergencyBrakes),
+		UnsafeDistance:          formatCiphertext(t.UnsafeDistance),
+		HighRiskZones:           formatCiphertext(t.HighRiskZones),
+		TrafficSignalCompliance: formatCiphertext(t.TrafficSignalCompliance),
+		NightDriving:            formatCiphertext(t.NightDriving),
+		Mileage:                 formatCiphertext(t.Mileage),
+		KeyVersion:              t.KeyVersion,
+		KeyFingerprint:          t.KeyFingerprint,
+		Bounded:                 t.Bounded,
+		Scales:                  t.Scales,
+		Packed:                  formatCiphertext(t.Packed),
+		SlotBits:                t.SlotBits,
+		Commitments:             formatCommitments(t.Commitments),
+	}
+}
+
+func newTripFieldOpening(o *telematics.TripFieldOpening) *TripFieldOpening {
+	opening := &TripFieldOpening{
+		TripID:         o.TripID,
+		Field:          o.Field,
+		ArbitratorID:   o.ArbitratorID,
+		TxID:           o.TxID,
+		Value:          formatCiphertext(o.Value),
+		Opening:        formatCiphertext(o.Opening),
+		KeyVersion:     o.KeyVersion,
+		KeyFingerprint: o.KeyFingerprint,
+	}
+	if o.Commitment != nil {
+		opening.Commitment = o.Commitment.Encode()
+	}
+	return opening
+}
+
+func newConfidentialWeights(w *policy.ConfidentialWeights) *ConfidentialWeights {
+	commitments := formatCommitments(w.Commitments)
+	if commitments == nil {
+		commitments = map[string]string{}
+	}
+	return &ConfidentialWeights{WeightsID: w.WeightsID, InsurerID: w.InsurerID, Commitments: commitments}
+}
+
+func newCalculationResult(r *billing.EncryptedCalculationResult) *CalculationResult {
+	return &CalculationResult{
+		ResultID:       r.ResultID,
+		PrimeTotale:    formatCiphertext(r.PrimeTotale),
+		R:              formatInt(r.R),
+		TripID:         r.TripID,
+		Scale:          r.Scale,
+		Bound:          formatInt(r.Bound),
+		KeyVersion:     r.KeyVersion,
+		SlotBits:       r.SlotBits,
+		Slot:           r.Slot,
+		SlotBound:      formatInt(r.SlotBound),
+		WeightsID:      r.WeightsID,
+		KeyFingerprint: r.KeyFingerprint,
+	}
+}
+
+func newEncryptedMonthPrime(p *billing.EncryptedMonthPrime) *EncryptedMonthPrime {
+	resultIDs := p.ResultIDs
+	if resultIDs == nil {
+		resultIDs = []string{}
+	}
+	return &EncryptedMonthPrime{
+		VehicleID:      p.VehicleID,
+		Month:          p.Month,
+		Year:           p.Year,
+		Total:          formatCiphertext(p.Total),
+		R:              formatInt(p.R),
+		ResultIDs:      resultIDs,
+		Scale:          p.Scale,
+		Bound:          formatInt(p.Bound),
+		KeyVersion:     p.KeyVersion,
+		SlotBits:       p.SlotBits,
+		Slot:           p.Slot,
+		KeyFingerprint: p.KeyFingerprint,
+	}
+}
+
+func newPrime(p *billing.Prime) *Prime {
+	prime := &Prime{
+		TripID:    p.TripID,
+		Date:      p.Date,
+		Prime:     p.Prime,
+		Plaintext: formatInt(p.Plaintext),
+		ResultID:  p.ResultID,
+		RPrime:    formatInt(p.RPrime),
+	}
+	for _, share := range p.Shares {
+		prime.Shares = append(prime.Shares, newDecryptionShare(share))
+	}
+	if p.Proof != nil {
+		prime.Proof = newDecryptionProof(p.Proof)
+	}
+	return prime
+}
+
+func newDecryptionProof(p *paillier.DecryptionProof) *DecryptionProof {
+	return &DecryptionProof{A: formatInt(p.A), Z: formatInt(p.Z)}
+}
+
+func newMonthPrime(p *billing.MonthPrime) *MonthPrime {
+	monthPrime := &MonthPrime{
+		VehicleID: p.VehicleID,
+		Month:     p.Month,
+		Year:      p.Year,
+		Prime:     p.Prime,
+		Plaintext: formatInt(p.Plaintext),
+		RPrime:    formatInt(p.RPrime),
+	}
+	for _, share := range p.Shares {
+		monthPrime.Shares = append(monthPrime.Shares, newDecryptionShare(share))
+	}
+	return monthPrime
+}
+
+func newDecryptionShare(s *paillier.DecryptionShare) *DecryptionShare {
+	share := &DecryptionShare{Index: s.Index, Share: formatInt(s.Share)}
+	if s.Proof != nil {
+		share.Proof = &ShareProof{A: formatInt(s.Proof.A), B: formatInt(s.Proof.B), Z: formatInt(s.Proof.Z)}
+	}
+	return share
+}
+
+// parseFieldProofs convertit les preuves soumises, au plus une entrée par
+// champ. Retourne nil si aucune preuve n'est jointe
+func parseFieldProofs(fieldProofs []*FieldProof) (*telematics.EncryptedFieldProofs, error) {
+	if len(fieldProofs) == 0 {
+		return nil, nil
+	}
+
+	proofs := &telematics.EncryptedFieldProofs{}
+	seen := make(map[string]bool, len(fieldProofs))
+	for _, p := range fieldProofs {
+		if p == nil {
+			continue
+		}
+		if seen[p.Field] {
+			return nil, fmt.Errorf("Duplicate proofs for field '%s'", p.Field)
+		}
+		seen[p.Field] = true
+
+		if p.Range != nil {
+			rangeProof, err := p.Range.proof()
+			if err != nil {
+				return nil, err
+			}
+			if proofs.Range == nil {
+				proofs.Range = make(map[string]*paillier.RangeProof)
+			}
+			proofs.Range[p.Field] = rangeProof
+		}
+		if p.Knowledge != nil {
+			knowledgeProof, err := p.Knowledge.proof()
+			if err != nil {
+				return nil, err
+			}
+			if proofs.Knowledge == nil {
+				proofs.Knowledge = make(map[string]*paillier.KnowledgeProof)
+			}
+			proofs.Knowledge[p.Field] = knowledgeProof
+		}
+		if p.Commitment != "" {
+			commitment, err := pedersen.ParseCommitment(p.Commitment)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse Commitment of field '%s'", p.Field)
+			}
+			if proofs.Commitments == nil {
+				proofs.Commitments = make(map[string]*pedersen.Commitment)
+			}
+			proofs.Commitments[p.Field] = commitment
+		}
+		if p.Link != nil {
+			linkProof, err := p.Link.proof()
+			if err != nil {
+				return nil, err
+			}
+			if proofs.Link == nil {
+				proofs.Link = make(map[string]*paillier.CommitmentLinkProof)
+			}
+			proofs.Link[p.Field] = linkProof
+		}
+	}
+	return proofs, nil
+}
+
+// Les conversions suivantes laissent à nil une preuve absente : les
+// vérifications la refusent comme une preuve invalide
+
+func (p *RangeProof) proof() (*paillier.RangeProof, error) {
+	if p == nil {
+		return nil, nil
+	}
+	lower, err := p.Lower.proof()
+	if err != nil {
+		return nil, err
+	}
+	upper, err := p.Upper.proof()
+	if err != nil {
+		return nil, err
+	}
+	return &paillier.RangeProof{Lower: lower, Upper: upper}, nil
+}
+
+func (p *BoundProof) proof() (*paillier.BoundProof, error) {
+	if p == nil {
+		return nil, nil
+	}
+	ints, err := parseInts("RangeProof", p.A, p.Z)
+	if err != nil {
+		return nil, err
+	}
+
+	bits := make([]*paillier.BitProof, len(p.Bits))
+	for i, bit := range p.Bits {
+		if bit == nil {
+			continue
+		}
+		c, err := paillier.ParseCiphertext(bit.C)
+		if err != nil {
+			return nil, errors.New("Failed to parse RangeProof ciphertext")
+		}
+		v, err := parseInts("RangeProof", bit.A0, bit.A1, bit.E0, bit.E1, bit.Z0, bit.Z1)
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = &paillier.BitProof{C: c, A0: v[0], A1: v[1], E0: v[2], E1: v[3], Z0: v[4], Z1: v[5]}
+	}
+	return &paillier.BoundProof{Bits: bits, A: ints[0], Z: ints[1]}, nil
+}
+
+func (p *KnowledgeProof) proof() (*paillier.KnowledgeProof, error) {
+	if p == nil {
+		return nil, nil
+	}
+	v, err := parseInts("KnowledgeProof", p.A, p.Z, p.W)
+	if err != nil {
+		return nil, err
+	}
+	return &paillier.KnowledgeProof{A: v[0], Z: v[1], W: v[2]}, nil
+}
+
+func (p *CommitmentLinkProof) proof() (*paillier.CommitmentLinkProof, error) {
+	if p == nil {
+		return nil, nil
+	}
+	v, err := parseInts("CommitmentLinkProof", p.A1, p.A2, p.Z, p.T, p.U)
+	if err != nil {
+		return nil, err
+	}
+	return &paillier.CommitmentLinkProof{A1: v[0], A2: v[1], Z: v[2], T: v[3], U: v[4]}, nil
+}
+
+func (p *CommittedScalarProof) proof() (*paillier.CommittedScalarProof, error) {
+	if p == nil {
+		return nil, nil
+	}
+	v, err := parseInts("CommittedScalarProof", p.A1, p.A2, p.Z, p.T, p.U)
+	if err != nil {
+		return nil, err
+	}
+	return &paillier.CommittedScalarProof{A1: v[0], A2: v[1], Z: v[2], T: v[3], U: v[4]}, nil
+}
+
+func (p *EqualityProof) proof() (*paillier.EqualityProof, error) {
+	if p == nil {
+		return nil, nil
+	}
+	v, err := parseInts("EqualityProof", p.A1, p.A2, p.Z, p.W1, p.W2)
+	if err != nil {
+		return nil, err
+	}
+	return &paillier.EqualityProof{A1: v[0], A2: v[1], Z: v[2], W1: v[3], W2: v[4]}, nil
+}
+
+func (p *DecryptionProof) proof() (*paillier.DecryptionProof, error) {
+	if p == nil {
+		return nil, nil
+	}
+	v, err := parseInts("DecryptionProof", p.A, p.Z)
+	if err != nil {
+		return nil, err
+	}
+	return &paillier.DecryptionProof{A: v[0], Z: v[1]}, nil
+}
+
+func (s *DecryptionShare) share() (*paillier.DecryptionShare, error) {
+	v, err := parseInts("DecryptionShare", s.Share)
+	if err != nil {
+		return nil, err
+	}
+	share := &paillier.DecryptionShare{Index: s.Index, Share: v[0]}
+	if s.Proof != nil {
+		proof, err := parseInts("DecryptionShare", s.Proof.A, s.Proof.B, s.Proof.Z)
+		if err != nil {
+			return nil, err
+		}
+		share.Proof = &paillier.ShareProof{A: proof[0], B: proof[1], Z: proof[2]}
+	}
+	return share, nil
+}
@@ -0,0 +1,51 @@
+// Package ledger définit l'accès au world state sur lequel reposent les dépôts
+// des paquets métier (crypto, telematics, policy, billing). Ces paquets ne
+// dépendent ni du stub Fabric ni de l'API des contrats : le chaincode fournit
+// une implémentation de State au-dessus du stub, les tests peuvent en fournir
+// une en mémoire.
+package ledger
+
+import "encoding/json"
+
+// KV est un résultat de requête : la clé d'un actif et sa valeur sérialisée.
+type KV struct {
+	Key   string
+	Value []byte
+}
+
+// State est le sous-ensemble du world state utilisé par les dépôts.
+type State interface {
+	GetState(key string) ([]byte, error)
+	PutState(key string, value []byte) error
+	DelState(key string) error
+
+	// CreateCompositeKey construit une clé composite (objectType, attributes),
+	// qui ne peut entrer en collision avec aucune clé simple.
+	CreateCompositeKey(objectType string, attributes []string) (string, error)
+
+	// Query exécute une requête riche CouchDB (sélecteur Mango) et retourne
+	// l'ensemble des résultats.
+	Query(query string) ([]KV, error)
+}
+
+// GetJSON désérialise dans v la valeur enregistrée sous key. Retourne false,
+// sans erreur, si la clé est absente.
+func GetJSON(state State, key string, v interface{}) (bool, error) {
+	data, err := state.GetState(key)
+	if err != nil {
+		return false, err
+	}
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// PutJSON sérialise v et l'enregistre sous key.
+func PutJSON(state State, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return state.PutState(key, data)
+}
@@ -0,0 +1,342 @@
+// Package ledgertest émule hors ligne les requêtes riches CouchDB du world
+// state. Il évalue le sous-ensemble des sélecteurs Mango utilisés par les
+// dépôts sur un état en mémoire : égalité, y compris sur des champs imbriqués,
+// $exists, $regex et les opérateurs de comparaison et de combinaison usuels.
+package ledgertest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"simple/ledger"
+)
+
+// Select exécute la requête riche query sur state, comme CouchDB sur le world
+// state d'un peer, et retourne les actifs sélectionnés triés par clé. La clé
+// d'un actif est exposée au sélecteur sous le champ _id ; les valeurs qui ne
+// sont pas des objets JSON ne sont jamais sélectionnées.
+func Select(state map[string][]byte, query string) ([]ledger.KV, error) {
+	selector, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var results []ledger.KV
+	for _, key := range keys {
+		var document map[string]interface{}
+		if json.Unmarshal(state[key], &document) != nil || document == nil {
+			continue
+		}
+		document["_id"] = key
+
+		if selector.match(document) {
+			results = append(results, ledger.KV{Key: key, Value: state[key]})
+		}
+	}
+	return results, nil
+}
+
+// parseQuery extrait et compile le sélecteur d'une requête riche
+func parseQuery(query string) (condition, error) {
+	var request map[string]json.RawMessage
+	err := json.Unmarshal([]byte(query), &request)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid query: %s", err)
+	}
+
+	for field := range request {
+		// use_index ne change pas les résultats, seulement le plan d'exécution
+		if field != "selector" && field != "use_index" {
+			return nil, fmt.Errorf("Unsupported query field '%s'", field)
+		}
+	}
+	raw, ok := request["selector"]
+	if !ok {
+		return nil, errors.New("Query must contain a 'selector'")
+	}
+
+	var selector interface{}
+	err = json.Unmarshal(raw, &selector)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid selector: %s", err)
+	}
+	object, ok := selector.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("Selector must be a JSON object")
+	}
+	return compileSelector(nil, object)
+}
+
+// condition est un sélecteur compilé, évalué sur un document
+type condition interface {
+	match(document map[string]interface{}) bool
+}
+
+type allOf []condition
+
+func (c allOf) match(document map[string]interface{}) bool {
+	for _, sub := range c {
+		if !sub.match(document) {
+			return false
+		}
+	}
+	return true
+}
+
+type anyOf []condition
+
+func (c anyOf) match(document map[string]interface{}) bool {
+	for _, sub := range c {
+		if sub.match(document) {
+			return true
+		}
+	}
+	return false
+}
+
+type not struct {
+	condition
+}
+
+func (c not) match(document map[string]interface{}) bool {
+	return !c.condition.match(document)
+}
+
+// fieldCondition applique un opérateur à la valeur d'un champ, désigné par son
+// chemin depuis la racine du document
+type fieldCondition struct {
+	path    []string
+	operate func(value interface{}, exists bool) bool
+}
+
+func (c fieldCondition) match(document map[string]interface{}) bool {
+	value, exists := lookup(document, c.path)
+	return c.operate(value, exists)
+}
+
+// lookup retourne la valeur du champ path du document
+func lookup(document map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = document
+	for _, name := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// compileSelector compile un objet sélecteur dont les champs sont relatifs à
+// prefix. Comme CouchDB, un objet sans opérateur sélectionne les champs du
+// sous-document : {"a":{"b":1}} équivaut à {"a.b":1}
+func compileSelector(prefix []string, selector map[string]interface{}) (condition, error) {
+	conditions := make(allOf, 0, len(selector))
+	for name, value := range selector {
+		var compiled condition
+		var err error
+
+		switch name {
+		case "$and", "$or", "$nor":
+			compiled, err = compileCombination(prefix, name, value)
+		case "$not":
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("Operator $not expects a selector")
+			}
+			compiled, err = compileSelector(prefix, object)
+			compiled = not{compiled}
+		default:
+			if strings.HasPrefix(name, "$") {
+				return nil, fmt.Errorf("Unsupported selector operator '%s'", name)
+			}
+			compiled, err = compileField(append(append([]string{}, prefix...), strings.Split(name, ".")...), value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, compiled)
+	}
+	return conditions, nil
+}
+
+// compileCombination compile $and, $or et $nor, qui combinent une liste de
+// sélecteurs
+func compileCombination(prefix []string, operator string, value interface{}) (condition, error) {
+	selectors, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Operator %s expects an array of selectors", operator)
+	}
+
+	conditions := make([]condition, 0, len(selectors))
+	for _, selector := range selectors {
+		object, ok := selector.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Operator %s expects an array of selectors", operator)
+		}
+		compiled, err := compileSelector(prefix, object)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, compiled)
+	}
+
+	switch operator {
+	case "$and":
+		return allOf(conditions), nil
+	case "$or":
+		return anyOf(conditions), nil
+	default:
+		return not{anyOf(conditions)}, nil
+	}
+}
+
+// compileField compile la condition portant sur le champ path
+func compileField(path []string, value interface{}) (condition, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return compileOperator(path, "$eq", value)
+	}
+
+	operators := 0
+	for name := range object {
+		if strings.HasPrefix(name, "$") {
+			operators++
+		}
+	}
+	if operators == 0 {
+		return compileSelector(path, object)
+	}
+	if operators != len(object) {
+		return nil, fmt.Errorf("Selector for field '%s' mixes operators and fields", strings.Join(path, "."))
+	}
+
+	conditions := make(allOf, 0, len(object))
+	for operator, argument := range object {
+		compiled, err := compileOperator(path, operator, argument)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, compiled)
+	}
+	return conditions, nil
+}
+
+// compileOperator compile un opérateur de condition. Comme CouchDB, seul
+// {"$exists":false} sélectionne un document dont le champ est absent
+func compileOperator(path []string, operator string, argument interface{}) (condition, error) {
+	var operate func(value interface{}) bool
+
+	switch operator {
+	case "$exists":
+		want, ok := argument.(bool)
+		if !ok {
+			return nil, errors.New("Operator $exists expects a boolean")
+		}
+		return fieldCondition{path, func(_ interface{}, exists bool) bool {
+			return exists == want
+		}}, nil
+
+	case "$eq":
+		operate = func(value interface{}) bool {
+			return reflect.DeepEqual(value, argument)
+		}
+	case "$ne":
+		operate = func(value interface{}) bool {
+			return !reflect.DeepEqual(value, argument)
+		}
+
+	case "$in", "$nin":
+		values, ok := argument.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Operator %s expects an array", operator)
+		}
+		operate = func(value interface{}) bool {
+			for _, candidate := range values {
+				if reflect.DeepEqual(value, candidate) {
+					return operator == "$in"
+				}
+			}
+			return operator == "$nin"
+		}
+
+	case "$gt", "$gte", "$lt", "$lte":
+		operate = func(value interface{}) bool {
+			order, ok := compare(value, argument)
+			if !ok {
+				return false
+			}
+			switch operator {
+			case "$gt":
+				return order > 0
+			case "$gte":
+				return order >= 0
+			case "$lt":
+				return order < 0
+			default:
+				return order <= 0
+			}
+		}
+
+	case "$regex":
+		pattern, ok := argument.(string)
+		if !ok {
+			return nil, errors.New("Operator $regex expects a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid $regex pattern: %s", err)
+		}
+		operate = func(value interface{}) bool {
+			s, ok := value.(string)
+			return ok && re.MatchString(s)
+		}
+
+	default:
+		return nil, fmt.Errorf("Unsupported selector operator '%s'", operator)
+	}
+
+	return fieldCondition{path, func(value interface{}, exists bool) bool {
+		return exists && operate(value)
+	}}, nil
+}
+
+// compare ordonne deux nombres ou deux chaînes. Les valeurs de types
+// différents ne sont pas comparées
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
@@ -0,0 +1,147 @@
+package ledgertest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Actifs du world state tels que les enregistrent les dépôts
+var testState = map[string][]byte{
+	"vehicle_v1":          []byte(`{"vehicleID":"v1","ownerID":"alice","keyVersion":1}`),
+	"vehicle_v2":          []byte(`{"vehicleID":"v2","ownerID":"bob","keyVersion":2}`),
+	"trip_t1":             []byte(`{"tripID":"t1","vehicleID":"v1","date":"2024-03-15"}`),
+	"contract_c1":         []byte(`{"contractID":"c1","ownerID":"alice","vehicleID":"v1"}`),
+	"criteriaweights_w1":  []byte(`{"criteriaWeightsID":"w1","alpha":2}`),
+	"verifier_alice":      []byte(`{"ownerID":"alice","key":{"version":1,"status":"retired"}}`),
+	"verifier_alice_v2":   []byte(`{"ownerID":"alice","key":{"version":2,"status":"active"}}`),
+	"nonce_legacy":        []byte(`"used"`),
+	"decryptor_alice":     []byte(`{"privateKey":"redacted"}`),
+	"criteriaweights_bad": []byte(`not json`),
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantKeys []string
+	}{
+		{
+			name:     "field equality",
+			query:    `{"selector":{"vehicleID":"v1"}}`,
+			wantKeys: []string{"contract_c1", "trip_t1", "vehicle_v1"},
+		},
+		{
+			name:     "several fields",
+			query:    `{"selector":{"ownerID":"alice","vehicleID":"v1"}}`,
+			wantKeys: []string{"contract_c1", "vehicle_v1"},
+		},
+		{
+			name:     "field exists",
+			query:    `{"selector":{"contractID":{"$exists":true}}}`,
+			wantKeys: []string{"contract_c1"},
+		},
+		{
+			name:     "field does not exist",
+			query:    `{"selector":{"ownerID":"alice", "contractID":{"$exists":false}}}`,
+			wantKeys: []string{"vehicle_v1", "verifier_alice", "verifier_alice_v2"},
+		},
+		{
+			name:     "key regex",
+			query:    `{"selector":{"_id":{"$regex":"^criteriaweights_"}}}`,
+			wantKeys: []string{"criteriaweights_w1"},
+		},
+		{
+			name:     "nested field object",
+			query:    `{"selector":{"key":{"status":"active"}}}`,
+			wantKeys: []string{"verifier_alice_v2"},
+		},
+		{
+			name:     "nested field path",
+			query:    `{"selector":{"key.version":{"$gte":1},"key.status":{"$ne":"active"}}}`,
+			wantKeys: []string{"verifier_alice"},
+		},
+		{
+			name:     "number equality",
+			query:    `{"selector":{"keyVersion":2}}`,
+			wantKeys: []string{"vehicle_v2"},
+		},
+		{
+			name:     "missing field fails other operators",
+			query:    `{"selector":{"keyVersion":{"$ne":2}}}`,
+			wantKeys: []string{"vehicle_v1"},
+		},
+		{
+			name:     "in",
+			query:    `{"selector":{"ownerID":{"$in":["bob","carol"]}}}`,
+			wantKeys: []string{"vehicle_v2"},
+		},
+		{
+			name:     "or",
+			query:    `{"selector":{"$or":[{"tripID":"t1"},{"criteriaWeightsID":"w1"}]}}`,
+			wantKeys: []string{"criteriaweights_w1", "trip_t1"},
+		},
+		{
+			name:     "nor",
+			query:    `{"selector":{"vehicleID":{"$exists":true},"$nor":[{"ownerID":"alice"},{"tripID":{"$exists":true}}]}}`,
+			wantKeys: []string{"vehicle_v2"},
+		},
+		{
+			name:     "string comparison",
+			query:    `{"selector":{"date":{"$gte":"2024-03-01","$lt":"2024-04-01"}},"use_index":["_design/indexDate","date"]}`,
+			wantKeys: []string{"trip_t1"},
+		},
+		{
+			name:  "no match",
+			query: `{"selector":{"vehicleID":"v3"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Select(testState, tt.query)
+			if err != nil {
+				t.Fatalf("Select: %v", err)
+			}
+
+			var keys []string
+			for _, result := range results {
+				if !reflect.DeepEqual(result.Value, testState[result.Key]) {
+					t.Fatalf("value of %s differs from the state", result.Key)
+				}
+				keys = append(keys, result.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Fatalf("selected %v, expected %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{"invalid JSON", `{"selector":`, "Invalid query"},
+		{"missing selector", `{"use_index":"index"}`, "Query must contain a 'selector'"},
+		{"unsupported query field", `{"selector":{},"sort":[{"date":"asc"}]}`, "Unsupported query field 'sort'"},
+		{"selector not an object", `{"selector":["vehicleID"]}`, "Selector must be a JSON object"},
+		{"unsupported operator", `{"selector":{"date":{"$size":1}}}`, "Unsupported selector operator '$size'"},
+		{"unsupported combination", `{"selector":{"$text":"v1"}}`, "Unsupported selector operator '$text'"},
+		{"invalid regex", `{"selector":{"_id":{"$regex":"("}}}`, "Invalid $regex pattern"},
+		{"invalid exists", `{"selector":{"_id":{"$exists":"yes"}}}`, "Operator $exists expects a boolean"},
+		{"operators mixed with fields", `{"selector":{"key":{"$exists":true,"status":"active"}}}`, "Selector for field 'key' mixes operators and fields"},
+		{"or without array", `{"selector":{"$or":{"tripID":"t1"}}}`, "Operator $or expects an array of selectors"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Select(testState, tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,225 +1,89 @@
 package main
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
-	"strconv"
 
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"simple/billing"
+	"simple/crypto"
+	"simple/paillier"
+	"simple/policy"
+	"simple/telematics"
 )
 
-// SmartContract structure
-type SmartContract struct{}
-
-// InsuranceContract représente un contrat d'assurance
-type InsuranceContract struct {
-	ContractID        string `json:"contractID"`        // Identifiant unique du contrat
-	OwnerID           string `json:"ownerID"`           // Identifiant du propriétaire
-	VehicleID         string `json:"vehicleID"`         // Identifiant du véhicule
-	CriteriaWeightsID string `json:"criteriaWeightsID"` // Identifiant des critères de pondération
-	StartMonth        int    `json:"startMonth"`        // Mois de début
-	StartYear         int    `json:"startYear"`         // Année de début
-	EndMonth          int    `json:"endMonth"`          // Mois de fin
-	EndYear           int    `json:"endYear"`           // Année de fin
-}
-
-// Decryptor représente une instance Decryptor
-type Decryptor struct {
-	P       string `json:"p"`
-	Q       string `json:"q"`
-	N       string `json:"n"`
-	Lambda  string `json:"lambda"`
-	OwnerID string `json:"ownerID"`
-}
-
-// Verifier représente une instance Verifier
-type Verifier struct {
-	N       string `json:"n"`
-	NSquare string `json:"nsquare"`
-	OwnerID string `json:"ownerID"`
-}
-
-// EncryptedVehicleData représente les données du véhicule chiffrées
-type EncryptedVehicleData struct {
-	VehicleID       string   `json:"vehicleID"`        // Identifiant unique du véhicule
-	VehicleType     *big.Int `json:"vehicle_type"`     // Chiffré
-	PurchaseMileage *big.Int `json:"purchase_mileage"` // Chiffré
-	Year            *big.Int `json:"year"`             // Chiffré
-	OwnerID         string   `json:"ownerID"`
-}
-
-// EncryptedTripData représente les données d'un trajet chiffrées
-type EncryptedTripData struct {
-	VehicleID               string   `json:"vehicleID"`                 // Identifiant du véhicule
-	TripID                  string   `json:"tripID"`                    // Identifiant unique du trajet
-	Date                    string   `json:"date"`                      // Date du trajet (format ISO 8601 : YYYY-MM-DD)
-	Speeding                *big.Int `json:"speeding"`                  // Chiffré
-	HardAccelerations       *big.Int `json:"hard_accelerations"`        // Chiffré
-	EmergencyBrakes         *big.Int `json:"emergency_brakes"`          // Chiffré
-	UnsafeDistance          *big.Int `json:"unsafe_distance"`           // Chiffré
-	HighRiskZones           *big.Int `json:"high_risk_zones"`           // Chiffré
-	TrafficSignalCompliance *big.Int `json:"traffic_signal_compliance"` // Chiffré
-	NightDriving            *big.Int `json:"night_driving"`             // Chiffré
-	Mileage                 *big.Int `json:"mileage"`                   // Chiffré
-}
-
-// CriteriaWeights représente les poids des critères
-type CriteriaWeights struct {
-	CriteriaWeightsID  string `json:"criteriaweightID"`
-	WeightTraffic      int    `json:"weight_traffic"`
-	WeightSpeed        int    `json:"weight_speed"`
-	WeightAcceleration int    `json:"weight_acceleration"`
-	WeightBraking      int    `json:"weight_braking"`
-	WeightDistance     int    `json:"weight_distance"`
-	WeightZone         int    `json:"weight_zone"`
-	WeightTime         int    `json:"weight_time"`
-	Alpha              int    `json:"alpha"` // Scalaire pour PAYD
-	Beta               int    `json:"beta"`  // Scalaire pour PHYD
-}
-
-// MonthPrime représente la prime mensuelle associée à un véhicule
-type MonthPrime struct {
-	VehicleID string `json:"vehicleID"` // Identifiant unique du véhicule
-	Month     int    `json:"month"`     // Mois (1-12)
-	Year      int    `json:"year"`      // Année (format YYYY)
-	Prime     int    `json:"month_prime"`
-}
-
-// Prime représente la prime associée à un trajet
-type Prime struct {
-	TripID string `json:"tripID"` // Identifiant unique du trajet
-	Date   string `json:"date"`   // Date du calcul (format ISO 8601 : YYYY-MM-DD)
-	Prime  int    `json:"prime"`  // Valeur de la prime
-}
-
-type EncryptedCalculationResult struct {
-	ResultID    string   `json:"resultID"`
-	PrimeTotale *big.Int `json:"prime_totale"` // Chiffré
-	R           *big.Int `json:"r"`            // Calculé pour décryptage
-	TripID      string   `json:"tripID"`
-}
-
-// Init initialise le contrat
-func (s *SmartContract) Init(stub shim.ChaincodeStubInterface) pb.Response {
-	fmt.Println("Smart Contract Initialized")
-	return shim.Success(nil)
-}
-
-// Invoke redirige les appels vers les fonctions appropriées
-func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
-	fn, args := stub.GetFunctionAndParameters()
-	fmt.Printf("Invoke function: %s\n", fn)
-	switch fn {
-	case "addDecryptor":
-		return s.addDecryptor(stub, args)
-	case "addVerifier":
-		return s.addVerifier(stub, args)
-	case "queryDecryptor":
-		return s.queryDecryptor(stub, args)
-	case "queryVerifier":
-		return s.queryVerifier(stub, args)
-	case "encrypt":
-		return s.TestEncryption(stub, args)
-	case "addCriteriaWeights":
-		return s.addCriteriaWeights(stub, args)
-	case "addEncryptedVehicleData":
-		return s.addEncryptedVehicleData(stub, args)
-	case "addEncryptedTripData":
-		return s.addEncryptedTripData(stub, args)
-	case "addVehicleData":
-		return s.addVehicleData(stub, args)
-	case "addTripData":
-		return s.addTripData(stub, args)
-	case "addMonthPrime":
-		return s.addMonthPrime(stub, args)
-	case "calculateInsurancePremium":
-		return s.CalculateInsurancePremium(stub, args)
-	case "queryVehicleData":
-		return s.queryVehicleData(stub, args)
-	case "addOwnerToVehicleData":
-		return s.addOwnerToVehicleData(stub, args)
-	case "decryptInsurancePremiumAndUpdate":
-		return s.decryptInsurancePremiumAndUpdate(stub, args)
-	case "queryEncryptedCalculationResult":
-		return s.queryEncryptedCalculationResult(stub, args)
-	case "queryTripsByVehicleID":
-		return s.queryTripsByVehicleID(stub, args)
-	case "decryptInsurancePremiumAndUpdateWithoutParams":
-		return s.decryptInsurancePremiumAndUpdateWithoutParams(stub, args)
-	case "TestCalculateAndDecryptInsurancePremium":
-		return s.TestCalculateAndDecryptInsurancePremium(stub, args)
-	case "queryPrime":
-		return s.queryPrime(stub, args)
-	case "queryMonthPrime":
-		return s.queryMonthPrime(stub, args)
-	case "queryTripData":
-		return s.queryTripData(stub, args)
-	case "queryInsuranceContractsByOwner":
-		return s.queryInsuranceContractsByOwner(stub, args)
-	case "addInsuranceContract":
-		return s.addInsuranceContract(stub, args)
-	case "queryInsuranceContract":
-		return s.queryInsuranceContract(stub, args)
-	case "queryOwnerDetails":
-		return s.queryOwnerDetails(stub, args)
-	case "queryCriteriaWeights":
-		return s.queryCriteriaWeights(stub, args)
-	case "queryMultipleOwnerDetails":
-		return s.queryMultipleOwnerDetails(stub, args)
-	case "queryPrimesByVehicleID":
-		return s.queryPrimesByVehicleID(stub, args)
-	case "queryEncryptedCalculationResultsByVehicleID":
-		return s.queryEncryptedCalculationResultsByVehicleID(stub, args)
-	case "queryAllCriteriaWeights":
-		return s.queryAllCriteriaWeights(stub)
-	case "queryAllInsuranceContracts":
-		return s.queryAllInsuranceContracts(stub)
-	case "queryVehiclesByOwner":
-		return s.queryVehiclesByOwner(stub, args)
-	case "deleteVerifier":
-		return s.deleteVerifier(stub, args)
-	case "deleteEncryptedTripData":
-		return s.deleteEncryptedTripData(stub, args)
-	case "removeAgeFromEncryptedVehicleData":
-		return s.removeAgeFromEncryptedVehicleData(stub)
-	default:
-		return shim.Error("Invalid function name. Valid functions: 'addDecryptor', 'addVerifier', 'queryDecryptor', 'queryVerifier', 'encrypt', 'addCriteriaWeights', 'addEncryptedVehicleData', 'addEncryptedTripData', 'addVehicleData', 'addTripData', 'addMonthPrime', 'calculateInsurancePremium'")
-	}
+// SmartContract structure. Chaque méthode exportée est une transaction dont
+// les paramètres et le résultat sont décrits dans les métadonnées générées par
+// fabric-contract-api-go (org.hyperledger.fabric:GetMetadata). Les
+// transactions décodent leurs arguments et délèguent aux paquets métier
+// (crypto, telematics, policy, billing)
+type SmartContract struct {
+	contractapi.Contract
 }
 
-func (s *SmartContract) addInsuranceContract(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Vérification du nombre d'arguments
-	if len(args) != 8 {
-		return shim.Error("Incorrect number of arguments. Expecting 8: ContractID, OwnerID, VehicleID, CriteriaWeightsID, StartMonth, StartYear, EndMonth, EndYear")
-	}
+// DecryptedPrime est le résultat des transactions qui enregistrent la prime
+// déchiffrée d'un trajet ou d'un mois
+type DecryptedPrime struct {
+	DecryptedPrime int `json:"decryptedPrime"`
+}
 
-	// Extraction des arguments
-	contractID := args[0]
-	ownerID := args[1]
-	vehicleID := args[2]
-	criteriaWeightsID := args[3]
-	startMonth := toInt(args[4])
-	startYear := toInt(args[5])
-	endMonth := toInt(args[6])
-	endYear := toInt(args[7])
+// PrimeVerification est le résultat de VerifyPrime
+type PrimeVerification struct {
+	TripID   string `json:"tripID"`
+	ResultID string `json:"resultID"`
+	Prime    int    `json:"prime"`
+	Verified bool   `json:"verified"`
+}
 
-	// Vérifiez si le contrat existe déjà
-	contractKey := "contract_" + contractID
-	existingContract, err := stub.GetState(contractKey)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to check existing contract: %s", err.Error()))
+// GetEvaluateTransactions liste les transactions en lecture seule, que les
+// clients évaluent sur un pair sans les soumettre à l'ordering
+func (s *SmartContract) GetEvaluateTransactions() []string {
+	return []string{
+		"QueryVerifier",
+		"QueryVerifierVersion",
+		"QueryTripFieldOpening",
+		"QueryConfidentialWeights",
+		"QueryFieldSpec",
+		"QueryVehiclesByOwner",
+		"QueryOwnerDetails",
+		"QueryMultipleOwnerDetails",
+		"QueryAllCriteriaWeights",
+		"QueryInsuranceContract",
+		"QueryInsuranceContractsByOwner",
+		"QueryCriteriaWeights",
+		"QueryVehicleData",
+		"QueryTripData",
+		"QueryTripsByVehicleID",
+		"QueryPrimesByVehicleID",
+		"QueryEncryptedCalculationResultsByVehicleID",
+		"QueryEncryptedCalculationResult",
+		"QueryPrime",
+		"QueryMonthPrime",
+		"VerifyPrime",
+		"QueryEncryptedMonthPrime",
+		"QueryAllInsuranceContracts",
 	}
-	if existingContract != nil {
-		return shim.Error("Contract with the given ContractID already exists")
+}
+
+// unknownTransaction répond aux appels d'une fonction absente du contrat
+func unknownTransaction(ctx contractapi.TransactionContextInterface) error {
+	fn, _ := ctx.GetStub().GetFunctionAndParameters()
+	switch fn {
+	case "addDecryptor", "queryDecryptor", "encrypt", "decryptInsurancePremiumAndUpdateWithoutParams", "TestCalculateAndDecryptInsurancePremium":
+		// Les clés privées ne sont plus conservées dans le world state : r′ est
+		// calculé hors chaîne par le propriétaire de la clé
+		return fmt.Errorf("Function '%s' is no longer supported: private keys must stay off-chain, submit r_prime with 'DecryptInsurancePremiumAndUpdate'", fn)
+	default:
+		return fmt.Errorf("Invalid function name '%s'. Valid functions are listed in the contract metadata: 'org.hyperledger.fabric:GetMetadata'", fn)
 	}
+}
 
-	// Création du contrat
-	contract := InsuranceContract{
+func (s *SmartContract) AddInsuranceContract(ctx contractapi.TransactionContextInterface, contractID, ownerID, vehicleID, criteriaWeightsID string, startMonth, startYear, endMonth, endYear int) error {
+	err := newServices(ctx).policy.AddContract(&policy.InsuranceContract{
 		ContractID:        contractID,
 		OwnerID:           ownerID,
 		VehicleID:         vehicleID,
@@ -228,2386 +92,1177 @@ func (s *SmartContract) addInsuranceContract(stub shim.ChaincodeStubInterface, a
 		StartYear:         startYear,
 		EndMonth:          endMonth,
 		EndYear:           endYear,
+	})
+	if err != nil {
+		return err
 	}
 
-	// Sérialisation du contrat en JSON
-	contractBytes, err := json.Marshal(contract)
+	fmt.Printf("InsuranceContract with ContractID %s added successfully\n", contractID)
+	return nil
+}
+
+// AddVerifier ajoute une instance Verifier au réseau. Un exposant S non nul
+// déclare une clé de Damgård–Jurik, dont l'espace des clairs est Z_{N^S}
+func (s *SmartContract) AddVerifier(ctx contractapi.TransactionContextInterface, ownerID, n, nSquare string, exponent int) error {
+	caller, err := clientCaller(ctx)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal InsuranceContract: %s", err.Error()))
+		return err
 	}
 
-	// Stockage du contrat dans le ledger
-	err = stub.PutState(contractKey, contractBytes)
+	err = newServices(ctx).crypto.AddVerifier(caller, ownerID, n, nSquare, exponent)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to store InsuranceContract: %s", err.Error()))
+		return err
 	}
 
-	fmt.Printf("InsuranceContract with ContractID %s added successfully\n", contractID)
-	return shim.Success(nil)
+	fmt.Printf("Verifier for OwnerID %s added successfully\n", ownerID)
+	return nil
 }
 
-// addDecryptor ajoute une instance Decryptor au réseau
-func (s *SmartContract) addDecryptor(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 5 {
-		return shim.Error("Incorrect number of arguments. Expecting 5: OwnerID, P, Q, N, Lambda")
+// AddThresholdVerifier enregistre la clé publique à seuil d'un propriétaire :
+// aucune partie ne détient seule de quoi déchiffrer ses données
+func (s *SmartContract) AddThresholdVerifier(ctx contractapi.TransactionContextInterface, ownerID, n string, threshold, parties int, v string, verificationKeys []string) error {
+	caller, err := clientCaller(ctx)
+	if err != nil {
+		return err
 	}
 
-	decryptor := Decryptor{
-		OwnerID: args[0],
-		P:       args[1],
-		Q:       args[2],
-		N:       args[3],
-		Lambda:  args[4],
+	err = newServices(ctx).crypto.AddThresholdVerifier(caller, ownerID, n, threshold, parties, v, verificationKeys)
+	if err != nil {
+		return err
 	}
 
-	decryptorJSON, err := json.Marshal(decryptor)
+	fmt.Printf("Threshold Verifier (%d-of-%d) for OwnerID %s added successfully\n", threshold, parties, ownerID)
+	return nil
+}
+
+// RotateVerifier enregistre une nouvelle version de la clé d'un propriétaire.
+// La version précédente est conservée sous la clé composite (verifierVersion,
+// OwnerID, KeyVersion) avec le statut "retired" : elle reste utilisable pour
+// vérifier et déchiffrer les résultats déjà calculés, mais plus pour chiffrer
+// ou calculer. Seuls le propriétaire (nom d'enrôlement égal à l'OwnerID) et
+// l'identité qui a enregistré la clé peuvent la renouveler
+func (s *SmartContract) RotateVerifier(ctx contractapi.TransactionContextInterface, ownerID, n, nSquare string, exponent int) (*crypto.Verifier, error) {
+	caller, err := clientCaller(ctx)
 	if err != nil {
-		return shim.Error("Failed to marshal Decryptor to JSON")
+		return nil, err
 	}
 
-	err = stub.PutState("decryptor_"+decryptor.OwnerID, decryptorJSON)
+	verifier, err := newServices(ctx).crypto.RotateVerifier(caller, ownerID, n, nSquare, exponent)
 	if err != nil {
-		return shim.Error("Failed to store Decryptor")
+		return nil, err
 	}
 
-	fmt.Printf("Decryptor for OwnerID %s added successfully\n", decryptor.OwnerID)
-	return shim.Success(nil)
+	fmt.Printf("Verifier for OwnerID %s rotated to key version %d\n", ownerID, verifier.KeyVersion)
+	return verifier, nil
 }
 
-// addVerifier ajoute une instance Verifier au réseau
-func (s *SmartContract) addVerifier(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 3 {
-		return shim.Error("Incorrect number of arguments. Expecting 3: OwnerID, N, NSquare")
+// QueryVerifierVersion récupère une version, active ou retirée, de la clé d'un propriétaire
+func (s *SmartContract) QueryVerifierVersion(ctx contractapi.TransactionContextInterface, ownerID string, version int) (*crypto.Verifier, error) {
+	if version <= 0 {
+		return nil, errors.New("Invalid KeyVersion value. Expecting a positive integer")
 	}
 
-	ownerID := args[0]
+	return newServices(ctx).crypto.VerifierVersion(ownerID, version)
+}
 
-	// Vérifiez si un Verifier existe déjà pour cet OwnerID
-	key := "verifier_" + ownerID
-	existingVerifier, err := stub.GetState(key)
+// ReencryptVehicleData remplace les chiffrés d'un véhicule enregistrés sous une
+// clé retirée par des chiffrés sous la clé active. Chaque nouveau chiffré est
+// accompagné d'une preuve qu'il chiffre la même valeur que l'ancien. Seuls le
+// propriétaire et l'identité qui a enregistré sa clé peuvent rechiffrer
+func (s *SmartContract) ReencryptVehicleData(ctx contractapi.TransactionContextInterface, vehicleID string, ciphertexts map[string]string, proofs map[string]*EqualityProof) error {
+	caller, err := clientCaller(ctx)
 	if err != nil {
-		return shim.Error("Failed to check existing Verifier")
-	}
-
-	if existingVerifier != nil {
-		return shim.Error("Verifier with this OwnerID already exists")
-	}
-
-	verifier := Verifier{
-		OwnerID: ownerID,
-		N:       args[1],
-		NSquare: args[2],
+		return err
 	}
 
-	verifierJSON, err := json.Marshal(verifier)
+	cCiphertexts, equalityProofs, err := parseReencryption(ciphertexts, proofs)
 	if err != nil {
-		return shim.Error("Failed to marshal Verifier to JSON")
+		return err
 	}
 
-	err = stub.PutState(key, verifierJSON)
+	version, err := newServices(ctx).telematics.ReencryptVehicleData(caller, vehicleID, cCiphertexts, equalityProofs)
 	if err != nil {
-		return shim.Error("Failed to store Verifier")
+		return err
 	}
 
-	fmt.Printf("Verifier for OwnerID %s added successfully\n", ownerID)
-	return shim.Success(nil)
+	fmt.Printf("EncryptedVehicleData for VehicleID %s re-encrypted under key version %d\n", vehicleID, version)
+	return nil
 }
 
-func (s *SmartContract) addEncryptedVehicleData(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 5 {
-		return shim.Error("Incorrect number of arguments. Expecting 5: VehicleID, VehicleType, PurchaseMileage, Year, verifierOwnerID")
+// ReencryptTripData remplace les chiffrés d'un trajet enregistrés sous une clé
+// retirée par des chiffrés sous la clé active, preuves d'égalité à l'appui
+func (s *SmartContract) ReencryptTripData(ctx contractapi.TransactionContextInterface, tripID string, ciphertexts map[string]string, proofs map[string]*EqualityProof) error {
+	caller, err := clientCaller(ctx)
+	if err != nil {
+		return err
 	}
 
-	vehicleID := args[0]
-	verifierOwnerID := args[4]
-
-	verifierKey := "verifier_" + verifierOwnerID
-	verifierBytes, err := stub.GetState(verifierKey)
-	if err != nil || verifierBytes == nil {
-		return shim.Error("Verifier not found for the given OwnerID")
+	cCiphertexts, equalityProofs, err := parseReencryption(ciphertexts, proofs)
+	if err != nil {
+		return err
 	}
 
-	// Vérifiez si les données du véhicule existent déjà
-	key := "vehicle_" + vehicleID
-	existingVehicle, err := stub.GetState(key)
+	version, err := newServices(ctx).telematics.ReencryptTripData(caller, tripID, cCiphertexts, equalityProofs)
 	if err != nil {
-		return shim.Error("Failed to check existing vehicle data")
+		return err
 	}
 
-	if existingVehicle != nil {
-		return shim.Error("Vehicle data with this VehicleID already exists")
+	fmt.Printf("EncryptedTripData for TripID %s re-encrypted under key version %d\n", tripID, version)
+	return nil
+}
+
+// parseReencryption décode les chiffrés soumis, indexés par champ, et les
+// preuves d'égalité associées
+func parseReencryption(ciphertexts map[string]string, proofs map[string]*EqualityProof) (map[string]*paillier.Ciphertext, map[string]*paillier.EqualityProof, error) {
+	cCiphertexts, err := parseCiphertexts("re-encrypted", ciphertexts)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	vehicleType, ok := new(big.Int).SetString(args[1], 10)
-	if !ok {
-		return shim.Error("Failed to convert VehicleType to *big.Int")
+	equalityProofs := make(map[string]*paillier.EqualityProof, len(proofs))
+	for field, proof := range proofs {
+		equalityProofs[field], err = proof.proof()
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+	return cCiphertexts, equalityProofs, nil
+}
 
-	purchaseMileage, ok := new(big.Int).SetString(args[2], 10)
-	if !ok {
-		return shim.Error("Failed to convert PurchaseMileage to *big.Int")
+// AddEncryptedVehicleData enregistre les données d'un véhicule chiffrées hors
+// chaîne. proofs peut être vide, mais seul un véhicule dont chaque champ a une
+// preuve d'intervalle peut être tarifé
+func (s *SmartContract) AddEncryptedVehicleData(ctx contractapi.TransactionContextInterface, vehicleID, vehicleType, purchaseMileage, year, verifierOwnerID string, proofs []*FieldProof) error {
+	cVehicleType, err := paillier.ParseCiphertext(vehicleType)
+	if err != nil {
+		return errors.New("Failed to parse VehicleType ciphertext")
 	}
 
-	year, ok := new(big.Int).SetString(args[3], 10)
-	if !ok {
-		return shim.Error("Failed to convert Year to *big.Int")
+	cPurchaseMileage, err := paillier.ParseCiphertext(purchaseMileage)
+	if err != nil {
+		return errors.New("Failed to parse PurchaseMileage ciphertext")
 	}
 
-	encryptedVehicleData := EncryptedVehicleData{
-		VehicleID:       vehicleID,
-		VehicleType:     vehicleType,
-		PurchaseMileage: purchaseMileage,
-		Year:            year,
-		OwnerID:         verifierOwnerID,
+	cYear, err := paillier.ParseCiphertext(year)
+	if err != nil {
+		return errors.New("Failed to parse Year ciphertext")
 	}
 
-	vehicleJSON, err := json.Marshal(encryptedVehicleData)
+	fieldProofs, err := parseFieldProofs(proofs)
 	if err != nil {
-		return shim.Error("Failed to marshal EncryptedVehicleData to JSON")
+		return err
 	}
 
-	err = stub.PutState(key, vehicleJSON)
+	err = newServices(ctx).telematics.AddEncryptedVehicleData(&telematics.EncryptedVehicleData{
+		VehicleID:       vehicleID,
+		VehicleType:     cVehicleType,
+		PurchaseMileage: cPurchaseMileage,
+		Year:            cYear,
+		OwnerID:         verifierOwnerID,
+	}, fieldProofs)
 	if err != nil {
-		return shim.Error("Failed to store EncryptedVehicleData")
+		return err
 	}
 
 	fmt.Printf("EncryptedVehicleData for VehicleID %s added successfully\n", vehicleID)
-	return shim.Success(nil)
+	return nil
 }
 
-func (s *SmartContract) addEncryptedTripData(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 11 {
-		return shim.Error("Incorrect number of arguments. Expecting 11: VehicleID, TripID, Date, Speeding, HardAccelerations, EmergencyBrakes, UnsafeDistance, HighRiskZones, TrafficSignalCompliance, NightDriving, Mileage")
-	}
-
-	vehicleID := args[0]
-	tripID := args[1]
-
-	// Vérifiez si les données du trajet existent déjà
-	key := "trip_" + tripID
-	existingTrip, err := stub.GetState(key)
+// AddEncryptedTripData enregistre les données d'un trajet chiffrées hors
+// chaîne. proofs peut être vide, mais seul un trajet dont chaque champ a une
+// preuve d'intervalle peut être tarifé
+func (s *SmartContract) AddEncryptedTripData(ctx contractapi.TransactionContextInterface, vehicleID, tripID, date, speeding, hardAccelerations, emergencyBrakes, unsafeDistance, highRiskZones, trafficSignalCompliance, nightDriving, mileage string, proofs []*FieldProof) error {
+	cSpeeding, err := paillier.ParseCiphertext(speeding)
 	if err != nil {
-		return shim.Error("Failed to check existing trip data")
+		return errors.New("Failed to parse Speeding ciphertext")
 	}
 
-	if existingTrip != nil {
-		return shim.Error("Trip data with this TripID already exists")
+	cHardAccelerations, err := paillier.ParseCiphertext(hardAccelerations)
+	if err != nil {
+		return errors.New("Failed to parse HardAccelerations ciphertext")
 	}
 
-	speeding, ok := new(big.Int).SetString(args[3], 10)
-	if !ok {
-		return shim.Error("Failed to convert Speeding to *big.Int")
+	cEmergencyBrakes, err := paillier.ParseCiphertext(emergencyBrakes)
+	if err != nil {
+		return errors.New("Failed to parse EmergencyBrakes ciphertext")
 	}
 
-	hardAccelerations, ok := new(big.Int).SetString(args[4], 10)
-	if !ok {
-		return shim.Error("Failed to convert HardAccelerations to *big.Int")
+	cUnsafeDistance, err := paillier.ParseCiphertext(unsafeDistance)
+	if err != nil {
+		return errors.New("Failed to parse UnsafeDistance ciphertext")
 	}
 
-	emergencyBrakes, ok := new(big.Int).SetString(args[5], 10)
-	if !ok {
-		return shim.Error("Failed to convert EmergencyBrakes to *big.Int")
+	cHighRiskZones, err := paillier.ParseCiphertext(highRiskZones)
+	if err != nil {
+		return errors.New("Failed to parse HighRiskZones ciphertext")
 	}
 
-	unsafeDistance, ok := new(big.Int).SetString(args[6], 10)
-	if !ok {
-		return shim.Error("Failed to convert UnsafeDistance to *big.Int")
+	cTrafficSignalCompliance, err := paillier.ParseCiphertext(trafficSignalCompliance)
+	if err != nil {
+		return errors.New("Failed to parse TrafficSignalCompliance ciphertext")
 	}
 
-	highRiskZones, ok := new(big.Int).SetString(args[7], 10)
-	if !ok {
-		return shim.Error("Failed to convert HighRiskZones to *big.Int")
+	cNightDriving, err := paillier.ParseCiphertext(nightDriving)
+	if err != nil {
+		return errors.New("Failed to parse NightDriving ciphertext")
 	}
 
-	trafficSignalCompliance, ok := new(big.Int).SetString(args[8], 10)
-	if !ok {
-		return shim.Error("Failed to convert TrafficSignalCompliance to *big.Int")
+	cMileage, err := paillier.ParseCiphertext(mileage)
+	if err != nil {
+		return errors.New("Failed to parse Mileage ciphertext")
 	}
 
-	nightDriving, ok := new(big.Int).SetString(args[9], 10)
-	if !ok {
-		return shim.Error("Failed to convert NightDriving to *big.Int")
+	fieldProofs, err := parseFieldProofs(proofs)
+	if err != nil {
+		return err
 	}
 
-	mileage, ok := new(big.Int).SetString(args[10], 10)
-	if !ok {
-		return shim.Error("Failed to convert Mileage to *big.Int")
+	// Re-randomisation optionnelle des chiffrés avant stockage (graine
+	// "rerandomize" dans le transient map). Les chiffrés soumis restent dans
+	// le bloc : seule une re-randomisation côté client les rend non reliables
+	seed, err := readRerandomizationSeed(ctx.GetStub())
+	if err != nil {
+		return err
 	}
 
-	encryptedTripData := EncryptedTripData{
+	err = newServices(ctx).telematics.AddEncryptedTripData(&telematics.EncryptedTripData{
 		VehicleID:               vehicleID,
 		TripID:                  tripID,
-		Date:                    args[2],
-		Speeding:                speeding,
-		HardAccelerations:       hardAccelerations,
-		EmergencyBrakes:         emergencyBrakes,
-		UnsafeDistance:          unsafeDistance,
-		HighRiskZones:           highRiskZones,
-		TrafficSignalCompliance: trafficSignalCompliance,
-		NightDriving:            nightDriving,
-		Mileage:                 mileage,
-	}
-
-	tripJSON, err := json.Marshal(encryptedTripData)
-	if err != nil {
-		return shim.Error("Failed to marshal EncryptedTripData to JSON")
-	}
-
-	err = stub.PutState(key, tripJSON)
+		Date:                    date,
+		Speeding:                cSpeeding,
+		HardAccelerations:       cHardAccelerations,
+		EmergencyBrakes:         cEmergencyBrakes,
+		UnsafeDistance:          cUnsafeDistance,
+		HighRiskZones:           cHighRiskZones,
+		TrafficSignalCompliance: cTrafficSignalCompliance,
+		NightDriving:            cNightDriving,
+		Mileage:                 cMileage,
+	}, fieldProofs, seed)
 	if err != nil {
-		return shim.Error("Failed to store EncryptedTripData")
+		return err
 	}
 
 	fmt.Printf("EncryptedTripData for TripID %s added successfully\n", tripID)
-	return shim.Success(nil)
+	return nil
 }
 
-func (s *SmartContract) addVehicleData(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 5 {
-		return shim.Error("Incorrect number of arguments. Expecting 5: VehicleID, VehicleType, PurchaseMileage, Year, VerifierOwnerID")
-	}
-
-	vehicleID := args[0]
-	verifierOwnerID := args[5]
-
-	// Charger l'instance Verifier associée à l'OwnerID
-	verifierKey := "verifier_" + verifierOwnerID
-	verifierBytes, err := stub.GetState(verifierKey)
-	if err != nil || verifierBytes == nil {
-		return shim.Error("Verifier not found for the given OwnerID")
+// AddArbitrator enregistre un arbitre devant lequel les conducteurs peuvent
+// ouvrir les engagements de leurs trajets. Sa clé doit avoir été enregistrée
+// avec AddVerifier sous l'identifiant arbitratorID
+func (s *SmartContract) AddArbitrator(ctx contractapi.TransactionContextInterface, arbitratorID string) error {
+	caller, err := clientCaller(ctx)
+	if err != nil {
+		return err
 	}
 
-	var verifier Verifier
-	err = json.Unmarshal(verifierBytes, &verifier)
+	err = newServices(ctx).telematics.AddArbitrator(caller, arbitratorID)
 	if err != nil {
-		return shim.Error("Failed to unmarshal Verifier")
+		return err
 	}
 
-	// Convertir N en *big.Int
-	n := new(big.Int)
-	n.SetString(verifier.N, 10)
+	fmt.Printf("Arbitrator %s added successfully\n", arbitratorID)
+	return nil
+}
 
-	// Générer r déterministe basé sur des données spécifiques
-	uniqueData := vehicleID + args[1] + args[2] + args[3] + args[4]
-	r := generateDeterministicR(uniqueData, n)
+// OpenTripCommitment ouvre devant un arbitre enregistré l'engagement d'un champ
+// d'un trajet contesté. La valeur engagée et son aléa sont lus dans le
+// transient map ("value" et "opening", en décimal) avec une graine d'aléa
+// ("nonce") : ils n'apparaissent dans le ledger que chiffrés sous la clé de
+// l'arbitre. Seuls le propriétaire du véhicule et l'identité qui a enregistré
+// sa clé peuvent ouvrir ses engagements
+func (s *SmartContract) OpenTripCommitment(ctx contractapi.TransactionContextInterface, tripID, field, arbitratorID string) error {
+	stub := ctx.GetStub()
 
-	vehicleType, ok := new(big.Int).SetString(args[1], 10)
-	if !ok {
-		return shim.Error("Failed to convert VehicleType to *big.Int")
-	}
-	encryptedVehicleType, err := verifier.EncryptWithCustomRandom(vehicleType, r)
+	caller, err := clientCaller(ctx)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt VehicleType: %s", err))
+		return err
 	}
 
-	purchaseMileage, ok := new(big.Int).SetString(args[2], 10)
-	if !ok {
-		return shim.Error("Failed to convert PurchaseMileage to *big.Int")
-	}
-	encryptedPurchaseMileage, err := verifier.EncryptWithCustomRandom(purchaseMileage, r)
+	value, opening, nonce, err := readTransientOpening(stub)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt PurchaseMileage: %s", err))
+		return err
 	}
 
-	year, ok := new(big.Int).SetString(args[3], 10)
-	if !ok {
-		return shim.Error("Failed to convert Year to *big.Int")
-	}
-	encryptedYear, err := verifier.EncryptWithCustomRandom(year, r)
+	err = newServices(ctx).telematics.OpenTripCommitment(caller, tripID, field, arbitratorID, value, opening, nonce, stub.GetTxID())
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt Year: %s", err))
+		return err
 	}
 
-	// Créer l'actif EncryptedVehicleData
-	encryptedVehicleData := EncryptedVehicleData{
-		VehicleID:       vehicleID,
-		VehicleType:     encryptedVehicleType,
-		PurchaseMileage: encryptedPurchaseMileage,
-		Year:            encryptedYear,
-		OwnerID:         verifierOwnerID,
-	}
+	fmt.Printf("Commitment of field %s for TripID %s opened to arbitrator %s\n", field, tripID, arbitratorID)
+	return nil
+}
 
-	vehicleJSON, err := json.Marshal(encryptedVehicleData)
+// QueryTripFieldOpening récupère l'attestation d'ouverture d'un champ d'un
+// trajet devant un arbitre
+func (s *SmartContract) QueryTripFieldOpening(ctx contractapi.TransactionContextInterface, tripID, field, arbitratorID string) (*TripFieldOpening, error) {
+	opening, err := newServices(ctx).telematics.Openings.Get(tripID, field, arbitratorID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal EncryptedVehicleData: %s", err))
+		return nil, err
 	}
-
-	// Enregistrer l'actif dans le ledger
-	key := "vehicle_" + vehicleID
-	err = stub.PutState(key, vehicleJSON)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to store EncryptedVehicleData: %s", err))
+	if opening == nil {
+		return nil, errors.New("TripFieldOpening not found for the given trip, field and arbitrator")
 	}
 
-	fmt.Printf("EncryptedVehicleData for VehicleID %s added successfully\n", vehicleID)
-	return shim.Success(nil)
+	return newTripFieldOpening(opening), nil
 }
 
-func (s *SmartContract) addTripData(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 12 {
-		return shim.Error("Incorrect number of arguments. Expecting 12: VehicleID, TripID, Date, Speeding, HardAccelerations, EmergencyBrakes, UnsafeDistance, HighRiskZones, TrafficSignalCompliance, NightDriving, Mileage, VerifierOwnerID")
-	}
-
-	const scaleFactor = 1 // Échelle pour convertir les décimales en entiers
-	vehicleID := args[0]
-	tripID := args[1]
-	date := args[2]
-	verifierOwnerID := args[11]
-
-	// Charger l'instance Verifier associée à l'OwnerID
-	verifierKey := "verifier_" + verifierOwnerID
-	verifierBytes, err := stub.GetState(verifierKey)
-	if err != nil || verifierBytes == nil {
-		return shim.Error("Verifier not found for the given OwnerID")
-	}
-
-	var verifier Verifier
-	err = json.Unmarshal(verifierBytes, &verifier)
+// readTransientOpening lit dans le transient map la valeur engagée et l'aléa
+// d'un engagement de Pedersen, ainsi que la graine d'aléa de leur chiffrement
+func readTransientOpening(stub shim.ChaincodeStubInterface) (*big.Int, *big.Int, []byte, error) {
+	transient, err := stub.GetTransient()
 	if err != nil {
-		return shim.Error("Failed to unmarshal Verifier")
+		return nil, nil, nil, fmt.Errorf("Failed to read transient map: %s", err)
 	}
 
-	// Convertir N en *big.Int
-	n := new(big.Int)
-	n.SetString(verifier.N, 10)
-
-	// Générer r déterministe basé sur des données spécifiques
-	uniqueData := vehicleID + tripID + date
-	r := generateDeterministicR(uniqueData, n)
-
-	// Convertir et chiffrer les données
-	toBigInt := func(value string) (*big.Int, error) {
-		// Convertir la valeur en flottant
-		floatValue, err := strconv.ParseFloat(value, 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse value '%s': %s", value, err)
+	values := make([]*big.Int, 2)
+	for i, name := range []string{"value", "opening"} {
+		valueBytes, ok := transient[name]
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("Transient map must contain '%s'", name)
+		}
+		values[i], ok = new(big.Int).SetString(string(valueBytes), 10)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("Failed to parse transient '%s' as a decimal integer", name)
 		}
-
-		// Appliquer le facteur d'échelle
-		scaledValue := int64(floatValue * scaleFactor)
-
-		// Convertir en *big.Int
-		return new(big.Int).SetInt64(scaledValue), nil
 	}
 
-	speeding, err := toBigInt(args[3])
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert Speeding: %s", err))
-	}
-	encryptedSpeeding, err := verifier.EncryptWithCustomRandom(speeding, r)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt Speeding: %s", err))
+	nonce, ok := transient["nonce"]
+	if !ok || len(nonce) < paillier.MinSeedSize {
+		return nil, nil, nil, fmt.Errorf("Transient map must contain a 'nonce' of at least %d random bytes", paillier.MinSeedSize)
 	}
+	return values[0], values[1], nonce, nil
+}
 
-	hardAccelerations, err := toBigInt(args[4])
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert HardAccelerations: %s", err))
-	}
-	encryptedHardAccelerations, err := verifier.EncryptWithCustomRandom(hardAccelerations, r)
+// AddPackedTripData ajoute un trajet dont les métriques ont été packées et
+// chiffrées hors chaîne, dans l'ordre de telematics.TripFields, dans un seul
+// chiffré. slots contient le chiffré de chaque métrique, indexé par champ, dont
+// le chiffré packé doit être le packing homomorphe, et proofs une preuve
+// d'intervalle par métrique
+func (s *SmartContract) AddPackedTripData(ctx contractapi.TransactionContextInterface, vehicleID, tripID, date, packed string, slotBits int, slots map[string]string, proofs []*FieldProof) error {
+	cPacked, err := paillier.ParseCiphertext(packed)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt HardAccelerations: %s", err))
+		return errors.New("Failed to parse Packed ciphertext")
 	}
 
-	emergencyBrakes, err := toBigInt(args[5])
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert EmergencyBrakes: %s", err))
-	}
-	encryptedEmergencyBrakes, err := verifier.EncryptWithCustomRandom(emergencyBrakes, r)
+	cSlots, err := parseCiphertexts("slot", slots)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt EmergencyBrakes: %s", err))
+		return err
 	}
 
-	unsafeDistance, err := toBigInt(args[6])
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert UnsafeDistance: %s", err))
-	}
-	encryptedUnsafeDistance, err := verifier.EncryptWithCustomRandom(unsafeDistance, r)
+	fieldProofs, err := parseFieldProofs(proofs)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt UnsafeDistance: %s", err))
+		return err
 	}
 
-	highRiskZones, err := toBigInt(args[7])
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert HighRiskZones: %s", err))
-	}
-	encryptedHighRiskZones, err := verifier.EncryptWithCustomRandom(highRiskZones, r)
+	err = newServices(ctx).telematics.AddPackedTripData(&telematics.EncryptedTripData{
+		VehicleID: vehicleID,
+		TripID:    tripID,
+		Date:      date,
+		Packed:    cPacked,
+		SlotBits:  slotBits,
+	}, cSlots, fieldProofs)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt HighRiskZones: %s", err))
+		return err
 	}
 
-	trafficSignalCompliance, err := toBigInt(args[8])
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert TrafficSignalCompliance: %s", err))
-	}
-	encryptedTrafficSignalCompliance, err := verifier.EncryptWithCustomRandom(trafficSignalCompliance, r)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt TrafficSignalCompliance: %s", err))
-	}
+	fmt.Printf("Packed EncryptedTripData for TripID %s added successfully\n", tripID)
+	return nil
+}
 
-	nightDriving, err := toBigInt(args[9])
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert NightDriving: %s", err))
-	}
-	encryptedNightDriving, err := verifier.EncryptWithCustomRandom(nightDriving, r)
+// AddVehicleData chiffre sur la chaîne les données d'un véhicule. Les clairs et
+// la graine d'aléa sont transmis dans le transient map (clés "vehicle" et
+// "nonce") : ils ne sont jamais écrits dans le ledger, et chaque champ est
+// chiffré avec un aléa distinct dérivé de la graine
+func (s *SmartContract) AddVehicleData(ctx contractapi.TransactionContextInterface, vehicleID, verifierOwnerID string) error {
+	values, nonce, err := readTransientPlaintexts(ctx.GetStub(), "vehicle")
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt NightDriving: %s", err))
+		return err
 	}
 
-	mileage, err := toBigInt(args[10])
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert Mileage: %s", err))
-	}
-	encryptedMileage, err := verifier.EncryptWithCustomRandom(mileage, r)
+	err = newServices(ctx).telematics.AddVehicleData(vehicleID, verifierOwnerID, values, nonce)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt Mileage: %s", err))
+		return err
 	}
 
-	// Créer l'actif EncryptedTripData
-	encryptedTripData := EncryptedTripData{
-		VehicleID:               vehicleID,
-		TripID:                  tripID,
-		Date:                    date,
-		Speeding:                encryptedSpeeding,
-		HardAccelerations:       encryptedHardAccelerations,
-		EmergencyBrakes:         encryptedEmergencyBrakes,
-		UnsafeDistance:          encryptedUnsafeDistance,
-		HighRiskZones:           encryptedHighRiskZones,
-		TrafficSignalCompliance: encryptedTrafficSignalCompliance,
-		NightDriving:            encryptedNightDriving,
-		Mileage:                 encryptedMileage,
-	}
+	fmt.Printf("EncryptedVehicleData for VehicleID %s added successfully\n", vehicleID)
+	return nil
+}
 
-	tripJSON, err := json.Marshal(encryptedTripData)
+// AddTripData chiffre sur la chaîne les données d'un trajet. Les clairs et la
+// graine d'aléa sont transmis dans le transient map (clés "trip" et "nonce").
+// Avec un SlotBits non nul, les métriques sont packées dans un seul chiffré
+func (s *SmartContract) AddTripData(ctx contractapi.TransactionContextInterface, vehicleID, tripID, date, verifierOwnerID string, slotBits int) error {
+	values, nonce, err := readTransientPlaintexts(ctx.GetStub(), "trip")
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal EncryptedTripData to JSON: %s", err))
+		return err
 	}
 
-	// Enregistrer l'actif dans le ledger
-	key := "trip_" + tripID
-	err = stub.PutState(key, tripJSON)
+	err = newServices(ctx).telematics.AddTripData(vehicleID, tripID, date, verifierOwnerID, values, nonce, slotBits)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to store EncryptedTripData: %s", err))
+		return err
 	}
 
 	fmt.Printf("EncryptedTripData for TripID %s added successfully\n", tripID)
-	return shim.Success(nil)
+	return nil
 }
 
-// addCriteriaWeights ajoute une instance CriteriaWeights au réseau
-func (s *SmartContract) addCriteriaWeights(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 10 {
-		return shim.Error("Incorrect number of arguments. Expecting 10: CriteriaWeightsID, WeightTraffic, WeightSpeed, WeightAcceleration, WeightBraking, WeightDistance, WeightZone, WeightTime, Alpha, Beta")
-	}
-
-	criteriaWeightsID := args[0]
-
-	// Vérifiez si les poids des critères existent déjà
-	key := "criteriaweights_" + criteriaWeightsID
-	existingWeights, err := stub.GetState(key)
+// readTransientPlaintexts lit dans le transient map les clairs à chiffrer
+// (objet JSON champ -> valeur) et la graine d'aléa associée
+func readTransientPlaintexts(stub shim.ChaincodeStubInterface, name string) (map[string]string, []byte, error) {
+	transient, err := stub.GetTransient()
 	if err != nil {
-		return shim.Error("Failed to check existing criteria weights")
-	}
-
-	if existingWeights != nil {
-		return shim.Error("Criteria weights with this ID already exists")
+		return nil, nil, fmt.Errorf("Failed to read transient map: %s", err)
 	}
 
-	criteriaWeights := CriteriaWeights{
-		CriteriaWeightsID:  criteriaWeightsID,
-		WeightTraffic:      toInt(args[1]),
-		WeightSpeed:        toInt(args[2]),
-		WeightAcceleration: toInt(args[3]),
-		WeightBraking:      toInt(args[4]),
-		WeightDistance:     toInt(args[5]),
-		WeightZone:         toInt(args[6]),
-		WeightTime:         toInt(args[7]),
-		Alpha:              toInt(args[8]),
-		Beta:               toInt(args[9]),
+	valuesBytes, ok := transient[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("Transient map must contain '%s'", name)
 	}
 
-	weightsJSON, err := json.Marshal(criteriaWeights)
+	var values map[string]string
+	err = json.Unmarshal(valuesBytes, &values)
 	if err != nil {
-		return shim.Error("Failed to marshal CriteriaWeights to JSON")
+		return nil, nil, fmt.Errorf("Failed to unmarshal transient '%s': %s", name, err)
 	}
 
-	err = stub.PutState(key, weightsJSON)
-	if err != nil {
-		return shim.Error("Failed to store CriteriaWeights")
+	nonce, ok := transient["nonce"]
+	if !ok || len(nonce) < paillier.MinSeedSize {
+		return nil, nil, fmt.Errorf("Transient map must contain a 'nonce' of at least %d random bytes", paillier.MinSeedSize)
 	}
 
-	fmt.Printf("CriteriaWeights for ID %s added successfully\n", criteriaWeightsID)
-	return shim.Success(nil)
+	return values, nonce, nil
 }
 
-// addMonthPrime ajoute une instance MonthPrime au réseau
-func (s *SmartContract) addMonthPrime(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 4 {
-		return shim.Error("Incorrect number of arguments. Expecting 4: VehicleID, Month, Year, Prime")
-	}
-
-	vehicleID := args[0]
-	month := toInt(args[1])
-	year := toInt(args[2])
-	prime := toInt(args[3])
-
-	// Vérifiez si la prime mensuelle existe déjà
-	key := fmt.Sprintf("monthprime_%s_%d_%d", vehicleID, month, year)
-	existingPrime, err := stub.GetState(key)
+// readRerandomizationSeed lit la graine optionnelle "rerandomize" du transient
+// map. Retourne nil si l'appelant n'a pas demandé de re-randomisation
+func readRerandomizationSeed(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	transient, err := stub.GetTransient()
 	if err != nil {
-		return shim.Error("Failed to check existing monthly prime")
+		return nil, fmt.Errorf("Failed to read transient map: %s", err)
 	}
 
-	if existingPrime != nil {
-		return shim.Error("MonthPrime for this VehicleID, Month, and Year already exists")
+	seed, ok := transient["rerandomize"]
+	if !ok {
+		return nil, nil
 	}
+	if len(seed) < paillier.MinSeedSize {
+		return nil, fmt.Errorf("Transient 'rerandomize' seed must contain at least %d random bytes", paillier.MinSeedSize)
+	}
+	return seed, nil
+}
 
-	monthPrime := MonthPrime{
-		VehicleID: vehicleID,
-		Month:     month,
-		Year:      year,
-		Prime:     prime,
+// AddCriteriaWeights ajoute une instance CriteriaWeights au réseau
+func (s *SmartContract) AddCriteriaWeights(ctx contractapi.TransactionContextInterface, criteriaWeightsID string, weightTraffic, weightSpeed, weightAcceleration, weightBraking, weightDistance, weightZone, weightTime, alpha, beta int) error {
+	err := newServices(ctx).policy.AddCriteriaWeights(&policy.CriteriaWeights{
+		CriteriaWeightsID:  criteriaWeightsID,
+		WeightTraffic:      weightTraffic,
+		WeightSpeed:        weightSpeed,
+		WeightAcceleration: weightAcceleration,
+		WeightBraking:      weightBraking,
+		WeightDistance:     weightDistance,
+		WeightZone:         weightZone,
+		WeightTime:         weightTime,
+		Alpha:              alpha,
+		Beta:               beta,
+	})
+	if err != nil {
+		return err
 	}
 
-	primeJSON, err := json.Marshal(monthPrime)
+	fmt.Printf("CriteriaWeights for ID %s added successfully\n", criteriaWeightsID)
+	return nil
+}
+
+// AddConfidentialWeights enregistre les engagements de Pedersen d'un assureur
+// sur les coefficients de sa tarification, un par champ du trajet
+func (s *SmartContract) AddConfidentialWeights(ctx contractapi.TransactionContextInterface, weightsID, insurerID string, commitments map[string]string) error {
+	cCommitments, err := parseCommitments(commitments)
 	if err != nil {
-		return shim.Error("Failed to marshal MonthPrime to JSON")
+		return err
 	}
 
-	err = stub.PutState(key, primeJSON)
+	err = newServices(ctx).policy.AddConfidentialWeights(&policy.ConfidentialWeights{
+		WeightsID:   weightsID,
+		InsurerID:   insurerID,
+		Commitments: cCommitments,
+	})
 	if err != nil {
-		return shim.Error("Failed to store MonthPrime")
+		return err
 	}
 
-	fmt.Printf("MonthPrime for VehicleID %s, Month %d, Year %d added successfully\n", vehicleID, month, year)
-	return shim.Success(nil)
+	fmt.Printf("ConfidentialWeights for ID %s added successfully\n", weightsID)
+	return nil
 }
 
-func (s *SmartContract) addOwnerToVehicleData(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 2 {
-		return shim.Error("Incorrect number of arguments. Expecting 2: VehicleID, OwnerID")
+// QueryConfidentialWeights récupère les engagements des poids d'un assureur
+func (s *SmartContract) QueryConfidentialWeights(ctx contractapi.TransactionContextInterface, weightsID string) (*ConfidentialWeights, error) {
+	weights, err := newServices(ctx).policy.ConfidentialWeights.Get(weightsID)
+	if err != nil {
+		return nil, err
+	}
+	if weights == nil {
+		return nil, errors.New("ConfidentialWeights not found for the given ID")
 	}
 
-	vehicleID := args[0]
-	ownerID := args[1]
-
-	// Construire la clé pour récupérer l'actif VehicleData
-	vehicleKey := "vehicle_" + vehicleID
+	return newConfidentialWeights(weights), nil
+}
 
-	// Récupérer les données chiffrées du véhicule
-	vehicleBytes, err := stub.GetState(vehicleKey)
-	if err != nil || vehicleBytes == nil {
-		return shim.Error("Vehicle data not found for the given VehicleID")
+// AddFieldSpec déclare les bornes et l'échelle de virgule fixe d'un champ
+// télématique chiffré. Réservée à l'assureur
+func (s *SmartContract) AddFieldSpec(ctx contractapi.TransactionContextInterface, field string, min, max, scale int64) error {
+	caller, err := clientCaller(ctx)
+	if err != nil {
+		return err
 	}
 
-	// Désérialiser les données existantes
-	var encryptedVehicleData EncryptedVehicleData
-	err = json.Unmarshal(vehicleBytes, &encryptedVehicleData)
+	err = newServices(ctx).telematics.AddFieldSpec(caller, field, min, max, scale)
 	if err != nil {
-		return shim.Error("Failed to unmarshal EncryptedVehicleData")
+		return err
 	}
 
-	// Ajouter ou mettre à jour l'OwnerID
-	encryptedVehicleData.OwnerID = ownerID
+	fmt.Printf("FieldSpec for field %s added successfully\n", field)
+	return nil
+}
 
-	// Sérialiser les données mises à jour
-	updatedVehicleBytes, err := json.Marshal(encryptedVehicleData)
+// QueryFieldSpec récupère les bornes déclarées d'un champ chiffré
+func (s *SmartContract) QueryFieldSpec(ctx contractapi.TransactionContextInterface, field string) (*telematics.FieldSpec, error) {
+	fieldSpec, err := newServices(ctx).telematics.FieldSpecs.Get(field)
 	if err != nil {
-		return shim.Error("Failed to marshal updated EncryptedVehicleData")
+		return nil, err
 	}
+	if fieldSpec == nil {
+		return nil, errors.New("FieldSpec not found for the given field")
+	}
+	return fieldSpec, nil
+}
 
-	// Enregistrer les données mises à jour dans le ledger
-	err = stub.PutState(vehicleKey, updatedVehicleBytes)
+// AddMonthPrime ajoute une instance MonthPrime au réseau
+func (s *SmartContract) AddMonthPrime(ctx contractapi.TransactionContextInterface, vehicleID string, month, year, prime int) error {
+	err := newServices(ctx).billing.AddMonthPrime(&billing.MonthPrime{
+		VehicleID: vehicleID,
+		Month:     month,
+		Year:      year,
+		Prime:     prime,
+	})
 	if err != nil {
-		return shim.Error("Failed to update EncryptedVehicleData")
+		return err
 	}
 
-	fmt.Printf("OwnerID '%s' added/updated for VehicleID '%s'\n", ownerID, vehicleID)
-	return shim.Success(nil)
+	fmt.Printf("MonthPrime for VehicleID %s, Month %d, Year %d added successfully\n", vehicleID, month, year)
+	return nil
 }
 
-func (s *SmartContract) queryVehiclesByOwner(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Vérification du nombre d'arguments
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: OwnerID")
+func (s *SmartContract) AddOwnerToVehicleData(ctx contractapi.TransactionContextInterface, vehicleID, ownerID string) error {
+	err := newServices(ctx).telematics.SetVehicleOwner(vehicleID, ownerID)
+	if err != nil {
+		return err
 	}
 
-	ownerID := args[0]
-
-	// Création de la requête pour récupérer les véhicules associés au OwnerID
-	vehicleQueryString := fmt.Sprintf(`{"selector":{"ownerID":"%s"}}`, ownerID)
+	fmt.Printf("OwnerID '%s' added/updated for VehicleID '%s'\n", ownerID, vehicleID)
+	return nil
+}
 
-	// Exécution de la requête
-	vehicleResultsIterator, err := stub.GetQueryResult(vehicleQueryString)
+func (s *SmartContract) QueryVehiclesByOwner(ctx contractapi.TransactionContextInterface, ownerID string) ([]*OwnerVehicle, error) {
+	vehicleData, err := newServices(ctx).telematics.Vehicles.ByOwner(ownerID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query vehicles by OwnerID: %s", err.Error()))
+		return nil, err
 	}
-	defer vehicleResultsIterator.Close()
 
-	// Liste pour stocker les véhicules récupérés
-	var vehicles []map[string]interface{}
-
-	for vehicleResultsIterator.HasNext() {
-		vehicleResponse, err := vehicleResultsIterator.Next()
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to iterate over vehicles: %s", err.Error()))
-		}
-
-		var vehicleData EncryptedVehicleData
-		err = json.Unmarshal(vehicleResponse.Value, &vehicleData)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal vehicle data: %s", err.Error()))
-		}
-
-		// Ajouter les détails du véhicule à la liste
-		vehicles = append(vehicles, map[string]interface{}{
-			"VehicleID":      vehicleData.VehicleID,
-			"VehicleDetails": vehicleData,
+	vehicles := []*OwnerVehicle{}
+	for i := range vehicleData {
+		vehicles = append(vehicles, &OwnerVehicle{
+			VehicleID:      vehicleData[i].VehicleID,
+			VehicleDetails: newVehicleData(&vehicleData[i]),
 		})
 	}
-
-	// Sérialisation des résultats en JSON
-	responseJSON, err := json.Marshal(vehicles)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal response: %s", err.Error()))
-	}
-
-	// Retourner les véhicules
-	return shim.Success(responseJSON)
+	return vehicles, nil
 }
 
-func (s *SmartContract) queryOwnerDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: OwnerID")
-	}
-
-	ownerID := args[0]
+func (s *SmartContract) QueryOwnerDetails(ctx contractapi.TransactionContextInterface, ownerID string) (*OwnerDetails, error) {
+	svc := newServices(ctx)
 
 	// Récupérer les véhicules appartenant au propriétaire
-	vehicleQueryString := fmt.Sprintf(`{"selector":{"ownerID":"%s", "contractID":{"$exists":false}}}`, ownerID)
-	vehicleResultsIterator, err := stub.GetQueryResult(vehicleQueryString)
+	vehicleData, err := svc.telematics.Vehicles.ByOwner(ownerID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query vehicles by OwnerID: %s", err.Error()))
+		return nil, err
 	}
-	defer vehicleResultsIterator.Close()
 
-	var vehicles []map[string]interface{}
+	details := &OwnerDetails{Vehicles: []*OwnerVehicle{}, Primes: []*PrimeSummary{}}
 	vehicleSet := make(map[string]bool) // Pour éviter les doublons de véhicules
 	var vehicleIDs []string
 
-	for vehicleResultsIterator.HasNext() {
-		vehicleResponse, err := vehicleResultsIterator.Next()
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to iterate over vehicles: %s", err.Error()))
-		}
-
-		var vehicleData EncryptedVehicleData
-		err = json.Unmarshal(vehicleResponse.Value, &vehicleData)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal vehicle data: %s", err.Error()))
-		}
-
-		if vehicleData.VehicleID == "" || vehicleData.OwnerID != ownerID {
+	for i := range vehicleData {
+		vehicle := &vehicleData[i]
+		if vehicleSet[vehicle.VehicleID] {
 			continue
 		}
-
-		// Ajouter seulement si le véhicule n'est pas déjà traité
-		if vehicleSet[vehicleData.VehicleID] {
-			continue
-		}
-		vehicleSet[vehicleData.VehicleID] = true
-		vehicleIDs = append(vehicleIDs, vehicleData.VehicleID)
+		vehicleSet[vehicle.VehicleID] = true
+		vehicleIDs = append(vehicleIDs, vehicle.VehicleID)
 
 		// Récupérer les contrats liés au véhicule
-		contractQueryString := fmt.Sprintf(`{"selector":{"ownerID":"%s","vehicleID":"%s"}}`, ownerID, vehicleData.VehicleID)
-		contractResultsIterator, err := stub.GetQueryResult(contractQueryString)
+		contractData, err := svc.policy.Contracts.ByOwnerAndVehicle(ownerID, vehicle.VehicleID)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to query contracts for vehicle %s: %s", vehicleData.VehicleID, err.Error()))
+			return nil, err
 		}
 
-		var contracts []map[string]interface{}
-		for contractResultsIterator.HasNext() {
-			contractResponse, err := contractResultsIterator.Next()
-			if err != nil {
-				return shim.Error(fmt.Sprintf("Failed to iterate over contracts for vehicle %s: %s", vehicleData.VehicleID, err.Error()))
-			}
-
-			var contractData InsuranceContract
-			err = json.Unmarshal(contractResponse.Value, &contractData)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("Failed to unmarshal contract data: %s", err.Error()))
-			}
-			if contractData.ContractID == "" {
-				continue
+		contracts := []*ContractSummary{}
+		for _, contract := range contractData {
+			summary := &ContractSummary{
+				ContractID:        contract.ContractID,
+				StartDate:         fmt.Sprintf("%02d-%04d", contract.StartMonth, contract.StartYear),
+				EndDate:           fmt.Sprintf("%02d-%04d", contract.EndMonth, contract.EndYear),
+				CriteriaWeightsID: contract.CriteriaWeightsID,
 			}
 
 			// Récupérer les détails des critères de pondération (CriteriaWeights)
-			var criteriaDetails map[string]interface{}
-			if contractData.CriteriaWeightsID != "" {
-				criteriaKey := "criteriaweights_" + contractData.CriteriaWeightsID
-				criteriaBytes, err := stub.GetState(criteriaKey)
-				if err == nil && criteriaBytes != nil {
-					var criteria CriteriaWeights
-					err = json.Unmarshal(criteriaBytes, &criteria)
-					if err == nil {
-						criteriaDetails = map[string]interface{}{
-							"WeightTraffic":      criteria.WeightTraffic,
-							"WeightSpeed":        criteria.WeightSpeed,
-							"WeightAcceleration": criteria.WeightAcceleration,
-							"WeightBraking":      criteria.WeightBraking,
-							"WeightDistance":     criteria.WeightDistance,
-							"WeightZone":         criteria.WeightZone,
-							"WeightTime":         criteria.WeightTime,
-							"Alpha":              criteria.Alpha,
-							"Beta":               criteria.Beta,
-						}
+			if contract.CriteriaWeightsID != "" {
+				criteria, err := svc.policy.CriteriaWeights.Get(contract.CriteriaWeightsID)
+				if err == nil && criteria != nil {
+					summary.CriteriaWeights = &CriteriaWeightsSummary{
+						WeightTraffic:      criteria.WeightTraffic,
+						WeightSpeed:        criteria.WeightSpeed,
+						WeightAcceleration: criteria.WeightAcceleration,
+						WeightBraking:      criteria.WeightBraking,
+						WeightDistance:     criteria.WeightDistance,
+						WeightZone:         criteria.WeightZone,
+						WeightTime:         criteria.WeightTime,
+						Alpha:              criteria.Alpha,
+						Beta:               criteria.Beta,
 					}
 				}
 			}
 
-			contracts = append(contracts, map[string]interface{}{
-				"ContractID":        contractData.ContractID,
-				"StartDate":         fmt.Sprintf("%02d-%04d", contractData.StartMonth, contractData.StartYear),
-				"EndDate":           fmt.Sprintf("%02d-%04d", contractData.EndMonth, contractData.EndYear),
-				"CriteriaWeightsID": contractData.CriteriaWeightsID,
-				"CriteriaWeights":   criteriaDetails,
-			})
+			contracts = append(contracts, summary)
 		}
-		contractResultsIterator.Close()
 
-		vehicles = append(vehicles, map[string]interface{}{
-			"VehicleID":      vehicleData.VehicleID,
-			"VehicleDetails": vehicleData,
-			"Contracts":      contracts,
+		details.Vehicles = append(details.Vehicles, &OwnerVehicle{
+			VehicleID:      vehicle.VehicleID,
+			VehicleDetails: newVehicleData(vehicle),
+			Contracts:      contracts,
 		})
 	}
 
 	// Récupérer les primes associées aux trajets des véhicules du propriétaire
-	var primes []map[string]interface{}
 	primeSet := make(map[string]bool) // Pour éviter les doublons de primes
 
 	for _, vehicleID := range vehicleIDs {
-		tripQueryString := fmt.Sprintf(`{"selector":{"vehicleID":"%s"}}`, vehicleID)
-		tripResultsIterator, err := stub.GetQueryResult(tripQueryString)
+		primeData, err := svc.billing.PrimesByVehicle(vehicleID)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to query trips for vehicle %s: %s", vehicleID, err.Error()))
+			return nil, err
 		}
 
-		for tripResultsIterator.HasNext() {
-			tripResponse, err := tripResultsIterator.Next()
-			if err != nil {
-				return shim.Error(fmt.Sprintf("Failed to iterate over trips for vehicle %s: %s", vehicleID, err.Error()))
-			}
-
-			var tripData EncryptedTripData
-			err = json.Unmarshal(tripResponse.Value, &tripData)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("Failed to unmarshal trip data: %s", err.Error()))
-			}
-
-			if tripData.TripID == "" || tripData.VehicleID != vehicleID {
+		for _, prime := range primeData {
+			if primeSet[prime.TripID] {
 				continue
 			}
+			primeSet[prime.TripID] = true
 
-			primeKey := "prime_" + tripData.TripID
-			if primeSet[primeKey] {
-				continue
-			}
-
-			primeBytes, err := stub.GetState(primeKey)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("Failed to get prime for TripID %s: %s", tripData.TripID, err.Error()))
-			}
-
-			if primeBytes != nil {
-				var primeData Prime
-				err = json.Unmarshal(primeBytes, &primeData)
-				if err != nil {
-					return shim.Error(fmt.Sprintf("Failed to unmarshal prime data: %s", err.Error()))
-				}
-
-				primeSet[primeKey] = true
-				primes = append(primes, map[string]interface{}{
-					"Date":   primeData.Date,
-					"Prime":  primeData.Prime,
-					"TripID": primeData.TripID,
-				})
-			}
+			details.Primes = append(details.Primes, &PrimeSummary{
+				Date:   prime.Date,
+				Prime:  prime.Prime,
+				TripID: prime.TripID,
+			})
 		}
-		tripResultsIterator.Close()
-	}
-
-	// Construire la réponse finale
-	response := map[string]interface{}{
-		"Vehicles": vehicles,
-		"Primes":   primes,
-	}
-
-	responseJSON, err := json.Marshal(response)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal response: %s", err.Error()))
 	}
 
-	return shim.Success(responseJSON)
+	return details, nil
 }
 
-func (s *SmartContract) queryMultipleOwnerDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) == 0 {
-		return shim.Error("Expecting at least one OwnerID as argument")
+func (s *SmartContract) QueryMultipleOwnerDetails(ctx contractapi.TransactionContextInterface, ownerIDs []string) ([]*OwnerDetails, error) {
+	if len(ownerIDs) == 0 {
+		return nil, errors.New("Expecting at least one OwnerID as argument")
 	}
 
 	// Liste pour stocker les détails de tous les propriétaires
-	var allOwnerDetails []map[string]interface{}
+	allOwnerDetails := []*OwnerDetails{}
 
-	for _, ownerID := range args {
+	for _, ownerID := range ownerIDs {
 		if ownerID == "" {
 			continue
 		}
 
-		// Appeler queryOwnerDetails pour chaque OwnerID
-		response := s.queryOwnerDetails(stub, []string{ownerID})
-		if response.Status != shim.OK {
-			return shim.Error(fmt.Sprintf("Failed to query details for owner %s: %s", ownerID, response.Message))
-		}
-
-		// Ajouter les détails à la liste
-		var ownerDetails map[string]interface{}
-		err := json.Unmarshal(response.Payload, &ownerDetails)
+		// Appeler QueryOwnerDetails pour chaque OwnerID
+		ownerDetails, err := s.QueryOwnerDetails(ctx, ownerID)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal owner details for %s: %s", ownerID, err.Error()))
+			return nil, fmt.Errorf("Failed to query details for owner %s: %s", ownerID, err.Error())
 		}
 
 		allOwnerDetails = append(allOwnerDetails, ownerDetails)
 	}
 
 	// Retourner tous les détails en une seule réponse
-	allOwnerDetailsJSON, err := json.Marshal(allOwnerDetails)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal all owner details: %s", err.Error()))
-	}
-
-	return shim.Success(allOwnerDetailsJSON)
+	return allOwnerDetails, nil
 }
 
-func (s *SmartContract) queryAllCriteriaWeights(stub shim.ChaincodeStubInterface) pb.Response {
-	queryString := `{"selector":{"_id":{"$regex":"^criteriaweights_"}}}`
+func (s *SmartContract) QueryAllCriteriaWeights(ctx contractapi.TransactionContextInterface) ([]policy.CriteriaWeights, error) {
+	return newServices(ctx).policy.CriteriaWeights.All()
+}
 
-	resultsIterator, err := stub.GetQueryResult(queryString)
+func (s *SmartContract) QueryInsuranceContract(ctx contractapi.TransactionContextInterface, contractID string) (*policy.InsuranceContract, error) {
+	contract, err := newServices(ctx).policy.Contracts.Get(contractID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query all CriteriaWeights: %s", err.Error()))
+		return nil, err
 	}
-	defer resultsIterator.Close()
-
-	var criteriaWeightsList []CriteriaWeights
-
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to iterate through CriteriaWeights: %s", err.Error()))
-		}
+	if contract == nil {
+		return nil, errors.New("InsuranceContract not found for the given ContractID")
+	}
+	return contract, nil
+}
 
-		var criteriaWeights CriteriaWeights
-		err = json.Unmarshal(queryResponse.Value, &criteriaWeights)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal CriteriaWeights: %s", err.Error()))
-		}
+func (s *SmartContract) QueryInsuranceContractsByOwner(ctx contractapi.TransactionContextInterface, ownerID string) ([]policy.InsuranceContract, error) {
+	return newServices(ctx).policy.Contracts.ByOwner(ownerID)
+}
 
-		criteriaWeightsList = append(criteriaWeightsList, criteriaWeights)
+func (s *SmartContract) QueryCriteriaWeights(ctx contractapi.TransactionContextInterface, criteriaWeightsID string) (*policy.CriteriaWeights, error) {
+	weights, err := newServices(ctx).policy.CriteriaWeights.Get(criteriaWeightsID)
+	if err != nil {
+		return nil, err
 	}
+	if weights == nil {
+		return nil, errors.New("CriteriaWeights not found for the given ID")
+	}
+	return weights, nil
+}
 
-	criteriaWeightsJSON, err := json.Marshal(criteriaWeightsList)
+// QueryVerifier récupère une instance Verifier à partir du réseau
+func (s *SmartContract) QueryVerifier(ctx contractapi.TransactionContextInterface, ownerID string) (*crypto.Verifier, error) {
+	verifier, err := newServices(ctx).crypto.Verifiers.Get(ownerID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal CriteriaWeights list: %s", err.Error()))
+		return nil, errors.New("Failed to get Verifier")
+	}
+	if verifier == nil {
+		return nil, errors.New("Verifier not found")
 	}
 
-	return shim.Success(criteriaWeightsJSON)
+	fmt.Printf("Query result for Verifier: %+v\n", *verifier)
+	return verifier, nil
 }
 
-func (s *SmartContract) queryInsuranceContract(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Vérification du nombre d'arguments
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: ContractID")
+// QueryVehicleData récupère les données d'un véhicule à partir du réseau
+func (s *SmartContract) QueryVehicleData(ctx contractapi.TransactionContextInterface, vehicleID string) (*VehicleData, error) {
+	vehicle, err := newServices(ctx).telematics.Vehicles.Get(vehicleID)
+	if err != nil {
+		return nil, errors.New("Failed to get VehicleData")
+	}
+	if vehicle == nil {
+		return nil, errors.New("VehicleData not found")
 	}
 
-	// Extraction de l'identifiant du contrat
-	contractID := args[0]
-
-	// Construction de la clé pour accéder au contrat
-	contractKey := "contract_" + contractID
+	fmt.Printf("Query result for VehicleData: %+v\n", *vehicle)
+	return newVehicleData(vehicle), nil
+}
 
-	// Récupération des données du contrat depuis le ledger
-	contractBytes, err := stub.GetState(contractKey)
+func (s *SmartContract) QueryTripData(ctx contractapi.TransactionContextInterface, tripID string) (*TripData, error) {
+	trip, err := newServices(ctx).telematics.Trips.Get(tripID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to retrieve InsuranceContract: %s", err.Error()))
+		return nil, err
 	}
-	if contractBytes == nil {
-		return shim.Error("InsuranceContract not found for the given ContractID")
+	if trip == nil {
+		return nil, errors.New("TripData not found for the given TripID")
 	}
 
-	// Retourner les données du contrat
-	return shim.Success(contractBytes)
+	return newTripData(trip), nil
 }
 
-func (s *SmartContract) queryInsuranceContractsByOwner(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Vérification du nombre d'arguments
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: OwnerID")
-	}
-
-	// Extraction de l'OwnerID
-	ownerID := args[0]
-
-	// Création d'un sélecteur pour interroger les contrats par OwnerID
-	queryString := fmt.Sprintf(`{"selector":{"ownerID":"%s"}}`, ownerID)
-
-	// Exécution de la requête
-	resultsIterator, err := stub.GetQueryResult(queryString)
+func (s *SmartContract) QueryTripsByVehicleID(ctx contractapi.TransactionContextInterface, vehicleID string) ([]*TripData, error) {
+	tripData, err := newServices(ctx).telematics.Trips.ByVehicle(vehicleID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query insurance contracts by OwnerID: %s", err.Error()))
-	}
-	defer resultsIterator.Close()
-
-	// Stockage des résultats
-	var contracts []InsuranceContract
-
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to iterate over query results: %s", err.Error()))
-		}
-
-		var contract InsuranceContract
-		err = json.Unmarshal(queryResponse.Value, &contract)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal insurance contract: %s", err.Error()))
-		}
-
-		contracts = append(contracts, contract)
+		return nil, err
 	}
 
-	// Sérialisation des résultats en JSON
-	contractsJSON, err := json.Marshal(contracts)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal query results: %s", err.Error()))
+	trips := []*TripData{}
+	for i := range tripData {
+		trips = append(trips, newTripData(&tripData[i]))
 	}
-
-	// Retourner les résultats
-	return shim.Success(contractsJSON)
+	return trips, nil
 }
 
-func (s *SmartContract) queryCriteriaWeights(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Vérifiez le nombre d'arguments
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: CriteriaWeightsID")
-	}
-
-	// Récupérer l'ID des poids des critères
-	criteriaWeightsID := args[0]
-
-	// Construire la clé pour accéder à CriteriaWeights
-	key := "criteriaweights_" + criteriaWeightsID
-
-	// Récupérer les données du ledger
-	weightsBytes, err := stub.GetState(key)
+func (s *SmartContract) QueryPrimesByVehicleID(ctx contractapi.TransactionContextInterface, vehicleID string) ([]*Prime, error) {
+	primeData, err := newServices(ctx).billing.PrimesByVehicle(vehicleID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to retrieve CriteriaWeights: %s", err.Error()))
-	}
-	if weightsBytes == nil {
-		return shim.Error("CriteriaWeights not found for the given ID")
+		return nil, err
 	}
 
-	// Retourner les données des poids des critères
-	return shim.Success(weightsBytes)
-}
-
-// queryDecryptor récupère une instance Decryptor à partir du réseau
-func (s *SmartContract) queryDecryptor(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: OwnerID")
+	primes := []*Prime{}
+	for i := range primeData {
+		primes = append(primes, newPrime(&primeData[i]))
 	}
+	return primes, nil
+}
 
-	key := "decryptor_" + args[0]
-
-	decryptorBytes, err := stub.GetState(key)
+func (s *SmartContract) QueryEncryptedCalculationResultsByVehicleID(ctx contractapi.TransactionContextInterface, vehicleID string) ([]*CalculationResult, error) {
+	resultData, err := newServices(ctx).billing.ResultsByVehicle(vehicleID)
 	if err != nil {
-		return shim.Error("Failed to get Decryptor")
-	}
-	if decryptorBytes == nil {
-		return shim.Error("Decryptor not found")
+		return nil, err
 	}
 
-	fmt.Printf("Query result for Decryptor: %s\n", string(decryptorBytes))
-	return shim.Success(decryptorBytes)
-}
-
-// queryVerifier récupère une instance Verifier à partir du réseau
-func (s *SmartContract) queryVerifier(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: OwnerID")
+	results := []*CalculationResult{}
+	for i := range resultData {
+		results = append(results, newCalculationResult(&resultData[i]))
 	}
+	return results, nil
+}
 
-	key := "verifier_" + args[0]
-
-	verifierBytes, err := stub.GetState(key)
+func (s *SmartContract) QueryEncryptedCalculationResult(ctx contractapi.TransactionContextInterface, resultID string) (*CalculationResult, error) {
+	result, err := newServices(ctx).billing.Results.Get(resultID)
 	if err != nil {
-		return shim.Error("Failed to get Verifier")
+		return nil, errors.New("Failed to get EncryptedCalculationResult")
 	}
-	if verifierBytes == nil {
-		return shim.Error("Verifier not found")
+	if result == nil {
+		return nil, errors.New("EncryptedCalculationResult not found")
 	}
 
-	fmt.Printf("Query result for Verifier: %s\n", string(verifierBytes))
-	return shim.Success(verifierBytes)
+	fmt.Printf("Query result for EncryptedCalculationResult: %+v\n", *result)
+	return newCalculationResult(result), nil
 }
 
-// queryVehicleData récupère les données d'un véhicule à partir du réseau
-func (s *SmartContract) queryVehicleData(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: VehicleID")
-	}
-
-	// Construire la clé pour l'actif VehicleData
-	key := "vehicle_" + args[0]
-
-	// Récupérer les données à partir du ledger
-	vehicleBytes, err := stub.GetState(key)
-	if err != nil {
-		return shim.Error("Failed to get VehicleData")
-	}
-	if vehicleBytes == nil {
-		return shim.Error("VehicleData not found")
+func (s *SmartContract) QueryPrime(ctx contractapi.TransactionContextInterface, tripID string) (*Prime, error) {
+	prime, err := newServices(ctx).billing.Primes.Get(tripID)
+	if err != nil || prime == nil {
+		return nil, errors.New("Prime not found for the given TripID")
 	}
 
-	fmt.Printf("Query result for VehicleData: %s\n", string(vehicleBytes))
-	return shim.Success(vehicleBytes)
+	return newPrime(prime), nil
 }
 
-func (s *SmartContract) queryTripData(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Vérifiez le nombre d'arguments
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: TripID")
+func (s *SmartContract) QueryMonthPrime(ctx contractapi.TransactionContextInterface, vehicleID string, month, year int) (*MonthPrime, error) {
+	err := billing.CheckMonth(month)
+	if err != nil {
+		return nil, err
 	}
 
-	// Récupérer l'identifiant du trajet
-	tripID := args[0]
-
-	// Construire la clé pour accéder aux données du trajet
-	tripKey := "trip_" + tripID
-
-	// Récupérer les données du trajet à partir du ledger
-	tripBytes, err := stub.GetState(tripKey)
+	monthPrime, err := newServices(ctx).billing.MonthPrimes.Get(vehicleID, month, year)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to retrieve TripData: %s", err.Error()))
+		return nil, err
 	}
-	if tripBytes == nil {
-		return shim.Error("TripData not found for the given TripID")
+	if monthPrime == nil {
+		return nil, errors.New("MonthPrime not found for the given VehicleID, Month, and Year")
 	}
 
-	// Retourner les données du trajet
-	return shim.Success(tripBytes)
+	return newMonthPrime(monthPrime), nil
 }
 
-func (s *SmartContract) queryTripsByVehicleID(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Vérification du nombre d'arguments
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: VehicleID")
-	}
-
-	// Extraction de l'identifiant du véhicule
-	vehicleID := args[0]
-
-	// Création d'une requête de sélection pour les trips associés au VehicleID
-	queryString := fmt.Sprintf(`{"selector":{"vehicleID":"%s"}}`, vehicleID)
-
-	// Exécution de la requête
-	resultsIterator, err := stub.GetQueryResult(queryString)
+func (s *SmartContract) CalculateInsurancePremium(ctx contractapi.TransactionContextInterface, vehicleID, tripID, criteriaWeightsID string) (*CalculationResult, error) {
+	// Re-randomisation optionnelle de la prime chiffrée : sans elle, le chiffré
+	// est une fonction publique des chiffrés du trajet et des poids
+	seed, err := readRerandomizationSeed(ctx.GetStub())
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query trips by VehicleID: %s", err.Error()))
-	}
-	defer resultsIterator.Close()
-
-	// Liste pour stocker les résultats
-	var trips []EncryptedTripData
-
-	// Parcourir les résultats
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to iterate over trips: %s", err.Error()))
-		}
-
-		// Désérialiser les données de chaque trip
-		var tripData EncryptedTripData
-		err = json.Unmarshal(queryResponse.Value, &tripData)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal trip data: %s", err.Error()))
-		}
-		if tripData.TripID == "" {
-			continue
-		}
-		// Ajouter le trip à la liste
-		trips = append(trips, tripData)
+		return nil, err
 	}
 
-	// Sérialiser la liste des trips en JSON
-	tripsJSON, err := json.Marshal(trips)
+	result, err := newServices(ctx).billing.CalculatePremium(vehicleID, tripID, criteriaWeightsID, seed)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal trips list: %s", err.Error()))
+		return nil, err
 	}
 
-	// Retourner les trips en tant que réponse
-	return shim.Success(tripsJSON)
+	return newCalculationResult(result), nil
 }
 
-func (s *SmartContract) queryPrimesByVehicleID(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: VehicleID")
-	}
-
-	vehicleID := args[0]
-	queryString := fmt.Sprintf(`{"selector":{"vehicleID":"%s"}}`, vehicleID)
-
-	resultsIterator, err := stub.GetQueryResult(queryString)
+// CalculateConfidentialInsurancePremium calcule la prime chiffrée d'un trajet
+// avec les poids confidentiels d'un assureur. L'assureur multiplie hors chaîne
+// chaque métrique chiffrée du trajet par son coefficient engagé, re-randomise
+// le produit et prouve qu'il utilise bien ce coefficient : le chaincode vérifie
+// les preuves puis additionne les produits, sans voir ni les métriques ni les poids
+func (s *SmartContract) CalculateConfidentialInsurancePremium(ctx contractapi.TransactionContextInterface, vehicleID, tripID, weightsID string, products map[string]string, proofs map[string]*CommittedScalarProof) (*CalculationResult, error) {
+	cProducts, err := parseCiphertexts("product", products)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query trips by VehicleID: %s", err.Error()))
+		return nil, err
 	}
-	defer resultsIterator.Close()
-
-	var primes []Prime
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to iterate over trips: %s", err.Error()))
-		}
-
-		var trip EncryptedTripData
-		err = json.Unmarshal(queryResponse.Value, &trip)
+	scalarProofs := make(map[string]*paillier.CommittedScalarProof, len(proofs))
+	for field, proof := range proofs {
+		scalarProofs[field], err = proof.proof()
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal trip data: %s", err.Error()))
+			return nil, err
 		}
-
-		primeKey := "prime_" + trip.TripID
-		primeBytes, err := stub.GetState(primeKey)
-		if err != nil || primeBytes == nil {
-			continue
-		}
-
-		var prime Prime
-		err = json.Unmarshal(primeBytes, &prime)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal prime data: %s", err.Error()))
-		}
-
-		primes = append(primes, prime)
 	}
 
-	primesJSON, err := json.Marshal(primes)
+	result, err := newServices(ctx).billing.CalculateConfidentialPremium(vehicleID, tripID, weightsID, cProducts, scalarProofs)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal primes: %s", err.Error()))
+		return nil, err
 	}
 
-	return shim.Success(primesJSON)
+	return newCalculationResult(result), nil
 }
 
-func (s *SmartContract) queryEncryptedCalculationResultsByVehicleID(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: VehicleID")
-	}
-
-	vehicleID := args[0]
-	queryString := fmt.Sprintf(`{"selector":{"vehicleID":"%s"}}`, vehicleID)
-
-	resultsIterator, err := stub.GetQueryResult(queryString)
+// DeleteVerifier supprime la clé active d'un propriétaire, à la demande du
+// propriétaire ou de l'identité qui l'a enregistrée
+func (s *SmartContract) DeleteVerifier(ctx contractapi.TransactionContextInterface, ownerID string) error {
+	caller, err := clientCaller(ctx)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query trips by VehicleID: %s", err.Error()))
-	}
-	defer resultsIterator.Close()
-
-	var results []EncryptedCalculationResult
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to iterate over trips: %s", err.Error()))
-		}
-
-		var trip EncryptedTripData
-		err = json.Unmarshal(queryResponse.Value, &trip)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal trip data: %s", err.Error()))
-		}
-
-		resultKey := "result_" + trip.TripID
-		resultBytes, err := stub.GetState(resultKey)
-		if err != nil || resultBytes == nil {
-			continue
-		}
-
-		var result EncryptedCalculationResult
-		err = json.Unmarshal(resultBytes, &result)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal encrypted calculation result: %s", err.Error()))
-		}
-
-		results = append(results, result)
+		return err
 	}
 
-	resultsJSON, err := json.Marshal(results)
+	err = newServices(ctx).crypto.DeleteVerifier(caller, ownerID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal encrypted calculation results: %s", err.Error()))
+		return err
 	}
 
-	return shim.Success(resultsJSON)
+	fmt.Printf("Verifier for OwnerID %s deleted successfully\n", ownerID)
+	return nil
 }
 
-func (s *SmartContract) queryEncryptedCalculationResult(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: ResultID")
+func (s *SmartContract) DecryptInsurancePremiumAndUpdate(ctx contractapi.TransactionContextInterface, tripID, rPrimeDecimal string) (*DecryptedPrime, error) {
+	// Convertir r_prime en *big.Int
+	rPrime, ok := new(big.Int).SetString(rPrimeDecimal, 10)
+	if !ok {
+		return nil, errors.New("Failed to parse r_prime into *big.Int")
 	}
 
-	// Construire la clé pour l'actif EncryptedCalculationResult
-	key := args[0] // ResultID directement passé comme clé
-
-	// Récupérer les données à partir du ledger
-	resultBytes, err := stub.GetState(key)
+	prime, err := newServices(ctx).billing.DecryptPremium(tripID, rPrime)
 	if err != nil {
-		return shim.Error("Failed to get EncryptedCalculationResult")
-	}
-	if resultBytes == nil {
-		return shim.Error("EncryptedCalculationResult not found")
+		return nil, err
 	}
 
-	fmt.Printf("Query result for EncryptedCalculationResult: %s\n", string(resultBytes))
-	return shim.Success(resultBytes)
+	fmt.Printf("Decrypted Insurance Premium: %d\n", prime.Prime)
+	return &DecryptedPrime{DecryptedPrime: prime.Prime}, nil
 }
 
-func (s *SmartContract) queryPrime(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Vérification du nombre d'arguments
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: TripID")
-	}
-
-	// Récupérer l'identifiant du trajet
-	tripID := args[0]
-
-	// Générer la clé de l'asset à partir du TripID
-	primeKey := "prime_" + tripID
-
-	// Récupérer les données de la prime depuis le ledger
-	primeBytes, err := stub.GetState(primeKey)
-	if err != nil || primeBytes == nil {
-		return shim.Error("Prime not found for the given TripID")
+// DecryptInsurancePremiumWithProof enregistre la prime d'un trajet déchiffrée
+// hors chaîne, accompagnée d'une preuve à divulgation nulle de déchiffrement
+// liée au ResultID et au chiffré
+func (s *SmartContract) DecryptInsurancePremiumWithProof(ctx contractapi.TransactionContextInterface, tripID, primeDecimal string, proof *DecryptionProof) (*DecryptedPrime, error) {
+	decryptedPrime, ok := new(big.Int).SetString(primeDecimal, 10)
+	if !ok {
+		return nil, errors.New("Failed to parse Prime into *big.Int")
 	}
 
-	// Désérialiser les données de la prime
-	var prime Prime
-	err = json.Unmarshal(primeBytes, &prime)
+	decryptionProof, err := proof.proof()
 	if err != nil {
-		return shim.Error("Failed to unmarshal prime data")
+		return nil, err
 	}
 
-	// Sérialiser la réponse en JSON pour l'utilisateur
-	primeJSON, err := json.Marshal(prime)
+	prime, err := newServices(ctx).billing.DecryptPremiumWithProof(tripID, decryptedPrime, decryptionProof)
 	if err != nil {
-		return shim.Error("Failed to marshal prime data")
+		return nil, err
 	}
 
-	return shim.Success(primeJSON)
+	fmt.Printf("Verified Insurance Premium: %d\n", prime.Prime)
+	return &DecryptedPrime{DecryptedPrime: prime.Prime}, nil
 }
 
-func (s *SmartContract) queryMonthPrime(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Vérifiez le nombre d'arguments
-	if len(args) != 3 {
-		return shim.Error("Incorrect number of arguments. Expecting 3: VehicleID, Month, Year")
-	}
-
-	// Parse des arguments
-	vehicleID := args[0]
-	month, err := strconv.Atoi(args[1])
-	if err != nil || month < 1 || month > 12 {
-		return shim.Error("Invalid month value. Expecting a number between 1 and 12")
-	}
-	year, err := strconv.Atoi(args[2])
-	if err != nil {
-		return shim.Error("Invalid year value. Expecting a valid year")
-	}
-
-	// Construire la clé pour accéder à la prime mensuelle
-	monthPrimeKey := fmt.Sprintf("monthprime_%s_%d_%d", vehicleID, month, year)
-
-	// Récupérer les données de la prime mensuelle à partir du ledger
-	monthPrimeBytes, err := stub.GetState(monthPrimeKey)
+// VerifyPrime permet à un assureur ou un auditeur de revérifier qu'une prime
+// enregistrée provient bien du déchiffrement du résultat chiffré du trajet
+func (s *SmartContract) VerifyPrime(ctx contractapi.TransactionContextInterface, tripID string) (*PrimeVerification, error) {
+	prime, err := newServices(ctx).billing.VerifyPrime(tripID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to retrieve MonthPrime: %s", err.Error()))
-	}
-	if monthPrimeBytes == nil {
-		return shim.Error("MonthPrime not found for the given VehicleID, Month, and Year")
+		return nil, err
 	}
 
-	// Retourner les données de la prime mensuelle
-	return shim.Success(monthPrimeBytes)
+	return &PrimeVerification{
+		TripID:   prime.TripID,
+		ResultID: prime.ResultID,
+		Prime:    prime.Prime,
+		Verified: true,
+	}, nil
 }
 
-// queryVerifier récupère une instance Verifier à partir du réseau
-func (s *SmartContract) TestEncryption(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 9 {
-		return shim.Error("Incorrect number of arguments. Expecting 9: OwnerID, Message1, Message2, ... , Scalar")
-	}
-
-	ownerID := args[0]
-	message1 := args[1]
-	message2 := args[2]
-	message3 := args[3]
-	message4 := args[4]
-	message5 := args[5]
-	message6 := args[6]
-	message7 := args[7]
-	scalar := args[8]
-
-	// Charger l'instance Verifier associée à OwnerID
-	verifierKey := "verifier_" + ownerID
-	verifierBytes, err := stub.GetState(verifierKey)
-	if err != nil || verifierBytes == nil {
-		return shim.Error("Verifier not found for the given OwnerID")
-	}
-
-	var verifier Verifier
-	err = json.Unmarshal(verifierBytes, &verifier)
-	if err != nil {
-		return shim.Error("Failed to unmarshal Verifier")
-	}
-
-	n := new(big.Int)
-	n.SetString(verifier.N, 10)
-
-	// Charger l'instance Decryptor associée à OwnerID
-	decryptorKey := "decryptor_" + ownerID
-	decryptorBytes, err := stub.GetState(decryptorKey)
-	if err != nil || decryptorBytes == nil {
-		return shim.Error("Decryptor not found for the given OwnerID")
-	}
-
-	var decryptor Decryptor
-	err = json.Unmarshal(decryptorBytes, &decryptor)
-	if err != nil {
-		return shim.Error("Failed to unmarshal Decryptor")
-	}
-
-	// Convertir les messages et le scalaire en entiers
-	m1 := new(big.Int)
-	m1.SetString(message1, 10)
-	m2 := new(big.Int)
-	m2.SetString(message2, 10)
-	alpha := new(big.Int)
-	alpha.SetString(scalar, 10)
-	m3 := new(big.Int)
-	m3.SetString(message3, 10)
-	m4 := new(big.Int)
-	m4.SetString(message4, 10)
-	m5 := new(big.Int)
-	m5.SetString(message5, 10)
-	m6 := new(big.Int)
-	m6.SetString(message6, 10)
-	m7 := new(big.Int)
-	m7.SetString(message7, 10)
-	tripID := "trip5"
-	vehicleID := "veh5"
-	date := "2024-11-11"
-
-	// Chiffrer les deux messages
-
-	// uniqueData := ownerID + message1 + message2
-
-	// r := generateDeterministicR(uniqueData, n)
-	r := big.NewInt(1234)
-
-	c2, err := verifier.EncryptWithCustomRandom(m2, r)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to encrypt m2: %s", err))
-	}
-
-	c3, err := verifier.EncryptWithCustomRandom(m3, r)
-	if err != nil {
-		return shim.Error("Failed to encrypt message2")
-	}
-
-	c4, err := verifier.EncryptWithCustomRandom(m4, r)
-	if err != nil {
-		return shim.Error("Failed to encrypt message2")
-	}
-
-	c5, err := verifier.EncryptWithCustomRandom(m5, r)
-	if err != nil {
-		return shim.Error("Failed to encrypt message2")
-	}
-
-	c6, err := verifier.EncryptWithCustomRandom(m6, r)
-	if err != nil {
-		return shim.Error("Failed to encrypt message2")
-	}
-
-	c7, err := verifier.EncryptWithCustomRandom(m7, r)
-	if err != nil {
-		return shim.Error("Failed to encrypt message2")
-	}
-
-	encryptedVehicleData := EncryptedVehicleData{
-		VehicleID:       ownerID,
-		VehicleType:     c2,
-		PurchaseMileage: c3,
-		Year:            c4,
-	}
-
-	encryptedTripData := EncryptedTripData{
-		VehicleID:               vehicleID,
-		TripID:                  tripID,
-		Date:                    date,
-		Speeding:                c3,
-		HardAccelerations:       c4,
-		EmergencyBrakes:         c5,
-		UnsafeDistance:          c6,
-		HighRiskZones:           c7,
-		TrafficSignalCompliance: c6,
-		NightDriving:            c5,
-		Mileage:                 c4,
-	}
-
-	cKilometrage := encryptedTripData.Mileage
-
-	cTrafficSignalCompliance, err := verifier.HomomorphicMultiplication(encryptedTripData.TrafficSignalCompliance, alpha)
-	if err != nil {
-		return shim.Error("Failed to multiply traffic signal compliance")
-	}
-	cSpeeding, err := verifier.HomomorphicMultiplication(encryptedTripData.Speeding, alpha)
-	if err != nil {
-		return shim.Error("Failed to multiply speeding")
-	}
-	cHardAccelerations, err := verifier.HomomorphicMultiplication(encryptedTripData.HardAccelerations, alpha)
-	if err != nil {
-		return shim.Error("Failed to multiply hard accelerations")
-	}
-	cEmergencyBrakes, err := verifier.HomomorphicMultiplication(encryptedTripData.EmergencyBrakes, alpha)
-	if err != nil {
-		return shim.Error("Failed to multiply emergency brakes")
-	}
-	cUnsafeDistance, err := verifier.HomomorphicMultiplication(encryptedTripData.UnsafeDistance, alpha)
-	if err != nil {
-		return shim.Error("Failed to multiply unsafe distance")
-	}
-	cHighRiskZones, err := verifier.HomomorphicMultiplication(encryptedTripData.HighRiskZones, alpha)
-	if err != nil {
-		return shim.Error("Failed to multiply high-risk zones")
-	}
-	cNightDriving, err := verifier.HomomorphicMultiplication(encryptedTripData.NightDriving, alpha)
-	if err != nil {
-		return shim.Error("Failed to multiply night driving")
-	}
-
-	cIndiceComportement, err := verifier.HomomorphicSum([]*big.Int{
-		cSpeeding,
-		cHardAccelerations,
-		cEmergencyBrakes,
-		cUnsafeDistance,
-		cHighRiskZones,
-		cNightDriving,
-	})
-	if err != nil {
-		return shim.Error("Failed to calculate behavioral index")
-	}
-
-	cIndiceComportement, err = verifier.HomomorphicSubtraction(cIndiceComportement, cTrafficSignalCompliance)
-	if err != nil {
-		return shim.Error("Failed to perform homomorphic subtraction for compliance")
-	}
-
-	cPrimePAYD, err := verifier.HomomorphicMultiplication(cKilometrage, alpha)
-	if err != nil {
-		return shim.Error("Failed to calculate PAYD premium")
-	}
-	cPrimePHYD, err := verifier.HomomorphicMultiplication(cIndiceComportement, alpha)
-	if err != nil {
-		return shim.Error("Failed to calculate PHYD premium")
-	}
-
-	// Calcul de la prime totale
-	cPrimeTotale, err := verifier.HomomorphicSum([]*big.Int{
-		encryptedVehicleData.VehicleType,
-		encryptedVehicleData.PurchaseMileage,
-		encryptedVehicleData.Year,
-		cPrimePAYD,
-		cPrimePHYD,
-	})
-	if err != nil {
-		return shim.Error("Failed to calculate total premium")
+// SubmitDecryptionShare enregistre la part de déchiffrement d'un détenteur de
+// la clé à seuil pour le résultat chiffré d'un trajet. La part est vérifiée à
+// sa soumission : une part invalide ne peut pas bloquer la combinaison
+func (s *SmartContract) SubmitDecryptionShare(ctx contractapi.TransactionContextInterface, tripID string, decryptionShare *DecryptionShare) error {
+	if decryptionShare == nil {
+		return errors.New("Expecting a DecryptionShare")
 	}
-
-	// Calcul de R pour le décryptage
-	R := verifier.ComputeR(cPrimeTotale)
-
-	// Générer un ResultID basé sur le TripID (ou autre stratégie unique)
-	resultID := "result_" + tripID
-
-	// Retourner les résultats chiffrés
-	encryptedResult := EncryptedCalculationResult{
-		ResultID:    resultID,
-		PrimeTotale: cPrimeTotale,
-		R:           R,
-		TripID:      tripID,
-	}
-
-	rPrime, err := decryptor.ComputeRPrime(encryptedResult.R)
+	share, err := decryptionShare.share()
 	if err != nil {
-		return shim.Error("error compute r prime")
-	}
-
-	// Déchiffrer la prime totale
-	decryptedPrime, err := verifier.VerifyAndDecrypt(encryptedResult.PrimeTotale, rPrime)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to decrypt prime: %s", err.Error()))
-	}
-
-	result := map[string]string{
-		"prime value": decryptedPrime.String(),
-		"rPrime":      rPrime.String(),
-		"r":           encryptedResult.R.String(),
+		return err
 	}
 
-	resultJSON, err := json.Marshal(result)
+	err = newServices(ctx).billing.SubmitShare(tripID, share)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal combined result: %s", err))
+		return err
 	}
 
-	return shim.Success(resultJSON)
+	fmt.Printf("DecryptionShare %d for TripID %s added successfully\n", share.Index, tripID)
+	return nil
 }
 
-func (s *SmartContract) TestCalculateAndDecryptInsurancePremium(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 3 {
-		return shim.Error("Incorrect number of arguments. Expecting 3: VehicleID, TripID, CriteriaWeightsID")
-	}
-
-	// Étape 1: Calcul de la prime d'assurance (CalculateInsurancePremium)
-	calculateResponse := s.CalculateInsurancePremium(stub, args)
-	if calculateResponse.Status != shim.OK {
-		return shim.Error(fmt.Sprintf("CalculateInsurancePremium failed: %s", calculateResponse.Message))
-	}
-
-	// Parse the result from CalculateInsurancePremium to extract ResultID
-	var encryptedResult EncryptedCalculationResult
-	err := json.Unmarshal(calculateResponse.Payload, &encryptedResult)
+// CombineDecryptionShares combine les parts soumises pour le résultat chiffré
+// d'un trajet et enregistre la prime obtenue
+func (s *SmartContract) CombineDecryptionShares(ctx contractapi.TransactionContextInterface, tripID string) (*DecryptedPrime, error) {
+	prime, err := newServices(ctx).billing.CombineShares(tripID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to parse result from CalculateInsurancePremium: %s", err))
-	}
-
-	// Étape 2: Décryptage de la prime et mise à jour des données (decryptInsurancePremiumAndUpdateWithoutParams)
-	decryptResponse := s.decryptInsurancePremiumAndUpdateWithoutParams(stub, []string{encryptedResult.ResultID})
-	if decryptResponse.Status != shim.OK {
-		return shim.Error(fmt.Sprintf("decryptInsurancePremiumAndUpdateWithoutParams failed: %s", decryptResponse.Message))
-	}
-
-	// Retourner le résultat combiné des deux étapes
-	result := map[string]string{
-		"CalculateInsurancePremium": string(calculateResponse.Payload),
-		"DecryptInsurancePremium":   string(decryptResponse.Payload),
-	}
-
-	resultJSON, err := json.Marshal(result)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal combined result: %s", err))
+		return nil, err
 	}
 
-	return shim.Success(resultJSON)
+	fmt.Printf("Combined Insurance Premium: %d\n", prime.Prime)
+	return &DecryptedPrime{DecryptedPrime: prime.Prime}, nil
 }
 
-func (s *SmartContract) CalculateInsurancePremium(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 3 {
-		return shim.Error("Incorrect number of arguments. Expecting 3: VehicleID, TripID, CriteriaWeightsID")
-	}
-
-	vehicleID := args[0]
-	tripID := args[1]
-	criteriaWeightsID := args[2]
-
-	// Charger les poids des critères à partir de l'ID
-	weightsKey := "criteriaweights_" + criteriaWeightsID
-	weightsBytes, err := stub.GetState(weightsKey)
-	if err != nil || weightsBytes == nil {
-		return shim.Error("Criteria weights not found for the given CriteriaWeightsID")
-	}
-
-	var weights CriteriaWeights
-	err = json.Unmarshal(weightsBytes, &weights)
-	if err != nil {
-		return shim.Error("Failed to unmarshal criteria weights")
-	}
-
-	// Charger les données chiffrées du véhicule
-	vehicleKey := "vehicle_" + vehicleID
-	vehicleBytes, err := stub.GetState(vehicleKey)
-	if err != nil || vehicleBytes == nil {
-		return shim.Error("Vehicle data not found for the given VehicleID")
-	}
-
-	var encryptedVehicleData EncryptedVehicleData
-	err = json.Unmarshal(vehicleBytes, &encryptedVehicleData)
-	if err != nil {
-		return shim.Error("Failed to unmarshal encrypted vehicle data")
-	}
-
-	// Récupérer l'OwnerID depuis les données du véhicule
-	ownerID := encryptedVehicleData.OwnerID
-
-	// Charger l'instance Verifier associée au OwnerID
-	verifierKey := "verifier_" + ownerID
-	verifierBytes, err := stub.GetState(verifierKey)
-	if err != nil || verifierBytes == nil {
-		return shim.Error("Verifier not found for the given OwnerID")
-	}
-
-	var verifier Verifier
-	err = json.Unmarshal(verifierBytes, &verifier)
-	if err != nil {
-		return shim.Error("Failed to unmarshal Verifier")
-	}
-
-	// Charger les données chiffrées du trajet
-	tripKey := "trip_" + tripID
-	tripBytes, err := stub.GetState(tripKey)
-	if err != nil || tripBytes == nil {
-		return shim.Error("Trip data not found for the given TripID")
-	}
-
-	var encryptedTripData EncryptedTripData
-	err = json.Unmarshal(tripBytes, &encryptedTripData)
-	if err != nil {
-		return shim.Error("Failed to unmarshal encrypted trip data")
-	}
-
-	// Calcul homomorphique des primes PAYD et PHYD
-	cKilometrage := encryptedTripData.Mileage
-
-	cTrafficSignalCompliance, err := verifier.HomomorphicMultiplication(encryptedTripData.TrafficSignalCompliance, big.NewInt(int64(weights.WeightTraffic)))
-	if err != nil {
-		return shim.Error("Failed to multiply traffic signal compliance")
-	}
-	cSpeeding, err := verifier.HomomorphicMultiplication(encryptedTripData.Speeding, big.NewInt(int64(weights.WeightSpeed)))
-	if err != nil {
-		return shim.Error("Failed to multiply speeding")
-	}
-	cHardAccelerations, err := verifier.HomomorphicMultiplication(encryptedTripData.HardAccelerations, big.NewInt(int64(weights.WeightAcceleration)))
-	if err != nil {
-		return shim.Error("Failed to multiply hard accelerations")
-	}
-	cEmergencyBrakes, err := verifier.HomomorphicMultiplication(encryptedTripData.EmergencyBrakes, big.NewInt(int64(weights.WeightBraking)))
-	if err != nil {
-		return shim.Error("Failed to multiply emergency brakes")
-	}
-	cUnsafeDistance, err := verifier.HomomorphicMultiplication(encryptedTripData.UnsafeDistance, big.NewInt(int64(weights.WeightDistance)))
-	if err != nil {
-		return shim.Error("Failed to multiply unsafe distance")
-	}
-	cHighRiskZones, err := verifier.HomomorphicMultiplication(encryptedTripData.HighRiskZones, big.NewInt(int64(weights.WeightZone)))
-	if err != nil {
-		return shim.Error("Failed to multiply high-risk zones")
-	}
-	cNightDriving, err := verifier.HomomorphicMultiplication(encryptedTripData.NightDriving, big.NewInt(int64(weights.WeightTime)))
-	if err != nil {
-		return shim.Error("Failed to multiply night driving")
-	}
-
-	cIndiceComportement, err := verifier.HomomorphicSum([]*big.Int{
-		cSpeeding,
-		cHardAccelerations,
-		cEmergencyBrakes,
-		cUnsafeDistance,
-		cHighRiskZones,
-		cNightDriving,
-	})
-	if err != nil {
-		return shim.Error("Failed to calculate behavioral index")
-	}
-
-	cIndiceComportement, err = verifier.HomomorphicSubtraction(cIndiceComportement, cTrafficSignalCompliance)
-	if err != nil {
-		return shim.Error("Failed to perform homomorphic subtraction for compliance")
-	}
-
-	cPrimePAYD, err := verifier.HomomorphicMultiplication(cKilometrage, big.NewInt(int64(weights.Alpha)))
-	if err != nil {
-		return shim.Error("Failed to calculate PAYD premium")
-	}
-	cPrimePHYD, err := verifier.HomomorphicMultiplication(cIndiceComportement, big.NewInt(int64(weights.Beta)))
-	if err != nil {
-		return shim.Error("Failed to calculate PHYD premium")
-	}
-
-	// Calcul de la prime totale
-	cPrimeTotale, err := verifier.HomomorphicSum([]*big.Int{
-		encryptedVehicleData.VehicleType,
-		encryptedVehicleData.PurchaseMileage,
-		encryptedVehicleData.Year,
-		cPrimePAYD,
-		cPrimePHYD,
-	})
-	if err != nil {
-		return shim.Error("Failed to calculate total premium")
-	}
-
-	// Calcul de R pour le décryptage
-	r := verifier.ComputeR(cPrimeTotale)
-
-	// Générer un ResultID basé sur le TripID (ou autre stratégie unique)
-	resultID := "result_" + tripID
-
-	// Retourner les résultats chiffrés
-	encryptedResult := EncryptedCalculationResult{
-		ResultID:    resultID,
-		PrimeTotale: cPrimeTotale,
-		R:           r,
-		TripID:      tripID,
-	}
-
-	encryptedResultJSON, err := json.Marshal(encryptedResult)
-	if err != nil {
-		return shim.Error("Failed to marshal encrypted calculation result")
-	}
-
-	// Enregistrer l'EncryptedCalculationResult dans le ledger
-	err = stub.PutState(resultID, encryptedResultJSON)
+// AggregateMonthlyPremium additionne homomorphiquement les primes chiffrées
+// des trajets d'un véhicule sur un mois. Aucune prime de trajet n'est
+// déchiffrée : seul le total est ensuite déchiffré, par DecryptMonthlyPremium
+// ou par parts pour une clé à seuil, et les primes des trajets du mois ne
+// peuvent plus être déchiffrées individuellement. L'agrégation peut être
+// relancée pour inclure de nouveaux trajets tant que le total n'a pas été
+// déchiffré et qu'aucune part n'a été soumise
+func (s *SmartContract) AggregateMonthlyPremium(ctx contractapi.TransactionContextInterface, vehicleID string, month, year int) (*EncryptedMonthPrime, error) {
+	monthPrime, err := newServices(ctx).billing.AggregateMonth(vehicleID, month, year)
 	if err != nil {
-		return shim.Error("Failed to store EncryptedCalculationResult")
-	}
-
-	return shim.Success(encryptedResultJSON)
-}
-
-func (d *Decryptor) ComputeRPrime(R *big.Int) (*big.Int, error) {
-	lambda := new(big.Int)
-	lambda.SetString(d.Lambda, 10)
-	n := new(big.Int)
-	n.SetString(d.N, 10)
-
-	nInverseModLambda := new(big.Int).ModInverse(n, lambda)
-	if nInverseModLambda == nil {
-		return nil, errors.New("Failed to compute modular inverse")
-	}
-
-	fmt.Printf("R: %s\n", R.String())
-	fmt.Printf("Lambda: %s\n", lambda.String())
-	fmt.Printf("N: %s\n", n.String())
-	fmt.Printf("N^-1 mod Lambda: %s\n", nInverseModLambda.String())
-
-	rPrime := new(big.Int).Exp(R, nInverseModLambda, n)
-	fmt.Printf("rPrime: %s\n", rPrime.String())
-
-	return rPrime, nil
-}
-
-func (v *Verifier) VerifyAndDecrypt(C, rPrime *big.Int) (*big.Int, error) {
-	// Convertir N et N^2 en big.Int
-	n := new(big.Int)
-	n.SetString(v.N, 10)
-	nsquare := new(big.Int).Mul(n, n)
-
-	// Étape 1 : Calculer rPrime^N mod N
-	rPrimePowerN := new(big.Int).Exp(rPrime, n, n)
-	fmt.Printf("rPrime: %s\n", rPrime.String())
-	fmt.Printf("N: %s\n", n.String())
-	fmt.Printf("C mod N: %s\n", new(big.Int).Mod(C, n).String())
-	fmt.Printf("rPrime^N mod N: %s\n", rPrimePowerN.String())
-
-	if rPrimePowerN.Cmp(new(big.Int).Mod(C, n)) != 0 {
-		return nil, errors.New("Verification failed: rPrime is invalid")
-	}
-
-	// Étape 2 : Déchiffrement
-	S := new(big.Int).Exp(rPrime, n, nsquare)
-	SInverse := new(big.Int).ModInverse(S, nsquare)
-	if SInverse == nil {
-		return nil, errors.New("Failed to compute modular inverse of S")
+		return nil, err
 	}
 
-	CtimesSInverse := new(big.Int).Mul(C, SInverse)
-	CtimesSInverse.Mod(CtimesSInverse, nsquare)
-	CminusOne := new(big.Int).Sub(CtimesSInverse, big.NewInt(1))
-
-	m := new(big.Int).Div(CminusOne, n)
-	return m, nil
+	fmt.Printf("EncryptedMonthPrime for VehicleID %s, Month %d, Year %d aggregated from %d results\n", vehicleID, month, year, len(monthPrime.ResultIDs))
+	return newEncryptedMonthPrime(monthPrime), nil
 }
 
-func (s *SmartContract) deleteVerifier(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: OwnerID")
-	}
-
-	ownerID := args[0]
-
-	// Construire la clé pour le Verifier
-	verifierKey := "verifier_" + ownerID
-
-	// Vérifier si le Verifier existe
-	existingVerifier, err := stub.GetState(verifierKey)
+// DecryptMonthlyPremium déchiffre le total mensuel chiffré d'un véhicule avec
+// le témoin r′ fourni par le propriétaire et enregistre la prime mensuelle
+func (s *SmartContract) DecryptMonthlyPremium(ctx contractapi.TransactionContextInterface, vehicleID string, month, year int, rPrimeDecimal string) (*DecryptedPrime, error) {
+	err := billing.CheckMonth(month)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to check existing Verifier: %s", err.Error()))
-	}
-	if existingVerifier == nil {
-		return shim.Error("Verifier not found for the given OwnerID")
-	}
-
-	// Supprimer le Verifier
-	err = stub.DelState(verifierKey)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to delete Verifier: %s", err.Error()))
-	}
-
-	fmt.Printf("Verifier for OwnerID %s deleted successfully\n", ownerID)
-	return shim.Success(nil)
-}
-
-func (s *SmartContract) decryptInsurancePremiumAndUpdate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 2 {
-		return shim.Error("Incorrect number of arguments. Expecting 2: TripID, r_prime")
+		return nil, err
 	}
 
-	resultID := "result_" + args[0]
-
 	// Convertir r_prime en *big.Int
-	rPrime := new(big.Int)
-	if _, ok := rPrime.SetString(args[1], 10); !ok {
-		return shim.Error("Failed to parse r_prime into *big.Int")
-	}
-
-	// Récupérer l'EncryptedCalculationResult à partir du ResultID
-	resultBytes, err := stub.GetState(resultID)
-	if err != nil || resultBytes == nil {
-		return shim.Error("EncryptedCalculationResult not found for the given ResultID")
-	}
-
-	var encryptedResult EncryptedCalculationResult
-	err = json.Unmarshal(resultBytes, &encryptedResult)
-	if err != nil {
-		return shim.Error("Failed to unmarshal EncryptedCalculationResult")
-	}
-
-	// Charger les données associées au TripID
-	tripKey := "trip_" + encryptedResult.TripID
-	tripBytes, err := stub.GetState(tripKey)
-	if err != nil || tripBytes == nil {
-		return shim.Error("Trip data not found for the given TripID")
-	}
-
-	var encryptedTripData EncryptedTripData
-	err = json.Unmarshal(tripBytes, &encryptedTripData)
-	if err != nil {
-		return shim.Error("Failed to unmarshal EncryptedTripData")
-	}
-
-	// Charger les données associées au véhicule
-	vehicleKey := "vehicle_" + encryptedTripData.VehicleID
-	vehicleBytes, err := stub.GetState(vehicleKey)
-	if err != nil || vehicleBytes == nil {
-		return shim.Error("Vehicle data not found for the given VehicleID")
-	}
-
-	var encryptedVehicleData EncryptedVehicleData
-	err = json.Unmarshal(vehicleBytes, &encryptedVehicleData)
-	if err != nil {
-		return shim.Error("Failed to unmarshal EncryptedVehicleData")
-	}
-
-	ownerID := encryptedVehicleData.OwnerID
-
-	// Charger l'instance Verifier associée au OwnerID
-	verifierKey := "verifier_" + ownerID
-	verifierBytes, err := stub.GetState(verifierKey)
-	if err != nil || verifierBytes == nil {
-		return shim.Error("Verifier not found for the given OwnerID")
-	}
-
-	var verifier Verifier
-	err = json.Unmarshal(verifierBytes, &verifier)
-	if err != nil {
-		return shim.Error("Failed to unmarshal Verifier")
-	}
-
-	// Déchiffrer la prime totale
-	decryptedPrime, err := verifier.VerifyAndDecrypt(encryptedResult.PrimeTotale, rPrime)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to decrypt prime: %s", err.Error()))
-	}
-
-	fmt.Printf("Decrypted Insurance Premium: %s\n", decryptedPrime.String())
-
-	// Ajouter la prime déchiffrée dans un nouvel asset Prime
-	primeValue := new(big.Int).Set(decryptedPrime).Int64()
-	prime := Prime{
-		TripID: encryptedResult.TripID,
-		Date:   encryptedTripData.Date,
-		Prime:  int(primeValue),
-	}
-
-	primeKey := "prime_" + encryptedResult.TripID
-	primeBytes, err := json.Marshal(prime)
-	if err != nil {
-		return shim.Error("Failed to marshal Prime")
-	}
-
-	err = stub.PutState(primeKey, primeBytes)
-	if err != nil {
-		return shim.Error("Failed to store Prime")
-	}
-
-	// Mettre à jour la prime mensuelle (MonthPrime) pour le véhicule
-	year, month, err := extractYearAndMonth(encryptedTripData.Date)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Invalid date format: %s", err.Error()))
-	}
-
-	monthPrimeKey := fmt.Sprintf("monthprime_%s_%d_%d", encryptedTripData.VehicleID, year, month)
-	monthPrimeBytes, err := stub.GetState(monthPrimeKey)
-	var monthPrime MonthPrime
-
-	if monthPrimeBytes == nil {
-		// Créer une nouvelle prime mensuelle si elle n'existe pas
-		monthPrime = MonthPrime{
-			VehicleID: encryptedTripData.VehicleID,
-			Month:     month,
-			Year:      year,
-			Prime:     int(primeValue),
-		}
-	} else {
-		// Charger et mettre à jour la prime existante
-		err = json.Unmarshal(monthPrimeBytes, &monthPrime)
-		if err != nil {
-			return shim.Error("Failed to unmarshal MonthPrime")
-		}
-		monthPrime.Prime += int(primeValue)
-	}
-
-	// Enregistrer les données mises à jour
-	updatedMonthPrimeBytes, err := json.Marshal(monthPrime)
-	if err != nil {
-		return shim.Error("Failed to marshal updated MonthPrime")
+	rPrime, ok := new(big.Int).SetString(rPrimeDecimal, 10)
+	if !ok {
+		return nil, errors.New("Failed to parse r_prime into *big.Int")
 	}
 
-	err = stub.PutState(monthPrimeKey, updatedMonthPrimeBytes)
+	monthPrime, err := newServices(ctx).billing.DecryptMonth(vehicleID, month, year, rPrime)
 	if err != nil {
-		return shim.Error("Failed to store updated MonthPrime")
+		return nil, err
 	}
 
-	fmt.Printf("Updated MonthPrime: %+v\n", monthPrime)
-	//return shim.Success([]byte(fmt.Sprintf("Decrypted prime: %d, updated month prime: %d", primeValue, monthPrime.Prime)))
-	primeResult := map[string]interface{}{
-		"decryptedPrime": primeValue,
-	}
-	primeResultBytes, _ := json.Marshal(primeResult)
-	return shim.Success(primeResultBytes)
+	fmt.Printf("Decrypted Monthly Premium: %d\n", monthPrime.Prime)
+	return &DecryptedPrime{DecryptedPrime: monthPrime.Prime}, nil
 }
 
-func (s *SmartContract) decryptInsurancePremiumAndUpdateWithoutParams(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: ResultID ")
-	}
-
-	resultID := args[0]
-
-	// Récupérer l'EncryptedCalculationResult à partir du ResultID
-	resultBytes, err := stub.GetState(resultID)
-	if err != nil || resultBytes == nil {
-		return shim.Error("EncryptedCalculationResult not found for the given ResultID")
-	}
-
-	var encryptedResult EncryptedCalculationResult
-	err = json.Unmarshal(resultBytes, &encryptedResult)
-	if err != nil {
-		return shim.Error("Failed to unmarshal EncryptedCalculationResult")
-	}
-
-	// Charger les données associées au TripID
-	tripKey := "trip_" + encryptedResult.TripID
-	tripBytes, err := stub.GetState(tripKey)
-	if err != nil || tripBytes == nil {
-		return shim.Error("Trip data not found for the given TripID")
+// SubmitMonthlyDecryptionShare enregistre la part de déchiffrement d'un
+// détenteur de la clé à seuil pour le total mensuel chiffré d'un véhicule. Une
+// fois une part soumise, le mois ne peut plus être ré-agrégé
+func (s *SmartContract) SubmitMonthlyDecryptionShare(ctx contractapi.TransactionContextInterface, vehicleID string, month, year int, decryptionShare *DecryptionShare) error {
+	if decryptionShare == nil {
+		return errors.New("Expecting a DecryptionShare")
 	}
-
-	var encryptedTripData EncryptedTripData
-	err = json.Unmarshal(tripBytes, &encryptedTripData)
-	if err != nil {
-		return shim.Error("Failed to unmarshal EncryptedTripData")
-	}
-
-	// Charger les données associées au véhicule
-	vehicleKey := "vehicle_" + encryptedTripData.VehicleID
-	vehicleBytes, err := stub.GetState(vehicleKey)
-	if err != nil || vehicleBytes == nil {
-		return shim.Error("Vehicle data not found for the given VehicleID")
-	}
-
-	var encryptedVehicleData EncryptedVehicleData
-	err = json.Unmarshal(vehicleBytes, &encryptedVehicleData)
-	if err != nil {
-		return shim.Error("Failed to unmarshal EncryptedVehicleData")
-	}
-
-	ownerID := encryptedVehicleData.OwnerID
-
-	// Charger l'instance Verifier associée au OwnerID
-	verifierKey := "verifier_" + ownerID
-	verifierBytes, err := stub.GetState(verifierKey)
-	if err != nil || verifierBytes == nil {
-		return shim.Error("Verifier not found for the given OwnerID")
-	}
-
-	var verifier Verifier
-	err = json.Unmarshal(verifierBytes, &verifier)
-	if err != nil {
-		return shim.Error("Failed to unmarshal Verifier")
-	}
-
-	decryptorKey := "decryptor_" + ownerID
-	decryptorBytes, err := stub.GetState(decryptorKey)
-	if err != nil || decryptorBytes == nil {
-		return shim.Error("Verifier not found for the given OwnerID")
-	}
-
-	var decryptor Decryptor
-	err = json.Unmarshal(decryptorBytes, &decryptor)
-	if err != nil {
-		return shim.Error("Failed to unmarshal Verifier")
-	}
-
-	rPrime, err := decryptor.ComputeRPrime(encryptedResult.R)
+	share, err := decryptionShare.share()
 	if err != nil {
-		return shim.Error("error compute r prime")
+		return err
 	}
 
-	// Déchiffrer la prime totale
-	decryptedPrime, err := verifier.VerifyAndDecrypt(encryptedResult.PrimeTotale, rPrime)
+	err = newServices(ctx).billing.SubmitMonthShare(vehicleID, month, year, share)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to decrypt prime: %s", err.Error()))
+		return err
 	}
 
-	fmt.Printf("Decrypted Insurance Premium: %s\n", decryptedPrime.String())
-
-	// Ajouter la prime déchiffrée dans un nouvel asset Prime
-	primeValue := new(big.Int).Set(decryptedPrime).Int64()
-	prime := Prime{
-		TripID: encryptedResult.TripID,
-		Date:   encryptedTripData.Date,
-		Prime:  int(primeValue),
-	}
+	fmt.Printf("DecryptionShare %d for VehicleID %s, Month %d, Year %d added successfully\n", share.Index, vehicleID, month, year)
+	return nil
+}
 
-	primeKey := "prime_" + encryptedResult.TripID
-	primeBytes, err := json.Marshal(prime)
+// CombineMonthlyDecryptionShares combine les parts soumises pour le total
+// mensuel chiffré d'un véhicule et enregistre la prime mensuelle
+func (s *SmartContract) CombineMonthlyDecryptionShares(ctx contractapi.TransactionContextInterface, vehicleID string, month, year int) (*DecryptedPrime, error) {
+	monthPrime, err := newServices(ctx).billing.CombineMonthShares(vehicleID, month, year)
 	if err != nil {
-		return shim.Error("Failed to marshal Prime")
+		return nil, err
 	}
 
-	err = stub.PutState(primeKey, primeBytes)
-	if err != nil {
-		return shim.Error("Failed to store Prime")
-	}
+	fmt.Printf("Combined Monthly Premium: %d\n", monthPrime.Prime)
+	return &DecryptedPrime{DecryptedPrime: monthPrime.Prime}, nil
+}
 
-	// Mettre à jour la prime mensuelle (MonthPrime) pour le véhicule
-	year, month, err := extractYearAndMonth(encryptedTripData.Date)
+// QueryEncryptedMonthPrime récupère le total mensuel chiffré d'un véhicule
+func (s *SmartContract) QueryEncryptedMonthPrime(ctx contractapi.TransactionContextInterface, vehicleID string, month, year int) (*EncryptedMonthPrime, error) {
+	err := billing.CheckMonth(month)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Invalid date format: %s", err.Error()))
-	}
-
-	monthPrimeKey := fmt.Sprintf("monthprime_%s_%d_%d", encryptedTripData.VehicleID, year, month)
-	monthPrimeBytes, err := stub.GetState(monthPrimeKey)
-	var monthPrime MonthPrime
-
-	if monthPrimeBytes == nil {
-		// Créer une nouvelle prime mensuelle si elle n'existe pas
-		monthPrime = MonthPrime{
-			VehicleID: encryptedTripData.VehicleID,
-			Month:     month,
-			Year:      year,
-			Prime:     int(primeValue),
-		}
-	} else {
-		// Charger et mettre à jour la prime existante
-		err = json.Unmarshal(monthPrimeBytes, &monthPrime)
-		if err != nil {
-			return shim.Error("Failed to unmarshal MonthPrime")
-		}
-		monthPrime.Prime += int(primeValue)
+		return nil, err
 	}
 
-	// Enregistrer les données mises à jour
-	updatedMonthPrimeBytes, err := json.Marshal(monthPrime)
+	monthPrime, err := newServices(ctx).billing.EncryptedMonthPrimes.Get(vehicleID, month, year)
 	if err != nil {
-		return shim.Error("Failed to marshal updated MonthPrime")
+		return nil, err
 	}
-
-	err = stub.PutState(monthPrimeKey, updatedMonthPrimeBytes)
-	if err != nil {
-		return shim.Error("Failed to store updated MonthPrime")
+	if monthPrime == nil {
+		return nil, errors.New("EncryptedMonthPrime not found for the given VehicleID, Month, and Year")
 	}
 
-	fmt.Printf("Updated MonthPrime: %+v\n", monthPrime)
-	return shim.Success([]byte(fmt.Sprintf("Decrypted prime: %d, updated month prime: %d", primeValue, monthPrime.Prime)))
+	return newEncryptedMonthPrime(monthPrime), nil
 }
 
-func (s *SmartContract) queryAllInsuranceContracts(stub shim.ChaincodeStubInterface) pb.Response {
-	queryString := `{"selector":{"contractID":{"$exists":true}}}`
+func (s *SmartContract) QueryAllInsuranceContracts(ctx contractapi.TransactionContextInterface) ([]policy.InsuranceContract, error) {
+	return newServices(ctx).policy.Contracts.All()
+}
 
-	resultsIterator, err := stub.GetQueryResult(queryString)
+func (s *SmartContract) RemoveAgeFromEncryptedVehicleData(ctx contractapi.TransactionContextInterface) (string, error) {
+	vehicleIDs, err := newServices(ctx).telematics.Vehicles.RemoveField("age")
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query all insurance contracts: %s", err.Error()))
-	}
-	defer resultsIterator.Close()
-
-	var contracts []InsuranceContract
-
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to iterate through insurance contracts: %s", err.Error()))
-		}
-
-		var contract InsuranceContract
-		err = json.Unmarshal(queryResponse.Value, &contract)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal insurance contract: %s", err.Error()))
-		}
-
-		contracts = append(contracts, contract)
+		return "", err
 	}
 
-	contractsJSON, err := json.Marshal(contracts)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to marshal insurance contracts list: %s", err.Error()))
+	for _, vehicleID := range vehicleIDs {
+		fmt.Printf("Removed 'age' from EncryptedVehicleData with VehicleID: %s\n", vehicleID)
 	}
-
-	return shim.Success(contractsJSON)
+	return "All EncryptedVehicleData assets updated successfully.", nil
 }
 
-func (s *SmartContract) removeAgeFromEncryptedVehicleData(stub shim.ChaincodeStubInterface) pb.Response {
-	queryString := `{"selector":{"vehicleID":{"$exists":true}}}`
+// purgedKeysWarning rappelle, dans la réponse de PurgeDecryptors, que la
+// purge ne retire pas les clés privées de l'historique des blocs
+const purgedKeysWarning = "Purged private keys remain in block history: affected owners must rotate their keys."
 
-	resultsIterator, err := stub.GetQueryResult(queryString)
+// PurgeDecryptors supprime du world state les clés privées (decryptor_*)
+// enregistrées par les versions précédentes du chaincode. Les clés sont
+// parcourues par plage, ce qui fonctionne sur LevelDB comme sur CouchDB.
+// Les clés purgées restent lisibles dans l'historique des blocs : elles
+// doivent être tenues pour compromises, et chaque propriétaire concerné doit
+// renouveler sa clé avec RotateVerifier
+func (s *SmartContract) PurgeDecryptors(ctx contractapi.TransactionContextInterface) (string, error) {
+	state := stubState{ctx.GetStub()}
+
+	// "`" suit "_" dans l'ordre des octets : la plage couvre toutes les clés decryptor_*
+	decryptors, err := state.Range("decryptor_", "decryptor`")
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query EncryptedVehicleData: %s", err.Error()))
+		return "", fmt.Errorf("Failed to query Decryptors: %s", err.Error())
 	}
-	defer resultsIterator.Close()
 
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
+	for _, decryptor := range decryptors {
+		err = state.DelState(decryptor.Key)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to iterate over EncryptedVehicleData: %s", err.Error()))
+			return "", fmt.Errorf("Failed to delete Decryptor %s: %s", decryptor.Key, err.Error())
 		}
 
-		var encryptedVehicleData map[string]interface{}
-		err = json.Unmarshal(queryResponse.Value, &encryptedVehicleData)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Failed to unmarshal EncryptedVehicleData: %s", err.Error()))
-		}
-
-		// Remove the 'age' field if it exists
-		if _, exists := encryptedVehicleData["age"]; exists {
-			delete(encryptedVehicleData, "age")
-
-			updatedVehicleBytes, err := json.Marshal(encryptedVehicleData)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("Failed to marshal updated EncryptedVehicleData: %s", err.Error()))
-			}
-
-			// Save updated record back to the ledger
-			err = stub.PutState(queryResponse.Key, updatedVehicleBytes)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("Failed to update EncryptedVehicleData: %s", err.Error()))
-			}
-
-			fmt.Printf("Removed 'age' from EncryptedVehicleData with VehicleID: %s\n", encryptedVehicleData["vehicleID"])
-		}
+		fmt.Printf("Purged Decryptor with key: %s\n", decryptor.Key)
 	}
 
-	return shim.Success([]byte("All EncryptedVehicleData assets updated successfully."))
+	return fmt.Sprintf("%d Decryptor assets purged successfully. %s", len(decryptors), purgedKeysWarning), nil
 }
 
-func (s *SmartContract) deleteEncryptedTripData(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: TripID")
-	}
-
-	tripID := args[0]
-	tripKey := "trip_" + tripID
-
-	// Vérifier si les données du trajet existent
-	existingTrip, err := stub.GetState(tripKey)
+func (s *SmartContract) DeleteEncryptedTripData(ctx contractapi.TransactionContextInterface, tripID string) error {
+	err := newServices(ctx).telematics.DeleteTrip(tripID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to check existing trip data: %s", err.Error()))
-	}
-
-	if existingTrip == nil {
-		return shim.Error("Trip data with this TripID does not exist")
-	}
-
-	// Supprimer les données du trajet du ledger
-	err = stub.DelState(tripKey)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Failed to delete EncryptedTripData: %s", err.Error()))
+		return err
 	}
 
 	fmt.Printf("EncryptedTripData for TripID %s deleted successfully\n", tripID)
-	return shim.Success(nil)
+	return nil
 }
 
-// extractYearAndMonth extrait l'année et le mois à partir d'une date au format YYYY-MM-DD
-func extractYearAndMonth(date string) (int, int, error) {
-	var year, month int
-	_, err := fmt.Sscanf(date, "%d-%d", &year, &month)
+func main() {
+	chaincode, err := contractapi.NewChaincode(&SmartContract{
+		Contract: contractapi.Contract{
+			UnknownTransaction: unknownTransaction,
+		},
+	})
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to parse date: %s", date)
+		fmt.Printf("Error creating Smart Contract: %s", err)
+		return
 	}
-	return year, month, nil
-}
 
-func (v *Verifier) Encrypt(m *big.Int) (*big.Int, error) {
-	// Convertir N en *big.Int
-	n := new(big.Int)
-	n.SetString(v.N, 10)
+	chaincode.Info.Title = "securedrive"
+	chaincode.Info.Version = "2.0.0"
 
-	// Générer un aléa r dans l'intervalle [1, N-1]
-	r, err := rand.Int(rand.Reader, new(big.Int).Sub(n, big.NewInt(1)))
+	server, err := newChaincodeServer(chaincode)
 	if err != nil {
-		return nil, err
-	}
-
-	// C = (1 + mN) * r^N mod N^2
-	nsquare := new(big.Int).Mul(n, n)
-	rExpN := new(big.Int).Exp(r, n, nsquare)
-	c := new(big.Int).Add(big.NewInt(1), new(big.Int).Mul(m, n))
-	c.Mul(c, rExpN)
-	c.Mod(c, nsquare)
-
-	return c, nil
-}
-
-func (v *Verifier) EncryptWithCustomRandom(m *big.Int, r *big.Int) (*big.Int, error) {
-	n := new(big.Int)
-	n.SetString(v.N, 10)
-
-	// Vérifier que r est dans l'intervalle [1, N-1]
-	if r.Cmp(big.NewInt(1)) < 0 || r.Cmp(new(big.Int).Sub(n, big.NewInt(1))) >= 0 {
-		return nil, errors.New("r must be in the range [1, N-1]")
-	}
-
-	// C = (1 + mN) * r^N mod N^2
-	nsquare := new(big.Int).Mul(n, n)
-	rExpN := new(big.Int).Exp(r, n, nsquare)
-	c := new(big.Int).Add(big.NewInt(1), new(big.Int).Mul(m, n))
-	c.Mul(c, rExpN)
-	c.Mod(c, nsquare)
-
-	return c, nil
-}
-
-func (v *Verifier) ComputeR(C *big.Int) *big.Int {
-	// Convertir N en big.Int
-	n := new(big.Int)
-	n.SetString(v.N, 10)
-
-	// Calcul de R = C mod N
-	return new(big.Int).Mod(C, n)
-}
-
-func (v *Verifier) HomomorphicAddition(C1, C2 *big.Int) *big.Int {
-	// N^2 = n * n
-	n := new(big.Int)
-	n.SetString(v.N, 10)
-
-	nsquare := new(big.Int).Mul(n, n)
-
-	// C1 * C2 mod N^2
-	return new(big.Int).Mod(new(big.Int).Mul(C1, C2), nsquare)
-}
-
-func (v *Verifier) HomomorphicSum(ciphertexts []*big.Int) (*big.Int, error) {
-	// Convertir N en big.Int
-	n := new(big.Int)
-	n.SetString(v.N, 10)
-
-	// Calculer N^2
-	nsquare := new(big.Int).Mul(n, n)
-
-	// Initialiser le résultat à 1 (identité multiplicative pour l'addition homomorphe)
-	result := big.NewInt(1)
-
-	// Effectuer l'addition homomorphe : (C1 * C2 * ... * Cn) mod N^2
-	for _, ciphertext := range ciphertexts {
-		result.Mul(result, ciphertext)
-		result.Mod(result, nsquare)
+		fmt.Printf("Error configuring Smart Contract server: %s", err)
+		return
 	}
 
-	return result, nil
-}
-
-func (v *Verifier) HomomorphicSubtraction(C1, C2 *big.Int) (*big.Int, error) {
-	// Convertir N en big.Int
-	n := new(big.Int)
-	n.SetString(v.N, 10)
-
-	// Calculer N^2
-	nsquare := new(big.Int).Mul(n, n)
-
-	// Calculer l'inverse modulaire de C2 modulo N^2
-	C2Inverse := new(big.Int).ModInverse(C2, nsquare)
-	if C2Inverse == nil {
-		return nil, errors.New("Failed to compute modular inverse of C2")
-	}
-
-	// Effectuer la soustraction homomorphe : (C1 * C2^(-1)) mod N^2
-	result := new(big.Int).Mul(C1, C2Inverse)
-	result.Mod(result, nsquare)
-
-	return result, nil
-}
-
-func (v *Verifier) HomomorphicMultiplication(C *big.Int, alpha *big.Int) (*big.Int, error) {
-	// Convertir N en big.Int
-	n := new(big.Int)
-	n.SetString(v.N, 10)
-
-	// Calculer N^2
-	nsquare := new(big.Int).Mul(n, n)
-
-	// Effectuer la multiplication homomorphe : (C^alpha) mod N^2
-	result := new(big.Int).Exp(C, alpha, nsquare)
-
-	return result, nil
-}
-
-// Génère un r déterministe basé sur un hash cryptographique.
-func generateDeterministicR(data string, n *big.Int) *big.Int {
-	// Hacher les données
-	hash := sha256.Sum256([]byte(data))
-
-	// Convertir le hash en un grand entier
-	hashInt := new(big.Int).SetBytes(hash[:])
-
-	// Calculer r = (hash mod (n-1)) + 1 pour garantir que 1 <= r < n
-	nMinusOne := new(big.Int).Sub(n, big.NewInt(1))
-	r := new(big.Int).Mod(hashInt, nMinusOne)
-	r.Add(r, big.NewInt(1))
-
-	return r
-}
-
-func toInt(value string) int {
-	result, _ := strconv.Atoi(value)
-	return result
-}
-
-func toInt64(value string) int64 {
-	result, err := strconv.ParseInt(value, 10, 64)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to convert value %s to int64", value))
+	// Sans CHAINCODE_SERVER_ADDRESS, le chaincode est lancé par le peer
+	if server != nil {
+		err = server.Start()
+	} else {
+		err = chaincode.Start()
 	}
-	return result
-}
-
-func main() {
-	err := shim.Start(new(SmartContract))
 	if err != nil {
 		fmt.Printf("Error starting Smart Contract: %s", err)
 	}
@@ -0,0 +1,2150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"simple/billing"
+	"simple/crypto"
+	"simple/paillier"
+	"simple/pedersen"
+	"simple/policy"
+	"simple/telematics"
+)
+
+// Les transactions sont appelées directement sur le SmartContract, avec un
+// contexte dont le stub est un shimtest.MockStub complété par l'émulateur de
+// requêtes riches CouchDB (voir queryStub).
+
+var (
+	testKeysOnce sync.Once
+	testKeys     []*paillier.PrivateKey
+)
+
+// ownerKey retourne l'une des deux clés de taille réelle générées une seule
+// fois pour les tests : la clé initiale d'un propriétaire (0) et celle vers
+// laquelle elle est renouvelée (1)
+func ownerKey(tb testing.TB, index int) *paillier.PrivateKey {
+	testKeysOnce.Do(func() {
+		for i := 0; i < 2; i++ {
+			sk, err := paillier.GenerateKey(rand.Reader, paillier.MinModulusBits)
+			if err != nil {
+				tb.Fatalf("GenerateKey: %v", err)
+			}
+			testKeys = append(testKeys, sk)
+		}
+	})
+	if len(testKeys) != 2 {
+		tb.Fatal("no test key")
+	}
+	return testKeys[index]
+}
+
+// thresholdKey est une clé à seuil 2-sur-3 générée hors ligne : la génération
+// de premiers sûrs de 1024 bits prend trop de temps pour un test unitaire.
+// Les éléments publics sont écrits dans le format compact attendu par
+// AddThresholdVerifier
+type thresholdKey struct {
+	N                string               `json:"n"`
+	Threshold        int                  `json:"threshold"`
+	Parties          int                  `json:"parties"`
+	V                string               `json:"v"`
+	VerificationKeys []string             `json:"verification_keys"`
+	Shares           []*paillier.KeyShare `json:"shares"`
+}
+
+func loadThresholdKey(tb testing.TB) *thresholdKey {
+	data, err := os.ReadFile("testdata/threshold_key.json")
+	if err != nil {
+		tb.Fatalf("ReadFile: %v", err)
+	}
+	var key thresholdKey
+	err = json.Unmarshal(data, &key)
+	if err != nil {
+		tb.Fatalf("Unmarshal threshold key: %v", err)
+	}
+	return &key
+}
+
+// Bornes déclarées par défaut pour chaque champ chiffré
+var testFieldBounds = map[string][2]int64{
+	"vehicle_type":              {0, 10},
+	"purchase_mileage":          {0, 1000000},
+	"year":                      {1900, 2100},
+	"speeding":                  {0, 1000},
+	"hard_accelerations":        {0, 1000},
+	"emergency_brakes":          {0, 1000},
+	"unsafe_distance":           {0, 1000},
+	"high_risk_zones":           {0, 1000},
+	"traffic_signal_compliance": {0, 1000},
+	"night_driving":             {0, 1000},
+	"mileage":                   {0, 100000},
+}
+
+var (
+	testVehicle = map[string]string{
+		"vehicle_type":     "2",
+		"purchase_mileage": "15000",
+		"year":             "2019",
+	}
+	testTrip = map[string]string{
+		"speeding":                  "3",
+		"hard_accelerations":        "2",
+		"emergency_brakes":          "1",
+		"unsafe_distance":           "4",
+		"high_risk_zones":           "2",
+		"traffic_signal_compliance": "95",
+		"night_driving":             "1",
+		"mileage":                   "120",
+	}
+	testWeights = policy.CriteriaWeights{
+		CriteriaWeightsID:  "weights1",
+		WeightTraffic:      1,
+		WeightSpeed:        5,
+		WeightAcceleration: 3,
+		WeightBraking:      4,
+		WeightDistance:     2,
+		WeightZone:         3,
+		WeightTime:         2,
+		Alpha:              2,
+		Beta:               3,
+	}
+)
+
+// referencePremium calcule en clair la prime attendue :
+// Σ véhicule + Alpha * kilométrage + Beta * (Σ poids * critère - poids * respect des feux),
+// arrondie à l'unité en s'éloignant de zéro comme paillier.RoundDiv
+func referencePremium(tb testing.TB, vehicle, trip map[string]string, weights policy.CriteriaWeights) int {
+	coefficients := referenceCoefficients(weights)
+	total := new(big.Rat)
+	for _, field := range telematics.VehicleFields {
+		total.Add(total, parseRat(tb, vehicle[field]))
+	}
+	for _, field := range telematics.TripFields {
+		term := parseRat(tb, trip[field])
+		total.Add(total, term.Mul(term, new(big.Rat).SetInt(coefficients[field])))
+	}
+
+	numerator := new(big.Int).Abs(total.Num())
+	numerator.Lsh(numerator, 1).Add(numerator, total.Denom())
+	rounded := numerator.Div(numerator, new(big.Int).Lsh(total.Denom(), 1))
+	if total.Sign() < 0 {
+		rounded.Neg(rounded)
+	}
+	return int(rounded.Int64())
+}
+
+// referenceCoefficients retourne le coefficient de chaque champ du trajet dans
+// la prime
+func referenceCoefficients(weights policy.CriteriaWeights) map[string]*big.Int {
+	behaviour := func(weight int) *big.Int {
+		return big.NewInt(int64(weights.Beta * weight))
+	}
+	return map[string]*big.Int{
+		"speeding":                  behaviour(weights.WeightSpeed),
+		"hard_accelerations":        behaviour(weights.WeightAcceleration),
+		"emergency_brakes":          behaviour(weights.WeightBraking),
+		"unsafe_distance":           behaviour(weights.WeightDistance),
+		"high_risk_zones":           behaviour(weights.WeightZone),
+		"traffic_signal_compliance": behaviour(-weights.WeightTraffic),
+		"night_driving":             behaviour(weights.WeightTime),
+		"mileage":                   big.NewInt(int64(weights.Alpha)),
+	}
+}
+
+func parseRat(tb testing.TB, value string) *big.Rat {
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		tb.Fatalf("invalid test value %q", value)
+	}
+	return rat
+}
+
+// testNetwork exécute les transactions du contrat sur un MockStub
+type testNetwork struct {
+	t        *testing.T
+	contract *SmartContract
+	stub     *queryStub
+	ctx      *contractapi.TransactionContext
+	txCount  int
+	scales   map[string]int64 // Échelles des FieldSpec déclarés
+}
+
+// testIdentity est l'identité du client qui soumet les transactions, telle
+// que l'expose cid.ClientIdentity pour un certificat émis par Fabric CA
+type testIdentity struct {
+	mspID        string
+	enrollmentID string
+}
+
+// testInsurer est l'identité par défaut : l'assureur, qui enregistre les clés
+// des propriétaires
+var testInsurer = &testIdentity{mspID: "org1-insurance-com", enrollmentID: "car_insurance1"}
+
+func (i *testIdentity) GetID() (string, error) {
+	return "x509::CN=" + i.enrollmentID + "::CN=ca1.org1.insurance.com", nil
+}
+
+func (i *testIdentity) GetMSPID() (string, error) {
+	return i.mspID, nil
+}
+
+func (i *testIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	if attrName == "hf.EnrollmentID" {
+		return i.enrollmentID, true, nil
+	}
+	return "", false, nil
+}
+
+func (i *testIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	value, found, _ := i.GetAttributeValue(attrName)
+	if !found || value != attrValue {
+		return fmt.Errorf("attribute %s does not equal %s", attrName, attrValue)
+	}
+	return nil
+}
+
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+func newTestNetwork(t *testing.T) *testNetwork {
+	stub := newQueryStub("securedrive")
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(testInsurer)
+
+	n := &testNetwork{
+		t:        t,
+		contract: &SmartContract{},
+		stub:     stub,
+		ctx:      ctx,
+		scales:   make(map[string]int64),
+	}
+	n.begin(nil)
+	return n
+}
+
+// begin démarre une nouvelle transaction dont le transient map est transient
+func (n *testNetwork) begin(transient map[string][]byte) *contractapi.TransactionContext {
+	n.stub.MockTransactionEnd("")
+	n.txCount++
+	n.stub.MockTransactionStart(fmt.Sprintf("tx%d", n.txCount))
+	n.stub.TransientMap = transient
+	return n.ctx
+}
+
+// as soumet les transactions suivantes sous l'identité identity
+func (n *testNetwork) as(identity *testIdentity) {
+	n.ctx.SetClientIdentity(identity)
+}
+
+// must échoue le test si la transaction name a retourné une erreur
+func (n *testNetwork) must(name string, err error) {
+	n.t.Helper()
+	if err != nil {
+		n.t.Fatalf("%s: %v", name, err)
+	}
+}
+
+// checkError vérifie qu'err contient want, ou qu'elle est nil si want est vide
+func checkError(tb testing.TB, err error, want string) {
+	tb.Helper()
+	switch {
+	case want == "" && err != nil:
+		tb.Fatalf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		tb.Fatalf("expected error containing %q, got nil", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		tb.Fatalf("expected error containing %q, got %q", want, err.Error())
+	}
+}
+
+// addVerifier enregistre la clé publique de sk pour ownerID, en décimal comme
+// le backend
+func (n *testNetwork) addVerifier(ownerID string, sk *paillier.PrivateKey) {
+	n.t.Helper()
+	n.must("AddVerifier", n.contract.AddVerifier(n.begin(nil), ownerID, sk.N.String(), sk.NSquare.String(), 0))
+}
+
+// addFieldSpecs déclare les bornes par défaut de chaque champ chiffré, avec
+// l'échelle de virgule fixe donnée (1 par défaut)
+func (n *testNetwork) addFieldSpecs(scales map[string]int64) {
+	n.t.Helper()
+	for field, bounds := range testFieldBounds {
+		scale := scales[field]
+		if scale == 0 {
+			scale = 1
+		}
+		n.must("AddFieldSpec", n.contract.AddFieldSpec(n.begin(nil), field, bounds[0]*scale, bounds[1]*scale, scale))
+		n.scales[field] = scale
+	}
+}
+
+func (n *testNetwork) addWeights(weights policy.CriteriaWeights) {
+	n.t.Helper()
+	n.must("AddCriteriaWeights", n.contract.AddCriteriaWeights(n.begin(nil), weights.CriteriaWeightsID,
+		weights.WeightTraffic, weights.WeightSpeed, weights.WeightAcceleration, weights.WeightBraking,
+		weights.WeightDistance, weights.WeightZone, weights.WeightTime, weights.Alpha, weights.Beta))
+}
+
+// clientValue est un champ chiffré côté client : le chiffré, le clair signé
+// encodé en virgule fixe et l'aléa du chiffrement, nécessaires aux preuves
+type clientValue struct {
+	c *paillier.Ciphertext
+	m *big.Int
+	r *big.Int
+}
+
+// encryptValue chiffre côté client la valeur décimale d'un champ, encodée
+// selon l'échelle de son FieldSpec
+func (n *testNetwork) encryptValue(publicKey *paillier.PublicKey, field, value string) *clientValue {
+	n.t.Helper()
+	scale := n.scales[field]
+	if scale == 0 {
+		scale = 1
+	}
+
+	m, err := paillier.EncodeFixedPoint(value, scale)
+	if err != nil {
+		n.t.Fatalf("EncodeFixedPoint(%s): %v", field, err)
+	}
+	plaintext, err := publicKey.EncodeSigned(m)
+	if err != nil {
+		n.t.Fatalf("EncodeSigned(%s): %v", field, err)
+	}
+	r, err := rand.Int(rand.Reader, publicKey.N)
+	if err != nil {
+		n.t.Fatalf("rand.Int: %v", err)
+	}
+	c, err := publicKey.EncryptWithRandom(plaintext, r)
+	if err != nil {
+		n.t.Fatalf("EncryptWithRandom(%s): %v", field, err)
+	}
+	return &clientValue{c: c, m: m, r: r}
+}
+
+func (n *testNetwork) encryptValues(publicKey *paillier.PublicKey, fields []string, values map[string]string) map[string]*clientValue {
+	encrypted := make(map[string]*clientValue, len(fields))
+	for _, field := range fields {
+		encrypted[field] = n.encryptValue(publicKey, field, values[field])
+	}
+	return encrypted
+}
+
+// addEncryptedVehicle chiffre côté client et enregistre les données d'un véhicule
+func (n *testNetwork) addEncryptedVehicle(vehicleID, ownerID string, publicKey *paillier.PublicKey, values map[string]string) map[string]*clientValue {
+	n.t.Helper()
+	encrypted := n.encryptValues(publicKey, telematics.VehicleFields, values)
+	n.must("AddEncryptedVehicleData", n.contract.AddEncryptedVehicleData(n.begin(nil), vehicleID,
+		encrypted["vehicle_type"].c.Encode(), encrypted["purchase_mileage"].c.Encode(), encrypted["year"].c.Encode(), ownerID, nil))
+	return encrypted
+}
+
+// addEncryptedTrip chiffre côté client et enregistre les données d'un trajet
+func (n *testNetwork) addEncryptedTrip(vehicleID, tripID, date string, publicKey *paillier.PublicKey, values map[string]string) map[string]*clientValue {
+	n.t.Helper()
+	encrypted := n.encryptValues(publicKey, telematics.TripFields, values)
+	n.must("AddEncryptedTripData", n.addTripCiphertexts(n.begin(nil), vehicleID, tripID, date, encrypted, nil))
+	return encrypted
+}
+
+// addTripCiphertexts soumet AddEncryptedTripData avec les chiffrés dans
+// l'ordre de telematics.TripFields
+func (n *testNetwork) addTripCiphertexts(ctx contractapi.TransactionContextInterface, vehicleID, tripID, date string, encrypted map[string]*clientValue, proofs []*FieldProof) error {
+	args := make([]string, len(telematics.TripFields))
+	for i, field := range telematics.TripFields {
+		args[i] = encrypted[field].c.Encode()
+	}
+	return n.contract.AddEncryptedTripData(ctx, vehicleID, tripID, date,
+		args[0], args[1], args[2], args[3], args[4], args[5], args[6], args[7], proofs)
+}
+
+// proveRanges prouve que chaque valeur chiffrée côté client respecte les
+// bornes de son FieldSpec, déclarées par addFieldSpecs
+func (n *testNetwork) proveRanges(publicKey *paillier.PublicKey, key string, encrypted map[string]*clientValue) []*FieldProof {
+	n.t.Helper()
+	proofs := &telematics.EncryptedFieldProofs{Range: make(map[string]*paillier.RangeProof, len(encrypted))}
+	for field, value := range encrypted {
+		scale := big.NewInt(n.scales[field])
+		min := new(big.Int).Mul(big.NewInt(testFieldBounds[field][0]), scale)
+		max := new(big.Int).Mul(big.NewInt(testFieldBounds[field][1]), scale)
+		proof, err := publicKey.ProveRange(rand.Reader, value.c, value.m, value.r, min, max, crypto.FieldContext(key, field))
+		n.must("ProveRange", err)
+		proofs.Range[field] = proof
+	}
+	return fieldProofs(n.t, proofs)
+}
+
+// onChainValues reconstitue côté client les champs chiffrés sur la chaîne avec
+// la graine nonce : l'aléa de chaque champ est dérivé comme par EncryptFields
+func (n *testNetwork) onChainValues(publicKey *paillier.PublicKey, key string, fields []string, values map[string]string, nonce []byte) map[string]*clientValue {
+	n.t.Helper()
+	encrypted := make(map[string]*clientValue, len(fields))
+	for _, field := range fields {
+		m, err := paillier.EncodeFixedPoint(values[field], n.scales[field])
+		n.must("EncodeFixedPoint", err)
+		plaintext, err := publicKey.EncodeSigned(m)
+		n.must("EncodeSigned", err)
+		r, err := publicKey.DeriveRandom(nonce, crypto.FieldContext(key, field))
+		n.must("DeriveRandom", err)
+		c, err := publicKey.EncryptWithRandom(plaintext, r)
+		n.must("EncryptWithRandom", err)
+		encrypted[field] = &clientValue{c: c, m: m, r: r}
+	}
+	return encrypted
+}
+
+// addVehicle enregistre les données d'un véhicule chiffrées sur la chaîne,
+// bornées par leurs FieldSpec, et retourne leurs valeurs côté client
+func (n *testNetwork) addVehicle(vehicleID, ownerID string, publicKey *paillier.PublicKey, values map[string]string) map[string]*clientValue {
+	n.t.Helper()
+	transient := transientValues(n.t, "vehicle", values)
+	n.must("AddVehicleData", n.contract.AddVehicleData(n.begin(transient), vehicleID, ownerID))
+	return n.onChainValues(publicKey, telematics.VehicleKey(vehicleID), telematics.VehicleFields, values, transient["nonce"])
+}
+
+// addTrip enregistre les données d'un trajet chiffrées sur la chaîne, bornées
+// par leurs FieldSpec, et retourne leurs valeurs côté client
+func (n *testNetwork) addTrip(vehicleID, tripID, date, ownerID string, publicKey *paillier.PublicKey, values map[string]string) map[string]*clientValue {
+	n.t.Helper()
+	transient := transientValues(n.t, "trip", values)
+	n.must("AddTripData", n.contract.AddTripData(n.begin(transient), vehicleID, tripID, date, ownerID, 0))
+	return n.onChainValues(publicKey, telematics.TripKey(tripID), telematics.TripFields, values, transient["nonce"])
+}
+
+// calculate calcule la prime chiffrée d'un trajet avec des poids publics
+func (n *testNetwork) calculate(vehicleID, tripID, weightsID string) *billing.EncryptedCalculationResult {
+	n.t.Helper()
+	result, err := n.contract.CalculateInsurancePremium(n.begin(nil), vehicleID, tripID, weightsID)
+	n.must("CalculateInsurancePremium", err)
+	return decodeResult(n.t, result)
+}
+
+// decodeResult relit le chiffré et l'aléa d'un résultat retourné par le contrat
+func decodeResult(tb testing.TB, result *CalculationResult) *billing.EncryptedCalculationResult {
+	tb.Helper()
+	primeTotale := parseCiphertext(tb, result.PrimeTotale)
+	r, ok := new(big.Int).SetString(result.R, 10)
+	if !ok {
+		tb.Fatalf("invalid R %q", result.R)
+	}
+	return &billing.EncryptedCalculationResult{
+		ResultID:    result.ResultID,
+		PrimeTotale: primeTotale,
+		R:           r,
+		TripID:      result.TripID,
+		Scale:       result.Scale,
+		KeyVersion:  result.KeyVersion,
+		SlotBits:    result.SlotBits,
+		Slot:        result.Slot,
+		WeightsID:   result.WeightsID,
+	}
+}
+
+func parseCiphertext(tb testing.TB, s string) *paillier.Ciphertext {
+	tb.Helper()
+	c, err := paillier.ParseCiphertext(s)
+	if err != nil {
+		tb.Fatalf("ParseCiphertext: %v", err)
+	}
+	return c
+}
+
+// exchanged convertit un objet du domaine en sa forme échangée avec le
+// contrat, où les entiers sont des chaînes décimales
+func exchanged(tb testing.TB, value, dto interface{}) {
+	tb.Helper()
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		tb.Fatalf("Marshal: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(valueJSON))
+	decoder.UseNumber()
+	var fields interface{}
+	err = decoder.Decode(&fields)
+	if err != nil {
+		tb.Fatalf("Decode: %v", err)
+	}
+	dtoJSON, err := json.Marshal(numbersAsStrings(fields))
+	if err != nil {
+		tb.Fatalf("Marshal: %v", err)
+	}
+	err = json.Unmarshal(dtoJSON, dto)
+	if err != nil {
+		tb.Fatalf("Unmarshal %T: %v", dto, err)
+	}
+}
+
+func numbersAsStrings(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case map[string]interface{}:
+		for key, field := range v {
+			v[key] = numbersAsStrings(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = numbersAsStrings(item)
+		}
+	}
+	return value
+}
+
+// fieldProofs convertit les preuves d'un client en paramètre de transaction,
+// une entrée par champ
+func fieldProofs(tb testing.TB, proofs *telematics.EncryptedFieldProofs) []*FieldProof {
+	tb.Helper()
+	var byKind struct {
+		Range       map[string]*RangeProof          `json:"range"`
+		Knowledge   map[string]*KnowledgeProof      `json:"knowledge"`
+		Commitments map[string]string               `json:"commitments"`
+		Link        map[string]*CommitmentLinkProof `json:"link"`
+	}
+	exchanged(tb, proofs, &byKind)
+
+	byField := make(map[string]*FieldProof)
+	entry := func(field string) *FieldProof {
+		if byField[field] == nil {
+			byField[field] = &FieldProof{Field: field}
+		}
+		return byField[field]
+	}
+	for field, proof := range byKind.Range {
+		entry(field).Range = proof
+	}
+	for field, proof := range byKind.Knowledge {
+		entry(field).Knowledge = proof
+	}
+	for field, commitment := range byKind.Commitments {
+		entry(field).Commitment = commitment
+	}
+	for field, proof := range byKind.Link {
+		entry(field).Link = proof
+	}
+
+	fieldProofs := make([]*FieldProof, 0, len(byField))
+	for _, proof := range byField {
+		fieldProofs = append(fieldProofs, proof)
+	}
+	return fieldProofs
+}
+
+// decrypt calcule hors chaîne le témoin r′ de la prime d'un trajet avec la clé
+// privée du propriétaire et enregistre la prime déchiffrée
+func (n *testNetwork) decrypt(result *billing.EncryptedCalculationResult, sk *paillier.PrivateKey) int {
+	n.t.Helper()
+	rPrime, err := sk.ComputeRPrime(result.R)
+	n.must("ComputeRPrime", err)
+	decrypted, err := n.contract.DecryptInsurancePremiumAndUpdate(n.begin(nil), result.TripID, rPrime.String())
+	n.must("DecryptInsurancePremiumAndUpdate", err)
+	return decrypted.DecryptedPrime
+}
+
+// newPricedNetwork enregistre un propriétaire, les FieldSpec, un véhicule, un
+// trajet et des poids : tout ce qu'il faut pour calculer une prime
+func newPricedNetwork(t *testing.T) (*testNetwork, map[string]*clientValue, map[string]*clientValue) {
+	sk := ownerKey(t, 0)
+	n := newTestNetwork(t)
+	n.addVerifier("owner1", sk)
+	n.addFieldSpecs(nil)
+	vehicle := n.addVehicle("vehicle1", "owner1", &sk.PublicKey, testVehicle)
+	trip := n.addTrip("vehicle1", "trip1", "2024-03-15", "owner1", &sk.PublicKey, testTrip)
+	n.addWeights(testWeights)
+	return n, vehicle, trip
+}
+
+func TestNewChaincode(t *testing.T) {
+	_, err := contractapi.NewChaincode(&SmartContract{
+		Contract: contractapi.Contract{
+			UnknownTransaction: unknownTransaction,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewChaincode: %v", err)
+	}
+}
+
+func TestGetEvaluateTransactions(t *testing.T) {
+	contractType := reflect.TypeOf(&SmartContract{})
+	for _, name := range (&SmartContract{}).GetEvaluateTransactions() {
+		if _, ok := contractType.MethodByName(name); !ok {
+			t.Errorf("evaluate transaction %s is not a method of SmartContract", name)
+		}
+	}
+}
+
+// invocationStub simule l'appel d'une fonction : le MockStub ne renseigne ses
+// arguments que dans MockInvoke
+type invocationStub struct {
+	*shimtest.MockStub
+	function string
+}
+
+func (s *invocationStub) GetFunctionAndParameters() (string, []string) {
+	return s.function, nil
+}
+
+func TestUnknownTransaction(t *testing.T) {
+	tests := []struct {
+		function string
+		wantErr  string
+	}{
+		{"addDecryptor", "Function 'addDecryptor' is no longer supported"},
+		{"decryptInsurancePremiumAndUpdateWithoutParams", "private keys must stay off-chain"},
+		{"queryCar", "Invalid function name 'queryCar'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.function, func(t *testing.T) {
+			ctx := &contractapi.TransactionContext{}
+			ctx.SetStub(&invocationStub{MockStub: shimtest.NewMockStub("securedrive", nil), function: tt.function})
+			checkError(t, unknownTransaction(ctx), tt.wantErr)
+		})
+	}
+}
+
+// TestInvokeTypedTransactions appelle les transactions par le chaincode, qui
+// valide les paramètres et les résultats selon le schéma de leurs types
+func TestInvokeTypedTransactions(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n, vehicle, _ := newPricedNetwork(t)
+	n.decrypt(n.calculate("vehicle1", "trip1", "weights1"), sk)
+
+	chaincode, err := contractapi.NewChaincode(&SmartContract{})
+	n.must("NewChaincode", err)
+	stub := shimtest.NewMockStub("securedrive", chaincode)
+	stub.MockTransactionStart("copy")
+	for key, value := range n.stub.State {
+		n.must("PutState", stub.PutState(key, value))
+	}
+	stub.MockTransactionEnd("copy")
+
+	tests := []struct {
+		function string
+		args     []string
+		want     interface{}
+	}{
+		{"QueryVehicleData", []string{"vehicle1"}, &VehicleData{}},
+		{"QueryTripData", []string{"trip1"}, &TripData{}},
+		{"QueryEncryptedCalculationResult", []string{"result_trip1"}, &CalculationResult{}},
+		{"QueryPrime", []string{"trip1"}, &Prime{}},
+		{"AddEncryptedVehicleData", []string{"vehicle2", vehicle["vehicle_type"].c.Encode(), vehicle["purchase_mileage"].c.Encode(), vehicle["year"].c.Encode(), "owner1", "[]"}, nil},
+	}
+	for i, tt := range tests {
+		t.Run(tt.function, func(t *testing.T) {
+			args := [][]byte{[]byte(tt.function)}
+			for _, arg := range tt.args {
+				args = append(args, []byte(arg))
+			}
+			response := stub.MockInvoke(fmt.Sprintf("invoke%d", i), args)
+			if response.Status != shim.OK {
+				t.Fatalf("%s: %s", tt.function, response.Message)
+			}
+			if tt.want != nil {
+				err := json.Unmarshal(response.Payload, tt.want)
+				if err != nil {
+					t.Fatalf("Unmarshal %T: %v", tt.want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPremiumEndToEnd(t *testing.T) {
+	sk := ownerKey(t, 0)
+	negativeWeights := testWeights
+	negativeWeights.WeightTraffic = 100
+
+	tests := []struct {
+		name     string
+		onChain  bool // Chiffrement sur la chaîne via le transient map
+		slotBits int  // Trajet packé
+		scales   map[string]int64
+		trip     map[string]string
+		weights  policy.CriteriaWeights
+	}{
+		{name: "client-side encryption", trip: testTrip, weights: testWeights},
+		{name: "on-chain encryption", onChain: true, trip: testTrip, weights: testWeights},
+		{name: "packed trip", onChain: true, slotBits: 64, trip: testTrip, weights: testWeights},
+		{name: "negative premium", onChain: true, trip: testTrip, weights: negativeWeights},
+		{
+			name:    "fixed-point mileage",
+			scales:  map[string]int64{"mileage": 10},
+			trip:    withValue(testTrip, "mileage", "120.5"),
+			weights: testWeights,
+		},
+		{
+			name:    "fixed-point rounding",
+			onChain: true,
+			scales:  map[string]int64{"mileage": 100, "speeding": 10},
+			trip:    withValue(withValue(testTrip, "mileage", "12.25"), "speeding", "0.5"),
+			weights: policy.CriteriaWeights{CriteriaWeightsID: "weights1", WeightSpeed: 1, Alpha: 1, Beta: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addVerifier("owner1", sk)
+			n.addFieldSpecs(tt.scales)
+			n.addWeights(tt.weights)
+
+			if tt.onChain {
+				n.must("AddVehicleData", n.contract.AddVehicleData(n.begin(transientValues(t, "vehicle", testVehicle)), "vehicle1", "owner1"))
+				n.must("AddTripData", n.contract.AddTripData(n.begin(transientValues(t, "trip", tt.trip)), "vehicle1", "trip1", "2024-03-15", "owner1", tt.slotBits))
+			} else {
+				// Le client prouve que chaque champ respecte son FieldSpec
+				vehicle := n.encryptValues(&sk.PublicKey, telematics.VehicleFields, testVehicle)
+				n.must("AddEncryptedVehicleData", n.contract.AddEncryptedVehicleData(n.begin(nil), "vehicle1",
+					vehicle["vehicle_type"].c.Encode(), vehicle["purchase_mileage"].c.Encode(), vehicle["year"].c.Encode(), "owner1",
+					n.proveRanges(&sk.PublicKey, telematics.VehicleKey("vehicle1"), vehicle)))
+				trip := n.encryptValues(&sk.PublicKey, telematics.TripFields, tt.trip)
+				n.must("AddEncryptedTripData", n.addTripCiphertexts(n.begin(nil), "vehicle1", "trip1", "2024-03-15", trip,
+					n.proveRanges(&sk.PublicKey, telematics.TripKey("trip1"), trip)))
+			}
+
+			result := n.calculate("vehicle1", "trip1", tt.weights.CriteriaWeightsID)
+			if result.ResultID != billing.ResultID("trip1") || result.TripID != "trip1" {
+				t.Fatalf("unexpected result identifiers %s, %s", result.ResultID, result.TripID)
+			}
+			if tt.slotBits != 0 && result.SlotBits != tt.slotBits {
+				t.Fatalf("packed result has SlotBits %d, expected %d", result.SlotBits, tt.slotBits)
+			}
+
+			want := referencePremium(t, testVehicle, tt.trip, tt.weights)
+			if got := n.decrypt(result, sk); got != want {
+				t.Fatalf("decrypted premium %d, expected %d", got, want)
+			}
+
+			// Le trajet conserve l'échelle de chaque champ
+			stored, err := n.contract.QueryTripData(n.begin(nil), "trip1")
+			n.must("QueryTripData", err)
+			if scale := tt.scales["mileage"]; scale != 0 && stored.Scales["mileage"] != scale {
+				t.Fatalf("trip records mileage scale %d, expected %d", stored.Scales["mileage"], scale)
+			}
+
+			prime, err := n.contract.QueryPrime(n.begin(nil), "trip1")
+			n.must("QueryPrime", err)
+			if prime.Prime != want || prime.Date != "2024-03-15" || prime.ResultID != result.ResultID {
+				t.Fatalf("unexpected recorded prime %+v", prime)
+			}
+
+			verification, err := n.contract.VerifyPrime(n.begin(nil), "trip1")
+			n.must("VerifyPrime", err)
+			if !verification.Verified || verification.Prime != want {
+				t.Fatalf("unexpected prime verification %+v", verification)
+			}
+		})
+	}
+}
+
+func withValue(values map[string]string, field, value string) map[string]string {
+	updated := make(map[string]string, len(values))
+	for name, v := range values {
+		updated[name] = v
+	}
+	updated[field] = value
+	return updated
+}
+
+// transientValues construit le transient map de AddVehicleData ou AddTripData
+func transientValues(tb testing.TB, name string, values map[string]string) map[string][]byte {
+	valuesJSON, err := json.Marshal(values)
+	if err != nil {
+		tb.Fatalf("Marshal %s: %v", name, err)
+	}
+	return map[string][]byte{
+		name:    valuesJSON,
+		"nonce": randomSeed(tb),
+	}
+}
+
+func randomSeed(tb testing.TB) []byte {
+	seed := make([]byte, paillier.MinSeedSize)
+	_, err := rand.Read(seed)
+	if err != nil {
+		tb.Fatalf("rand.Read: %v", err)
+	}
+	return seed
+}
+
+func TestRerandomizedPremium(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n, _, _ := newPricedNetwork(t)
+
+	seed := randomSeed(t)
+	result, err := n.contract.CalculateInsurancePremium(n.begin(map[string][]byte{"rerandomize": seed}), "vehicle1", "trip1", "weights1")
+	n.must("CalculateInsurancePremium", err)
+
+	if got, want := n.decrypt(decodeResult(t, result), sk), referencePremium(t, testVehicle, testTrip, testWeights); got != want {
+		t.Fatalf("decrypted premium %d, expected %d", got, want)
+	}
+
+	_, err = n.contract.CalculateInsurancePremium(n.begin(map[string][]byte{"rerandomize": seed}), "vehicle1", "trip1", "weights1")
+	checkError(t, err, "Nonce has already been used")
+}
+
+func TestDecryptInsurancePremiumWithProof(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n, _, _ := newPricedNetwork(t)
+	result := n.calculate("vehicle1", "trip1", "weights1")
+
+	plaintext, proof, err := sk.ProveDecryption(rand.Reader, result.PrimeTotale, []byte(result.ResultID))
+	n.must("ProveDecryption", err)
+	validProof := newDecryptionProof(proof)
+
+	tests := []struct {
+		name      string
+		plaintext string
+		proof     *DecryptionProof
+		wantErr   string
+	}{
+		{"invalid plaintext", "12a", validProof, "Failed to parse Prime into *big.Int"},
+		{"invalid proof", plaintext.String(), &DecryptionProof{A: "pz1:", Z: validProof.Z}, "Failed to parse DecryptionProof"},
+		{"wrong plaintext", new(big.Int).Add(plaintext, big.NewInt(1)).String(), validProof, "Failed to verify decryption proof"},
+		{"valid proof", plaintext.String(), validProof, ""},
+		{"already recorded", plaintext.String(), validProof, "Prime for this TripID has already been recorded"},
+	}
+
+	for _, tt := range tests {
+		decrypted, err := n.contract.DecryptInsurancePremiumWithProof(n.begin(nil), "trip1", tt.plaintext, tt.proof)
+		checkError(t, err, tt.wantErr)
+		if err == nil && decrypted.DecryptedPrime != referencePremium(t, testVehicle, testTrip, testWeights) {
+			t.Fatalf("%s: decrypted premium %d", tt.name, decrypted.DecryptedPrime)
+		}
+	}
+
+	verification, err := n.contract.VerifyPrime(n.begin(nil), "trip1")
+	n.must("VerifyPrime", err)
+	if !verification.Verified {
+		t.Fatal("prime recorded with a decryption proof failed to verify")
+	}
+}
+
+func TestDecryptInsurancePremiumAndUpdate(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n, _, _ := newPricedNetwork(t)
+	result := n.calculate("vehicle1", "trip1", "weights1")
+	rPrime, err := sk.ComputeRPrime(result.R)
+	n.must("ComputeRPrime", err)
+
+	tests := []struct {
+		name    string
+		tripID  string
+		rPrime  string
+		wantErr string
+	}{
+		{"invalid r_prime", "trip1", "not-a-number", "Failed to parse r_prime into *big.Int"},
+		{"missing result", "trip2", rPrime.String(), "EncryptedCalculationResult not found for the given ResultID"},
+		{"wrong r_prime", "trip1", new(big.Int).Add(rPrime, big.NewInt(1)).String(), "Failed to decrypt prime"},
+		{"valid r_prime", "trip1", rPrime.String(), ""},
+		{"already recorded", "trip1", rPrime.String(), "Prime for this TripID has already been recorded"},
+	}
+
+	for _, tt := range tests {
+		_, err := n.contract.DecryptInsurancePremiumAndUpdate(n.begin(nil), tt.tripID, tt.rPrime)
+		if err == nil && tt.wantErr != "" || err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("%s: unexpected error %v", tt.name, err)
+		}
+	}
+
+	_, err = n.contract.VerifyPrime(n.begin(nil), "trip2")
+	checkError(t, err, "Prime not found for the given TripID")
+}
+
+func TestCalculateInsurancePremiumErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(n *testNetwork)
+		vehicle string
+		trip    string
+		weights string
+		wantErr string
+	}{
+		{name: "missing weights", vehicle: "vehicle1", trip: "trip1", weights: "weights2", wantErr: "Criteria weights not found for the given CriteriaWeightsID"},
+		{name: "missing vehicle", vehicle: "vehicle2", trip: "trip1", weights: "weights1", wantErr: "Vehicle data not found for the given VehicleID"},
+		{name: "missing trip", vehicle: "vehicle1", trip: "trip2", weights: "weights1", wantErr: "Trip data not found for the given TripID"},
+		{
+			name: "trip of another vehicle",
+			setup: func(n *testNetwork) {
+				n.addEncryptedVehicle("vehicle2", "owner1", &ownerKey(n.t, 0).PublicKey, testVehicle)
+			},
+			vehicle: "vehicle2", trip: "trip1", weights: "weights1",
+			wantErr: "Trip data does not belong to the given VehicleID",
+		},
+		{
+			name: "deleted verifier",
+			setup: func(n *testNetwork) {
+				n.must("DeleteVerifier", n.contract.DeleteVerifier(n.begin(nil), "owner1"))
+			},
+			vehicle: "vehicle1", trip: "trip1", weights: "weights1",
+			wantErr: "Verifier not found for the given OwnerID",
+		},
+		{
+			name: "weights exceeding the prime range",
+			setup: func(n *testNetwork) {
+				weights := testWeights
+				weights.CriteriaWeightsID = "weights2"
+				weights.Alpha = 1 << 60
+				n.addWeights(weights)
+			},
+			vehicle: "vehicle1", trip: "trip1", weights: "weights2",
+			wantErr: "Premium calculation could exceed the range of the prime value",
+		},
+		{
+			name: "scale differing from the FieldSpec",
+			setup: func(n *testNetwork) {
+				var trip telematics.EncryptedTripData
+				n.must("Unmarshal trip", json.Unmarshal(n.stub.State["trip_trip1"], &trip))
+				trip.Scales["mileage"] = 10
+				data, err := json.Marshal(&trip)
+				n.must("Marshal trip", err)
+				n.begin(nil)
+				n.must("PutState", n.stub.PutState("trip_trip1", data))
+			},
+			vehicle: "vehicle1", trip: "trip1", weights: "weights1",
+			wantErr: "Field 'mileage' was encoded with scale 10, but its FieldSpec declares scale 1",
+		},
+		{
+			name: "trip without range proofs",
+			setup: func(n *testNetwork) {
+				n.addEncryptedTrip("vehicle1", "trip2", "2024-03-15", &ownerKey(n.t, 0).PublicKey, testTrip)
+			},
+			vehicle: "vehicle1", trip: "trip2", weights: "weights1",
+			wantErr: "Vehicle and trip data must carry a range proof for every field to be priced",
+		},
+		{name: "valid trip", vehicle: "vehicle1", trip: "trip1", weights: "weights1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, _, _ := newPricedNetwork(t)
+			if tt.setup != nil {
+				tt.setup(n)
+			}
+			_, err := n.contract.CalculateInsurancePremium(n.begin(nil), tt.vehicle, tt.trip, tt.weights)
+			checkError(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("undeclared FieldSpec", func(t *testing.T) {
+		sk := ownerKey(t, 0)
+		n := newTestNetwork(t)
+		n.addVerifier("owner1", sk)
+		n.addEncryptedVehicle("vehicle1", "owner1", &sk.PublicKey, testVehicle)
+		n.addEncryptedTrip("vehicle1", "trip1", "2024-03-15", &sk.PublicKey, testTrip)
+		n.addWeights(testWeights)
+
+		_, err := n.contract.CalculateInsurancePremium(n.begin(nil), "vehicle1", "trip1", "weights1")
+		checkError(t, err, "must be declared to bound the premium")
+	})
+
+	t.Run("short rerandomization seed", func(t *testing.T) {
+		n, _, _ := newPricedNetwork(t)
+		_, err := n.contract.CalculateInsurancePremium(n.begin(map[string][]byte{"rerandomize": []byte("short")}), "vehicle1", "trip1", "weights1")
+		checkError(t, err, "Transient 'rerandomize' seed must contain at least")
+	})
+}
+
+func TestConfidentialPremium(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n, _, trip := newPricedNetwork(t)
+	params := pedersen.DefaultParams()
+
+	// L'assureur s'engage sur les coefficients de sa tarification
+	coefficients := referenceCoefficients(testWeights)
+	commitments := make(map[string]*pedersen.Commitment)
+	openings := make(map[string]*big.Int)
+	for field, coefficient := range coefficients {
+		commitment, rho, err := params.Commit(rand.Reader, coefficient)
+		n.must("Commit", err)
+		commitments[field], openings[field] = commitment, rho
+	}
+	encoded := formatCommitments(commitments)
+
+	weightsTests := []struct {
+		name        string
+		commitments map[string]string
+		wantErr     string
+	}{
+		{"invalid commitment", map[string]string{"mileage": "pd1:!"}, "Failed to parse Commitment of field 'mileage'"},
+		{"unknown field", map[string]string{"age": encoded["mileage"]}, "Unknown field 'age' in commitments"},
+		{"missing commitments", map[string]string{}, "Invalid commitment for field"},
+		{"valid commitments", encoded, ""},
+		{"duplicate ID", encoded, "Confidential weights with this ID already exist"},
+	}
+	for _, tt := range weightsTests {
+		err := n.contract.AddConfidentialWeights(n.begin(nil), "confidential1", "insurer1", tt.commitments)
+		if err == nil && tt.wantErr != "" || err != nil && (tt.wantErr == "" || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Fatalf("AddConfidentialWeights %s: unexpected error %v", tt.name, err)
+		}
+	}
+
+	weights, err := n.contract.QueryConfidentialWeights(n.begin(nil), "confidential1")
+	n.must("QueryConfidentialWeights", err)
+	if weights.InsurerID != "insurer1" || !reflect.DeepEqual(weights.Commitments, encoded) {
+		t.Fatalf("unexpected ConfidentialWeights %+v", weights)
+	}
+	_, err = n.contract.QueryConfidentialWeights(n.begin(nil), "confidential2")
+	checkError(t, err, "ConfidentialWeights not found for the given ID")
+
+	// L'assureur multiplie hors chaîne chaque métrique chiffrée par son coefficient
+	publicKey := &sk.PublicKey
+	products := make(map[string]string)
+	proofs := make(map[string]*CommittedScalarProof)
+	for _, field := range telematics.TripFields {
+		product, r, err := publicKey.MulConstRandomized(rand.Reader, trip[field].c, coefficients[field])
+		n.must("MulConstRandomized", err)
+		context := crypto.FieldContext("weightedtrip_trip1/confidential1", field)
+		proof, err := publicKey.ProveCommittedScalar(rand.Reader, params, trip[field].c, product, commitments[field], coefficients[field], openings[field], r, context)
+		n.must("ProveCommittedScalar", err)
+		products[field] = product.Encode()
+		proofs[field] = &CommittedScalarProof{A1: formatInt(proof.A1), A2: formatInt(proof.A2), Z: formatInt(proof.Z), T: formatInt(proof.T), U: formatInt(proof.U)}
+	}
+
+	tampered := withValue(products, "speeding", publicKey.Add(parseCiphertext(t, products["speeding"]), trip["speeding"].c).Encode())
+	invalidProduct := withValue(products, "speeding", "pc1:!")
+	invalidProofs := make(map[string]*CommittedScalarProof, len(proofs))
+	for field, proof := range proofs {
+		invalidProofs[field] = proof
+	}
+	invalidProofs["speeding"] = &CommittedScalarProof{A1: "0x1", A2: "1", Z: "1", T: "1", U: "1"}
+
+	premiumTests := []struct {
+		name     string
+		weights  string
+		products map[string]string
+		proofs   map[string]*CommittedScalarProof
+		wantErr  string
+	}{
+		{"missing weights", "confidential2", products, proofs, "ConfidentialWeights not found for the given WeightsID"},
+		{"invalid products", "confidential1", invalidProduct, proofs, "Failed to parse product ciphertext of field 'speeding'"},
+		{"invalid proofs", "confidential1", products, invalidProofs, "Failed to parse CommittedScalarProof"},
+		{"tampered product", "confidential1", tampered, proofs, "Invalid weighted product for field 'speeding'"},
+		{"valid products", "confidential1", products, proofs, ""},
+	}
+	var calculated *CalculationResult
+	for _, tt := range premiumTests {
+		calculated, err = n.contract.CalculateConfidentialInsurancePremium(n.begin(nil), "vehicle1", "trip1", tt.weights, tt.products, tt.proofs)
+		if err == nil && tt.wantErr != "" || err != nil && (tt.wantErr == "" || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Fatalf("CalculateConfidentialInsurancePremium %s: unexpected error %v", tt.name, err)
+		}
+	}
+
+	result := decodeResult(t, calculated)
+	if result.WeightsID != "confidential1" {
+		t.Fatalf("result does not reference the confidential weights: %+v", result)
+	}
+	if got, want := n.decrypt(result, sk), referencePremium(t, testVehicle, testTrip, testWeights); got != want {
+		t.Fatalf("decrypted confidential premium %d, expected %d", got, want)
+	}
+
+	// Seule la somme des produits pondérés est conservée : un produit par
+	// champ révélerait son poids au conducteur
+	var weighted billing.WeightedTripData
+	n.must("Unmarshal WeightedTripData", json.Unmarshal(n.stub.State["weightedtrip_trip1"], &weighted))
+	for field, product := range products {
+		if strings.Contains(string(n.stub.State["weightedtrip_trip1"]), product) {
+			t.Fatalf("weighted product of field %s is stored", field)
+		}
+	}
+	want := new(big.Int)
+	for _, field := range telematics.TripFields {
+		want.Add(want, new(big.Int).Mul(coefficients[field], trip[field].m))
+	}
+	decrypted, err := sk.Decrypt(weighted.Weighted)
+	n.must("Decrypt", err)
+	if got := sk.DecodeSigned(decrypted); got.Cmp(want) != 0 {
+		t.Fatalf("weighted sum decrypts to %s, expected %s", got, want)
+	}
+}
+
+func TestThresholdDecryption(t *testing.T) {
+	key := loadThresholdKey(t)
+	n := newTestNetwork(t)
+	n.addFieldSpecs(nil)
+	n.addWeights(testWeights)
+
+	verifierTests := []struct {
+		name      string
+		threshold int
+		parties   int
+		v         string
+		wantErr   string
+	}{
+		{"threshold above parties", 4, 3, key.V, "Invalid threshold public key"},
+		{"invalid generator", key.Threshold, key.Parties, "pk1:!", "Invalid threshold public key"},
+		{"valid key", key.Threshold, key.Parties, key.V, ""},
+		{"duplicate OwnerID", key.Threshold, key.Parties, key.V, "Verifier with this OwnerID already exists"},
+	}
+	for _, tt := range verifierTests {
+		verificationKeys := append([]string{}, key.VerificationKeys...)
+		err := n.contract.AddThresholdVerifier(n.begin(nil), "owner1", key.N, tt.threshold, tt.parties, tt.v, verificationKeys)
+		if err == nil && tt.wantErr != "" || err != nil && (tt.wantErr == "" || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Fatalf("AddThresholdVerifier %s: unexpected error %v", tt.name, err)
+		}
+	}
+
+	verifier, err := n.contract.QueryVerifier(n.begin(nil), "owner1")
+	n.must("QueryVerifier", err)
+	thresholdKey, err := verifier.ThresholdPublicKey()
+	n.must("ThresholdPublicKey", err)
+
+	n.addVehicle("vehicle1", "owner1", &thresholdKey.PublicKey, testVehicle)
+	n.addTrip("vehicle1", "trip1", "2024-03-15", "owner1", &thresholdKey.PublicKey, testTrip)
+	result := n.calculate("vehicle1", "trip1", "weights1")
+
+	// L'empreinte d'une clé à seuil n'est pas celle de la clé de Paillier de même module
+	if verifier.KeyFingerprint != thresholdKey.Fingerprint() || verifier.KeyFingerprint == thresholdKey.PublicKey.Fingerprint() {
+		t.Fatalf("Verifier fingerprint %s, expected %s", verifier.KeyFingerprint, thresholdKey.Fingerprint())
+	}
+	trip, err := n.contract.QueryTripData(n.begin(nil), "trip1")
+	n.must("QueryTripData", err)
+	if trip.KeyFingerprint != verifier.KeyFingerprint {
+		t.Fatalf("trip fingerprint %s, expected %s", trip.KeyFingerprint, verifier.KeyFingerprint)
+	}
+
+	// r′ ne peut pas être calculé sans Lambda : seules des parts sont acceptées
+	_, err = n.contract.DecryptInsurancePremiumAndUpdate(n.begin(nil), "trip1", "2")
+	checkError(t, err, "Verifier uses threshold decryption")
+
+	shares := make([]*DecryptionShare, len(key.Shares))
+	for i, keyShare := range key.Shares {
+		share, err := thresholdKey.DecryptShare(rand.Reader, keyShare, result.PrimeTotale, []byte(result.ResultID))
+		n.must("DecryptShare", err)
+		shares[i] = newDecryptionShare(share)
+	}
+
+	forged, err := thresholdKey.DecryptShare(rand.Reader, key.Shares[2], result.PrimeTotale, []byte("result_trip2"))
+	n.must("DecryptShare", err)
+	invalid := *shares[0]
+	invalid.Share = "pw1:"
+
+	_, err = n.contract.CombineDecryptionShares(n.begin(nil), "trip1")
+	checkError(t, err, "Not enough decryption shares: 0 of 2 submitted")
+
+	shareTests := []struct {
+		name    string
+		tripID  string
+		share   *DecryptionShare
+		wantErr string
+	}{
+		{"missing share", "trip1", nil, "Expecting a DecryptionShare"},
+		{"invalid share", "trip1", &invalid, "Failed to parse DecryptionShare"},
+		{"missing result", "trip2", shares[0], "EncryptedCalculationResult not found for the given ResultID"},
+		{"share bound to another result", "trip1", newDecryptionShare(forged), "Failed to verify decryption share"},
+		{"first share", "trip1", shares[0], ""},
+		{"duplicate index", "trip1", shares[0], "DecryptionShare for this index has already been submitted"},
+	}
+	for _, tt := range shareTests {
+		err := n.contract.SubmitDecryptionShare(n.begin(nil), tt.tripID, tt.share)
+		if err == nil && tt.wantErr != "" || err != nil && (tt.wantErr == "" || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Fatalf("SubmitDecryptionShare %s: unexpected error %v", tt.name, err)
+		}
+	}
+
+	_, err = n.contract.CombineDecryptionShares(n.begin(nil), "trip1")
+	checkError(t, err, "Not enough decryption shares: 1 of 2 submitted")
+
+	n.must("SubmitDecryptionShare", n.contract.SubmitDecryptionShare(n.begin(nil), "trip1", shares[2]))
+	decrypted, err := n.contract.CombineDecryptionShares(n.begin(nil), "trip1")
+	n.must("CombineDecryptionShares", err)
+	if want := referencePremium(t, testVehicle, testTrip, testWeights); decrypted.DecryptedPrime != want {
+		t.Fatalf("combined premium %d, expected %d", decrypted.DecryptedPrime, want)
+	}
+
+	verification, err := n.contract.VerifyPrime(n.begin(nil), "trip1")
+	n.must("VerifyPrime", err)
+	if !verification.Verified {
+		t.Fatal("prime combined from decryption shares failed to verify")
+	}
+}
+
+func TestThresholdMonthlyPremium(t *testing.T) {
+	key := loadThresholdKey(t)
+	n := newTestNetwork(t)
+	n.addFieldSpecs(nil)
+	n.addWeights(testWeights)
+	n.must("AddThresholdVerifier", n.contract.AddThresholdVerifier(n.begin(nil), "owner1", key.N, key.Threshold, key.Parties, key.V, append([]string{}, key.VerificationKeys...)))
+
+	verifier, err := n.contract.QueryVerifier(n.begin(nil), "owner1")
+	n.must("QueryVerifier", err)
+	thresholdKey, err := verifier.ThresholdPublicKey()
+	n.must("ThresholdPublicKey", err)
+
+	n.addVehicle("vehicle1", "owner1", &thresholdKey.PublicKey, testVehicle)
+	n.addTrip("vehicle1", "trip1", "2024-03-15", "owner1", &thresholdKey.PublicKey, testTrip)
+	n.addTrip("vehicle1", "trip2", "2024-03-20", "owner1", &thresholdKey.PublicKey, testTrip)
+	trip := n.calculate("vehicle1", "trip1", "weights1")
+	n.calculate("vehicle1", "trip2", "weights1")
+
+	encrypted, err := n.contract.AggregateMonthlyPremium(n.begin(nil), "vehicle1", 3, 2024)
+	n.must("AggregateMonthlyPremium", err)
+	total := parseCiphertext(t, encrypted.Total)
+
+	// Les primes des trajets agrégés ne se déchiffrent plus individuellement
+	tripShare, err := thresholdKey.DecryptShare(rand.Reader, key.Shares[0], trip.PrimeTotale, []byte(trip.ResultID))
+	n.must("DecryptShare", err)
+	err = n.contract.SubmitDecryptionShare(n.begin(nil), "trip1", newDecryptionShare(tripShare))
+	checkError(t, err, "Trip premiums of an aggregated month can only be decrypted through the monthly total")
+	_, err = n.contract.DecryptMonthlyPremium(n.begin(nil), "vehicle1", 3, 2024, "2")
+	checkError(t, err, "Verifier uses threshold decryption")
+
+	monthID := []byte(billing.MonthID("vehicle1", 3, 2024))
+	shares := make([]*DecryptionShare, len(key.Shares))
+	for i, keyShare := range key.Shares {
+		share, err := thresholdKey.DecryptShare(rand.Reader, keyShare, total, monthID)
+		n.must("DecryptShare", err)
+		shares[i] = newDecryptionShare(share)
+	}
+	forged, err := thresholdKey.DecryptShare(rand.Reader, key.Shares[1], total, []byte(billing.MonthID("vehicle1", 4, 2024)))
+	n.must("DecryptShare", err)
+
+	_, err = n.contract.CombineMonthlyDecryptionShares(n.begin(nil), "vehicle1", 3, 2024)
+	checkError(t, err, "Not enough decryption shares: 0 of 2 submitted")
+
+	shareTests := []struct {
+		name    string
+		month   int
+		share   *DecryptionShare
+		wantErr string
+	}{
+		{"missing share", 3, nil, "Expecting a DecryptionShare"},
+		{"invalid month", 13, shares[0], "Invalid month value"},
+		{"month not aggregated", 4, shares[0], "aggregate the month first"},
+		{"share bound to another month", 3, newDecryptionShare(forged), "Failed to verify decryption share"},
+		{"first share", 3, shares[0], ""},
+		{"duplicate index", 3, shares[0], "DecryptionShare for this index has already been submitted"},
+	}
+	for _, tt := range shareTests {
+		checkError(t, n.contract.SubmitMonthlyDecryptionShare(n.begin(nil), "vehicle1", tt.month, 2024, tt.share), tt.wantErr)
+	}
+
+	// Le total sur lequel portent les parts ne peut plus être modifié
+	_, err = n.contract.AggregateMonthlyPremium(n.begin(nil), "vehicle1", 3, 2024)
+	checkError(t, err, "Decryption shares have already been submitted for this month")
+
+	_, err = n.contract.CombineMonthlyDecryptionShares(n.begin(nil), "vehicle1", 3, 2024)
+	checkError(t, err, "Not enough decryption shares: 1 of 2 submitted")
+
+	n.must("SubmitMonthlyDecryptionShare", n.contract.SubmitMonthlyDecryptionShare(n.begin(nil), "vehicle1", 3, 2024, shares[2]))
+	decrypted, err := n.contract.CombineMonthlyDecryptionShares(n.begin(nil), "vehicle1", 3, 2024)
+	n.must("CombineMonthlyDecryptionShares", err)
+	if want := 2 * referencePremium(t, testVehicle, testTrip, testWeights); decrypted.DecryptedPrime != want {
+		t.Fatalf("combined monthly premium %d, expected %d", decrypted.DecryptedPrime, want)
+	}
+
+	monthPrime, err := n.contract.QueryMonthPrime(n.begin(nil), "vehicle1", 3, 2024)
+	n.must("QueryMonthPrime", err)
+	if monthPrime.Prime != decrypted.DecryptedPrime || len(monthPrime.Shares) != 2 {
+		t.Fatalf("unexpected MonthPrime %+v", monthPrime)
+	}
+	_, err = n.contract.CombineMonthlyDecryptionShares(n.begin(nil), "vehicle1", 3, 2024)
+	checkError(t, err, "MonthPrime for this VehicleID, Month, and Year already exists")
+}
+
+func TestKeyRotationAndReencryption(t *testing.T) {
+	oldKey, newKey := ownerKey(t, 0), ownerKey(t, 1)
+	n, vehicle, trip := newPricedNetwork(t)
+
+	rotateTests := []struct {
+		name     string
+		ownerID  string
+		key      *paillier.PrivateKey
+		exponent int
+		wantErr  string
+	}{
+		{"missing owner", "owner2", newKey, 0, "Verifier not found for the given OwnerID"},
+		{"same key", "owner1", oldKey, 0, "New key must differ from the active key"},
+		{"invalid exponent", "owner1", newKey, paillier.MaxS + 1, "Invalid S value"},
+		{"other client", "owner1", newKey, 0, "Caller is not allowed to manage the Verifier"},
+		{"new key", "owner1", newKey, 0, ""},
+	}
+	for _, tt := range rotateTests {
+		n.as(testInsurer)
+		if tt.name == "other client" {
+			n.as(&testIdentity{mspID: "org2-insurance-com", enrollmentID: "car_insurance2"})
+		}
+		verifier, err := n.contract.RotateVerifier(n.begin(nil), tt.ownerID, tt.key.N.String(), tt.key.NSquare.String(), tt.exponent)
+		if err == nil && tt.wantErr != "" || err != nil && (tt.wantErr == "" || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Fatalf("RotateVerifier %s: unexpected error %v", tt.name, err)
+		}
+		if err == nil && verifier.KeyVersion != 2 {
+			t.Fatalf("rotated Verifier has version %d", verifier.KeyVersion)
+		}
+	}
+
+	versionTests := []struct {
+		version    int
+		wantStatus string
+		wantErr    string
+	}{
+		{0, "", "Invalid KeyVersion value"},
+		{1, crypto.StatusRetired, ""},
+		{2, crypto.StatusActive, ""},
+		{3, "", "Verifier not found for the given OwnerID"},
+	}
+	for _, tt := range versionTests {
+		verifier, err := n.contract.QueryVerifierVersion(n.begin(nil), "owner1", tt.version)
+		checkError(t, err, tt.wantErr)
+		if err == nil && verifier.Status != tt.wantStatus {
+			t.Fatalf("version %d has status %s, expected %s", tt.version, verifier.Status, tt.wantStatus)
+		}
+	}
+
+	// Les données chiffrées sous la clé retirée ne sont plus tarifées
+	_, err := n.contract.CalculateInsurancePremium(n.begin(nil), "vehicle1", "trip1", "weights1")
+	checkError(t, err, "must be encrypted under the active key version 2")
+
+	vehicleCiphertexts, vehicleProofs := reencrypt(n, telematics.VehicleKey("vehicle1"), vehicle, oldKey, newKey)
+	tripCiphertexts, tripProofs := reencrypt(n, telematics.TripKey("trip1"), trip, oldKey, newKey)
+
+	reencryptTests := []struct {
+		name        string
+		vehicleID   string
+		ciphertexts map[string]string
+		proofs      map[string]*EqualityProof
+		wantErr     string
+	}{
+		{"invalid ciphertexts", "vehicle1", withValue(vehicleCiphertexts, "year", "pc1:!"), vehicleProofs, "Failed to parse re-encrypted ciphertext of field 'year'"},
+		{"invalid proofs", "vehicle1", vehicleCiphertexts, map[string]*EqualityProof{"year": {A1: "a"}}, "Failed to parse EqualityProof"},
+		{"missing vehicle", "vehicle2", vehicleCiphertexts, vehicleProofs, "Vehicle data not found for the given VehicleID"},
+		{"proofs of another record", "vehicle1", vehicleCiphertexts, tripProofs, "Invalid re-encryption of field"},
+		{"other client", "vehicle1", vehicleCiphertexts, vehicleProofs, "Caller is not allowed to manage the Verifier"},
+		{"valid re-encryption", "vehicle1", vehicleCiphertexts, vehicleProofs, ""},
+		{"already re-encrypted", "vehicle1", vehicleCiphertexts, vehicleProofs, "Record is already encrypted under the active key version"},
+	}
+	for _, tt := range reencryptTests {
+		n.as(testInsurer)
+		if tt.name == "other client" {
+			n.as(&testIdentity{mspID: "org2-insurance-com", enrollmentID: "car_insurance2"})
+		}
+		err := n.contract.ReencryptVehicleData(n.begin(nil), tt.vehicleID, tt.ciphertexts, tt.proofs)
+		if err == nil && tt.wantErr != "" || err != nil && (tt.wantErr == "" || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Fatalf("ReencryptVehicleData %s: unexpected error %v", tt.name, err)
+		}
+	}
+
+	n.as(testInsurer)
+	err = n.contract.ReencryptTripData(n.begin(nil), "trip2", tripCiphertexts, tripProofs)
+	checkError(t, err, "Trip data not found for the given TripID")
+	n.as(&testIdentity{mspID: "org2-insurance-com", enrollmentID: "car_insurance2"})
+	err = n.contract.ReencryptTripData(n.begin(nil), "trip1", tripCiphertexts, tripProofs)
+	checkError(t, err, "Caller is not allowed to manage the Verifier")
+	n.as(testInsurer)
+	n.must("ReencryptTripData", n.contract.ReencryptTripData(n.begin(nil), "trip1", tripCiphertexts, tripProofs))
+
+	// La prime est calculée sous la nouvelle clé et déchiffrée avec elle
+	result := n.calculate("vehicle1", "trip1", "weights1")
+	if result.KeyVersion != 2 {
+		t.Fatalf("premium calculated under key version %d", result.KeyVersion)
+	}
+	if got, want := n.decrypt(result, newKey), referencePremium(t, testVehicle, testTrip, testWeights); got != want {
+		t.Fatalf("decrypted premium %d, expected %d", got, want)
+	}
+}
+
+// reencrypt chiffre de nouveau sous la clé to les champs d'un actif chiffrés
+// sous la clé from, et prouve l'égalité des clairs. Retourne les chiffrés et
+// les preuves attendus par ReencryptVehicleData et ReencryptTripData
+func reencrypt(n *testNetwork, key string, values map[string]*clientValue, from, to *paillier.PrivateKey) (map[string]string, map[string]*EqualityProof) {
+	n.t.Helper()
+	ciphertexts := make(map[string]string, len(values))
+	proofs := make(map[string]*EqualityProof, len(values))
+	for field, value := range values {
+		reencrypted := n.encryptValue(&to.PublicKey, field, new(big.Rat).SetInt(value.m).RatString())
+
+		// La preuve est liée à l'actif, au champ et à la version cible
+		context := fmt.Sprintf("%s/v%d", crypto.FieldContext(key, field), 2)
+		proof, err := paillier.ProveEquality(rand.Reader, &from.PublicKey, value.c, value.r, &to.PublicKey, reencrypted.c, reencrypted.r, value.m, []byte(context))
+		n.must("ProveEquality", err)
+
+		ciphertexts[field] = reencrypted.c.Encode()
+		proofs[field] = &EqualityProof{A1: formatInt(proof.A1), A2: formatInt(proof.A2), Z: formatInt(proof.Z), W1: formatInt(proof.W1), W2: formatInt(proof.W2)}
+	}
+	return ciphertexts, proofs
+}
+
+func TestVerifierTransactions(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n := newTestNetwork(t)
+
+	addTests := []struct {
+		name     string
+		ownerID  string
+		n        string
+		nSquare  string
+		exponent int
+		wantErr  string
+	}{
+		{"invalid N", "owner1", "abc", "", 0, "Invalid Verifier public key"},
+		{"mismatched NSquare", "owner1", sk.N.String(), sk.N.String(), 0, "Invalid Verifier public key"},
+		{"invalid exponent", "owner1", sk.N.String(), "", -1, "Invalid S value"},
+		{"valid key", "owner1", sk.N.String(), sk.NSquare.String(), 0, ""},
+		{"duplicate OwnerID", "owner1", sk.N.String(), sk.NSquare.String(), 0, "Verifier with this OwnerID already exists"},
+		{"compact key without NSquare", "owner2", paillier.EncodeCompact(paillier.KeyTag, sk.N), "", 0, ""},
+	}
+	for _, tt := range addTests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, n.contract.AddVerifier(n.begin(nil), tt.ownerID, tt.n, tt.nSquare, tt.exponent), tt.wantErr)
+		})
+	}
+
+	verifier, err := n.contract.QueryVerifier(n.begin(nil), "owner1")
+	n.must("QueryVerifier", err)
+	if verifier.N != paillier.EncodeCompact(paillier.KeyTag, sk.N) || verifier.KeyVersion != 1 || verifier.Status != crypto.StatusActive {
+		t.Fatalf("unexpected Verifier %+v", verifier)
+	}
+	if verifier.KeyFingerprint != sk.PublicKey.Fingerprint() {
+		t.Fatalf("Verifier fingerprint %s, expected %s", verifier.KeyFingerprint, sk.PublicKey.Fingerprint())
+	}
+
+	n.must("DeleteVerifier", n.contract.DeleteVerifier(n.begin(nil), "owner1"))
+	checkError(t, n.contract.DeleteVerifier(n.begin(nil), "owner1"), "Verifier not found for the given OwnerID")
+	_, err = n.contract.QueryVerifier(n.begin(nil), "owner1")
+	checkError(t, err, "Verifier not found")
+}
+
+func TestVerifierAccessControl(t *testing.T) {
+	oldKey, newKey := ownerKey(t, 0), ownerKey(t, 1)
+	n := newTestNetwork(t)
+	n.addVerifier("owner1", oldKey)
+
+	// Un autre client ne peut ni renouveler ni supprimer la clé, même sous le
+	// nom d'enrôlement de l'assureur dans un autre MSP
+	strangers := []*testIdentity{
+		{mspID: "org2-insurance-com", enrollmentID: "car_insurance2"},
+		{mspID: "org2-insurance-com", enrollmentID: "car_insurance1"},
+	}
+	for _, stranger := range strangers {
+		n.as(stranger)
+		_, err := n.contract.RotateVerifier(n.begin(nil), "owner1", newKey.N.String(), newKey.NSquare.String(), 0)
+		checkError(t, err, "Caller is not allowed to manage the Verifier of this OwnerID")
+		checkError(t, n.contract.DeleteVerifier(n.begin(nil), "owner1"), "Caller is not allowed to manage the Verifier of this OwnerID")
+	}
+
+	// Le propriétaire renouvelle sa clé ; l'assureur en reste le Controller
+	n.as(&testIdentity{mspID: "org1-insurance-com", enrollmentID: "owner1"})
+	verifier, err := n.contract.RotateVerifier(n.begin(nil), "owner1", newKey.N.String(), newKey.NSquare.String(), 0)
+	n.must("RotateVerifier", err)
+	controller, _ := testInsurer.GetID()
+	if verifier.KeyVersion != 2 || verifier.Controller != "org1-insurance-com/"+controller {
+		t.Fatalf("unexpected rotated Verifier %+v", verifier)
+	}
+
+	// Une clé enregistrée avant le contrôle d'accès n'a pas de Controller :
+	// seul le propriétaire peut la gérer
+	legacy, err := json.Marshal(crypto.Verifier{OwnerID: "owner2", N: oldKey.N.String(), NSquare: oldKey.NSquare.String()})
+	n.must("Marshal Verifier", err)
+	n.begin(nil)
+	n.must("PutState", n.stub.PutState("verifier_owner2", legacy))
+
+	n.as(testInsurer)
+	checkError(t, n.contract.DeleteVerifier(n.begin(nil), "owner2"), "Caller is not allowed to manage the Verifier of this OwnerID")
+	n.as(&testIdentity{mspID: "org1-insurance-com", enrollmentID: "owner2"})
+	n.must("DeleteVerifier", n.contract.DeleteVerifier(n.begin(nil), "owner2"))
+
+	// Sans identité client, la transaction est refusée
+	n.ctx.SetClientIdentity(nil)
+	checkError(t, n.contract.AddVerifier(n.begin(nil), "owner3", oldKey.N.String(), "", 0), "Failed to get client identity")
+}
+
+func TestVerifierVersionKeys(t *testing.T) {
+	oldKey, newKey := ownerKey(t, 0), ownerKey(t, 1)
+	n := newTestNetwork(t)
+	n.addVerifier("owner1", oldKey)
+	_, err := n.contract.RotateVerifier(n.begin(nil), "owner1", newKey.N.String(), newKey.NSquare.String(), 0)
+	n.must("RotateVerifier", err)
+
+	// L'ancienne clé simple verifier_owner1_v1 est la clé active de owner1_v1 :
+	// les versions retirées ne doivent pas y être archivées
+	n.addVerifier("owner1_v1", newKey)
+	retired, err := n.contract.QueryVerifierVersion(n.begin(nil), "owner1", 1)
+	n.must("QueryVerifierVersion", err)
+	if retired.OwnerID != "owner1" || retired.Status != crypto.StatusRetired || retired.N != paillier.EncodeCompact(paillier.KeyTag, oldKey.N) {
+		t.Fatalf("unexpected retired Verifier %+v", retired)
+	}
+	active, err := n.contract.QueryVerifier(n.begin(nil), "owner1_v1")
+	n.must("QueryVerifier", err)
+	if active.OwnerID != "owner1_v1" || active.Status != crypto.StatusActive || active.KeyVersion != 1 {
+		t.Fatalf("unexpected active Verifier %+v", active)
+	}
+
+	// Les versions archivées sous l'ancienne clé simple restent lisibles...
+	putVerifier := func(key string, verifier crypto.Verifier) {
+		t.Helper()
+		data, err := json.Marshal(verifier)
+		n.must("Marshal Verifier", err)
+		n.begin(nil)
+		n.must("PutState", n.stub.PutState(key, data))
+	}
+	putVerifier("verifier_owner2", crypto.Verifier{OwnerID: "owner2", N: newKey.N.String(), KeyVersion: 2, Status: crypto.StatusActive})
+	putVerifier("verifier_owner2_v1", crypto.Verifier{OwnerID: "owner2", N: oldKey.N.String(), KeyVersion: 1, Status: crypto.StatusRetired})
+	legacy, err := n.contract.QueryVerifierVersion(n.begin(nil), "owner2", 1)
+	n.must("QueryVerifierVersion", err)
+	if legacy.OwnerID != "owner2" || legacy.Status != crypto.StatusRetired {
+		t.Fatalf("unexpected legacy retired Verifier %+v", legacy)
+	}
+
+	// ... mais la clé active d'un autre propriétaire n'est pas prise pour une
+	// version retirée, ni une version retirée pour une clé active
+	putVerifier("verifier_owner3", crypto.Verifier{OwnerID: "owner3", N: newKey.N.String(), KeyVersion: 2, Status: crypto.StatusActive})
+	n.addVerifier("owner3_v1", oldKey)
+	_, err = n.contract.QueryVerifierVersion(n.begin(nil), "owner3", 1)
+	checkError(t, err, "Verifier not found for the given OwnerID")
+	_, err = n.contract.QueryVerifier(n.begin(nil), "owner2_v1")
+	checkError(t, err, "Verifier not found")
+	checkError(t, n.contract.AddVerifier(n.begin(nil), "owner2_v1", oldKey.N.String(), "", 0), "Verifier key is used by another OwnerID")
+}
+
+func TestFieldSpecTransactions(t *testing.T) {
+	n := newTestNetwork(t)
+
+	tests := []struct {
+		name     string
+		field    string
+		min, max int64
+		scale    int64
+		wantErr  string
+	}{
+		{"unknown field", "age", 0, 100, 1, "Unknown encrypted field 'age'"},
+		{"min above max", "mileage", 10, 0, 1, "Min must be lower than or equal to Max"},
+		{"negative scale", "mileage", 0, 10, -1, "Invalid Scale value"},
+		{"default scale", "mileage", 0, 100000, 0, ""},
+		{"duplicate field", "mileage", 0, 100000, 1, "FieldSpec for this field already exists"},
+		{"vehicle field", "year", 1900, 2100, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, n.contract.AddFieldSpec(n.begin(nil), tt.field, tt.min, tt.max, tt.scale), tt.wantErr)
+		})
+	}
+
+	fieldSpec, err := n.contract.QueryFieldSpec(n.begin(nil), "mileage")
+	n.must("QueryFieldSpec", err)
+	if *fieldSpec != (telematics.FieldSpec{Field: "mileage", Min: 0, Max: 100000, Scale: 1}) {
+		t.Fatalf("unexpected FieldSpec %+v", fieldSpec)
+	}
+	_, err = n.contract.QueryFieldSpec(n.begin(nil), "speeding")
+	checkError(t, err, "FieldSpec not found for the given field")
+
+	// Seul l'assureur déclare les FieldSpec
+	n.as(&testIdentity{mspID: "org2-insurance-com", enrollmentID: "car_insurance2"})
+	checkError(t, n.contract.AddFieldSpec(n.begin(nil), "speeding", 0, 1000, 1), "Only the insurer can declare a FieldSpec")
+	n.as(testInsurer)
+
+	// Les données déjà enregistrées ont été encodées sans FieldSpec
+	sk := ownerKey(t, 0)
+	n.addVerifier("owner1", sk)
+	n.addEncryptedVehicle("vehicle1", "owner1", &sk.PublicKey, testVehicle)
+	n.addEncryptedTrip("vehicle1", "trip1", "2024-03-15", &sk.PublicKey, testTrip)
+	checkError(t, n.contract.AddFieldSpec(n.begin(nil), "vehicle_type", 0, 10, 1), "Data has already been recorded for this field")
+	checkError(t, n.contract.AddFieldSpec(n.begin(nil), "speeding", 0, 1000, 1), "Data has already been recorded for this field")
+
+	// Un cumul mensuel, qui porte un champ year, n'est pas un véhicule
+	n = newTestNetwork(t)
+	n.must("AddMonthPrime", n.contract.AddMonthPrime(n.begin(nil), "vehicle1", 3, 2024, 100))
+	n.must("AddFieldSpec", n.contract.AddFieldSpec(n.begin(nil), "year", 1900, 2100, 1))
+}
+
+func TestAddEncryptedVehicleData(t *testing.T) {
+	sk := ownerKey(t, 0)
+	publicKey := &sk.PublicKey
+	n := newTestNetwork(t)
+	n.addVerifier("owner1", sk)
+	n.addFieldSpecs(nil)
+
+	vehicle := n.encryptValues(publicKey, telematics.VehicleFields, testVehicle)
+	year := vehicle["year"]
+	key := telematics.VehicleKey("vehicle1")
+
+	rangeProof, err := publicKey.ProveRange(rand.Reader, year.c, year.m, year.r, big.NewInt(1900), big.NewInt(2100), crypto.FieldContext(key, "year"))
+	n.must("ProveRange", err)
+	knowledgeProof, err := publicKey.ProveKnowledge(rand.Reader, year.c, year.m, year.r, crypto.FieldContext(key, "year"))
+	n.must("ProveKnowledge", err)
+	validProofs := fieldProofs(t, &telematics.EncryptedFieldProofs{
+		Range:     map[string]*paillier.RangeProof{"year": rangeProof},
+		Knowledge: map[string]*paillier.KnowledgeProof{"year": knowledgeProof},
+	})
+	wrongContext := fieldProofs(t, &telematics.EncryptedFieldProofs{
+		Knowledge: map[string]*paillier.KnowledgeProof{"vehicle_type": knowledgeProof},
+	})
+	unknownField := fieldProofs(t, &telematics.EncryptedFieldProofs{
+		Range: map[string]*paillier.RangeProof{"mileage": rangeProof},
+	})
+	invalidProofs := []*FieldProof{{Field: "year", Knowledge: &KnowledgeProof{A: "1", Z: "1", W: "-"}}}
+	duplicateField := append(fieldProofs(t, &telematics.EncryptedFieldProofs{
+		Range: map[string]*paillier.RangeProof{"year": rangeProof},
+	}), &FieldProof{Field: "year"})
+
+	tests := []struct {
+		name      string
+		vehicleID string
+		ownerID   string
+		year      string
+		proofs    []*FieldProof
+		wantErr   string
+	}{
+		{"invalid ciphertext", "vehicle1", "owner1", "pc1:!", nil, "Failed to parse Year ciphertext"},
+		{"ciphertext outside Z*_N²", "vehicle1", "owner1", "0", nil, "Invalid ciphertext for field 'year'"},
+		{"invalid proofs", "vehicle1", "owner1", year.c.Encode(), invalidProofs, "Failed to parse KnowledgeProof"},
+		{"duplicate field", "vehicle1", "owner1", year.c.Encode(), duplicateField, "Duplicate proofs for field 'year'"},
+		{"missing owner", "vehicle1", "owner2", year.c.Encode(), nil, "Verifier not found for the given OwnerID"},
+		{"proof for another field", "vehicle1", "owner1", year.c.Encode(), wrongContext, "Knowledge proof for field 'vehicle_type' failed to verify"},
+		{"proof for an unknown field", "vehicle1", "owner1", year.c.Encode(), unknownField, "Unknown field 'mileage' in range proofs"},
+		{"valid proofs", "vehicle1", "owner1", year.c.Encode(), validProofs, ""},
+		{"duplicate VehicleID", "vehicle1", "owner1", year.c.Encode(), nil, "Vehicle data with this VehicleID already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := n.contract.AddEncryptedVehicleData(n.begin(nil), tt.vehicleID, vehicle["vehicle_type"].c.Encode(), vehicle["purchase_mileage"].c.Encode(), tt.year, tt.ownerID, tt.proofs)
+			checkError(t, err, tt.wantErr)
+		})
+	}
+
+	stored, err := n.contract.QueryVehicleData(n.begin(nil), "vehicle1")
+	n.must("QueryVehicleData", err)
+	if stored.OwnerID != "owner1" || stored.KeyVersion != 1 || stored.KeyFingerprint != publicKey.Fingerprint() {
+		t.Fatalf("unexpected VehicleData %+v", stored)
+	}
+	if stored.Year != year.c.Encode() {
+		t.Fatal("stored Year ciphertext differs from the submitted one")
+	}
+
+	_, err = n.contract.QueryVehicleData(n.begin(nil), "vehicle2")
+	checkError(t, err, "VehicleData not found")
+}
+
+func TestAddEncryptedTripData(t *testing.T) {
+	sk := ownerKey(t, 0)
+	publicKey := &sk.PublicKey
+	n := newTestNetwork(t)
+	n.addVerifier("owner1", sk)
+	n.addFieldSpecs(nil)
+	n.addEncryptedVehicle("vehicle1", "owner1", publicKey, testVehicle)
+
+	trip := n.encryptValues(publicKey, telematics.TripFields, testTrip)
+	invalid := make(map[string]*clientValue, len(trip))
+	for field, value := range trip {
+		invalid[field] = value
+	}
+	invalid["mileage"] = &clientValue{c: paillier.NewCiphertext(publicKey.N)}
+
+	seed := randomSeed(t)
+	tests := []struct {
+		name      string
+		vehicleID string
+		tripID    string
+		trip      map[string]*clientValue
+		transient map[string][]byte
+		wantErr   string
+	}{
+		{"missing vehicle", "vehicle2", "trip1", trip, nil, "Vehicle data not found for the given VehicleID"},
+		{"ciphertext outside Z*_N²", "vehicle1", "trip1", invalid, nil, "Invalid ciphertext for field 'mileage'"},
+		{"short rerandomization seed", "vehicle1", "trip1", trip, map[string][]byte{"rerandomize": seed[:8]}, "Transient 'rerandomize' seed must contain at least"},
+		{"valid trip", "vehicle1", "trip1", trip, nil, ""},
+		{"duplicate TripID", "vehicle1", "trip1", trip, nil, "Trip data with this TripID already exists"},
+		{"rerandomized trip", "vehicle1", "trip2", trip, map[string][]byte{"rerandomize": seed}, ""},
+		{"reused rerandomization seed", "vehicle1", "trip3", trip, map[string][]byte{"rerandomize": seed}, "Nonce has already been used"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := n.addTripCiphertexts(n.begin(tt.transient), tt.vehicleID, tt.tripID, "2024-03-15", tt.trip, nil)
+			checkError(t, err, tt.wantErr)
+		})
+	}
+
+	err := n.contract.AddEncryptedTripData(n.begin(nil), "vehicle1", "trip4", "2024-03-15", "x", "", "", "", "", "", "", "", nil)
+	checkError(t, err, "Failed to parse Speeding ciphertext")
+
+	// La re-randomisation conserve les clairs mais remplace les chiffrés
+	stored, err := n.contract.QueryTripData(n.begin(nil), "trip2")
+	n.must("QueryTripData", err)
+	if stored.Mileage == trip["mileage"].c.Encode() {
+		t.Fatal("rerandomized trip stores the submitted ciphertext")
+	}
+	decrypted, err := sk.Decrypt(parseCiphertext(t, stored.Mileage))
+	n.must("Decrypt", err)
+	if decrypted.Cmp(trip["mileage"].m) != 0 {
+		t.Fatalf("rerandomized mileage decrypts to %s", decrypted)
+	}
+
+	_, err = n.contract.QueryTripData(n.begin(nil), "trip5")
+	checkError(t, err, "TripData not found for the given TripID")
+}
+
+func TestOnChainEncryption(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n := newTestNetwork(t)
+	n.addVerifier("owner1", sk)
+	n.addFieldSpecs(nil)
+
+	vehicleJSON, err := json.Marshal(testVehicle)
+	n.must("Marshal vehicle", err)
+	nonce := randomSeed(t)
+
+	vehicleTests := []struct {
+		name      string
+		vehicleID string
+		ownerID   string
+		transient map[string][]byte
+		wantErr   string
+	}{
+		{"missing values", "vehicle1", "owner1", map[string][]byte{"nonce": nonce}, "Transient map must contain 'vehicle'"},
+		{"invalid values", "vehicle1", "owner1", map[string][]byte{"vehicle": []byte("["), "nonce": nonce}, "Failed to unmarshal transient 'vehicle'"},
+		{"missing nonce", "vehicle1", "owner1", map[string][]byte{"vehicle": vehicleJSON}, "Transient map must contain a 'nonce'"},
+		{"short nonce", "vehicle1", "owner1", map[string][]byte{"vehicle": vehicleJSON, "nonce": nonce[:16]}, "Transient map must contain a 'nonce'"},
+		{"missing field", "vehicle1", "owner1", transientValues(t, "vehicle", map[string]string{"vehicle_type": "2", "purchase_mileage": "15000", "age": "4"}), "Missing field 'year'"},
+		{"invalid value", "vehicle1", "owner1", transientValues(t, "vehicle", withValue(testVehicle, "year", "twenty")), "Failed to convert year"},
+		{"value outside its FieldSpec", "vehicle1", "owner1", transientValues(t, "vehicle", withValue(testVehicle, "year", "1850")), "Value of year is outside the declared FieldSpec range"},
+		{"missing owner", "vehicle1", "owner2", transientValues(t, "vehicle", testVehicle), "Verifier not found for the given OwnerID"},
+		{"valid values", "vehicle1", "owner1", map[string][]byte{"vehicle": vehicleJSON, "nonce": nonce}, ""},
+		{"duplicate VehicleID", "vehicle1", "owner1", transientValues(t, "vehicle", testVehicle), "Vehicle data with this VehicleID already exists"},
+		{"reused nonce", "vehicle2", "owner1", map[string][]byte{"vehicle": vehicleJSON, "nonce": nonce}, "Nonce has already been used"},
+	}
+	for _, tt := range vehicleTests {
+		t.Run("vehicle/"+tt.name, func(t *testing.T) {
+			checkError(t, n.contract.AddVehicleData(n.begin(tt.transient), tt.vehicleID, tt.ownerID), tt.wantErr)
+		})
+	}
+
+	tripTests := []struct {
+		name      string
+		tripID    string
+		transient map[string][]byte
+		slotBits  int
+		wantErr   string
+	}{
+		{"missing values", "trip1", map[string][]byte{"nonce": randomSeed(t)}, 0, "Transient map must contain 'trip'"},
+		{"missing field", "trip1", transientValues(t, "trip", testVehicle), 0, "Expecting exactly 8 fields"},
+		{"slots too wide", "trip1", transientValues(t, "trip", testTrip), 512, "SlotBits must be between 2 and 128"},
+		{"value outside its FieldSpec", "trip1", transientValues(t, "trip", withValue(testTrip, "speeding", "5000")), 0, "Value of speeding is outside the declared FieldSpec range"},
+		{"packed value outside its FieldSpec", "trip1", transientValues(t, "trip", withValue(testTrip, "speeding", "-1")), 64, "Value of speeding is outside the declared FieldSpec range"},
+		{"valid values", "trip1", transientValues(t, "trip", testTrip), 0, ""},
+		{"duplicate TripID", "trip1", transientValues(t, "trip", testTrip), 0, "Trip data with this TripID already exists"},
+		{"packed values", "trip2", transientValues(t, "trip", testTrip), 64, ""},
+	}
+	for _, tt := range tripTests {
+		t.Run("trip/"+tt.name, func(t *testing.T) {
+			checkError(t, n.contract.AddTripData(n.begin(tt.transient), "vehicle1", tt.tripID, "2024-03-15", "owner1", tt.slotBits), tt.wantErr)
+		})
+	}
+
+	// Les clairs transmis par le transient map ne sont pas écrits dans le ledger
+	for key, value := range n.stub.State {
+		if strings.Contains(string(value), `"15000"`) || strings.Contains(string(value), `"traffic_signal_compliance":"95"`) {
+			t.Fatalf("plaintext written to the ledger under %s", key)
+		}
+	}
+}
+
+func TestAddPackedTripData(t *testing.T) {
+	sk := ownerKey(t, 0)
+	publicKey := &sk.PublicKey
+	n := newTestNetwork(t)
+	n.addVerifier("owner1", sk)
+	n.addFieldSpecs(nil)
+	n.addVehicle("vehicle1", "owner1", publicKey, testVehicle)
+	n.addWeights(testWeights)
+
+	// Le client chiffre chaque métrique, prouve qu'elle respecte son FieldSpec
+	// puis packe les chiffrés dans l'ordre de telematics.TripFields
+	slotBits := 64
+	trip := n.encryptValues(publicKey, telematics.TripFields, testTrip)
+	slots := make(map[string]string, len(trip))
+	ordered := make([]*paillier.Ciphertext, len(telematics.TripFields))
+	proofs := &telematics.EncryptedFieldProofs{Range: make(map[string]*paillier.RangeProof, len(trip))}
+	for i, field := range telematics.TripFields {
+		value, bounds := trip[field], testFieldBounds[field]
+		proof, err := publicKey.ProveRange(rand.Reader, value.c, value.m, value.r, big.NewInt(bounds[0]), big.NewInt(bounds[1]), crypto.FieldContext(telematics.TripKey("trip1"), field))
+		n.must("ProveRange", err)
+		proofs.Range[field] = proof
+		slots[field] = value.c.Encode()
+		ordered[i] = value.c
+	}
+	c, err := publicKey.PackCiphertexts(ordered, uint(slotBits))
+	n.must("PackCiphertexts", err)
+	validProofs := fieldProofs(t, proofs)
+
+	// Un chiffré packé frais des mêmes valeurs ne correspond pas aux slots prouvés
+	values := make([]*big.Int, len(telematics.TripFields))
+	for i, field := range telematics.TripFields {
+		values[i] = trip[field].m
+	}
+	packed, err := paillier.Pack(values, uint(slotBits))
+	n.must("Pack", err)
+	fresh, err := publicKey.Encrypt(rand.Reader, packed)
+	n.must("Encrypt", err)
+
+	missingProof := &telematics.EncryptedFieldProofs{Range: make(map[string]*paillier.RangeProof, len(trip))}
+	for field, proof := range proofs.Range {
+		if field != "mileage" {
+			missingProof.Range[field] = proof
+		}
+	}
+	missingSlot := make(map[string]string, len(slots))
+	for field, slot := range slots {
+		if field != "mileage" {
+			missingSlot[field] = slot
+		}
+	}
+
+	tests := []struct {
+		name      string
+		vehicleID string
+		tripID    string
+		packed    string
+		slotBits  int
+		slots     map[string]string
+		proofs    []*FieldProof
+		wantErr   string
+	}{
+		{"invalid ciphertext", "vehicle1", "trip1", "pc1:", slotBits, slots, validProofs, "Failed to parse Packed ciphertext"},
+		{"invalid slots", "vehicle1", "trip1", c.Encode(), slotBits, withValue(slots, "mileage", "pc1:!"), validProofs, "Failed to parse slot ciphertext of field 'mileage'"},
+		{"missing vehicle", "vehicle2", "trip1", c.Encode(), slotBits, slots, validProofs, "Vehicle data not found for the given VehicleID"},
+		{"slots too narrow", "vehicle1", "trip1", c.Encode(), 1, slots, validProofs, "SlotBits must be between 2 and 128"},
+		{"ciphertext outside Z*_N²", "vehicle1", "trip1", "0", slotBits, slots, validProofs, "Invalid packed ciphertext"},
+		{"missing slot", "vehicle1", "trip1", c.Encode(), slotBits, missingSlot, validProofs, "Expecting exactly 8 slot ciphertexts"},
+		{"missing proofs", "vehicle1", "trip1", c.Encode(), slotBits, slots, nil, "Packed trip data requires a range proof for each slot"},
+		{"missing range proof", "vehicle1", "trip1", c.Encode(), slotBits, slots, fieldProofs(t, missingProof), "Missing range proof for field 'mileage'"},
+		{"FieldSpec wider than a slot", "vehicle1", "trip1", c.Encode(), 16, slots, validProofs, "FieldSpec range of field mileage does not fit in a slot of 16 bits"},
+		{"proofs of another trip", "vehicle1", "trip2", c.Encode(), slotBits, slots, validProofs, "Range proof for field 'speeding' failed to verify"},
+		{"packing of other ciphertexts", "vehicle1", "trip1", fresh.Encode(), slotBits, slots, validProofs, "Packed ciphertext does not match the packing of the slot ciphertexts"},
+		{"valid trip", "vehicle1", "trip1", c.Encode(), slotBits, slots, validProofs, ""},
+		{"duplicate TripID", "vehicle1", "trip1", c.Encode(), slotBits, slots, validProofs, "Trip data with this TripID already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, n.contract.AddPackedTripData(n.begin(nil), tt.vehicleID, tt.tripID, "2024-03-15", tt.packed, tt.slotBits, tt.slots, tt.proofs), tt.wantErr)
+		})
+	}
+
+	result := n.calculate("vehicle1", "trip1", "weights1")
+	if got, want := n.decrypt(result, sk), referencePremium(t, testVehicle, testTrip, testWeights); got != want {
+		t.Fatalf("decrypted packed premium %d, expected %d", got, want)
+	}
+
+	// Un trajet packé n'a qu'un chiffré : les poids confidentiels ne s'y appliquent pas
+	_, err = n.contract.CalculateConfidentialInsurancePremium(n.begin(nil), "vehicle1", "trip1", "confidential1", map[string]string{}, map[string]*CommittedScalarProof{})
+	checkError(t, err, "ConfidentialWeights not found")
+}
+
+func TestTripCommitmentOpening(t *testing.T) {
+	sk := ownerKey(t, 0)
+	publicKey := &sk.PublicKey
+	params := pedersen.DefaultParams()
+	n := newTestNetwork(t)
+	n.addVerifier("owner1", sk)
+	n.addFieldSpecs(nil)
+	n.addEncryptedVehicle("vehicle1", "owner1", publicKey, testVehicle)
+
+	// Le conducteur s'engage sur ses excès de vitesse et lie l'engagement au chiffré
+	trip := n.encryptValues(publicKey, telematics.TripFields, testTrip)
+	speeding := trip["speeding"]
+	commitment, rho, err := params.Commit(rand.Reader, speeding.m)
+	n.must("Commit", err)
+	link, err := publicKey.ProveCommitmentLink(rand.Reader, params, speeding.c, commitment, speeding.m, rho, speeding.r, crypto.FieldContext(telematics.TripKey("trip1"), "speeding"))
+	n.must("ProveCommitmentLink", err)
+
+	unlinked := fieldProofs(t, &telematics.EncryptedFieldProofs{
+		Commitments: map[string]*pedersen.Commitment{"speeding": commitment},
+	})
+	wrongField := fieldProofs(t, &telematics.EncryptedFieldProofs{
+		Commitments: map[string]*pedersen.Commitment{"mileage": commitment},
+		Link:        map[string]*paillier.CommitmentLinkProof{"mileage": link},
+	})
+	linked := fieldProofs(t, &telematics.EncryptedFieldProofs{
+		Commitments: map[string]*pedersen.Commitment{"speeding": commitment},
+		Link:        map[string]*paillier.CommitmentLinkProof{"speeding": link},
+	})
+
+	for _, tt := range []struct {
+		name    string
+		proofs  []*FieldProof
+		wantErr string
+	}{
+		{"commitment without link proof", unlinked, "Missing link proof for the commitment of field 'speeding'"},
+		{"link proof of another field", wrongField, "Commitment link proof for field 'mileage' failed to verify"},
+		{"linked commitment", linked, ""},
+	} {
+		checkError(t, n.addTripCiphertexts(n.begin(nil), "vehicle1", "trip1", "2024-03-15", trip, tt.proofs), tt.wantErr)
+	}
+
+	// L'arbitre enregistre sa clé, sous laquelle les ouvertures lui sont chiffrées
+	arbitratorKey := ownerKey(t, 1)
+	n.addVerifier("arbitrator1", arbitratorKey)
+	stranger := &testIdentity{mspID: "org2-insurance-com", enrollmentID: "car_insurance2"}
+	n.as(stranger)
+	checkError(t, n.contract.AddArbitrator(n.begin(nil), "arbitrator1"), "Caller is not allowed to manage the Verifier of this OwnerID")
+	n.as(testInsurer)
+	checkError(t, n.contract.AddArbitrator(n.begin(nil), "arbitrator2"), "Verifier not found for the given OwnerID")
+	n.must("AddArbitrator", n.contract.AddArbitrator(n.begin(nil), "arbitrator1"))
+	checkError(t, n.contract.AddArbitrator(n.begin(nil), "arbitrator1"), "Arbitrator with this ArbitratorID already exists")
+
+	usedNonce := randomSeed(t)
+	openingWithNonce := func(value, rho *big.Int, nonce []byte) map[string][]byte {
+		return map[string][]byte{"value": []byte(value.String()), "opening": []byte(rho.String()), "nonce": nonce}
+	}
+	opening := func(value, rho *big.Int) map[string][]byte {
+		return openingWithNonce(value, rho, randomSeed(t))
+	}
+
+	tests := []struct {
+		name         string
+		caller       *testIdentity
+		tripID       string
+		field        string
+		arbitratorID string
+		transient    map[string][]byte
+		wantErr      string
+	}{
+		{"missing opening", testInsurer, "trip1", "speeding", "arbitrator1", map[string][]byte{"value": []byte("3")}, "Transient map must contain 'opening'"},
+		{"invalid value", testInsurer, "trip1", "speeding", "arbitrator1", map[string][]byte{"value": []byte("three"), "opening": []byte("1")}, "Failed to parse transient 'value'"},
+		{"missing nonce", testInsurer, "trip1", "speeding", "arbitrator1", map[string][]byte{"value": []byte("3"), "opening": []byte("1")}, "Transient map must contain a 'nonce'"},
+		{"missing trip", testInsurer, "trip2", "speeding", "arbitrator1", opening(speeding.m, rho), "Trip data not found for the given TripID"},
+		{"other client", stranger, "trip1", "speeding", "arbitrator1", opening(speeding.m, rho), "Caller is not allowed to manage the Verifier of this OwnerID"},
+		{"unregistered arbitrator", testInsurer, "trip1", "speeding", "owner1", opening(speeding.m, rho), "Arbitrator not registered for the given ArbitratorID"},
+		{"field without commitment", testInsurer, "trip1", "mileage", "arbitrator1", opening(speeding.m, rho), "No commitment recorded for field 'mileage'"},
+		{"wrong value", testInsurer, "trip1", "speeding", "arbitrator1", opening(big.NewInt(4), rho), "Opening does not match the commitment"},
+		{"valid opening", testInsurer, "trip1", "speeding", "arbitrator1", openingWithNonce(speeding.m, rho, usedNonce), ""},
+		{"reused nonce", testInsurer, "trip1", "speeding", "arbitrator1", openingWithNonce(speeding.m, rho, usedNonce), "Nonce has already been used"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n.as(tt.caller)
+			defer n.as(testInsurer)
+			checkError(t, n.contract.OpenTripCommitment(n.begin(tt.transient), tt.tripID, tt.field, tt.arbitratorID), tt.wantErr)
+		})
+	}
+
+	// Le propriétaire ouvre lui-même ses engagements
+	n.as(&testIdentity{mspID: "org1-insurance-com", enrollmentID: "owner1"})
+	n.must("OpenTripCommitment", n.contract.OpenTripCommitment(n.begin(opening(speeding.m, rho)), "trip1", "speeding", "arbitrator1"))
+	n.as(testInsurer)
+
+	recorded, err := n.contract.QueryTripFieldOpening(n.begin(nil), "trip1", "speeding", "arbitrator1")
+	n.must("QueryTripFieldOpening", err)
+	if recorded.TxID == "" || recorded.Commitment != commitment.Encode() {
+		t.Fatalf("unexpected TripFieldOpening %+v", recorded)
+	}
+	if recorded.KeyVersion != 1 || recorded.KeyFingerprint != arbitratorKey.PublicKey.Fingerprint() {
+		t.Fatalf("opening encrypted under key version %d (%s)", recorded.KeyVersion, recorded.KeyFingerprint)
+	}
+	if strings.Contains(string(n.stub.State["tripopening_trip1_speeding_arbitrator1"]), rho.String()) {
+		t.Fatal("opening randomness written to the ledger")
+	}
+
+	// L'arbitre déchiffre la valeur et l'aléa, puis vérifie lui-même l'ouverture
+	value, err := arbitratorKey.Decrypt(parseCiphertext(t, recorded.Value))
+	n.must("Decrypt value", err)
+	randomness, err := arbitratorKey.Decrypt(parseCiphertext(t, recorded.Opening))
+	n.must("Decrypt opening", err)
+	value, randomness = arbitratorKey.DecodeSigned(value), arbitratorKey.DecodeSigned(randomness)
+	if value.Cmp(speeding.m) != 0 {
+		t.Fatalf("arbitrator decrypted value %s, expected %s", value, speeding.m)
+	}
+	n.must("Open", params.Open(commitment, value, randomness))
+
+	_, err = n.contract.QueryTripFieldOpening(n.begin(nil), "trip1", "speeding", "arbitrator2")
+	checkError(t, err, "TripFieldOpening not found")
+}
+
+func TestPolicyTransactions(t *testing.T) {
+	n := newTestNetwork(t)
+
+	n.addWeights(testWeights)
+	err := n.contract.AddCriteriaWeights(n.begin(nil), "weights1", 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	checkError(t, err, "Criteria weights with this ID already exists")
+
+	weights, err := n.contract.QueryCriteriaWeights(n.begin(nil), "weights1")
+	n.must("QueryCriteriaWeights", err)
+	if *weights != testWeights {
+		t.Fatalf("unexpected CriteriaWeights %+v", weights)
+	}
+	_, err = n.contract.QueryCriteriaWeights(n.begin(nil), "weights2")
+	checkError(t, err, "CriteriaWeights not found for the given ID")
+
+	tests := []struct {
+		name       string
+		contractID string
+		wantErr    string
+	}{
+		{"new contract", "contract1", ""},
+		{"duplicate ContractID", "contract1", "Contract with the given ContractID already exists"},
+	}
+	for _, tt := range tests {
+		err := n.contract.AddInsuranceContract(n.begin(nil), tt.contractID, "owner1", "vehicle1", "weights1", 1, 2024, 12, 2024)
+		checkError(t, err, tt.wantErr)
+	}
+
+	contract, err := n.contract.QueryInsuranceContract(n.begin(nil), "contract1")
+	n.must("QueryInsuranceContract", err)
+	want := policy.InsuranceContract{ContractID: "contract1", OwnerID: "owner1", VehicleID: "vehicle1", CriteriaWeightsID: "weights1", StartMonth: 1, StartYear: 2024, EndMonth: 12, EndYear: 2024}
+	if *contract != want {
+		t.Fatalf("unexpected InsuranceContract %+v", contract)
+	}
+	_, err = n.contract.QueryInsuranceContract(n.begin(nil), "contract2")
+	checkError(t, err, "InsuranceContract not found for the given ContractID")
+}
+
+func TestMonthPrimeTransactions(t *testing.T) {
+	n := newTestNetwork(t)
+
+	for _, tt := range []struct {
+		name    string
+		month   int
+		wantErr string
+	}{
+		{"new month", 3, ""},
+		{"duplicate month", 3, "MonthPrime for this VehicleID, Month, and Year already exists"},
+	} {
+		checkError(t, n.contract.AddMonthPrime(n.begin(nil), "vehicle1", tt.month, 2024, 450), tt.wantErr)
+	}
+
+	monthPrime, err := n.contract.QueryMonthPrime(n.begin(nil), "vehicle1", 3, 2024)
+	n.must("QueryMonthPrime", err)
+	if monthPrime.Prime != 450 {
+		t.Fatalf("unexpected MonthPrime %+v", monthPrime)
+	}
+
+	tests := []struct {
+		name    string
+		run     func(ctx contractapi.TransactionContextInterface) error
+		wantErr string
+	}{
+		{"QueryMonthPrime invalid month", func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.QueryMonthPrime(ctx, "vehicle1", 13, 2024)
+			return err
+		}, "Invalid month value"},
+		{"QueryMonthPrime missing month", func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.QueryMonthPrime(ctx, "vehicle1", 4, 2024)
+			return err
+		}, "MonthPrime not found for the given VehicleID, Month, and Year"},
+		{"AggregateMonthlyPremium invalid month", func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.AggregateMonthlyPremium(ctx, "vehicle1", 0, 2024)
+			return err
+		}, "Invalid month value"},
+		{"AggregateMonthlyPremium decrypted month", func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.AggregateMonthlyPremium(ctx, "vehicle1", 3, 2024)
+			return err
+		}, "MonthPrime for this VehicleID, Month, and Year already exists"},
+		{"DecryptMonthlyPremium invalid month", func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.DecryptMonthlyPremium(ctx, "vehicle1", 13, 2024, "2")
+			return err
+		}, "Invalid month value"},
+		{"DecryptMonthlyPremium invalid r_prime", func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.DecryptMonthlyPremium(ctx, "vehicle1", 4, 2024, "0x2")
+			return err
+		}, "Failed to parse r_prime into *big.Int"},
+		{"DecryptMonthlyPremium month not aggregated", func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.DecryptMonthlyPremium(ctx, "vehicle1", 4, 2024, "2")
+			return err
+		}, "aggregate the month first"},
+		{"QueryEncryptedMonthPrime invalid month", func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.QueryEncryptedMonthPrime(ctx, "vehicle1", -1, 2024)
+			return err
+		}, "Invalid month value"},
+		{"QueryEncryptedMonthPrime missing month", func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.QueryEncryptedMonthPrime(ctx, "vehicle1", 4, 2024)
+			return err
+		}, "EncryptedMonthPrime not found for the given VehicleID, Month, and Year"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, tt.run(n.begin(nil)), tt.wantErr)
+		})
+	}
+}
+
+func TestMonthlyCumulativePrime(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n, _, _ := newPricedNetwork(t)
+	n.addTrip("vehicle1", "trip2", "2024-03-28", "owner1", &sk.PublicKey, testTrip)
+
+	total := 0
+	for _, tripID := range []string{"trip1", "trip2"} {
+		total += n.decrypt(n.calculate("vehicle1", tripID, "weights1"), sk)
+	}
+
+	// Le cumul des primes déchiffrées une à une conserve sa clé historique
+	// monthprime_<VehicleID>_<Year>_<Month>
+	var cumulative billing.MonthPrime
+	n.must("Unmarshal MonthPrime", json.Unmarshal(n.stub.State["monthprime_vehicle1_2024_3"], &cumulative))
+	if cumulative.Prime != total || total != 2*referencePremium(t, testVehicle, testTrip, testWeights) {
+		t.Fatalf("cumulative prime %d, expected %d", cumulative.Prime, total)
+	}
+}
+
+func TestVehicleAndTripMaintenance(t *testing.T) {
+	n, _, _ := newPricedNetwork(t)
+
+	checkError(t, n.contract.AddOwnerToVehicleData(n.begin(nil), "vehicle2", "owner2"), "Vehicle data not found for the given VehicleID")
+	n.must("AddOwnerToVehicleData", n.contract.AddOwnerToVehicleData(n.begin(nil), "vehicle1", "owner2"))
+
+	vehicle, err := n.contract.QueryVehicleData(n.begin(nil), "vehicle1")
+	n.must("QueryVehicleData", err)
+	if vehicle.OwnerID != "owner2" {
+		t.Fatalf("owner not updated: %+v", vehicle)
+	}
+
+	// Le nouveau propriétaire n'a pas de clé : la prime ne peut plus être calculée
+	_, err = n.contract.CalculateInsurancePremium(n.begin(nil), "vehicle1", "trip1", "weights1")
+	checkError(t, err, "Verifier not found for the given OwnerID")
+
+	for _, tt := range []struct {
+		tripID  string
+		wantErr string
+	}{
+		{"trip2", "Trip data with this TripID does not exist"},
+		{"trip1", ""},
+		{"trip1", "Trip data with this TripID does not exist"},
+	} {
+		checkError(t, n.contract.DeleteEncryptedTripData(n.begin(nil), tt.tripID), tt.wantErr)
+	}
+
+	_, err = n.contract.QueryEncryptedCalculationResult(n.begin(nil), "result_trip1")
+	checkError(t, err, "EncryptedCalculationResult not found")
+}
+
+func TestQueryEncryptedCalculationResult(t *testing.T) {
+	n, _, _ := newPricedNetwork(t)
+	result := n.calculate("vehicle1", "trip1", "weights1")
+
+	queried, err := n.contract.QueryEncryptedCalculationResult(n.begin(nil), result.ResultID)
+	n.must("QueryEncryptedCalculationResult", err)
+	stored := decodeResult(t, queried)
+	if stored.PrimeTotale.Int().Cmp(result.PrimeTotale.Int()) != 0 || stored.R.Cmp(result.R) != 0 {
+		t.Fatal("stored EncryptedCalculationResult differs from the calculated one")
+	}
+
+	_, err = n.contract.QueryPrime(n.begin(nil), "trip1")
+	checkError(t, err, "Prime not found for the given TripID")
+}
+
+func TestQueryMultipleOwnerDetailsArguments(t *testing.T) {
+	n := newTestNetwork(t)
+	_, err := n.contract.QueryMultipleOwnerDetails(n.begin(nil), nil)
+	checkError(t, err, "Expecting at least one OwnerID as argument")
+
+	// Les OwnerID vides sont ignorés sans requête
+	details, err := n.contract.QueryMultipleOwnerDetails(n.begin(nil), []string{""})
+	n.must("QueryMultipleOwnerDetails", err)
+	if details == nil || len(details) != 0 {
+		t.Fatalf("unexpected details %+v", details)
+	}
+}
@@ -0,0 +1,67 @@
+package paillier
+
+import (
+	"errors"
+	"math/big"
+
+	"simple/compact"
+)
+
+// Ciphertext représente un chiffré de Paillier, élément de Z*_{N²}.
+type Ciphertext struct {
+	c *big.Int
+}
+
+// NewCiphertext encapsule la valeur entière c d'un chiffré.
+func NewCiphertext(c *big.Int) *Ciphertext {
+	return &Ciphertext{c: new(big.Int).Set(c)}
+}
+
+// ParseCiphertext lit un chiffré écrit dans le format compact ou en décimal.
+func ParseCiphertext(s string) (*Ciphertext, error) {
+	c, err := DecodeCompact(CiphertextTag, s)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return &Ciphertext{c: c}, nil
+}
+
+// Int retourne une copie de la valeur entière du chiffré.
+func (c *Ciphertext) Int() *big.Int {
+	return new(big.Int).Set(c.c)
+}
+
+// String retourne la représentation décimale du chiffré.
+func (c *Ciphertext) String() string {
+	if c == nil || c.c == nil {
+		return "<nil>"
+	}
+	return c.c.String()
+}
+
+// Encode retourne la représentation compacte versionnée du chiffré.
+func (c *Ciphertext) Encode() string {
+	return EncodeCompact(CiphertextTag, c.c)
+}
+
+// MarshalJSON encode le chiffré comme une chaîne JSON au format compact.
+func (c *Ciphertext) MarshalJSON() ([]byte, error) {
+	if c == nil || c.c == nil {
+		return []byte("null"), nil
+	}
+	return compact.MarshalJSON(CiphertextTag, c.c)
+}
+
+// UnmarshalJSON décode un chiffré écrit comme une chaîne JSON (format compact
+// ou décimal) ou, pour les enregistrements antérieurs, comme un nombre JSON
+// décimal.
+func (c *Ciphertext) UnmarshalJSON(data []byte) error {
+	value, err := compact.UnmarshalJSON(CiphertextTag, data)
+	if err != nil {
+		return errors.New("paillier: failed to unmarshal ciphertext: " + err.Error())
+	}
+	if value != nil {
+		c.c = value
+	}
+	return nil
+}
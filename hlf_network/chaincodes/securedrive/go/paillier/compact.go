@@ -0,0 +1,31 @@
+package paillier
+
+import (
+	"math/big"
+
+	"simple/compact"
+)
+
+// Étiquettes du format compact (voir le paquet compact) des valeurs persistées
+// par ce paquet.
+const (
+	CiphertextTag = "pc1" // Chiffré de Paillier ou de Damgård–Jurik, version 1
+	KeyTag        = "pk1" // Élément d'une clé publique (N, N², V, clés de vérification), version 1
+	ProofTag      = "pz1" // Élément d'une preuve de déchiffrement ou d'une part de déchiffrement, version 1
+	WitnessTag    = "pw1" // Clair déchiffré ou témoin de déchiffrement (r, r′), version 1
+)
+
+// ErrInvalidEncoding est retournée lorsqu'une valeur n'est ni dans le format
+// compact attendu ni un entier décimal.
+var ErrInvalidEncoding = compact.ErrInvalidEncoding
+
+// EncodeCompact écrit l'entier positif x dans le format compact d'étiquette tag.
+func EncodeCompact(tag string, x *big.Int) string {
+	return compact.Encode(tag, x)
+}
+
+// DecodeCompact lit un entier positif écrit dans le format compact d'étiquette
+// tag ou, pour les valeurs enregistrées avant son introduction, en décimal.
+func DecodeCompact(tag, s string) (*big.Int, error) {
+	return compact.Decode(tag, s)
+}
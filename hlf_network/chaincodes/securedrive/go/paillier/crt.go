@@ -0,0 +1,89 @@
+package paillier
+
+import "math/big"
+
+// crtValues regroupe les valeurs précalculées pour le déchiffrement et le
+// calcul de r′ par le théorème des restes chinois (CRT) : les exponentiations
+// se font modulo P² et Q² (ou P et Q) au lieu de N² (ou N).
+type crtValues struct {
+	pSquare   *big.Int
+	qSquare   *big.Int
+	pMinusOne *big.Int
+	qMinusOne *big.Int
+	hp        *big.Int // L_P(g^(P-1) mod P²)^-1 mod P
+	hq        *big.Int // L_Q(g^(Q-1) mod Q²)^-1 mod Q
+	np        *big.Int // (N^S)^-1 mod (P-1)
+	nq        *big.Int // (N^S)^-1 mod (Q-1)
+	qInverse  *big.Int // Q^-1 mod P
+}
+
+// Precompute calcule les valeurs CRT de la clé. NewPrivateKey l'appelle
+// automatiquement ; elle n'est utile que pour une clé construite littéralement.
+// Sans précalcul, Decrypt et ComputeRPrime utilisent le calcul par Lambda. Le
+// déchiffrement d'une clé de Damgård–Jurik ne passe pas par le CRT ; seul le
+// calcul de r′ en profite.
+func (sk *PrivateKey) Precompute() error {
+	if sk.P == nil || sk.Q == nil || sk.N == nil {
+		return ErrInvalidPrivateKey
+	}
+
+	values := &crtValues{
+		pSquare:   new(big.Int).Mul(sk.P, sk.P),
+		qSquare:   new(big.Int).Mul(sk.Q, sk.Q),
+		pMinusOne: new(big.Int).Sub(sk.P, one),
+		qMinusOne: new(big.Int).Sub(sk.Q, one),
+		np:        new(big.Int).ModInverse(sk.plaintextModulus(), new(big.Int).Sub(sk.P, one)),
+		nq:        new(big.Int).ModInverse(sk.plaintextModulus(), new(big.Int).Sub(sk.Q, one)),
+		qInverse:  new(big.Int).ModInverse(sk.Q, sk.P),
+	}
+	values.hp = crtH(sk.N, sk.P, values.pSquare, values.pMinusOne)
+	values.hq = crtH(sk.N, sk.Q, values.qSquare, values.qMinusOne)
+
+	// Les anciennes clés de test peuvent ne pas vérifier gcd(N, (P-1)(Q-1)) = 1
+	if values.np == nil || values.nq == nil || values.qInverse == nil || values.hp == nil || values.hq == nil {
+		return ErrInvalidPrivateKey
+	}
+
+	sk.crt = values
+	return nil
+}
+
+// crtH calcule L_p(g^(p-1) mod p²)^-1 mod p avec g = N + 1.
+func crtH(n, p, pSquare, pMinusOne *big.Int) *big.Int {
+	g := new(big.Int).Add(n, one)
+	u := new(big.Int).Exp(g, pMinusOne, pSquare)
+	return new(big.Int).ModInverse(lp(u, p), p)
+}
+
+// lp calcule L_p(u) = (u - 1) / p.
+func lp(u, p *big.Int) *big.Int {
+	return new(big.Int).Div(new(big.Int).Sub(u, one), p)
+}
+
+// decryptCRT calcule m_P = L_P(C^(P-1) mod P²) * h_P mod P, m_Q de même, puis
+// recombine m modulo N.
+func (sk *PrivateKey) decryptCRT(c *Ciphertext) *big.Int {
+	mp := lp(new(big.Int).Exp(c.c, sk.crt.pMinusOne, sk.crt.pSquare), sk.P)
+	mp.Mul(mp, sk.crt.hp).Mod(mp, sk.P)
+
+	mq := lp(new(big.Int).Exp(c.c, sk.crt.qMinusOne, sk.crt.qSquare), sk.Q)
+	mq.Mul(mq, sk.crt.hq).Mod(mq, sk.Q)
+
+	return sk.crtCombine(mp, mq)
+}
+
+// computeRPrimeCRT calcule les racines N^S-ièmes de R modulo P et modulo Q
+// puis les recombine modulo N.
+func (sk *PrivateKey) computeRPrimeCRT(r *big.Int) *big.Int {
+	rp := new(big.Int).Exp(new(big.Int).Mod(r, sk.P), sk.crt.np, sk.P)
+	rq := new(big.Int).Exp(new(big.Int).Mod(r, sk.Q), sk.crt.nq, sk.Q)
+	return sk.crtCombine(rp, rq)
+}
+
+// crtCombine retourne l'unique x mod N tel que x ≡ xp mod P et x ≡ xq mod Q
+// (recombinaison de Garner).
+func (sk *PrivateKey) crtCombine(xp, xq *big.Int) *big.Int {
+	h := new(big.Int).Sub(xp, xq)
+	h.Mul(h, sk.crt.qInverse).Mod(h, sk.P)
+	return h.Mul(h, sk.Q).Add(h, xq)
+}
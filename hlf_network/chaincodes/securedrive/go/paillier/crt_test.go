@@ -0,0 +1,112 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+var (
+	benchmarkKeyOnce sync.Once
+	benchmarkKey     *PrivateKey
+)
+
+// testKey génère une seule fois une clé de taille réelle pour les tests et
+// les benchmarks.
+func testKey(tb testing.TB) *PrivateKey {
+	benchmarkKeyOnce.Do(func() {
+		sk, err := GenerateKey(rand.Reader, MinModulusBits)
+		if err != nil {
+			tb.Fatalf("GenerateKey: %v", err)
+		}
+		benchmarkKey = sk
+	})
+	if benchmarkKey == nil {
+		tb.Fatal("no test key")
+	}
+	return benchmarkKey
+}
+
+func testCiphertext(tb testing.TB, sk *PrivateKey, m int64) *Ciphertext {
+	c, err := sk.Encrypt(rand.Reader, big.NewInt(m))
+	if err != nil {
+		tb.Fatalf("Encrypt: %v", err)
+	}
+	return c
+}
+
+func TestCRTMatchesLambda(t *testing.T) {
+	sk := testKey(t)
+	if sk.crt == nil {
+		t.Fatal("NewPrivateKey did not precompute CRT values")
+	}
+
+	for _, m := range []int64{0, 1, 42, 1 << 40} {
+		c := testCiphertext(t, sk, m)
+
+		decrypted, err := sk.Decrypt(c)
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+		if decrypted.Cmp(big.NewInt(m)) != 0 || decrypted.Cmp(sk.decryptLambda(c)) != 0 {
+			t.Fatalf("CRT decryption of %d returned %s", m, decrypted)
+		}
+
+		r := sk.ComputeR(c)
+		rPrime, err := sk.ComputeRPrime(r)
+		if err != nil {
+			t.Fatalf("ComputeRPrime: %v", err)
+		}
+		expected, err := sk.computeRPrimeLambda(r)
+		if err != nil {
+			t.Fatalf("computeRPrimeLambda: %v", err)
+		}
+		if rPrime.Cmp(expected) != 0 {
+			t.Fatalf("CRT r′ differs from the Lambda path for %d", m)
+		}
+
+		verified, err := sk.VerifyAndDecrypt(c, rPrime)
+		if err != nil || verified.Cmp(big.NewInt(m)) != 0 {
+			t.Fatalf("VerifyAndDecrypt with CRT r′: %v, %v", verified, err)
+		}
+	}
+}
+
+func BenchmarkDecryptLambda(b *testing.B) {
+	sk := testKey(b)
+	c := testCiphertext(b, sk, 123456)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sk.decryptLambda(c)
+	}
+}
+
+func BenchmarkDecryptCRT(b *testing.B) {
+	sk := testKey(b)
+	c := testCiphertext(b, sk, 123456)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sk.decryptCRT(c)
+	}
+}
+
+func BenchmarkComputeRPrimeLambda(b *testing.B) {
+	sk := testKey(b)
+	r := sk.ComputeR(testCiphertext(b, sk, 123456))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sk.computeRPrimeLambda(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkComputeRPrimeCRT(b *testing.B) {
+	sk := testKey(b)
+	r := sk.ComputeR(testCiphertext(b, sk, 123456))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sk.computeRPrimeCRT(r)
+	}
+}
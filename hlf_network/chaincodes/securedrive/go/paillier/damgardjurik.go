@@ -0,0 +1,151 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+)
+
+// MaxS est l'exposant de Damgård–Jurik maximal accepté : au-delà, le coût des
+// exponentiations modulo N^(S+1) devient prohibitif pour un chaincode.
+const MaxS = 8
+
+// NewDamgardJurikPublicKey construit une clé publique de Damgård–Jurik
+// d'exposant s à partir du module N. s = 1 équivaut à NewPublicKey.
+func NewDamgardJurikPublicKey(n *big.Int, s int) (*PublicKey, error) {
+	pk, err := NewPublicKey(n)
+	if err != nil {
+		return nil, err
+	}
+	if s < 1 || s > MaxS {
+		return nil, ErrInvalidPublicKey
+	}
+	if s == 1 {
+		return pk, nil
+	}
+
+	pk.S = s
+	pk.ns = new(big.Int).Exp(pk.N, big.NewInt(int64(s)), nil)
+	pk.ns1 = new(big.Int).Mul(pk.ns, pk.N)
+	return pk, nil
+}
+
+// NewDamgardJurikPrivateKey construit une clé privée de Damgård–Jurik
+// d'exposant s à partir des facteurs premiers P et Q.
+func NewDamgardJurikPrivateKey(p, q *big.Int, s int) (*PrivateKey, error) {
+	sk, err := NewPrivateKey(p, q)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := NewDamgardJurikPublicKey(sk.N, s)
+	if err != nil {
+		return nil, err
+	}
+	if s == 1 {
+		return sk, nil
+	}
+
+	mu := new(big.Int).ModInverse(sk.Lambda, pk.ns)
+	if mu == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	sk.PublicKey = *pk
+	sk.Mu = mu
+	sk.crt = nil
+	_ = sk.Precompute()
+	return sk, nil
+}
+
+// GenerateDamgardJurikKey génère une clé privée de Damgård–Jurik d'exposant s
+// dont le module N fait exactement bits bits.
+func GenerateDamgardJurikKey(random io.Reader, bits, s int) (*PrivateKey, error) {
+	if s < 1 || s > MaxS {
+		return nil, ErrInvalidPublicKey
+	}
+	sk, err := GenerateKey(random, bits)
+	if err != nil {
+		return nil, err
+	}
+	return NewDamgardJurikPrivateKey(sk.P, sk.Q, s)
+}
+
+// plaintextModulus retourne N^S, module des clairs et exposant de l'aléa.
+func (pk *PublicKey) plaintextModulus() *big.Int {
+	if pk.S <= 1 {
+		return pk.N
+	}
+	if pk.ns != nil {
+		return pk.ns
+	}
+	return new(big.Int).Exp(pk.N, big.NewInt(int64(pk.S)), nil)
+}
+
+// ciphertextModulus retourne N^(S+1), module des chiffrés.
+func (pk *PublicKey) ciphertextModulus() *big.Int {
+	if pk.S <= 1 {
+		return pk.NSquare
+	}
+	if pk.ns1 != nil {
+		return pk.ns1
+	}
+	return new(big.Int).Exp(pk.N, big.NewInt(int64(pk.S+1)), nil)
+}
+
+// PlaintextBits retourne la taille en bits du module des clairs N^S.
+func (pk *PublicKey) PlaintextBits() int {
+	return pk.plaintextModulus().BitLen()
+}
+
+// randomPlaintext tire un masque uniforme de Z_{N^S} (de Z*_N pour une clé de
+// Paillier).
+func (pk *PublicKey) randomPlaintext(random io.Reader) (*big.Int, error) {
+	if pk.S <= 1 {
+		return pk.randomUnit(random)
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	return rand.Int(random, pk.plaintextModulus())
+}
+
+// dlog retourne i mod N^S à partir de u = (1 + N)^i mod N^(S+1), par
+// l'algorithme d'extraction de Damgård et Jurik : i est reconstruit modulo
+// N, N², ..., N^S en retirant à chaque étape les termes binomiaux d'ordre
+// supérieur du développement de (1 + N)^i.
+func (pk *PublicKey) dlog(u *big.Int) *big.Int {
+	if pk.S <= 1 {
+		return pk.l(u)
+	}
+
+	i := new(big.Int)
+	nj := new(big.Int).Set(pk.N) // N^j
+	for j := 1; j <= pk.S; j++ {
+		nj1 := new(big.Int).Mul(nj, pk.N) // N^(j+1)
+		t1 := pk.l(new(big.Int).Mod(u, nj1))
+		t2 := new(big.Int).Set(i)
+
+		nk := big.NewInt(1)        // N^(k-1)
+		factorial := big.NewInt(1) // k!
+		for k := 2; k <= j; k++ {
+			i.Sub(i, one)
+			t2.Mul(t2, i).Mod(t2, nj)
+			nk.Mul(nk, pk.N)
+			factorial.Mul(factorial, big.NewInt(int64(k)))
+
+			term := new(big.Int).Mul(t2, nk)
+			term.Mul(term, new(big.Int).ModInverse(factorial, nj))
+			t1.Sub(t1, term).Mod(t1, nj)
+		}
+		i.Set(t1)
+		nj = nj1
+	}
+	return i
+}
+
+// decryptDamgardJurik calcule m = dlog(C^Lambda mod N^(S+1)) * Mu mod N^S.
+func (sk *PrivateKey) decryptDamgardJurik(c *Ciphertext) *big.Int {
+	u := new(big.Int).Exp(c.c, sk.Lambda, sk.ciphertextModulus())
+	m := sk.dlog(u)
+	m.Mul(m, sk.Mu)
+	return m.Mod(m, sk.plaintextModulus())
+}
@@ -0,0 +1,154 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func testDamgardJurikKey(tb testing.TB, s int) *PrivateKey {
+	sk := testKey(tb)
+	djKey, err := NewDamgardJurikPrivateKey(sk.P, sk.Q, s)
+	if err != nil {
+		tb.Fatalf("NewDamgardJurikPrivateKey(%d): %v", s, err)
+	}
+	return djKey
+}
+
+func TestDamgardJurikEncryptDecrypt(t *testing.T) {
+	for _, s := range []int{2, 3} {
+		sk := testDamgardJurikKey(t, s)
+		if sk.PlaintextBits() <= s*(sk.N.BitLen()-1) {
+			t.Fatalf("s = %d: plaintext space has only %d bits", s, sk.PlaintextBits())
+		}
+
+		// Clairs supérieurs à N, hors de portée d'une clé de Paillier
+		ns := sk.plaintextModulus()
+		values := []*big.Int{
+			big.NewInt(0),
+			new(big.Int).Add(sk.N, big.NewInt(17)),
+			new(big.Int).Sub(ns, one),
+		}
+		for _, m := range values {
+			c, err := sk.Encrypt(rand.Reader, m)
+			if err != nil {
+				t.Fatalf("s = %d: Encrypt: %v", s, err)
+			}
+			decrypted, err := sk.Decrypt(c)
+			if err != nil || decrypted.Cmp(m) != 0 {
+				t.Fatalf("s = %d: Decrypt returned %v, %v", s, decrypted, err)
+			}
+
+			// Déchiffrement vérifiable par le témoin r′
+			rPrime, err := sk.ComputeRPrime(sk.ComputeR(c))
+			if err != nil {
+				t.Fatalf("s = %d: ComputeRPrime: %v", s, err)
+			}
+			verified, err := sk.VerifyAndDecrypt(c, rPrime)
+			if err != nil || verified.Cmp(m) != 0 {
+				t.Fatalf("s = %d: VerifyAndDecrypt returned %v, %v", s, verified, err)
+			}
+			wrong := new(big.Int).Add(rPrime, one)
+			if _, err := sk.VerifyAndDecrypt(c, wrong.Mod(wrong, sk.N)); err != ErrVerification {
+				t.Fatalf("s = %d: VerifyAndDecrypt accepted a wrong r′: %v", s, err)
+			}
+		}
+	}
+}
+
+func TestDamgardJurikHomomorphism(t *testing.T) {
+	sk := testDamgardJurikKey(t, 2)
+	m1 := new(big.Int).Mul(sk.N, big.NewInt(3))
+	m2 := big.NewInt(1234)
+
+	c1, err := sk.Encrypt(rand.Reader, m1)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	c2, err := sk.Encrypt(rand.Reader, m2)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	sum, err := sk.Decrypt(sk.Add(c1, c2))
+	if err != nil || sum.Cmp(new(big.Int).Add(m1, m2)) != 0 {
+		t.Fatalf("Add: %v, %v", sum, err)
+	}
+
+	product, err := sk.MulConst(c2, big.NewInt(-5))
+	if err != nil {
+		t.Fatalf("MulConst: %v", err)
+	}
+	if decoded := sk.DecodeSigned(mustDecrypt(t, sk, product)); decoded.Cmp(big.NewInt(-6170)) != 0 {
+		t.Fatalf("MulConst(-5) returned %s", decoded)
+	}
+
+	rerandomized, err := sk.Rerandomize(rand.Reader, c1)
+	if err != nil {
+		t.Fatalf("Rerandomize: %v", err)
+	}
+	if rerandomized.Int().Cmp(c1.Int()) == 0 || mustDecrypt(t, sk, rerandomized).Cmp(m1) != 0 {
+		t.Fatal("Rerandomize did not produce a fresh ciphertext of the same plaintext")
+	}
+}
+
+func TestDamgardJurikProofs(t *testing.T) {
+	sk := testDamgardJurikKey(t, 2)
+	m := new(big.Int).Add(sk.N, big.NewInt(99))
+	context := []byte("result_trip1")
+	c, r := encryptWithRandom(t, &sk.PublicKey, m)
+
+	decrypted, proof, err := sk.ProveDecryption(rand.Reader, c, context)
+	if err != nil || decrypted.Cmp(m) != 0 {
+		t.Fatalf("ProveDecryption: %v, %v", decrypted, err)
+	}
+	if err := sk.VerifyDecryption(c, m, proof, context); err != nil {
+		t.Fatalf("VerifyDecryption: %v", err)
+	}
+	if err := sk.VerifyDecryption(c, m, proof, []byte("result_trip2")); err != ErrInvalidProof {
+		t.Errorf("other context: expected ErrInvalidProof, got %v", err)
+	}
+	// Un clair hors de Z_{N^S} est refusé, même congru au bon clair
+	outside := new(big.Int).Add(m, sk.plaintextModulus())
+	if err := sk.VerifyDecryption(c, outside, proof, context); err != ErrInvalidProof {
+		t.Errorf("plaintext out of Z_{N^S}: expected ErrInvalidProof, got %v", err)
+	}
+
+	knowledge, err := sk.ProveKnowledge(rand.Reader, c, m, r, context)
+	if err != nil {
+		t.Fatalf("ProveKnowledge: %v", err)
+	}
+	if err := sk.VerifyKnowledge(c, knowledge, context); err != nil {
+		t.Fatalf("VerifyKnowledge: %v", err)
+	}
+	knowledge.Z = new(big.Int).Add(knowledge.Z, one)
+	if err := sk.VerifyKnowledge(c, knowledge, context); err != ErrInvalidProof {
+		t.Errorf("tampered response: expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestDamgardJurikRejectsExponent(t *testing.T) {
+	sk := testKey(t)
+	for _, s := range []int{0, -1, MaxS + 1} {
+		if _, err := NewDamgardJurikPublicKey(sk.N, s); err != ErrInvalidPublicKey {
+			t.Errorf("NewDamgardJurikPublicKey(%d): expected ErrInvalidPublicKey, got %v", s, err)
+		}
+		if _, err := GenerateDamgardJurikKey(rand.Reader, MinModulusBits, s); err != ErrInvalidPublicKey {
+			t.Errorf("GenerateDamgardJurikKey(%d): expected ErrInvalidPublicKey, got %v", s, err)
+		}
+	}
+
+	// Un chiffré doit appartenir à Z*_{N^(S+1)}
+	djKey := testDamgardJurikKey(t, 2)
+	if err := djKey.ValidateCiphertext(NewCiphertext(djKey.ciphertextModulus())); err != ErrInvalidCiphertext {
+		t.Errorf("ciphertext out of range: expected ErrInvalidCiphertext, got %v", err)
+	}
+}
+
+func mustDecrypt(tb testing.TB, sk *PrivateKey, c *Ciphertext) *big.Int {
+	m, err := sk.Decrypt(c)
+	if err != nil {
+		tb.Fatalf("Decrypt: %v", err)
+	}
+	return m
+}
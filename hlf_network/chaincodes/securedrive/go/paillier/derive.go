@@ -0,0 +1,46 @@
+package paillier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+// MinSeedSize est la taille minimale, en octets, d'une graine d'aléa.
+const MinSeedSize = 32
+
+// ErrSeedTooShort est retournée lorsque la graine fournie est trop courte.
+var ErrSeedTooShort = errors.New("paillier: seed is too short")
+
+// DeriveRandom dérive de façon déterministe un aléa r ∈ Z*_N à partir d'une
+// graine secrète et d'une étiquette (HMAC-SHA256 en mode compteur). Chaque
+// étiquette distincte donne un aléa indépendant : une même graine ne doit
+// jamais servir deux fois pour la même étiquette.
+func (pk *PublicKey) DeriveRandom(seed, label []byte) (*big.Int, error) {
+	if len(seed) < MinSeedSize {
+		return nil, ErrSeedTooShort
+	}
+
+	// 128 bits supplémentaires rendent le biais de la réduction modulaire négligeable
+	size := (pk.N.BitLen() + 128 + 7) / 8
+	for counter := uint32(0); ; counter++ {
+		stream := make([]byte, 0, size+sha256.Size)
+		for block := uint32(0); len(stream) < size; block++ {
+			mac := hmac.New(sha256.New, seed)
+			var header [8]byte
+			binary.BigEndian.PutUint32(header[:4], counter)
+			binary.BigEndian.PutUint32(header[4:], block)
+			mac.Write(header[:])
+			mac.Write(label)
+			stream = mac.Sum(stream)
+		}
+
+		r := new(big.Int).SetBytes(stream[:size])
+		r.Mod(r, pk.N)
+		if pk.isUnit(r) {
+			return r, nil
+		}
+	}
+}
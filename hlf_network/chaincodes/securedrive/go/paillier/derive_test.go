@@ -0,0 +1,52 @@
+package paillier
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestDeriveRandom(t *testing.T) {
+	sk := testKey(t)
+	seed := bytes.Repeat([]byte{0x5a}, MinSeedSize)
+
+	r, err := sk.DeriveRandom(seed, []byte("vehicle_vehicle1/purchase_mileage"))
+	if err != nil {
+		t.Fatalf("DeriveRandom: %v", err)
+	}
+	if !sk.isUnit(r) || r.Cmp(sk.N) >= 0 {
+		t.Fatalf("DeriveRandom returned %s, not an element of Z*_N", r)
+	}
+	if _, err := sk.EncryptWithRandom(big.NewInt(42), r); err != nil {
+		t.Fatalf("EncryptWithRandom rejected the derived random: %v", err)
+	}
+
+	// Même graine et même étiquette : même aléa
+	again, err := sk.DeriveRandom(seed, []byte("vehicle_vehicle1/purchase_mileage"))
+	if err != nil || again.Cmp(r) != 0 {
+		t.Fatalf("DeriveRandom is not deterministic: %v", err)
+	}
+
+	// Une autre étiquette ou une autre graine donne un autre aléa
+	other, err := sk.DeriveRandom(seed, []byte("vehicle_vehicle1/purchase_price"))
+	if err != nil || other.Cmp(r) == 0 {
+		t.Fatalf("distinct labels derived the same random: %v", err)
+	}
+	otherSeed := append(bytes.Repeat([]byte{0x5a}, MinSeedSize-1), 0x5b)
+	other, err = sk.DeriveRandom(otherSeed, []byte("vehicle_vehicle1/purchase_mileage"))
+	if err != nil || other.Cmp(r) == 0 {
+		t.Fatalf("distinct seeds derived the same random: %v", err)
+	}
+}
+
+func TestDeriveRandomRejectsShortSeed(t *testing.T) {
+	sk := testKey(t)
+	for _, seed := range [][]byte{nil, {}, make([]byte, MinSeedSize-1)} {
+		if _, err := sk.DeriveRandom(seed, []byte("label")); err != ErrSeedTooShort {
+			t.Errorf("DeriveRandom(%d bytes): expected ErrSeedTooShort, got %v", len(seed), err)
+		}
+	}
+	if _, err := sk.DeriveRandom(make([]byte, MinSeedSize), []byte("label")); err != nil {
+		t.Errorf("DeriveRandom(%d bytes): %v", MinSeedSize, err)
+	}
+}
@@ -0,0 +1,93 @@
+package paillier
+
+import (
+	"errors"
+	"math/big"
+)
+
+// ErrPlaintextOverflow est retournée lorsqu'un clair signé ne tient pas dans
+// l'intervalle représentable ]-N/2, N/2] (]-N^S/2, N^S/2] pour une clé de
+// Damgård–Jurik).
+var ErrPlaintextOverflow = errors.New("paillier: plaintext does not fit in the signed range")
+
+// ErrInvalidScale est retournée lorsqu'une échelle de virgule fixe n'est pas strictement positive.
+var ErrInvalidScale = errors.New("paillier: fixed-point scale must be positive")
+
+// EncodeSigned représente l'entier signé m dans Z_N (Z_{N^S}) : les valeurs
+// négatives sont ramenées dans la moitié haute ]N/2, N) (convention N/2).
+func (pk *PublicKey) EncodeSigned(m *big.Int) (*big.Int, error) {
+	if !pk.FitsSigned(m) {
+		return nil, ErrPlaintextOverflow
+	}
+	return new(big.Int).Mod(m, pk.plaintextModulus()), nil
+}
+
+// DecodeSigned interprète un clair de Z_N (Z_{N^S}) comme un entier signé :
+// les valeurs supérieures à N/2 représentent m - N.
+func (pk *PublicKey) DecodeSigned(m *big.Int) *big.Int {
+	modulus := pk.plaintextModulus()
+	decoded := new(big.Int).Mod(m, modulus)
+	if decoded.Cmp(pk.halfN()) > 0 {
+		decoded.Sub(decoded, modulus)
+	}
+	return decoded
+}
+
+// FitsSigned indique si tout entier de valeur absolue au plus bound est
+// représentable sans ambiguïté par EncodeSigned. Le module étant impair,
+// ]-N/2, N/2] contient exactement les entiers de valeur absolue au plus ⌊N/2⌋,
+// que DecodeSigned restitue tous.
+func (pk *PublicKey) FitsSigned(bound *big.Int) bool {
+	return new(big.Int).Abs(bound).Cmp(pk.halfN()) <= 0
+}
+
+// halfN retourne ⌊N/2⌋, la moitié du module des clairs.
+func (pk *PublicKey) halfN() *big.Int {
+	return new(big.Int).Rsh(pk.plaintextModulus(), 1)
+}
+
+// EncodeFixedPoint convertit une valeur décimale (ex. "12.345" ou "-0.5") en
+// entier à l'échelle donnée : round(value * scale), arrondi au plus proche,
+// les demis étant arrondis en s'éloignant de zéro. La conversion est exacte,
+// sans passer par un flottant.
+func EncodeFixedPoint(value string, scale int64) (*big.Int, error) {
+	if scale <= 0 {
+		return nil, ErrInvalidScale
+	}
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, errors.New("paillier: invalid decimal value '" + value + "'")
+	}
+	rat.Mul(rat, new(big.Rat).SetInt64(scale))
+	return roundRat(rat), nil
+}
+
+// DecodeFixedPoint retourne la valeur décimale m / scale.
+func DecodeFixedPoint(m *big.Int, scale int64) (*big.Rat, error) {
+	if scale <= 0 {
+		return nil, ErrInvalidScale
+	}
+	return new(big.Rat).SetFrac(m, big.NewInt(scale)), nil
+}
+
+// RoundDiv retourne round(m / divisor), les demis étant arrondis en s'éloignant de zéro.
+func RoundDiv(m *big.Int, divisor int64) (*big.Int, error) {
+	if divisor <= 0 {
+		return nil, ErrInvalidScale
+	}
+	return roundRat(new(big.Rat).SetFrac(m, big.NewInt(divisor))), nil
+}
+
+func roundRat(rat *big.Rat) *big.Int {
+	numerator := new(big.Int).Abs(rat.Num())
+	denominator := rat.Denom()
+
+	// round(|a| / b) = floor((2|a| + b) / 2b)
+	rounded := new(big.Int).Lsh(numerator, 1)
+	rounded.Add(rounded, denominator)
+	rounded.Quo(rounded, new(big.Int).Lsh(denominator, 1))
+	if rat.Sign() < 0 {
+		rounded.Neg(rounded)
+	}
+	return rounded
+}
@@ -0,0 +1,67 @@
+package paillier
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSignedEncodingBoundaries(t *testing.T) {
+	for _, s := range []int{1, 2} {
+		pk, err := NewDamgardJurikPublicKey(big.NewInt(3233), s)
+		if err != nil {
+			t.Fatalf("NewDamgardJurikPublicKey(%d): %v", s, err)
+		}
+		modulus := pk.plaintextModulus()
+		half := new(big.Int).Rsh(modulus, 1)
+		above := new(big.Int).Add(half, one)
+
+		// ⌊N/2⌋ et son opposé sont représentables, ⌊N/2⌋ + 1 ne l'est pas
+		for _, m := range []*big.Int{half, new(big.Int).Neg(half)} {
+			encoded, err := pk.EncodeSigned(m)
+			if err != nil {
+				t.Fatalf("s=%d: EncodeSigned(%s): %v", s, m, err)
+			}
+			if decoded := pk.DecodeSigned(encoded); decoded.Cmp(m) != 0 {
+				t.Fatalf("s=%d: DecodeSigned(EncodeSigned(%s)) = %s", s, m, decoded)
+			}
+			if !pk.FitsSigned(m) {
+				t.Errorf("s=%d: FitsSigned(%s) = false", s, m)
+			}
+		}
+		for _, m := range []*big.Int{above, new(big.Int).Neg(above)} {
+			if _, err := pk.EncodeSigned(m); err != ErrPlaintextOverflow {
+				t.Errorf("s=%d: EncodeSigned(%s): expected ErrPlaintextOverflow, got %v", s, m, err)
+			}
+			if pk.FitsSigned(m) {
+				t.Errorf("s=%d: FitsSigned(%s) = true", s, m)
+			}
+		}
+
+		// Les deux représentants voisins de la frontière
+		if decoded := pk.DecodeSigned(half); decoded.Cmp(half) != 0 {
+			t.Errorf("s=%d: DecodeSigned(⌊N/2⌋) = %s", s, decoded)
+		}
+		if decoded := pk.DecodeSigned(above); decoded.Cmp(new(big.Int).Neg(half)) != 0 {
+			t.Errorf("s=%d: DecodeSigned(⌊N/2⌋ + 1) = %s", s, decoded)
+		}
+	}
+}
+
+func TestSignedEncodingIsBijective(t *testing.T) {
+	pk, err := NewPublicKey(big.NewInt(3233))
+	if err != nil {
+		t.Fatalf("NewPublicKey: %v", err)
+	}
+
+	// Tout clair de Z_N est l'encodage d'exactement un entier représentable
+	for x := int64(0); x < 3233; x++ {
+		decoded := pk.DecodeSigned(big.NewInt(x))
+		encoded, err := pk.EncodeSigned(decoded)
+		if err != nil {
+			t.Fatalf("EncodeSigned(DecodeSigned(%d)): %v", x, err)
+		}
+		if encoded.Int64() != x {
+			t.Fatalf("EncodeSigned(DecodeSigned(%d)) = %s", x, encoded)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+)
+
+const equalityDomain = "securedrive/paillier/equality/v1"
+
+// EqualityProof prouve que deux chiffrés, sous deux clés publiques
+// différentes, chiffrent le même entier signé m, sans révéler m. Elle permet
+// de rechiffrer sous une nouvelle clé des données enregistrées sous une clé
+// retirée sans que le propriétaire puisse en modifier le contenu.
+type EqualityProof struct {
+	A1 *big.Int `json:"a1"` // a1 = (1+N1)^x * s1^N1 mod N1²
+	A2 *big.Int `json:"a2"` // a2 = (1+N2)^x * s2^N2 mod N2²
+	Z  *big.Int `json:"z"`  // z = x + e*m (dans Z)
+	W1 *big.Int `json:"w1"` // w1 = s1 * r1^e mod N1
+	W2 *big.Int `json:"w2"` // w2 = s2 * r2^e mod N2
+}
+
+// equalityBits retourne la taille maximale de |m| dans une preuve d'égalité
+// entre from et to. La réponse z = x + e*m dépasse m de 2 * challengeBits + 1
+// bits : elle reste inférieure à la moitié du plus petit module, de sorte que
+// l'entier extrait d'une preuve se décode de la même façon sous les deux clés.
+func equalityBits(from, to *PublicKey) int {
+	bits := from.plaintextModulus().BitLen()
+	if to.plaintextModulus().BitLen() < bits {
+		bits = to.plaintextModulus().BitLen()
+	}
+	return bits - 2*challengeBits - 4
+}
+
+// ProveEquality prouve que c1 = Enc_from(m; r1) et c2 = Enc_to(m; r2)
+// chiffrent le même entier signé m, avec |m| < 2^equalityBits. Le détenteur
+// de l'ancienne clé obtient r1 par ComputeRPrime.
+func ProveEquality(random io.Reader, from *PublicKey, c1 *Ciphertext, r1 *big.Int, to *PublicKey, c2 *Ciphertext, r2, m *big.Int, context []byte) (*EqualityProof, error) {
+	if err := from.ValidateCiphertext(c1); err != nil {
+		return nil, err
+	}
+	if err := to.ValidateCiphertext(c2); err != nil {
+		return nil, err
+	}
+	if !from.isUnit(r1) || !to.isUnit(r2) {
+		return nil, ErrInvalidRandom
+	}
+	bits := equalityBits(from, to)
+	if new(big.Int).Abs(m).BitLen() > bits {
+		return nil, ErrPlaintextOverflow
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	// x masque e*m : il dépasse de 2 * challengeBits bits la borne des valeurs
+	x, err := rand.Int(random, new(big.Int).Lsh(one, uint(bits+2*challengeBits)))
+	if err != nil {
+		return nil, err
+	}
+	s1, err := from.randomUnit(random)
+	if err != nil {
+		return nil, err
+	}
+	s2, err := to.randomUnit(random)
+	if err != nil {
+		return nil, err
+	}
+
+	a1 := from.mulMod(from.gExp(x), new(big.Int).Exp(s1, from.plaintextModulus(), from.ciphertextModulus()))
+	a2 := to.mulMod(to.gExp(x), new(big.Int).Exp(s2, to.plaintextModulus(), to.ciphertextModulus()))
+
+	e := equalityChallenge(from, c1, to, c2, a1, a2, context)
+
+	z := new(big.Int).Mul(e, m)
+	z.Add(z, x)
+
+	w1 := new(big.Int).Exp(r1, e, from.N)
+	w1.Mul(w1, s1).Mod(w1, from.N)
+	w2 := new(big.Int).Exp(r2, e, to.N)
+	w2.Mul(w2, s2).Mod(w2, to.N)
+
+	return &EqualityProof{A1: a1, A2: a2, Z: z, W1: w1, W2: w2}, nil
+}
+
+// VerifyEquality vérifie que proof établit que c1 (sous from) et c2 (sous to)
+// chiffrent le même entier signé pour le contexte donné.
+func VerifyEquality(from *PublicKey, c1 *Ciphertext, to *PublicKey, c2 *Ciphertext, proof *EqualityProof, context []byte) error {
+	if err := from.ValidateCiphertext(c1); err != nil {
+		return err
+	}
+	if err := to.ValidateCiphertext(c2); err != nil {
+		return err
+	}
+	if proof == nil || proof.A1 == nil || proof.A2 == nil || proof.Z == nil {
+		return ErrInvalidProof
+	}
+	if from.ValidateCiphertext(&Ciphertext{c: proof.A1}) != nil || to.ValidateCiphertext(&Ciphertext{c: proof.A2}) != nil {
+		return ErrInvalidProof
+	}
+	if !from.isUnit(proof.W1) || !to.isUnit(proof.W2) {
+		return ErrInvalidProof
+	}
+	// Une réponse trop grande permettrait de prouver un entier congru à m1
+	// modulo N1 et à m2 modulo N2, avec m1 ≠ m2
+	if new(big.Int).Abs(proof.Z).BitLen() > equalityBits(from, to)+2*challengeBits+1 {
+		return ErrInvalidProof
+	}
+
+	e := equalityChallenge(from, c1, to, c2, proof.A1, proof.A2, context)
+
+	// (1+N)^z * w^N ≡ a * c^e mod N², pour chacune des deux clés
+	for _, side := range []struct {
+		pk   *PublicKey
+		c    *Ciphertext
+		a, w *big.Int
+	}{
+		{from, c1, proof.A1, proof.W1},
+		{to, c2, proof.A2, proof.W2},
+	} {
+		left := side.pk.mulMod(side.pk.gExp(proof.Z), new(big.Int).Exp(side.w, side.pk.plaintextModulus(), side.pk.ciphertextModulus()))
+		right := side.pk.mulMod(side.a, new(big.Int).Exp(side.c.c, e, side.pk.ciphertextModulus()))
+		if left.Cmp(right) != 0 {
+			return ErrInvalidProof
+		}
+	}
+	return nil
+}
+
+func equalityChallenge(from *PublicKey, c1 *Ciphertext, to *PublicKey, c2 *Ciphertext, a1, a2 *big.Int, context []byte) *big.Int {
+	return challenge(equalityDomain,
+		from.N.Bytes(),
+		c1.c.Bytes(),
+		to.N.Bytes(),
+		c2.c.Bytes(),
+		a1.Bytes(),
+		a2.Bytes(),
+		context,
+	)
+}
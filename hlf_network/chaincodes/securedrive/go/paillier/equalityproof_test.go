@@ -0,0 +1,161 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+var (
+	otherKeyOnce sync.Once
+	otherKey     *PrivateKey
+)
+
+// testOtherKey génère une seule fois une seconde clé, de module différent de
+// celui de testKey, vers laquelle les tests rechiffrent
+func testOtherKey(tb testing.TB) *PrivateKey {
+	otherKeyOnce.Do(func() {
+		sk, err := GenerateKey(rand.Reader, MinModulusBits)
+		if err != nil {
+			tb.Fatalf("GenerateKey: %v", err)
+		}
+		otherKey = sk
+	})
+	if otherKey == nil {
+		tb.Fatal("no second test key")
+	}
+	return otherKey
+}
+
+// reencryptTestValue chiffre m sous from et to, en retournant les aléas
+func reencryptTestValue(tb testing.TB, from, to *PublicKey, m *big.Int) (*Ciphertext, *big.Int, *Ciphertext, *big.Int) {
+	m1, err := from.EncodeSigned(m)
+	if err != nil {
+		tb.Fatalf("EncodeSigned: %v", err)
+	}
+	m2, err := to.EncodeSigned(m)
+	if err != nil {
+		tb.Fatalf("EncodeSigned: %v", err)
+	}
+	c1, r1 := encryptWithRandom(tb, from, m1)
+	c2, r2 := encryptWithRandom(tb, to, m2)
+	return c1, r1, c2, r2
+}
+
+func TestEqualityProof(t *testing.T) {
+	from, to := &testKey(t).PublicKey, &testOtherKey(t).PublicKey
+	context := []byte("vehicle_vehicle1/year/v2")
+
+	for _, value := range []int64{0, 2019, -42} {
+		m := big.NewInt(value)
+		c1, r1, c2, r2 := reencryptTestValue(t, from, to, m)
+		proof, err := ProveEquality(rand.Reader, from, c1, r1, to, c2, r2, m, context)
+		if err != nil {
+			t.Fatalf("ProveEquality(%d): %v", value, err)
+		}
+		if err := VerifyEquality(from, c1, to, c2, proof, context); err != nil {
+			t.Fatalf("VerifyEquality(%d): %v", value, err)
+		}
+	}
+
+	// Le prouveur refuse un clair trop grand pour que la preuve soit sûre
+	m := new(big.Int).Lsh(one, uint(equalityBits(from, to)))
+	c1, r1, c2, r2 := reencryptTestValue(t, from, to, m)
+	if _, err := ProveEquality(rand.Reader, from, c1, r1, to, c2, r2, m, context); err != ErrPlaintextOverflow {
+		t.Errorf("oversized plaintext: expected ErrPlaintextOverflow, got %v", err)
+	}
+}
+
+func TestEqualityProofRejectsTampering(t *testing.T) {
+	from, to := &testKey(t).PublicKey, &testOtherKey(t).PublicKey
+	context := []byte("vehicle_vehicle1/year/v2")
+	m := big.NewInt(2019)
+	c1, r1, c2, r2 := reencryptTestValue(t, from, to, m)
+
+	prove := func() *EqualityProof {
+		proof, err := ProveEquality(rand.Reader, from, c1, r1, to, c2, r2, m, context)
+		if err != nil {
+			t.Fatalf("ProveEquality: %v", err)
+		}
+		return proof
+	}
+
+	// Un rechiffrement d'une autre valeur
+	_, _, other, _ := reencryptTestValue(t, from, to, big.NewInt(2020))
+	if err := VerifyEquality(from, c1, to, other, prove(), context); err != ErrInvalidProof {
+		t.Errorf("other plaintext: expected ErrInvalidProof, got %v", err)
+	}
+	if err := VerifyEquality(from, c1, to, c2, prove(), []byte("vehicle_vehicle2/year/v2")); err != ErrInvalidProof {
+		t.Errorf("other context: expected ErrInvalidProof, got %v", err)
+	}
+
+	proof := prove()
+	proof.Z = new(big.Int).Add(proof.Z, one)
+	if err := VerifyEquality(from, c1, to, c2, proof, context); err != ErrInvalidProof {
+		t.Errorf("tampered response: expected ErrInvalidProof, got %v", err)
+	}
+	proof = prove()
+	proof.W2 = nil
+	if err := VerifyEquality(from, c1, to, c2, proof, context); err != ErrInvalidProof {
+		t.Errorf("missing response: expected ErrInvalidProof, got %v", err)
+	}
+}
+
+// TestEqualityProofRejectsCRTLift rejoue la preuve d'un prouveur malhonnête
+// qui « rechiffre » 50000 en 10 : l'entier M ≡ 50000 mod N1 et M ≡ 10 mod N2,
+// obtenu par le théorème des restes chinois, satisfait les deux équations de
+// vérification, mais la réponse z = x + e*M dépasse la borne
+func TestEqualityProofRejectsCRTLift(t *testing.T) {
+	from, to := &testKey(t).PublicKey, &testOtherKey(t).PublicKey
+	context := []byte("trip_trip1/mileage/v2")
+	c1, r1 := encryptWithRandom(t, from, big.NewInt(50000))
+	c2, r2 := encryptWithRandom(t, to, big.NewInt(10))
+
+	// M = 50000 + N1 * ((10 - 50000) * N1^-1 mod N2)
+	k := new(big.Int).ModInverse(from.N, to.N)
+	k.Mul(k, big.NewInt(10-50000)).Mod(k, to.N)
+	lifted := new(big.Int).Mul(k, from.N)
+	lifted.Add(lifted, big.NewInt(50000))
+
+	x, err := rand.Int(rand.Reader, new(big.Int).Lsh(one, uint(lifted.BitLen()+2*challengeBits)))
+	if err != nil {
+		t.Fatalf("rand.Int: %v", err)
+	}
+	sigma1, err := from.randomUnit(rand.Reader)
+	if err != nil {
+		t.Fatalf("randomUnit: %v", err)
+	}
+	sigma2, err := to.randomUnit(rand.Reader)
+	if err != nil {
+		t.Fatalf("randomUnit: %v", err)
+	}
+	a1 := from.mulMod(from.gExp(x), new(big.Int).Exp(sigma1, from.N, from.NSquare))
+	a2 := to.mulMod(to.gExp(x), new(big.Int).Exp(sigma2, to.N, to.NSquare))
+
+	e := equalityChallenge(from, c1, to, c2, a1, a2, context)
+	z := new(big.Int).Mul(e, lifted)
+	z.Add(z, x)
+	w1 := new(big.Int).Exp(r1, e, from.N)
+	w1.Mul(w1, sigma1).Mod(w1, from.N)
+	w2 := new(big.Int).Exp(r2, e, to.N)
+	w2.Mul(w2, sigma2).Mod(w2, to.N)
+	proof := &EqualityProof{A1: a1, A2: a2, Z: z, W1: w1, W2: w2}
+
+	// Sans la borne sur z, les deux équations sont satisfaites
+	for _, side := range []struct {
+		pk   *PublicKey
+		c    *Ciphertext
+		a, w *big.Int
+	}{{from, c1, a1, w1}, {to, c2, a2, w2}} {
+		left := side.pk.mulMod(side.pk.gExp(z), new(big.Int).Exp(side.w, side.pk.N, side.pk.NSquare))
+		right := side.pk.mulMod(side.a, new(big.Int).Exp(side.c.c, e, side.pk.NSquare))
+		if left.Cmp(right) != 0 {
+			t.Fatal("lifted proof does not satisfy the verification equations")
+		}
+	}
+
+	if err := VerifyEquality(from, c1, to, c2, proof, context); err != ErrInvalidProof {
+		t.Fatalf("lifted proof: expected ErrInvalidProof, got %v", err)
+	}
+}
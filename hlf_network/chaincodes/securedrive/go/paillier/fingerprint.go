@@ -0,0 +1,45 @@
+package paillier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Fingerprint retourne l'empreinte SHA-256, en hexadécimal, de la clé
+// publique : condensat de N au format compact, suivi de ";s=<S>" pour une clé
+// de Damgård–Jurik. Deux clés de même module mais d'exposants différents ont
+// donc des empreintes distinctes.
+func (pk *PublicKey) Fingerprint() string {
+	return fingerprint(pk.encodeFingerprint())
+}
+
+// Fingerprint retourne l'empreinte de la clé à seuil : celle de la clé
+// publique étend l'encodage de N par ";t=<Threshold>/<Parties>", V et les clés
+// de vérification. Une clé à seuil n'a donc pas l'empreinte de la clé de
+// Paillier de même module, ni celle d'un autre partage de la même clé.
+func (tpk *ThresholdPublicKey) Fingerprint() string {
+	encoded := []string{
+		tpk.PublicKey.encodeFingerprint(),
+		"t=" + strconv.Itoa(tpk.Threshold) + "/" + strconv.Itoa(tpk.Parties),
+		"v=" + EncodeCompact(KeyTag, tpk.V),
+	}
+	for _, verificationKey := range tpk.VerificationKeys {
+		encoded = append(encoded, "vk="+EncodeCompact(KeyTag, verificationKey))
+	}
+	return fingerprint(strings.Join(encoded, ";"))
+}
+
+func (pk *PublicKey) encodeFingerprint() string {
+	encoded := EncodeCompact(KeyTag, pk.N)
+	if pk.S > 1 {
+		encoded += ";s=" + strconv.Itoa(pk.S)
+	}
+	return encoded
+}
+
+func fingerprint(encoded string) string {
+	sum := sha256.Sum256([]byte(encoded))
+	return hex.EncodeToString(sum[:])
+}
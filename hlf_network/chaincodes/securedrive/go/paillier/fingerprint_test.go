@@ -0,0 +1,71 @@
+package paillier
+
+import (
+	"math/big"
+	"strconv"
+	"testing"
+)
+
+func TestFingerprintIsStable(t *testing.T) {
+	// Les empreintes sont enregistrées sur le ledger : leur encodage ne doit
+	// pas changer
+	pk, err := NewPublicKey(big.NewInt(3233))
+	if err != nil {
+		t.Fatalf("NewPublicKey: %v", err)
+	}
+	if fingerprint := pk.Fingerprint(); fingerprint != "b634098fa59598b72f8cea8085114a92b0af666e3e1510a07502bfdbda92f944" {
+		t.Fatalf("Fingerprint returned %s", fingerprint)
+	}
+
+	sk := testKey(t)
+	rebuilt, err := NewPublicKey(new(big.Int).Set(sk.N))
+	if err != nil {
+		t.Fatalf("NewPublicKey: %v", err)
+	}
+	if sk.Fingerprint() != rebuilt.Fingerprint() || sk.Fingerprint() != sk.PublicKey.Fingerprint() {
+		t.Fatal("fingerprint depends on how the key was built")
+	}
+
+	tpk, _ := testThresholdKey(t)
+	if tpk.Fingerprint() != tpk.Fingerprint() {
+		t.Fatal("threshold fingerprint is not deterministic")
+	}
+}
+
+func TestFingerprintDistinguishesKeys(t *testing.T) {
+	sk := testKey(t)
+	fingerprints := map[string]string{sk.Fingerprint(): "Paillier"}
+	record := func(name, fingerprint string) {
+		if other, ok := fingerprints[fingerprint]; ok {
+			t.Errorf("%s has the fingerprint of %s", name, other)
+		}
+		fingerprints[fingerprint] = name
+	}
+
+	// Même N, exposants de Damgård–Jurik différents
+	for _, s := range []int{2, 3} {
+		pk, err := NewDamgardJurikPublicKey(sk.N, s)
+		if err != nil {
+			t.Fatalf("NewDamgardJurikPublicKey(%d): %v", s, err)
+		}
+		record("Damgård–Jurik s="+strconv.Itoa(s), pk.Fingerprint())
+	}
+
+	// Même N, seuil ou partage différents
+	tpk, _ := testThresholdKey(t)
+	record("threshold key modulus", tpk.PublicKey.Fingerprint())
+	record("2-of-3 threshold key", tpk.Fingerprint())
+
+	threshold := *tpk
+	threshold.Threshold = 3
+	record("3-of-3 threshold key", threshold.Fingerprint())
+
+	resplit := *tpk
+	resplit.VerificationKeys = append([]*big.Int{}, tpk.VerificationKeys...)
+	resplit.VerificationKeys[0], resplit.VerificationKeys[1] = tpk.VerificationKeys[1], tpk.VerificationKeys[0]
+	record("threshold key with other verification keys", resplit.Fingerprint())
+
+	generator := *tpk
+	generator.V = new(big.Int).Exp(tpk.V, big.NewInt(2), tpk.NSquare)
+	record("threshold key with another generator", generator.Fingerprint())
+}
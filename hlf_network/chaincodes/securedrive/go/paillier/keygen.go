@@ -0,0 +1,77 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// MinModulusBits est la taille minimale du module N acceptée par GenerateKey.
+const MinModulusBits = 2048
+
+// primeRounds est le nombre de tours de Miller-Rabin utilisés pour vérifier
+// les facteurs générés.
+const primeRounds = 64
+
+// ErrModulusTooSmall est retournée lorsque la taille demandée est inférieure à MinModulusBits.
+var ErrModulusTooSmall = errors.New("paillier: modulus size is below the minimum")
+
+// GenerateKey génère une clé privée dont le module N = PQ fait exactement bits
+// bits. P et Q sont deux premiers distincts de même longueur (bits/2) et
+// vérifient gcd(PQ, (P-1)(Q-1)) = 1.
+func GenerateKey(random io.Reader, bits int) (*PrivateKey, error) {
+	if bits < MinModulusBits {
+		return nil, ErrModulusTooSmall
+	}
+	if bits%2 != 0 {
+		return nil, errors.New("paillier: modulus size must be even")
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	for {
+		// rand.Prime fixe les deux bits de poids fort : N fait donc exactement bits bits
+		p, err := rand.Prime(random, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := rand.Prime(random, bits/2)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkFactors(p, q); err != nil {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bits {
+			continue
+		}
+
+		return NewPrivateKey(p, q)
+	}
+}
+
+// checkFactors vérifie que P et Q sont deux premiers distincts de même
+// longueur et que gcd(PQ, (P-1)(Q-1)) = 1.
+func checkFactors(p, q *big.Int) error {
+	if p == nil || q == nil || p.Sign() <= 0 || q.Sign() <= 0 {
+		return ErrInvalidPrivateKey
+	}
+	if p.Cmp(q) == 0 || p.BitLen() != q.BitLen() {
+		return ErrInvalidPrivateKey
+	}
+	if !p.ProbablyPrime(primeRounds) || !q.ProbablyPrime(primeRounds) {
+		return ErrInvalidPrivateKey
+	}
+
+	n := new(big.Int).Mul(p, q)
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+	if new(big.Int).GCD(nil, nil, n, phi).Cmp(one) != 0 {
+		return ErrInvalidPrivateKey
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestGenerateKey(t *testing.T) {
+	sk := testKey(t)
+
+	if sk.N.BitLen() != MinModulusBits {
+		t.Fatalf("N has %d bits, expected %d", sk.N.BitLen(), MinModulusBits)
+	}
+	if sk.P.BitLen() != MinModulusBits/2 || sk.Q.BitLen() != MinModulusBits/2 {
+		t.Fatalf("factors have %d and %d bits, expected %d", sk.P.BitLen(), sk.Q.BitLen(), MinModulusBits/2)
+	}
+	if err := checkFactors(sk.P, sk.Q); err != nil {
+		t.Fatalf("checkFactors on generated factors: %v", err)
+	}
+
+	c := testCiphertext(t, sk, 12345)
+	m, err := sk.Decrypt(c)
+	if err != nil || m.Cmp(big.NewInt(12345)) != 0 {
+		t.Fatalf("Decrypt: %v, %v", m, err)
+	}
+}
+
+func TestGenerateKeyRejectsSize(t *testing.T) {
+	if _, err := GenerateKey(rand.Reader, MinModulusBits-2); err != ErrModulusTooSmall {
+		t.Fatalf("GenerateKey(%d): expected ErrModulusTooSmall, got %v", MinModulusBits-2, err)
+	}
+	if _, err := GenerateKey(rand.Reader, 1024); err != ErrModulusTooSmall {
+		t.Fatalf("GenerateKey(1024): expected ErrModulusTooSmall, got %v", err)
+	}
+	if _, err := GenerateKey(rand.Reader, MinModulusBits+1); err == nil {
+		t.Fatal("GenerateKey accepted an odd modulus size")
+	}
+}
+
+func TestCheckFactors(t *testing.T) {
+	sk := testKey(t)
+	nonPrime := new(big.Int).Add(sk.Q, big.NewInt(2))
+	for nonPrime.ProbablyPrime(primeRounds) {
+		nonPrime.Add(nonPrime, big.NewInt(2))
+	}
+
+	tests := []struct {
+		name string
+		p, q *big.Int
+	}{
+		{"nil factor", sk.P, nil},
+		{"negative factor", sk.P, new(big.Int).Neg(sk.Q)},
+		{"p == q", sk.P, new(big.Int).Set(sk.P)},
+		{"different lengths", big.NewInt(11), big.NewInt(23)},
+		{"composite factor", sk.P, nonPrime},
+	}
+	for _, test := range tests {
+		if err := checkFactors(test.p, test.q); err != ErrInvalidPrivateKey {
+			t.Errorf("%s: expected ErrInvalidPrivateKey, got %v", test.name, err)
+		}
+	}
+
+	// Deux premiers distincts de même longueur vérifient gcd(PQ, (P-1)(Q-1)) = 1
+	if err := checkFactors(big.NewInt(11), big.NewInt(13)); err != nil {
+		t.Fatalf("checkFactors(11, 13): %v", err)
+	}
+}
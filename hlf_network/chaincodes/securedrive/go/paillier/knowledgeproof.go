@@ -0,0 +1,71 @@
+package paillier
+
+import (
+	"io"
+	"math/big"
+)
+
+const knowledgeDomain = "securedrive/paillier/knowledge/v1"
+
+// KnowledgeProof prouve la connaissance du clair m et de l'aléa r d'un chiffré
+// C = (1+N)^m * r^N mod N². Liée à la clé publique, elle empêche de soumettre
+// un chiffré produit sous une autre clé ou une valeur arbitraire de Z*_{N²}.
+type KnowledgeProof struct {
+	A *big.Int `json:"a"` // Engagement a = (1+N)^x * s^N mod N²
+	Z *big.Int `json:"z"` // Réponse z = x + e*m mod N (mod N^S)
+	W *big.Int `json:"w"` // Réponse w = s * r^e mod N
+}
+
+// ProveKnowledge produit la preuve de connaissance de (m, r) pour c = Enc(m; r).
+func (pk *PublicKey) ProveKnowledge(random io.Reader, c *Ciphertext, m, r *big.Int, context []byte) (*KnowledgeProof, error) {
+	if !pk.isUnit(r) {
+		return nil, ErrInvalidRandom
+	}
+	x, err := pk.randomPlaintext(random)
+	if err != nil {
+		return nil, err
+	}
+	s, err := pk.randomUnit(random)
+	if err != nil {
+		return nil, err
+	}
+	a := pk.mulMod(pk.gExp(x), new(big.Int).Exp(s, pk.plaintextModulus(), pk.ciphertextModulus()))
+
+	e := pk.knowledgeChallenge(c, a, context)
+	z := new(big.Int).Mul(e, m)
+	z.Add(z, x)
+	z.Mod(z, pk.plaintextModulus())
+	w := new(big.Int).Exp(r, e, pk.N)
+	w.Mul(w, s)
+	w.Mod(w, pk.N)
+
+	return &KnowledgeProof{A: a, Z: z, W: w}, nil
+}
+
+// VerifyKnowledge vérifie (1+N)^z * w^N ≡ a * C^e mod N².
+func (pk *PublicKey) VerifyKnowledge(c *Ciphertext, proof *KnowledgeProof, context []byte) error {
+	if err := pk.ValidateCiphertext(c); err != nil {
+		return err
+	}
+	if proof == nil || proof.A == nil || proof.Z == nil || proof.W == nil {
+		return ErrInvalidProof
+	}
+	if pk.ValidateCiphertext(&Ciphertext{c: proof.A}) != nil || !pk.isUnit(proof.W) {
+		return ErrInvalidProof
+	}
+	if proof.Z.Sign() < 0 || proof.Z.Cmp(pk.plaintextModulus()) >= 0 {
+		return ErrInvalidProof
+	}
+
+	e := pk.knowledgeChallenge(c, proof.A, context)
+	left := pk.mulMod(pk.gExp(proof.Z), new(big.Int).Exp(proof.W, pk.plaintextModulus(), pk.ciphertextModulus()))
+	right := pk.mulMod(proof.A, new(big.Int).Exp(c.c, e, pk.ciphertextModulus()))
+	if left.Cmp(right) != 0 {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+func (pk *PublicKey) knowledgeChallenge(c *Ciphertext, a *big.Int, context []byte) *big.Int {
+	return challenge(knowledgeDomain, pk.N.Bytes(), c.c.Bytes(), a.Bytes(), context)
+}
@@ -0,0 +1,79 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestKnowledgeProof(t *testing.T) {
+	sk := testKey(t)
+	m := big.NewInt(2019)
+	c, r := encryptWithRandom(t, &sk.PublicKey, m)
+	context := []byte("vehicle_car1/year")
+
+	proof, err := sk.ProveKnowledge(rand.Reader, c, m, r, context)
+	if err != nil {
+		t.Fatalf("ProveKnowledge: %v", err)
+	}
+	if err := sk.VerifyKnowledge(c, proof, context); err != nil {
+		t.Fatalf("VerifyKnowledge: %v", err)
+	}
+
+	if err := sk.VerifyKnowledge(c, proof, []byte("vehicle_car2/year")); err != ErrInvalidProof {
+		t.Errorf("other context: expected ErrInvalidProof, got %v", err)
+	}
+	other, _ := encryptWithRandom(t, &sk.PublicKey, m)
+	if err := sk.VerifyKnowledge(other, proof, context); err != ErrInvalidProof {
+		t.Errorf("other ciphertext: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyKnowledge(c, nil, context); err != ErrInvalidProof {
+		t.Errorf("nil proof: expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestKnowledgeProofTampered(t *testing.T) {
+	sk := testKey(t)
+	m := big.NewInt(15000)
+	c, r := encryptWithRandom(t, &sk.PublicKey, m)
+	context := []byte("vehicle_car1/purchase_mileage")
+
+	proof, err := sk.ProveKnowledge(rand.Reader, c, m, r, context)
+	if err != nil {
+		t.Fatalf("ProveKnowledge: %v", err)
+	}
+
+	tampered := *proof
+	tampered.A = sk.mulMod(proof.A, sk.gExp(one))
+	if err := sk.VerifyKnowledge(c, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("tampered commitment: expected ErrInvalidProof, got %v", err)
+	}
+
+	tampered = *proof
+	tampered.Z = new(big.Int).Mod(new(big.Int).Add(proof.Z, one), sk.N)
+	if err := sk.VerifyKnowledge(c, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("tampered response z: expected ErrInvalidProof, got %v", err)
+	}
+
+	tampered = *proof
+	tampered.W = new(big.Int).Mod(new(big.Int).Add(proof.W, one), sk.N)
+	if err := sk.VerifyKnowledge(c, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("tampered response w: expected ErrInvalidProof, got %v", err)
+	}
+
+	// z doit être réduit modulo N
+	tampered = *proof
+	tampered.Z = new(big.Int).Add(proof.Z, sk.N)
+	if err := sk.VerifyKnowledge(c, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("unreduced response: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Une preuve produite avec un autre clair ne vérifie pas
+	wrong, err := sk.ProveKnowledge(rand.Reader, c, big.NewInt(15001), r, context)
+	if err != nil {
+		t.Fatalf("ProveKnowledge: %v", err)
+	}
+	if err := sk.VerifyKnowledge(c, wrong, context); err != ErrInvalidProof {
+		t.Errorf("wrong plaintext: expected ErrInvalidProof, got %v", err)
+	}
+}
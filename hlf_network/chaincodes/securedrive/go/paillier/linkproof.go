@@ -0,0 +1,124 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"simple/pedersen"
+)
+
+const linkDomain = "securedrive/paillier/commitment-link/v1"
+
+// CommitmentLinkProof prouve qu'un chiffré C = (1+N)^m * r^N mod N² et un
+// engagement de Pedersen W = G^m * H^ρ mod P portent sur le même entier signé
+// m, sans révéler m. Le conducteur peut ensuite ouvrir W devant un arbitre
+// sans révéler la clé privée ni les autres champs du trajet.
+type CommitmentLinkProof struct {
+	A1 *big.Int `json:"a1"` // a1 = G^x * H^σ mod P
+	A2 *big.Int `json:"a2"` // a2 = (1+N)^x * s^N mod N²
+	Z  *big.Int `json:"z"`  // z = x + e*m (dans Z)
+	T  *big.Int `json:"t"`  // t = σ + e*ρ mod Q
+	U  *big.Int `json:"u"`  // u = s * r^e mod N
+}
+
+// ProveCommitmentLink prouve que c = Enc(m; r) et que commitment engage m
+// avec l'aléa rho. m doit vérifier |m| < 2^ScalarBits.
+func (pk *PublicKey) ProveCommitmentLink(random io.Reader, params *pedersen.Params, c *Ciphertext, commitment *pedersen.Commitment, m, rho, r *big.Int, context []byte) (*CommitmentLinkProof, error) {
+	if err := pk.ValidateCiphertext(c); err != nil {
+		return nil, err
+	}
+	if !pk.isUnit(r) {
+		return nil, ErrInvalidRandom
+	}
+	if new(big.Int).Abs(m).BitLen() > ScalarBits {
+		return nil, ErrPlaintextOverflow
+	}
+	if err := params.Open(commitment, m, rho); err != nil {
+		return nil, err
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	// x masque e*m : il dépasse de 2 * challengeBits bits la borne des valeurs
+	x, err := rand.Int(random, new(big.Int).Lsh(one, ScalarBits+2*challengeBits))
+	if err != nil {
+		return nil, err
+	}
+	sigma, err := params.RandomExponent(random)
+	if err != nil {
+		return nil, err
+	}
+	s, err := pk.randomUnit(random)
+	if err != nil {
+		return nil, err
+	}
+
+	a1 := params.CommitWithRandom(x, sigma).Int()
+	a2 := pk.mulMod(pk.gExp(x), new(big.Int).Exp(s, pk.plaintextModulus(), pk.ciphertextModulus()))
+
+	e := pk.linkChallenge(params, c, commitment, a1, a2, context)
+
+	z := new(big.Int).Mul(e, m)
+	z.Add(z, x)
+	t := new(big.Int).Mul(e, rho)
+	t.Add(t, sigma).Mod(t, params.Q)
+	u := new(big.Int).Exp(r, e, pk.N)
+	u.Mul(u, s).Mod(u, pk.N)
+
+	return &CommitmentLinkProof{A1: a1, A2: a2, Z: z, T: t, U: u}, nil
+}
+
+// VerifyCommitmentLink vérifie que proof établit que c et commitment portent
+// sur le même entier signé, pour le contexte donné.
+func (pk *PublicKey) VerifyCommitmentLink(params *pedersen.Params, c *Ciphertext, commitment *pedersen.Commitment, proof *CommitmentLinkProof, context []byte) error {
+	if err := pk.ValidateCiphertext(c); err != nil {
+		return err
+	}
+	if err := params.ValidateCommitment(commitment); err != nil {
+		return err
+	}
+	if proof == nil || proof.A1 == nil || proof.A2 == nil || proof.Z == nil || proof.T == nil {
+		return ErrInvalidProof
+	}
+	if pk.ValidateCiphertext(&Ciphertext{c: proof.A2}) != nil || !pk.isUnit(proof.U) {
+		return ErrInvalidProof
+	}
+	// Une réponse trop grande permettrait d'engager m + kQ au lieu de m
+	if new(big.Int).Abs(proof.Z).BitLen() > ScalarBits+2*challengeBits+1 {
+		return ErrInvalidProof
+	}
+	if proof.T.Sign() < 0 || proof.T.Cmp(params.Q) >= 0 {
+		return ErrInvalidProof
+	}
+
+	e := pk.linkChallenge(params, c, commitment, proof.A1, proof.A2, context)
+
+	// G^z * H^t ≡ a1 * W^e mod P
+	if !params.VerifyResponse(commitment, proof.A1, e, proof.Z, proof.T) {
+		return ErrInvalidProof
+	}
+
+	// (1+N)^z * u^N ≡ a2 * C^e mod N²
+	left := pk.mulMod(pk.gExp(proof.Z), new(big.Int).Exp(proof.U, pk.plaintextModulus(), pk.ciphertextModulus()))
+	right := pk.mulMod(proof.A2, new(big.Int).Exp(c.c, e, pk.ciphertextModulus()))
+	if left.Cmp(right) != 0 {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+func (pk *PublicKey) linkChallenge(params *pedersen.Params, c *Ciphertext, commitment *pedersen.Commitment, a1, a2 *big.Int, context []byte) *big.Int {
+	return challenge(linkDomain,
+		pk.N.Bytes(),
+		params.P.Bytes(),
+		params.G.Bytes(),
+		params.H.Bytes(),
+		commitment.Int().Bytes(),
+		c.c.Bytes(),
+		a1.Bytes(),
+		a2.Bytes(),
+		context,
+	)
+}
@@ -0,0 +1,132 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"simple/pedersen"
+)
+
+// linkFixture regroupe un chiffré, l'engagement du même clair et leurs aléas,
+// tels qu'un conducteur les détient.
+type linkFixture struct {
+	c          *Ciphertext
+	commitment *pedersen.Commitment
+	m, rho, r  *big.Int
+}
+
+func newLinkFixture(tb testing.TB, sk *PrivateKey, m int64) *linkFixture {
+	plaintext, err := sk.EncodeSigned(big.NewInt(m))
+	if err != nil {
+		tb.Fatalf("EncodeSigned: %v", err)
+	}
+	c, r := encryptWithRandom(tb, &sk.PublicKey, plaintext)
+	commitment, rho, err := pedersen.DefaultParams().Commit(rand.Reader, big.NewInt(m))
+	if err != nil {
+		tb.Fatalf("Commit: %v", err)
+	}
+	return &linkFixture{c: c, commitment: commitment, m: big.NewInt(m), rho: rho, r: r}
+}
+
+func (f *linkFixture) prove(tb testing.TB, sk *PrivateKey, context []byte) *CommitmentLinkProof {
+	proof, err := sk.ProveCommitmentLink(rand.Reader, pedersen.DefaultParams(), f.c, f.commitment, f.m, f.rho, f.r, context)
+	if err != nil {
+		tb.Fatalf("ProveCommitmentLink: %v", err)
+	}
+	return proof
+}
+
+func TestCommitmentLinkProof(t *testing.T) {
+	sk := testKey(t)
+	params := pedersen.DefaultParams()
+	context := []byte("trip_trip1/speeding/arbitrator1")
+
+	for _, m := range []int64{0, 3, -12} {
+		f := newLinkFixture(t, sk, m)
+		proof := f.prove(t, sk, context)
+		if err := sk.VerifyCommitmentLink(params, f.c, f.commitment, proof, context); err != nil {
+			t.Fatalf("VerifyCommitmentLink(m = %d): %v", m, err)
+		}
+	}
+}
+
+func TestCommitmentLinkProofRejects(t *testing.T) {
+	sk := testKey(t)
+	params := pedersen.DefaultParams()
+	context := []byte("trip_trip1/speeding/arbitrator1")
+	f := newLinkFixture(t, sk, 3)
+	proof := f.prove(t, sk, context)
+
+	// Engagement ou chiffré d'une autre valeur
+	other := newLinkFixture(t, sk, 4)
+	if err := sk.VerifyCommitmentLink(params, f.c, other.commitment, proof, context); err != ErrInvalidProof {
+		t.Errorf("other commitment: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyCommitmentLink(params, other.c, f.commitment, proof, context); err != ErrInvalidProof {
+		t.Errorf("other ciphertext: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyCommitmentLink(params, f.c, f.commitment, proof, []byte("trip_trip2/speeding/arbitrator1")); err != ErrInvalidProof {
+		t.Errorf("other context: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Le prouveur refuse un engagement qui n'ouvre pas sur m
+	_, err := sk.ProveCommitmentLink(rand.Reader, params, f.c, other.commitment, f.m, f.rho, f.r, context)
+	if err != pedersen.ErrInvalidOpening {
+		t.Errorf("wrong opening: expected ErrInvalidOpening, got %v", err)
+	}
+
+	tampered := *proof
+	tampered.Z = new(big.Int).Add(proof.Z, one)
+	if err := sk.VerifyCommitmentLink(params, f.c, f.commitment, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("tampered response z: expected ErrInvalidProof, got %v", err)
+	}
+
+	tampered = *proof
+	tampered.U = nil
+	if err := sk.VerifyCommitmentLink(params, f.c, f.commitment, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("missing response u: expected ErrInvalidProof, got %v", err)
+	}
+
+	tampered = *proof
+	tampered.T = new(big.Int).Add(proof.T, params.Q)
+	if err := sk.VerifyCommitmentLink(params, f.c, f.commitment, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("unreduced response t: expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestCommitmentLinkProofRejectsOversizedResponse(t *testing.T) {
+	sk := testKey(t)
+	params := pedersen.DefaultParams()
+	context := []byte("trip_trip1/speeding/arbitrator1")
+	f := newLinkFixture(t, sk, 3)
+	proof := f.prove(t, sk, context)
+
+	// z + Q·N satisfait les deux équations de vérification, qui ne voient z
+	// que modulo Q et modulo N : seule la borne sur z l'écarte
+	tampered := *proof
+	tampered.Z = new(big.Int).Add(proof.Z, new(big.Int).Mul(params.Q, sk.N))
+	e := sk.linkChallenge(params, f.c, f.commitment, proof.A1, proof.A2, context)
+	if !params.VerifyResponse(f.commitment, proof.A1, e, tampered.Z, proof.T) {
+		t.Fatal("shifted response does not satisfy the commitment equation")
+	}
+	left := sk.mulMod(sk.gExp(tampered.Z), new(big.Int).Exp(proof.U, sk.N, sk.NSquare))
+	right := sk.mulMod(proof.A2, new(big.Int).Exp(f.c.c, e, sk.NSquare))
+	if left.Cmp(right) != 0 {
+		t.Fatal("shifted response does not satisfy the ciphertext equation")
+	}
+	if err := sk.VerifyCommitmentLink(params, f.c, f.commitment, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("oversized response: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Le prouveur refuse une valeur de plus de ScalarBits bits
+	m := new(big.Int).Lsh(one, ScalarBits)
+	commitment, rho, err := params.Commit(rand.Reader, m)
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	c, r := encryptWithRandom(t, &sk.PublicKey, m)
+	if _, err := sk.ProveCommitmentLink(rand.Reader, params, c, commitment, m, rho, r, context); err != ErrPlaintextOverflow {
+		t.Errorf("oversized value: expected ErrPlaintextOverflow, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package paillier
+
+import (
+	"errors"
+	"math/big"
+)
+
+// ErrSlotOverflow est retournée lorsqu'une valeur ne tient pas dans un slot.
+var ErrSlotOverflow = errors.New("paillier: value does not fit in a packing slot")
+
+// Pack regroupe des entiers signés dans un seul clair : la valeur j occupe le
+// slot de poids 2^((k-1-j)*slotBits), k étant le nombre de valeurs. Les
+// slots sont des chiffres équilibrés : chaque valeur doit vérifier
+// |m_j| < 2^(slotBits-1).
+func Pack(values []*big.Int, slotBits uint) (*big.Int, error) {
+	if slotBits < 2 {
+		return nil, ErrSlotOverflow
+	}
+	limit := new(big.Int).Lsh(one, slotBits-1)
+
+	packed := new(big.Int)
+	for _, value := range values {
+		if new(big.Int).Abs(value).Cmp(limit) >= 0 {
+			return nil, ErrSlotOverflow
+		}
+		packed.Lsh(packed, slotBits)
+		packed.Add(packed, value)
+	}
+	return packed, nil
+}
+
+// PackCiphertexts packe de façon homomorphe des chiffrés, dans l'ordre de
+// Pack : le résultat, déterministe, chiffre Pack des clairs. Il ne vérifie pas
+// que chaque clair tient dans son slot.
+func (pk *PublicKey) PackCiphertexts(ciphertexts []*Ciphertext, slotBits uint) (*Ciphertext, error) {
+	if slotBits < 2 {
+		return nil, ErrSlotOverflow
+	}
+	shift := new(big.Int).Lsh(one, slotBits)
+
+	packed := &Ciphertext{c: big.NewInt(1)}
+	for _, c := range ciphertexts {
+		if err := pk.ValidateCiphertext(c); err != nil {
+			return nil, err
+		}
+		shifted, err := pk.MulConst(packed, shift)
+		if err != nil {
+			return nil, err
+		}
+		packed = pk.Add(shifted, c)
+	}
+	return packed, nil
+}
+
+// Unpack retrouve les slots valeurs signées d'un clair produit par Pack.
+func Unpack(packed *big.Int, slots int, slotBits uint) []*big.Int {
+	values := make([]*big.Int, slots)
+	for j := 0; j < slots; j++ {
+		values[j] = ExtractSlot(packed, slots-1-j, slotBits)
+	}
+	return values
+}
+
+// PackedWeights retourne le scalaire W = Σ w_j * 2^(j*slotBits). Si P est le
+// clair produit par Pack sur k valeurs m_j, le slot k-1 du produit P * W vaut
+// le produit scalaire Σ w_j * m_j, à condition que chaque diagonale
+// Σ_{i-j=d} w_i * m_j reste inférieure à 2^(slotBits-2) en valeur absolue.
+// Multiplier un chiffré de P par W (MulConst) calcule ainsi une somme pondérée
+// de tous les slots en une seule exponentiation.
+func PackedWeights(weights []*big.Int, slotBits uint) *big.Int {
+	w := new(big.Int)
+	for j := len(weights) - 1; j >= 0; j-- {
+		w.Lsh(w, slotBits)
+		w.Add(w, weights[j])
+	}
+	return w
+}
+
+// ExtractSlot retourne le chiffre équilibré de position position (poids
+// 2^(position*slotBits)) de l'entier signé t.
+func ExtractSlot(t *big.Int, position int, slotBits uint) *big.Int {
+	shift := uint(position) * slotBits
+
+	// Retirer les slots inférieurs, eux-mêmes représentés de façon équilibrée
+	high := new(big.Int).Sub(t, centeredMod(t, shift))
+	high.Rsh(high, shift)
+	return centeredMod(high, slotBits)
+}
+
+// centeredMod retourne le représentant de x modulo 2^bits dans
+// [-2^(bits-1), 2^(bits-1)).
+func centeredMod(x *big.Int, bits uint) *big.Int {
+	if bits == 0 {
+		return new(big.Int)
+	}
+	modulus := new(big.Int).Lsh(one, bits)
+	half := new(big.Int).Rsh(modulus, 1)
+
+	r := new(big.Int).Mod(x, modulus)
+	if r.Cmp(half) >= 0 {
+		r.Sub(r, modulus)
+	}
+	return r
+}
@@ -0,0 +1,64 @@
+package paillier
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestPackUnpack(t *testing.T) {
+	values := []*big.Int{big.NewInt(3), big.NewInt(-2), big.NewInt(0), big.NewInt(127), big.NewInt(-127)}
+	packed, err := Pack(values, 8)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	for j, value := range Unpack(packed, len(values), 8) {
+		if value.Cmp(values[j]) != 0 {
+			t.Fatalf("slot %d unpacked to %s, expected %s", j, value, values[j])
+		}
+	}
+
+	// Les slots sont des chiffres équilibrés : |m_j| < 2^(slotBits-1)
+	if _, err := Pack([]*big.Int{big.NewInt(128)}, 8); err != ErrSlotOverflow {
+		t.Errorf("value 128 in 8-bit slots: expected ErrSlotOverflow, got %v", err)
+	}
+	if _, err := Pack(values, 1); err != ErrSlotOverflow {
+		t.Errorf("1-bit slots: expected ErrSlotOverflow, got %v", err)
+	}
+}
+
+func TestPackCiphertexts(t *testing.T) {
+	sk := testKey(t)
+	values := []int64{3, -2, 0, 1000}
+	ciphertexts := make([]*Ciphertext, len(values))
+	slots := make([]*big.Int, len(values))
+	for j, value := range values {
+		ciphertexts[j] = testCiphertext(t, sk, value)
+		slots[j] = big.NewInt(value)
+	}
+
+	packed, err := sk.PackCiphertexts(ciphertexts, 16)
+	if err != nil {
+		t.Fatalf("PackCiphertexts: %v", err)
+	}
+	expected, err := Pack(slots, 16)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	if decoded := sk.DecodeSigned(mustDecrypt(t, sk, packed)); decoded.Cmp(expected) != 0 {
+		t.Fatalf("packed ciphertext decrypts to %s, expected %s", decoded, expected)
+	}
+
+	// Le packing est déterministe : il peut être recalculé par le vérifieur
+	again, err := sk.PackCiphertexts(ciphertexts, 16)
+	if err != nil || again.Int().Cmp(packed.Int()) != 0 {
+		t.Fatal("PackCiphertexts is not deterministic")
+	}
+
+	if _, err := sk.PackCiphertexts(ciphertexts, 1); err != ErrSlotOverflow {
+		t.Errorf("1-bit slots: expected ErrSlotOverflow, got %v", err)
+	}
+	invalid := []*Ciphertext{ciphertexts[0], NewCiphertext(sk.N)}
+	if _, err := sk.PackCiphertexts(invalid, 16); err != ErrInvalidCiphertext {
+		t.Errorf("ciphertext outside Z*_N²: expected ErrInvalidCiphertext, got %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+// Package paillier implémente le chiffrement homomorphe de Paillier utilisé par
+// le chaincode securedrive et par les outils hors chaîne : chiffrement,
+// opérations homomorphes, calcul de r′ et déchiffrement vérifiable. Les clés
+// peuvent déclarer un exposant de Damgård–Jurik s > 1 pour agrandir l'espace
+// des clairs à Z_{N^s}.
+package paillier
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+)
+
+var one = big.NewInt(1)
+
+var (
+	// ErrInvalidPublicKey est retournée lorsque le module N est invalide.
+	ErrInvalidPublicKey = errors.New("paillier: invalid public key")
+	// ErrInvalidPrivateKey est retournée lorsque P et Q ne forment pas une clé valide.
+	ErrInvalidPrivateKey = errors.New("paillier: invalid private key")
+	// ErrInvalidCiphertext est retournée lorsqu'un chiffré n'appartient pas à Z*_{N²}.
+	ErrInvalidCiphertext = errors.New("paillier: invalid ciphertext")
+	// ErrInvalidRandom est retournée lorsque l'aléa r n'appartient pas à Z*_N.
+	ErrInvalidRandom = errors.New("paillier: r must be in Z*_N")
+	// ErrVerification est retournée lorsque r′ n'est pas un témoin de déchiffrement valide.
+	ErrVerification = errors.New("paillier: verification failed: rPrime is invalid")
+)
+
+// PublicKey représente une clé publique de Paillier (g = N + 1). Lorsque S est
+// supérieur à 1, il s'agit d'une clé de Damgård–Jurik : les clairs vivent dans
+// Z_{N^S} et les chiffrés dans Z*_{N^(S+1)}. NSquare reste N² dans tous les cas.
+type PublicKey struct {
+	N       *big.Int
+	NSquare *big.Int
+	S       int // Exposant de Damgård–Jurik (0 ou 1 : Paillier)
+
+	ns  *big.Int // N^S précalculé (nil : Paillier ou clé construite littéralement)
+	ns1 *big.Int // N^(S+1) précalculé
+}
+
+// NewPublicKey construit une clé publique à partir du module N.
+func NewPublicKey(n *big.Int) (*PublicKey, error) {
+	pk := &PublicKey{
+		N:       new(big.Int).Set(n),
+		NSquare: new(big.Int).Mul(n, n),
+	}
+	if err := pk.Validate(); err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
+
+// Validate vérifie la cohérence de la clé publique.
+func (pk *PublicKey) Validate() error {
+	if pk == nil || pk.N == nil || pk.NSquare == nil {
+		return ErrInvalidPublicKey
+	}
+	// N est le produit de deux premiers impairs : il est impair et supérieur à 3.
+	if pk.N.Cmp(big.NewInt(3)) <= 0 || pk.N.Bit(0) == 0 {
+		return ErrInvalidPublicKey
+	}
+	if pk.NSquare.Cmp(new(big.Int).Mul(pk.N, pk.N)) != 0 {
+		return ErrInvalidPublicKey
+	}
+	if pk.S < 0 || pk.S > MaxS {
+		return ErrInvalidPublicKey
+	}
+	return nil
+}
+
+// ValidateCiphertext vérifie que c est un élément de Z*_{N^(S+1)}.
+func (pk *PublicKey) ValidateCiphertext(c *Ciphertext) error {
+	if c == nil || c.c == nil {
+		return ErrInvalidCiphertext
+	}
+	if c.c.Sign() <= 0 || c.c.Cmp(pk.ciphertextModulus()) >= 0 {
+		return ErrInvalidCiphertext
+	}
+	if new(big.Int).GCD(nil, nil, c.c, pk.N).Cmp(one) != 0 {
+		return ErrInvalidCiphertext
+	}
+	return nil
+}
+
+// Encrypt chiffre m avec un aléa tiré de random.
+func (pk *PublicKey) Encrypt(random io.Reader, m *big.Int) (*Ciphertext, error) {
+	r, err := pk.randomUnit(random)
+	if err != nil {
+		return nil, err
+	}
+	return pk.EncryptWithRandom(m, r)
+}
+
+// EncryptWithRandom calcule C = (1 + mN) * r^N mod N² (ou
+// (1 + N)^m * r^(N^S) mod N^(S+1)) pour un aléa r fourni.
+func (pk *PublicKey) EncryptWithRandom(m, r *big.Int) (*Ciphertext, error) {
+	if !pk.isUnit(r) {
+		return nil, ErrInvalidRandom
+	}
+	rExpN := new(big.Int).Exp(r, pk.plaintextModulus(), pk.ciphertextModulus())
+	return &Ciphertext{c: pk.mulMod(pk.gExp(m), rExpN)}, nil
+}
+
+// Add retourne le chiffré de m1 + m2.
+func (pk *PublicKey) Add(c1, c2 *Ciphertext) *Ciphertext {
+	return &Ciphertext{c: pk.mulMod(c1.c, c2.c)}
+}
+
+// Sum retourne le chiffré de la somme des clairs (C1 * C2 * ... * Cn mod N²).
+func (pk *PublicKey) Sum(ciphertexts ...*Ciphertext) *Ciphertext {
+	result := big.NewInt(1)
+	for _, c := range ciphertexts {
+		result = pk.mulMod(result, c.c)
+	}
+	return &Ciphertext{c: result}
+}
+
+// Sub retourne le chiffré de m1 - m2 (C1 * C2^-1 mod N²).
+func (pk *PublicKey) Sub(c1, c2 *Ciphertext) (*Ciphertext, error) {
+	inverse := new(big.Int).ModInverse(c2.c, pk.ciphertextModulus())
+	if inverse == nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return &Ciphertext{c: pk.mulMod(c1.c, inverse)}, nil
+}
+
+// MulConst retourne le chiffré de k * m (C^k mod N²). k peut être négatif.
+func (pk *PublicKey) MulConst(c *Ciphertext, k *big.Int) (*Ciphertext, error) {
+	result := new(big.Int).Exp(c.c, k, pk.ciphertextModulus())
+	if result == nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return &Ciphertext{c: result}, nil
+}
+
+// Rerandomize retourne un nouveau chiffré du même clair, C * r^N mod N² avec
+// un aléa r tiré de random : le résultat ne peut pas être relié à C.
+func (pk *PublicKey) Rerandomize(random io.Reader, c *Ciphertext) (*Ciphertext, error) {
+	r, err := pk.randomUnit(random)
+	if err != nil {
+		return nil, err
+	}
+	return pk.RerandomizeWithRandom(c, r)
+}
+
+// RerandomizeWithRandom calcule C * r^N mod N² pour un aléa r ∈ Z*_N fourni.
+func (pk *PublicKey) RerandomizeWithRandom(c *Ciphertext, r *big.Int) (*Ciphertext, error) {
+	if err := pk.ValidateCiphertext(c); err != nil {
+		return nil, err
+	}
+	if !pk.isUnit(r) {
+		return nil, ErrInvalidRandom
+	}
+	rExpN := new(big.Int).Exp(r, pk.plaintextModulus(), pk.ciphertextModulus())
+	return &Ciphertext{c: pk.mulMod(c.c, rExpN)}, nil
+}
+
+// ComputeR calcule R = C mod N, publié pour permettre au détenteur de la clé
+// privée de calculer r′.
+func (pk *PublicKey) ComputeR(c *Ciphertext) *big.Int {
+	return new(big.Int).Mod(c.c, pk.N)
+}
+
+// VerifyAndDecrypt vérifie que r′^N ≡ C mod N puis retourne le clair
+// m = ((C * (r′^N)^-1 mod N²) - 1) / N. Pour une clé de Damgård–Jurik, r′ est
+// la racine N^S-ième de R et m est extrait de C * (r′^(N^S))^-1 mod N^(S+1).
+func (pk *PublicKey) VerifyAndDecrypt(c *Ciphertext, rPrime *big.Int) (*big.Int, error) {
+	if err := pk.ValidateCiphertext(c); err != nil {
+		return nil, err
+	}
+	if !pk.isUnit(rPrime) {
+		return nil, ErrVerification
+	}
+
+	rPrimePowerN := new(big.Int).Exp(rPrime, pk.plaintextModulus(), pk.N)
+	if rPrimePowerN.Cmp(pk.ComputeR(c)) != 0 {
+		return nil, ErrVerification
+	}
+
+	s := new(big.Int).Exp(rPrime, pk.plaintextModulus(), pk.ciphertextModulus())
+	sInverse := new(big.Int).ModInverse(s, pk.ciphertextModulus())
+	if sInverse == nil {
+		return nil, ErrVerification
+	}
+	return pk.dlog(pk.mulMod(c.c, sInverse)), nil
+}
+
+// gExp calcule (1 + N)^m = 1 + mN mod N² (par exponentiation modulo N^(S+1)
+// pour une clé de Damgård–Jurik).
+func (pk *PublicKey) gExp(m *big.Int) *big.Int {
+	if pk.S > 1 {
+		g := new(big.Int).Add(pk.N, one)
+		exponent := new(big.Int).Mod(m, pk.plaintextModulus())
+		return g.Exp(g, exponent, pk.ciphertextModulus())
+	}
+	gm := new(big.Int).Mul(m, pk.N)
+	gm.Add(gm, one)
+	return gm.Mod(gm, pk.NSquare)
+}
+
+// l calcule L(u) = (u - 1) / N.
+func (pk *PublicKey) l(u *big.Int) *big.Int {
+	return new(big.Int).Div(new(big.Int).Sub(u, one), pk.N)
+}
+
+func (pk *PublicKey) mulMod(a, b *big.Int) *big.Int {
+	result := new(big.Int).Mul(a, b)
+	return result.Mod(result, pk.ciphertextModulus())
+}
+
+// isUnit indique si x appartient à Z*_N.
+func (pk *PublicKey) isUnit(x *big.Int) bool {
+	if x == nil || x.Sign() <= 0 || x.Cmp(pk.N) >= 0 {
+		return false
+	}
+	return new(big.Int).GCD(nil, nil, x, pk.N).Cmp(one) == 0
+}
+
+// randomUnit tire un élément uniforme de Z*_N.
+func (pk *PublicKey) randomUnit(random io.Reader) (*big.Int, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+	for {
+		r, err := rand.Int(random, pk.N)
+		if err != nil {
+			return nil, err
+		}
+		if pk.isUnit(r) {
+			return r, nil
+		}
+	}
+}
+
+// PrivateKey représente une clé privée de Paillier.
+type PrivateKey struct {
+	PublicKey
+	P      *big.Int
+	Q      *big.Int
+	Lambda *big.Int // lcm(P-1, Q-1)
+	Mu     *big.Int // Lambda^-1 mod N (mod N^S pour une clé de Damgård–Jurik)
+
+	crt *crtValues // Valeurs CRT précalculées (nil : calcul par Lambda)
+}
+
+// NewPrivateKey construit une clé privée à partir des facteurs premiers P et Q.
+func NewPrivateKey(p, q *big.Int) (*PrivateKey, error) {
+	if p == nil || q == nil || p.Cmp(q) == 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	if !p.ProbablyPrime(20) || !q.ProbablyPrime(20) {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	n := new(big.Int).Mul(p, q)
+	pk, err := NewPublicKey(n)
+	if err != nil {
+		return nil, err
+	}
+
+	pMinusOne := new(big.Int).Sub(p, one)
+	qMinusOne := new(big.Int).Sub(q, one)
+	gcd := new(big.Int).GCD(nil, nil, pMinusOne, qMinusOne)
+	lambda := new(big.Int).Mul(pMinusOne, qMinusOne)
+	lambda.Div(lambda, gcd)
+
+	mu := new(big.Int).ModInverse(lambda, n)
+	if mu == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	sk := &PrivateKey{
+		PublicKey: *pk,
+		P:         new(big.Int).Set(p),
+		Q:         new(big.Int).Set(q),
+		Lambda:    lambda,
+		Mu:        mu,
+	}
+	// Une clé qui ne se prête pas au CRT reste utilisable par le calcul par Lambda
+	_ = sk.Precompute()
+	return sk, nil
+}
+
+// ComputeRPrime calcule r′ = R^(N^-1 mod Lambda) mod N, la racine N-ième de R
+// qui sert de témoin de déchiffrement (racine N^S-ième pour une clé de
+// Damgård–Jurik). Le calcul passe par le CRT lorsque la clé a été précalculée.
+func (sk *PrivateKey) ComputeRPrime(r *big.Int) (*big.Int, error) {
+	if sk.crt != nil {
+		return sk.computeRPrimeCRT(r), nil
+	}
+	return sk.computeRPrimeLambda(r)
+}
+
+// computeRPrimeLambda calcule r′ par une exponentiation modulo N.
+func (sk *PrivateKey) computeRPrimeLambda(r *big.Int) (*big.Int, error) {
+	nInverseModLambda := new(big.Int).ModInverse(sk.plaintextModulus(), sk.Lambda)
+	if nInverseModLambda == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	return new(big.Int).Exp(r, nInverseModLambda, sk.N), nil
+}
+
+// Decrypt retourne le clair de C, par le CRT lorsque la clé a été précalculée.
+func (sk *PrivateKey) Decrypt(c *Ciphertext) (*big.Int, error) {
+	if err := sk.ValidateCiphertext(c); err != nil {
+		return nil, err
+	}
+	if sk.S > 1 {
+		return sk.decryptDamgardJurik(c), nil
+	}
+	if sk.crt != nil {
+		return sk.decryptCRT(c), nil
+	}
+	return sk.decryptLambda(c), nil
+}
+
+// decryptLambda calcule m = L(C^Lambda mod N²) * Mu mod N.
+func (sk *PrivateKey) decryptLambda(c *Ciphertext) *big.Int {
+	u := new(big.Int).Exp(c.c, sk.Lambda, sk.NSquare)
+	m := sk.l(u)
+	m.Mul(m, sk.Mu)
+	return m.Mod(m, sk.N)
+}
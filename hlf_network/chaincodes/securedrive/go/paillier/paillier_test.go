@@ -0,0 +1,189 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	sk := testKey(t)
+	values := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(987654321),
+		new(big.Int).Sub(sk.N, one),
+	}
+	for _, m := range values {
+		c, err := sk.Encrypt(rand.Reader, m)
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+		if err := sk.ValidateCiphertext(c); err != nil {
+			t.Fatalf("Encrypt produced an invalid ciphertext: %v", err)
+		}
+		decrypted, err := sk.Decrypt(c)
+		if err != nil || decrypted.Cmp(m) != 0 {
+			t.Fatalf("Decrypt returned %v, %v, expected %s", decrypted, err, m)
+		}
+	}
+
+	// Le chiffrement est probabiliste, sauf avec un aléa fourni
+	c1, c2 := testCiphertext(t, sk, 42), testCiphertext(t, sk, 42)
+	if c1.Int().Cmp(c2.Int()) == 0 {
+		t.Fatal("two encryptions of the same plaintext are equal")
+	}
+	r, err := sk.randomUnit(rand.Reader)
+	if err != nil {
+		t.Fatalf("randomUnit: %v", err)
+	}
+	first, err := sk.EncryptWithRandom(big.NewInt(42), r)
+	if err != nil {
+		t.Fatalf("EncryptWithRandom: %v", err)
+	}
+	second, err := sk.EncryptWithRandom(big.NewInt(42), r)
+	if err != nil || first.Int().Cmp(second.Int()) != 0 {
+		t.Fatalf("EncryptWithRandom is not deterministic: %v", err)
+	}
+
+	for _, r := range []*big.Int{big.NewInt(0), sk.N, sk.P, nil} {
+		if _, err := sk.EncryptWithRandom(big.NewInt(42), r); err != ErrInvalidRandom {
+			t.Errorf("EncryptWithRandom: expected ErrInvalidRandom, got %v", err)
+		}
+	}
+	if _, err := sk.Decrypt(NewCiphertext(sk.NSquare)); err != ErrInvalidCiphertext {
+		t.Errorf("Decrypt(N²): expected ErrInvalidCiphertext, got %v", err)
+	}
+}
+
+func TestHomomorphicOperations(t *testing.T) {
+	sk := testKey(t)
+	c1, c2 := testCiphertext(t, sk, 1500), testCiphertext(t, sk, 275)
+
+	if m := mustDecrypt(t, sk, sk.Add(c1, c2)); m.Cmp(big.NewInt(1775)) != 0 {
+		t.Fatalf("Add returned %s", m)
+	}
+	if m := mustDecrypt(t, sk, sk.Sum(c1, c2, c2)); m.Cmp(big.NewInt(2050)) != 0 {
+		t.Fatalf("Sum returned %s", m)
+	}
+	if m := mustDecrypt(t, sk, sk.Sum()); m.Sign() != 0 {
+		t.Fatalf("empty Sum returned %s", m)
+	}
+
+	// Une différence négative est représentée modulo N
+	difference, err := sk.Sub(c2, c1)
+	if err != nil {
+		t.Fatalf("Sub: %v", err)
+	}
+	if m := sk.DecodeSigned(mustDecrypt(t, sk, difference)); m.Cmp(big.NewInt(-1225)) != 0 {
+		t.Fatalf("Sub returned %s", m)
+	}
+	if _, err := sk.Sub(c1, NewCiphertext(sk.P)); err != ErrInvalidCiphertext {
+		t.Errorf("Sub of a non-invertible ciphertext: expected ErrInvalidCiphertext, got %v", err)
+	}
+
+	for _, k := range []int64{0, 1, 7, -3} {
+		product, err := sk.MulConst(c1, big.NewInt(k))
+		if err != nil {
+			t.Fatalf("MulConst(%d): %v", k, err)
+		}
+		if m := sk.DecodeSigned(mustDecrypt(t, sk, product)); m.Cmp(big.NewInt(1500*k)) != 0 {
+			t.Fatalf("MulConst(%d) returned %s", k, m)
+		}
+	}
+
+	// Les clairs sont additionnés modulo N
+	wrapped := sk.Add(testCiphertext(t, sk, 2), mustEncrypt(t, sk, new(big.Int).Sub(sk.N, one)))
+	if m := mustDecrypt(t, sk, wrapped); m.Cmp(one) != 0 {
+		t.Fatalf("Add does not wrap modulo N: %s", m)
+	}
+}
+
+func TestValidateCiphertext(t *testing.T) {
+	sk := testKey(t)
+	tests := []struct {
+		name    string
+		c       *Ciphertext
+		wantErr error
+	}{
+		{"fresh ciphertext", testCiphertext(t, sk, 7), nil},
+		{"one", NewCiphertext(one), nil},
+		{"nil", nil, ErrInvalidCiphertext},
+		{"zero", NewCiphertext(big.NewInt(0)), ErrInvalidCiphertext},
+		{"negative", NewCiphertext(big.NewInt(-5)), ErrInvalidCiphertext},
+		{"N²", NewCiphertext(sk.NSquare), ErrInvalidCiphertext},
+		{"above N²", NewCiphertext(new(big.Int).Add(sk.NSquare, one)), ErrInvalidCiphertext},
+		{"multiple of P", NewCiphertext(new(big.Int).Mul(sk.P, big.NewInt(3))), ErrInvalidCiphertext},
+		{"multiple of Q", NewCiphertext(sk.Q), ErrInvalidCiphertext},
+	}
+	for _, tt := range tests {
+		if err := sk.ValidateCiphertext(tt.c); err != tt.wantErr {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func mustEncrypt(tb testing.TB, sk *PrivateKey, m *big.Int) *Ciphertext {
+	c, err := sk.Encrypt(rand.Reader, m)
+	if err != nil {
+		tb.Fatalf("Encrypt: %v", err)
+	}
+	return c
+}
+
+func TestRerandomize(t *testing.T) {
+	sk := testKey(t)
+	c := testCiphertext(t, sk, 2019)
+
+	rerandomized, err := sk.Rerandomize(rand.Reader, c)
+	if err != nil {
+		t.Fatalf("Rerandomize: %v", err)
+	}
+	if rerandomized.Int().Cmp(c.Int()) == 0 {
+		t.Fatal("Rerandomize returned the same ciphertext")
+	}
+	if m, err := sk.Decrypt(rerandomized); err != nil || m.Cmp(big.NewInt(2019)) != 0 {
+		t.Fatalf("rerandomized ciphertext decrypts to %v, %v", m, err)
+	}
+
+	// Avec un aléa fourni, le résultat est déterministe
+	r, err := sk.randomUnit(rand.Reader)
+	if err != nil {
+		t.Fatalf("randomUnit: %v", err)
+	}
+	first, err := sk.RerandomizeWithRandom(c, r)
+	if err != nil {
+		t.Fatalf("RerandomizeWithRandom: %v", err)
+	}
+	second, err := sk.RerandomizeWithRandom(c, r)
+	if err != nil || first.Int().Cmp(second.Int()) != 0 {
+		t.Fatalf("RerandomizeWithRandom is not deterministic: %v", err)
+	}
+}
+
+func TestRerandomizeRejectsInvalidInput(t *testing.T) {
+	sk := testKey(t)
+	c := testCiphertext(t, sk, 42)
+
+	invalid := []struct {
+		name string
+		c    *Ciphertext
+	}{
+		{"zero", NewCiphertext(big.NewInt(0))},
+		{"N²", NewCiphertext(sk.NSquare)},
+		{"multiple of P", NewCiphertext(sk.P)},
+		{"nil", nil},
+	}
+	for _, tt := range invalid {
+		if _, err := sk.Rerandomize(rand.Reader, tt.c); err != ErrInvalidCiphertext {
+			t.Errorf("Rerandomize(%s): expected ErrInvalidCiphertext, got %v", tt.name, err)
+		}
+	}
+
+	// r doit appartenir à Z*_N : ni 0, ni N, ni un multiple d'un facteur
+	for _, r := range []*big.Int{big.NewInt(0), sk.N, sk.Q} {
+		if _, err := sk.RerandomizeWithRandom(c, r); err != ErrInvalidRandom {
+			t.Errorf("RerandomizeWithRandom: expected ErrInvalidRandom, got %v", err)
+		}
+	}
+}
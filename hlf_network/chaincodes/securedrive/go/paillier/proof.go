@@ -0,0 +1,168 @@
+package paillier
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+
+	"simple/compact"
+)
+
+// ErrInvalidProof est retournée lorsqu'une preuve à divulgation nulle ne se vérifie pas.
+var ErrInvalidProof = errors.New("paillier: invalid proof")
+
+// DecryptionProof est une preuve non interactive (Fiat–Shamir) que C chiffre m :
+// elle établit la connaissance d'une racine N-ième ρ de U = C * (1+N)^-m mod N²
+// sans révéler ρ. Le défi est lié à la clé, au chiffré, au clair et à un contexte
+// (par exemple le ResultID) pour empêcher le rejeu de la preuve.
+type DecryptionProof struct {
+	A *big.Int `json:"a"` // Engagement a = s^N mod N²
+	Z *big.Int `json:"z"` // Réponse z = s * ρ^e mod N
+}
+
+// decryptionProofJSON est la forme sérialisée d'une DecryptionProof.
+type decryptionProofJSON struct {
+	A json.RawMessage `json:"a"`
+	Z json.RawMessage `json:"z"`
+}
+
+// MarshalJSON encode les éléments de la preuve au format compact ProofTag.
+func (p DecryptionProof) MarshalJSON() ([]byte, error) {
+	fields, err := compact.MarshalFields(ProofTag, p.A, p.Z)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(decryptionProofJSON{A: fields[0], Z: fields[1]})
+}
+
+// UnmarshalJSON décode une preuve écrite au format compact ou, pour les
+// enregistrements antérieurs, en nombres JSON décimaux.
+func (p *DecryptionProof) UnmarshalJSON(data []byte) error {
+	var encoded decryptionProofJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	values, err := compact.UnmarshalFields(ProofTag, encoded.A, encoded.Z)
+	if err != nil {
+		return errors.New("paillier: failed to unmarshal decryption proof: " + err.Error())
+	}
+	p.A, p.Z = values[0], values[1]
+	return nil
+}
+
+// ProveDecryption déchiffre c et produit la preuve que le clair retourné est
+// correct, liée au contexte fourni.
+func (sk *PrivateKey) ProveDecryption(random io.Reader, c *Ciphertext, context []byte) (*big.Int, *DecryptionProof, error) {
+	m, err := sk.Decrypt(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// ρ est la racine N-ième de C mod N, c'est-à-dire r′
+	rho, err := sk.ComputeRPrime(sk.ComputeR(c))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, err := sk.decryptionResidue(c, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, z, err := sk.proveNthRoot(random, u, rho, decryptionDomain, c.c.Bytes(), m.Bytes(), context)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return m, &DecryptionProof{A: a, Z: z}, nil
+}
+
+// VerifyDecryption vérifie que proof établit que c chiffre m pour le contexte donné.
+func (pk *PublicKey) VerifyDecryption(c *Ciphertext, m *big.Int, proof *DecryptionProof, context []byte) error {
+	if err := pk.ValidateCiphertext(c); err != nil {
+		return err
+	}
+	if m == nil || m.Sign() < 0 || m.Cmp(pk.plaintextModulus()) >= 0 {
+		return ErrInvalidProof
+	}
+	if proof == nil {
+		return ErrInvalidProof
+	}
+
+	u, err := pk.decryptionResidue(c, m)
+	if err != nil {
+		return ErrInvalidProof
+	}
+	if !pk.verifyNthRoot(u, proof.A, proof.Z, decryptionDomain, c.c.Bytes(), m.Bytes(), context) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+const decryptionDomain = "securedrive/paillier/decryption/v1"
+
+// decryptionResidue calcule U = C * (1+N)^-m mod N², qui est une puissance
+// N-ième si et seulement si C chiffre m.
+func (pk *PublicKey) decryptionResidue(c *Ciphertext, m *big.Int) (*big.Int, error) {
+	gmInverse := new(big.Int).ModInverse(pk.gExp(m), pk.ciphertextModulus())
+	if gmInverse == nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return pk.mulMod(c.c, gmInverse), nil
+}
+
+// proveNthRoot prouve la connaissance de ρ tel que u = ρ^N mod N² : a = s^N,
+// e = H(domaine, N, u, a, contexte), z = s * ρ^e mod N.
+func (pk *PublicKey) proveNthRoot(random io.Reader, u, rho *big.Int, domain string, context ...[]byte) (*big.Int, *big.Int, error) {
+	s, err := pk.randomUnit(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	a := new(big.Int).Exp(s, pk.plaintextModulus(), pk.ciphertextModulus())
+
+	e := pk.nthRootChallenge(u, a, domain, context)
+	z := new(big.Int).Exp(rho, e, pk.N)
+	z.Mul(z, s)
+	z.Mod(z, pk.N)
+	return a, z, nil
+}
+
+// verifyNthRoot vérifie z^N ≡ a * u^e mod N².
+func (pk *PublicKey) verifyNthRoot(u, a, z *big.Int, domain string, context ...[]byte) bool {
+	if a == nil || z == nil {
+		return false
+	}
+	if pk.ValidateCiphertext(&Ciphertext{c: a}) != nil || !pk.isUnit(z) {
+		return false
+	}
+
+	e := pk.nthRootChallenge(u, a, domain, context)
+	left := new(big.Int).Exp(z, pk.plaintextModulus(), pk.ciphertextModulus())
+	right := pk.mulMod(a, new(big.Int).Exp(u, e, pk.ciphertextModulus()))
+	return left.Cmp(right) == 0
+}
+
+func (pk *PublicKey) nthRootChallenge(u, a *big.Int, domain string, context [][]byte) *big.Int {
+	parts := append([][]byte{pk.N.Bytes(), u.Bytes(), a.Bytes()}, context...)
+	return challenge(domain, parts...)
+}
+
+// challenge dérive le défi Fiat–Shamir e = SHA-256(domaine || parties), chaque
+// partie étant préfixée par sa longueur pour que l'encodage soit injectif.
+func challenge(domain string, parts ...[]byte) *big.Int {
+	hash := sha256.New()
+	writePart(hash, []byte(domain))
+	for _, part := range parts {
+		writePart(hash, part)
+	}
+	return new(big.Int).SetBytes(hash.Sum(nil))
+}
+
+func writePart(w io.Writer, part []byte) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(part)))
+	w.Write(length[:])
+	w.Write(part)
+}
@@ -0,0 +1,108 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestDecryptionProof(t *testing.T) {
+	sk := testKey(t)
+	c := testCiphertext(t, sk, 4242)
+	context := []byte("result_trip1")
+
+	m, proof, err := sk.ProveDecryption(rand.Reader, c, context)
+	if err != nil {
+		t.Fatalf("ProveDecryption: %v", err)
+	}
+	if m.Cmp(big.NewInt(4242)) != 0 {
+		t.Fatalf("ProveDecryption returned %s", m)
+	}
+	if err := sk.VerifyDecryption(c, m, proof, context); err != nil {
+		t.Fatalf("VerifyDecryption: %v", err)
+	}
+
+	// Un autre clair, un autre chiffré ou un autre contexte sont refusés
+	if err := sk.VerifyDecryption(c, big.NewInt(4243), proof, context); err != ErrInvalidProof {
+		t.Errorf("wrong plaintext: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyDecryption(testCiphertext(t, sk, 4242), m, proof, context); err != ErrInvalidProof {
+		t.Errorf("other ciphertext: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyDecryption(c, m, proof, []byte("result_trip2")); err != ErrInvalidProof {
+		t.Errorf("other context: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyDecryption(c, m, nil, context); err != ErrInvalidProof {
+		t.Errorf("nil proof: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyDecryption(c, sk.N, proof, context); err != ErrInvalidProof {
+		t.Errorf("plaintext out of Z_N: expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestDecryptionProofTampered(t *testing.T) {
+	sk := testKey(t)
+	c := testCiphertext(t, sk, 7)
+	context := []byte("result_trip1")
+
+	m, proof, err := sk.ProveDecryption(rand.Reader, c, context)
+	if err != nil {
+		t.Fatalf("ProveDecryption: %v", err)
+	}
+
+	// Engagement modifié : le défi recalculé ne correspond plus à la réponse
+	tampered := &DecryptionProof{A: sk.mulMod(proof.A, sk.gExp(one)), Z: proof.Z}
+	if err := sk.VerifyDecryption(c, m, tampered, context); err != ErrInvalidProof {
+		t.Errorf("tampered commitment: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Réponse modifiée
+	z := new(big.Int).Add(proof.Z, one)
+	tampered = &DecryptionProof{A: proof.A, Z: z.Mod(z, sk.N)}
+	if err := sk.VerifyDecryption(c, m, tampered, context); err != ErrInvalidProof {
+		t.Errorf("tampered response: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Réponse hors de Z*_N
+	tampered = &DecryptionProof{A: proof.A, Z: new(big.Int).Add(proof.Z, sk.N)}
+	if err := sk.VerifyDecryption(c, m, tampered, context); err != ErrInvalidProof {
+		t.Errorf("response out of Z*_N: expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestDecryptionProofJSON(t *testing.T) {
+	sk := testKey(t)
+	c := testCiphertext(t, sk, 4242)
+	context := []byte("result_trip1")
+	m, proof, err := sk.ProveDecryption(rand.Reader, c, context)
+	if err != nil {
+		t.Fatalf("ProveDecryption: %v", err)
+	}
+
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"a":"`+ProofTag+`:`) || !strings.Contains(string(data), `"z":"`+ProofTag+`:`) {
+		t.Fatalf("proof is not in the compact format: %s", data)
+	}
+
+	// Format compact et nombres décimaux des enregistrements antérieurs
+	legacy := `{"a":` + proof.A.String() + `,"z":` + proof.Z.String() + `}`
+	for _, input := range []string{string(data), legacy} {
+		var decoded DecryptionProof
+		if err := json.Unmarshal([]byte(input), &decoded); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if err := sk.VerifyDecryption(c, m, &decoded, context); err != nil {
+			t.Fatalf("VerifyDecryption of the decoded proof: %v", err)
+		}
+	}
+
+	var decoded DecryptionProof
+	if err := json.Unmarshal([]byte(`{"a":"`+CiphertextTag+`:AQ","z":1}`), &decoded); err == nil {
+		t.Error("Unmarshal accepted a value with another tag")
+	}
+}
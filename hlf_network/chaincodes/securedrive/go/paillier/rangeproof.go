@@ -0,0 +1,310 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+)
+
+const rangeDomain = "securedrive/paillier/range/v1"
+
+// challengeBits est la taille des défis Fiat–Shamir (sortie de SHA-256).
+const challengeBits = 256
+
+var challengeModulus = new(big.Int).Lsh(one, challengeBits)
+
+// ErrOutOfRange est retournée lorsque le clair à prouver n'appartient pas à [min, max].
+var ErrOutOfRange = errors.New("paillier: plaintext is out of range")
+
+// RangeProof prouve, sans révéler m, que le chiffré C = Enc(m; r) vérifie
+// min <= m <= max. Avec k = bitlen(max - min), la preuve décompose m - min et
+// max - m en k bits chiffrés : chaque bit est accompagné d'une preuve OR
+// « chiffre 0 ou 1 » et la recomposition est prouvée égale au chiffré initial.
+type RangeProof struct {
+	Lower *BoundProof `json:"lower"` // m - min ∈ [0, 2^k)
+	Upper *BoundProof `json:"upper"` // max - m ∈ [0, 2^k)
+}
+
+// BoundProof prouve qu'un chiffré dérivé de C chiffre une valeur de [0, 2^k).
+type BoundProof struct {
+	Bits []*BitProof `json:"bits"`
+	A    *big.Int    `json:"a"` // Preuve que C' / Π Bi^(2^i) chiffre 0
+	Z    *big.Int    `json:"z"`
+}
+
+// BitProof prouve que le chiffré C chiffre 0 ou 1 (preuve OR de
+// Cramer–Damgård–Schoenmakers sur deux racines N-ièmes).
+type BitProof struct {
+	C  *Ciphertext `json:"c"`
+	A0 *big.Int    `json:"a0"`
+	A1 *big.Int    `json:"a1"`
+	E0 *big.Int    `json:"e0"`
+	E1 *big.Int    `json:"e1"`
+	Z0 *big.Int    `json:"z0"`
+	Z1 *big.Int    `json:"z1"`
+}
+
+// ProveRange produit la preuve que c = Enc(m; r) avec min <= m <= max.
+func (pk *PublicKey) ProveRange(random io.Reader, c *Ciphertext, m, r, min, max *big.Int, context []byte) (*RangeProof, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+	if min.Cmp(max) > 0 || m.Cmp(min) < 0 || m.Cmp(max) > 0 {
+		return nil, ErrOutOfRange
+	}
+	if !pk.isUnit(r) {
+		return nil, ErrInvalidRandom
+	}
+	k := new(big.Int).Sub(max, min).BitLen()
+	rangeContext := pk.rangeContext(c, min, max, context)
+
+	// Borne inférieure : C * (1+N)^-min = Enc(m - min; r)
+	lowerCipher, err := pk.shiftedCiphertext(c, min, false)
+	if err != nil {
+		return nil, err
+	}
+	lower, err := pk.proveBound(random, lowerCipher, new(big.Int).Sub(m, min), r, k, rangeContext, "lower")
+	if err != nil {
+		return nil, err
+	}
+
+	// Borne supérieure : (1+N)^max * C^-1 = Enc(max - m; r^-1)
+	upperCipher, err := pk.shiftedCiphertext(c, max, true)
+	if err != nil {
+		return nil, err
+	}
+	rInverse := new(big.Int).ModInverse(r, pk.N)
+	upper, err := pk.proveBound(random, upperCipher, new(big.Int).Sub(max, m), rInverse, k, rangeContext, "upper")
+	if err != nil {
+		return nil, err
+	}
+
+	return &RangeProof{Lower: lower, Upper: upper}, nil
+}
+
+// VerifyRange vérifie que proof établit que c chiffre une valeur de [min, max].
+func (pk *PublicKey) VerifyRange(c *Ciphertext, min, max *big.Int, proof *RangeProof, context []byte) error {
+	if err := pk.ValidateCiphertext(c); err != nil {
+		return err
+	}
+	if min == nil || max == nil || min.Cmp(max) > 0 {
+		return ErrOutOfRange
+	}
+	if proof == nil || proof.Lower == nil || proof.Upper == nil {
+		return ErrInvalidProof
+	}
+	k := new(big.Int).Sub(max, min).BitLen()
+	rangeContext := pk.rangeContext(c, min, max, context)
+
+	lowerCipher, err := pk.shiftedCiphertext(c, min, false)
+	if err != nil {
+		return ErrInvalidProof
+	}
+	if !pk.verifyBound(lowerCipher, proof.Lower, k, rangeContext, "lower") {
+		return ErrInvalidProof
+	}
+
+	upperCipher, err := pk.shiftedCiphertext(c, max, true)
+	if err != nil {
+		return ErrInvalidProof
+	}
+	if !pk.verifyBound(upperCipher, proof.Upper, k, rangeContext, "upper") {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// shiftedCiphertext calcule C * (1+N)^-bound, ou (1+N)^bound * C^-1 si negate.
+func (pk *PublicKey) shiftedCiphertext(c *Ciphertext, bound *big.Int, negate bool) (*big.Int, error) {
+	if negate {
+		cInverse := new(big.Int).ModInverse(c.c, pk.ciphertextModulus())
+		if cInverse == nil {
+			return nil, ErrInvalidCiphertext
+		}
+		return pk.mulMod(pk.gExp(bound), cInverse), nil
+	}
+	return pk.decryptionResidue(c, bound)
+}
+
+// proveBound prouve que shifted = Enc(value; rho) avec value ∈ [0, 2^k).
+func (pk *PublicKey) proveBound(random io.Reader, shifted, value, rho *big.Int, k int, context []byte, label string) (*BoundProof, error) {
+	proof := &BoundProof{Bits: make([]*BitProof, k)}
+
+	// rhoQuotient est l'aléa de shifted / Π Bi^(2^i), qui chiffre 0
+	rhoQuotient := new(big.Int).Set(rho)
+	for i := 0; i < k; i++ {
+		bit := value.Bit(i)
+		ri, err := pk.randomUnit(random)
+		if err != nil {
+			return nil, err
+		}
+		bitCipher, err := pk.EncryptWithRandom(big.NewInt(int64(bit)), ri)
+		if err != nil {
+			return nil, err
+		}
+		bitProof, err := pk.proveBit(random, bitCipher, bit, ri, context, label, i)
+		if err != nil {
+			return nil, err
+		}
+		proof.Bits[i] = bitProof
+
+		weight := new(big.Int).Lsh(one, uint(i))
+		riInverse := new(big.Int).ModInverse(new(big.Int).Exp(ri, weight, pk.N), pk.N)
+		rhoQuotient.Mul(rhoQuotient, riInverse)
+		rhoQuotient.Mod(rhoQuotient, pk.N)
+	}
+
+	quotient, err := pk.bitQuotient(shifted, proof.Bits)
+	if err != nil {
+		return nil, err
+	}
+	a, z, err := pk.proveNthRoot(random, quotient, rhoQuotient, rangeDomain, context, []byte(label))
+	if err != nil {
+		return nil, err
+	}
+	proof.A = a
+	proof.Z = z
+	return proof, nil
+}
+
+func (pk *PublicKey) verifyBound(shifted *big.Int, proof *BoundProof, k int, context []byte, label string) bool {
+	if len(proof.Bits) != k {
+		return false
+	}
+	for i, bitProof := range proof.Bits {
+		if !pk.verifyBit(bitProof, context, label, i) {
+			return false
+		}
+	}
+	quotient, err := pk.bitQuotient(shifted, proof.Bits)
+	if err != nil {
+		return false
+	}
+	return pk.verifyNthRoot(quotient, proof.A, proof.Z, rangeDomain, context, []byte(label))
+}
+
+// bitQuotient calcule shifted / Π Bi^(2^i) mod N².
+func (pk *PublicKey) bitQuotient(shifted *big.Int, bits []*BitProof) (*big.Int, error) {
+	recomposed := big.NewInt(1)
+	for i, bitProof := range bits {
+		weight := new(big.Int).Lsh(one, uint(i))
+		recomposed = pk.mulMod(recomposed, new(big.Int).Exp(bitProof.C.c, weight, pk.ciphertextModulus()))
+	}
+	inverse := new(big.Int).ModInverse(recomposed, pk.ciphertextModulus())
+	if inverse == nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return pk.mulMod(shifted, inverse), nil
+}
+
+// proveBit produit la preuve OR que c = Enc(bit; r) avec bit ∈ {0, 1} : la
+// branche vraie est prouvée normalement, l'autre est simulée.
+func (pk *PublicKey) proveBit(random io.Reader, c *Ciphertext, bit uint, r *big.Int, context []byte, label string, index int) (*BitProof, error) {
+	u := pk.bitResidues(c)
+	if u[1] == nil {
+		return nil, ErrInvalidCiphertext
+	}
+	a := make([]*big.Int, 2)
+	e := make([]*big.Int, 2)
+	z := make([]*big.Int, 2)
+
+	// Branche simulée : e et z aléatoires, a = z^N * u^-e
+	fake := 1 - bit
+	var err error
+	e[fake], err = rand.Int(random, challengeModulus)
+	if err != nil {
+		return nil, err
+	}
+	z[fake], err = pk.randomUnit(random)
+	if err != nil {
+		return nil, err
+	}
+	uInverse := new(big.Int).ModInverse(u[fake], pk.ciphertextModulus())
+	if uInverse == nil {
+		return nil, ErrInvalidCiphertext
+	}
+	a[fake] = pk.mulMod(new(big.Int).Exp(z[fake], pk.plaintextModulus(), pk.ciphertextModulus()), new(big.Int).Exp(uInverse, e[fake], pk.ciphertextModulus()))
+
+	// Branche réelle : engagement s^N
+	s, err := pk.randomUnit(random)
+	if err != nil {
+		return nil, err
+	}
+	a[bit] = new(big.Int).Exp(s, pk.plaintextModulus(), pk.ciphertextModulus())
+
+	challengeValue := pk.bitChallenge(c, a[0], a[1], context, label, index)
+	e[bit] = new(big.Int).Sub(challengeValue, e[fake])
+	e[bit].Mod(e[bit], challengeModulus)
+	z[bit] = new(big.Int).Exp(r, e[bit], pk.N)
+	z[bit].Mul(z[bit], s)
+	z[bit].Mod(z[bit], pk.N)
+
+	return &BitProof{C: c, A0: a[0], A1: a[1], E0: e[0], E1: e[1], Z0: z[0], Z1: z[1]}, nil
+}
+
+func (pk *PublicKey) verifyBit(proof *BitProof, context []byte, label string, index int) bool {
+	if proof == nil || proof.E0 == nil || proof.E1 == nil || proof.A0 == nil || proof.A1 == nil {
+		return false
+	}
+	if pk.ValidateCiphertext(proof.C) != nil {
+		return false
+	}
+	u := pk.bitResidues(proof.C)
+	if u[1] == nil {
+		return false
+	}
+
+	// Les défis doivent rester dans [0, 2^256) : un défi plus grand, multiple
+	// de N, serait absorbé par la puissance N-ième et permettrait de simuler
+	// les deux branches
+	if !isChallenge(proof.E0) || !isChallenge(proof.E1) {
+		return false
+	}
+	challengeValue := pk.bitChallenge(proof.C, proof.A0, proof.A1, context, label, index)
+	sum := new(big.Int).Add(proof.E0, proof.E1)
+	if sum.Mod(sum, challengeModulus).Cmp(challengeValue) != 0 {
+		return false
+	}
+
+	branches := []struct{ a, e, z *big.Int }{{proof.A0, proof.E0, proof.Z0}, {proof.A1, proof.E1, proof.Z1}}
+	for i, branch := range branches {
+		if pk.ValidateCiphertext(&Ciphertext{c: branch.a}) != nil || !pk.isUnit(branch.z) {
+			return false
+		}
+		left := new(big.Int).Exp(branch.z, pk.plaintextModulus(), pk.ciphertextModulus())
+		right := pk.mulMod(branch.a, new(big.Int).Exp(u[i], branch.e, pk.ciphertextModulus()))
+		if left.Cmp(right) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isChallenge indique si e appartient à l'espace des défis [0, 2^256)
+func isChallenge(e *big.Int) bool {
+	return e.Sign() >= 0 && e.Cmp(challengeModulus) < 0
+}
+
+// bitResidues retourne C et C * (1+N)^-1, dont l'un est une puissance N-ième
+// si C chiffre 0 ou 1.
+func (pk *PublicKey) bitResidues(c *Ciphertext) []*big.Int {
+	u1, err := pk.decryptionResidue(c, one)
+	if err != nil {
+		return []*big.Int{c.c, nil}
+	}
+	return []*big.Int{c.c, u1}
+}
+
+func (pk *PublicKey) bitChallenge(c *Ciphertext, a0, a1 *big.Int, context []byte, label string, index int) *big.Int {
+	return challenge(rangeDomain, pk.N.Bytes(), context, []byte(label), big.NewInt(int64(index)).Bytes(),
+		c.c.Bytes(), a0.Bytes(), a1.Bytes())
+}
+
+// rangeContext lie toutes les sous-preuves à la clé, au chiffré, à l'intervalle
+// et au contexte de l'appelant.
+func (pk *PublicKey) rangeContext(c *Ciphertext, min, max *big.Int, context []byte) []byte {
+	minBytes, _ := min.GobEncode()
+	maxBytes, _ := max.GobEncode()
+	return challenge(rangeDomain, pk.N.Bytes(), c.c.Bytes(), minBytes, maxBytes, context).Bytes()
+}
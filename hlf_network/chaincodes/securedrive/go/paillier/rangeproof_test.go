@@ -0,0 +1,246 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// encryptWithRandom chiffre m et retourne l'aléa utilisé, nécessaire aux preuves.
+func encryptWithRandom(tb testing.TB, pk *PublicKey, m *big.Int) (*Ciphertext, *big.Int) {
+	r, err := pk.randomUnit(rand.Reader)
+	if err != nil {
+		tb.Fatalf("randomUnit: %v", err)
+	}
+	c, err := pk.EncryptWithRandom(m, r)
+	if err != nil {
+		tb.Fatalf("EncryptWithRandom: %v", err)
+	}
+	return c, r
+}
+
+func TestRangeProof(t *testing.T) {
+	sk := testKey(t)
+	min, max := big.NewInt(10), big.NewInt(100)
+	context := []byte("trip_trip1/speeding")
+
+	for _, value := range []int64{10, 57, 100} {
+		m := big.NewInt(value)
+		c, r := encryptWithRandom(t, &sk.PublicKey, m)
+
+		proof, err := sk.ProveRange(rand.Reader, c, m, r, min, max, context)
+		if err != nil {
+			t.Fatalf("ProveRange(%d): %v", value, err)
+		}
+		if err := sk.VerifyRange(c, min, max, proof, context); err != nil {
+			t.Fatalf("VerifyRange(%d): %v", value, err)
+		}
+	}
+}
+
+func TestRangeProofRejectsOutOfRange(t *testing.T) {
+	sk := testKey(t)
+	min, max := big.NewInt(10), big.NewInt(100)
+	context := []byte("trip_trip1/speeding")
+
+	// Le prouveur honnête refuse une valeur hors de l'intervalle
+	for _, value := range []int64{9, 101} {
+		m := big.NewInt(value)
+		c, r := encryptWithRandom(t, &sk.PublicKey, m)
+		if _, err := sk.ProveRange(rand.Reader, c, m, r, min, max, context); err != ErrOutOfRange {
+			t.Errorf("ProveRange(%d): expected ErrOutOfRange, got %v", value, err)
+		}
+	}
+
+	// Une preuve valide pour [0, 1000] ne vaut pas pour [0, 100] si m = 500
+	m := big.NewInt(500)
+	c, r := encryptWithRandom(t, &sk.PublicKey, m)
+	proof, err := sk.ProveRange(rand.Reader, c, m, r, big.NewInt(0), big.NewInt(1000), context)
+	if err != nil {
+		t.Fatalf("ProveRange: %v", err)
+	}
+	if err := sk.VerifyRange(c, big.NewInt(0), big.NewInt(1000), proof, context); err != nil {
+		t.Fatalf("VerifyRange: %v", err)
+	}
+	if err := sk.VerifyRange(c, big.NewInt(0), big.NewInt(100), proof, context); err != ErrInvalidProof {
+		t.Errorf("narrower range: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyRange(c, big.NewInt(100), big.NewInt(0), proof, context); err != ErrOutOfRange {
+		t.Errorf("empty range: expected ErrOutOfRange, got %v", err)
+	}
+}
+
+func TestRangeProofTampered(t *testing.T) {
+	sk := testKey(t)
+	min, max := big.NewInt(0), big.NewInt(15)
+	context := []byte("trip_trip1/speeding")
+	m := big.NewInt(6)
+	c, r := encryptWithRandom(t, &sk.PublicKey, m)
+
+	prove := func() *RangeProof {
+		proof, err := sk.ProveRange(rand.Reader, c, m, r, min, max, context)
+		if err != nil {
+			t.Fatalf("ProveRange: %v", err)
+		}
+		return proof
+	}
+
+	// Contexte ou chiffré différents
+	proof := prove()
+	if err := sk.VerifyRange(c, min, max, proof, []byte("trip_trip2/speeding")); err != ErrInvalidProof {
+		t.Errorf("other context: expected ErrInvalidProof, got %v", err)
+	}
+	other, _ := encryptWithRandom(t, &sk.PublicKey, m)
+	if err := sk.VerifyRange(other, min, max, proof, context); err != ErrInvalidProof {
+		t.Errorf("other ciphertext: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Défis d'une preuve de bit modifiés, leur somme restant inchangée
+	proof = prove()
+	bit := proof.Lower.Bits[0]
+	bit.E0 = new(big.Int).Add(bit.E0, one)
+	bit.E1 = new(big.Int).Sub(bit.E1, one)
+	if err := sk.VerifyRange(c, min, max, proof, context); err != ErrInvalidProof {
+		t.Errorf("tampered challenge: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Réponse d'une preuve de bit modifiée
+	proof = prove()
+	bit = proof.Upper.Bits[1]
+	bit.Z1 = new(big.Int).Mod(new(big.Int).Add(bit.Z1, one), sk.N)
+	if err := sk.VerifyRange(c, min, max, proof, context); err != ErrInvalidProof {
+		t.Errorf("tampered bit response: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Réponse de la preuve de recomposition modifiée
+	proof = prove()
+	proof.Lower.Z = new(big.Int).Mod(new(big.Int).Add(proof.Lower.Z, one), sk.N)
+	if err := sk.VerifyRange(c, min, max, proof, context); err != ErrInvalidProof {
+		t.Errorf("tampered response: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Bit retiré : la décomposition n'a plus la longueur attendue
+	proof = prove()
+	proof.Upper.Bits = proof.Upper.Bits[1:]
+	if err := sk.VerifyRange(c, min, max, proof, context); err != ErrInvalidProof {
+		t.Errorf("missing bit: expected ErrInvalidProof, got %v", err)
+	}
+}
+
+// forgeBit simule une preuve de bit pour un chiffré quelconque avec un défi
+// e0 = k·N ≡ H mod 2^256 : le terme u0^(k·N) est absorbé par la puissance
+// N-ième de z0 = s0 * u0^k. Seul le contrôle de la taille des défis l'écarte
+func forgeBit(tb testing.TB, pk *PublicKey, c *Ciphertext, context []byte, label string, index int) *BitProof {
+	s0, err := pk.randomUnit(rand.Reader)
+	if err != nil {
+		tb.Fatalf("randomUnit: %v", err)
+	}
+	s1, err := pk.randomUnit(rand.Reader)
+	if err != nil {
+		tb.Fatalf("randomUnit: %v", err)
+	}
+	a0 := new(big.Int).Exp(s0, pk.N, pk.NSquare)
+	a1 := new(big.Int).Exp(s1, pk.N, pk.NSquare)
+
+	h := pk.bitChallenge(c, a0, a1, context, label, index)
+	k := new(big.Int).ModInverse(pk.N, challengeModulus)
+	k.Mul(k, h).Mod(k, challengeModulus)
+	z0 := new(big.Int).Exp(c.c, k, pk.N)
+	z0.Mul(z0, s0).Mod(z0, pk.N)
+	return &BitProof{C: c, A0: a0, A1: a1, E0: new(big.Int).Mul(k, pk.N), E1: big.NewInt(0), Z0: z0, Z1: s1}
+}
+
+// forgeBound construit une BoundProof pour shifted : les bits de poids fort
+// chiffrent honnêtement 0 et le bit de poids faible, forgé, absorbe shifted
+// pour que le quotient de recomposition vaille 1
+func forgeBound(tb testing.TB, pk *PublicKey, shifted *big.Int, k int, context []byte, label string) *BoundProof {
+	proof := &BoundProof{Bits: make([]*BitProof, k)}
+	low := new(big.Int).Set(shifted)
+	for i := 1; i < k; i++ {
+		c, r := encryptWithRandom(tb, pk, big.NewInt(0))
+		bit, err := pk.proveBit(rand.Reader, c, 0, r, context, label, i)
+		if err != nil {
+			tb.Fatalf("proveBit: %v", err)
+		}
+		proof.Bits[i] = bit
+		weighted := new(big.Int).Exp(c.c, new(big.Int).Lsh(one, uint(i)), pk.NSquare)
+		low = pk.mulMod(low, new(big.Int).ModInverse(weighted, pk.NSquare))
+	}
+	proof.Bits[0] = forgeBit(tb, pk, &Ciphertext{c: low}, context, label, 0)
+
+	a, z, err := pk.proveNthRoot(rand.Reader, one, one, rangeDomain, context, []byte(label))
+	if err != nil {
+		tb.Fatalf("proveNthRoot: %v", err)
+	}
+	proof.A, proof.Z = a, z
+	return proof
+}
+
+func TestRangeProofRejectsForgedBits(t *testing.T) {
+	sk := testKey(t)
+	pk := &sk.PublicKey
+	min, max := big.NewInt(0), big.NewInt(500)
+	context := []byte("trip_trip1/speeding")
+	k := new(big.Int).Sub(max, min).BitLen()
+
+	// Enc(-1000000) prétendu dans [0, 500]
+	m, err := pk.EncodeSigned(big.NewInt(-1000000))
+	if err != nil {
+		t.Fatalf("EncodeSigned: %v", err)
+	}
+	c, _ := encryptWithRandom(t, pk, m)
+	rangeContext := pk.rangeContext(c, min, max, context)
+	lowerCipher, err := pk.shiftedCiphertext(c, min, false)
+	if err != nil {
+		t.Fatalf("shiftedCiphertext: %v", err)
+	}
+	upperCipher, err := pk.shiftedCiphertext(c, max, true)
+	if err != nil {
+		t.Fatalf("shiftedCiphertext: %v", err)
+	}
+	proof := &RangeProof{
+		Lower: forgeBound(t, pk, lowerCipher, k, rangeContext, "lower"),
+		Upper: forgeBound(t, pk, upperCipher, k, rangeContext, "upper"),
+	}
+
+	// Hors contrôle de taille, le bit forgé satisfait les équations de vérification
+	forged := proof.Lower.Bits[0]
+	u := pk.bitResidues(forged.C)
+	left := new(big.Int).Exp(forged.Z0, pk.N, pk.NSquare)
+	if left.Cmp(pk.mulMod(forged.A0, new(big.Int).Exp(u[0], forged.E0, pk.NSquare))) != 0 {
+		t.Fatal("forged bit does not satisfy the verification equation")
+	}
+
+	if err := pk.VerifyRange(c, min, max, proof, context); err != ErrInvalidProof {
+		t.Fatalf("forged range proof: expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestBitProofRejectsOversizedChallenges(t *testing.T) {
+	sk := testKey(t)
+	pk := &sk.PublicKey
+	context := []byte("trip_trip1/speeding")
+	c, r := encryptWithRandom(t, pk, big.NewInt(1))
+
+	tests := []struct {
+		name   string
+		tamper func(proof *BitProof)
+	}{
+		{"E0 shifted by 2^256", func(proof *BitProof) { proof.E0 = new(big.Int).Add(proof.E0, challengeModulus) }},
+		{"E1 shifted by 2^256", func(proof *BitProof) { proof.E1 = new(big.Int).Add(proof.E1, challengeModulus) }},
+		{"negative E0", func(proof *BitProof) { proof.E0 = new(big.Int).Sub(proof.E0, challengeModulus) }},
+	}
+	for _, tt := range tests {
+		proof, err := pk.proveBit(rand.Reader, c, 1, r, context, "lower", 0)
+		if err != nil {
+			t.Fatalf("proveBit: %v", err)
+		}
+		if !pk.verifyBit(proof, context, "lower", 0) {
+			t.Fatal("honest bit proof failed to verify")
+		}
+		tt.tamper(proof)
+		if pk.verifyBit(proof, context, "lower", 0) {
+			t.Errorf("%s: tampered bit proof verified", tt.name)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"simple/pedersen"
+)
+
+const scalarDomain = "securedrive/paillier/committed-scalar/v1"
+
+// ScalarBits borne la taille des scalaires engagés : |w| < 2^ScalarBits.
+const ScalarBits = 64
+
+// CommittedScalarProof prouve qu'un chiffré c′ = c^w * r^N mod N² est le
+// produit de c par le scalaire w engagé dans W = G^w * H^ρ mod P, sans révéler
+// w, ρ ni r. Elle permet à un assureur d'appliquer ses poids de tarification
+// aux métriques chiffrées d'un conducteur sans publier ses poids, et au
+// conducteur de ne pas révéler ses métriques.
+type CommittedScalarProof struct {
+	A1 *big.Int `json:"a1"` // a1 = G^x * H^σ mod P
+	A2 *big.Int `json:"a2"` // a2 = c^x * s^N mod N²
+	Z  *big.Int `json:"z"`  // z = x + e*w (dans Z)
+	T  *big.Int `json:"t"`  // t = σ + e*ρ mod Q
+	U  *big.Int `json:"u"`  // u = s * r^e mod N
+}
+
+// MulConstRandomized retourne c^k * r^N mod N², produit de c par k
+// re-randomisé pour que k ne puisse pas être retrouvé en comparant le résultat
+// à c^k, et l'aléa r utilisé.
+func (pk *PublicKey) MulConstRandomized(random io.Reader, c *Ciphertext, k *big.Int) (*Ciphertext, *big.Int, error) {
+	product, err := pk.MulConst(c, k)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := pk.randomUnit(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	product, err = pk.RerandomizeWithRandom(product, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return product, r, nil
+}
+
+// CommittedScalarBound retourne la borne sur |w| garantie par une preuve
+// acceptée : un prouveur malhonnête ne peut pas utiliser un scalaire plus grand.
+func CommittedScalarBound() *big.Int {
+	return new(big.Int).Lsh(one, ScalarBits+2*challengeBits+2)
+}
+
+// ProveCommittedScalar prouve que product = c^w * r^N mod N², w étant la
+// valeur engagée dans commitment avec l'aléa rho.
+func (pk *PublicKey) ProveCommittedScalar(random io.Reader, params *pedersen.Params, c, product *Ciphertext, commitment *pedersen.Commitment, w, rho, r *big.Int, context []byte) (*CommittedScalarProof, error) {
+	if err := pk.ValidateCiphertext(c); err != nil {
+		return nil, err
+	}
+	if err := pk.ValidateCiphertext(product); err != nil {
+		return nil, err
+	}
+	if !pk.isUnit(r) {
+		return nil, ErrInvalidRandom
+	}
+	if new(big.Int).Abs(w).BitLen() > ScalarBits {
+		return nil, ErrPlaintextOverflow
+	}
+	if err := params.Open(commitment, w, rho); err != nil {
+		return nil, err
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	// x masque e*w : il dépasse de 2 * challengeBits bits la borne des scalaires
+	x, err := rand.Int(random, new(big.Int).Lsh(one, ScalarBits+2*challengeBits))
+	if err != nil {
+		return nil, err
+	}
+	sigma, err := params.RandomExponent(random)
+	if err != nil {
+		return nil, err
+	}
+	s, err := pk.randomUnit(random)
+	if err != nil {
+		return nil, err
+	}
+
+	a1 := params.CommitWithRandom(x, sigma).Int()
+	a2 := pk.mulMod(new(big.Int).Exp(c.c, x, pk.ciphertextModulus()), new(big.Int).Exp(s, pk.plaintextModulus(), pk.ciphertextModulus()))
+
+	e := pk.scalarChallenge(params, c, product, commitment, a1, a2, context)
+
+	z := new(big.Int).Mul(e, w)
+	z.Add(z, x)
+	t := new(big.Int).Mul(e, rho)
+	t.Add(t, sigma).Mod(t, params.Q)
+	u := new(big.Int).Exp(r, e, pk.N)
+	u.Mul(u, s).Mod(u, pk.N)
+
+	return &CommittedScalarProof{A1: a1, A2: a2, Z: z, T: t, U: u}, nil
+}
+
+// VerifyCommittedScalar vérifie que proof établit que product est le produit
+// de c par le scalaire engagé dans commitment, pour le contexte donné.
+func (pk *PublicKey) VerifyCommittedScalar(params *pedersen.Params, c, product *Ciphertext, commitment *pedersen.Commitment, proof *CommittedScalarProof, context []byte) error {
+	if err := pk.ValidateCiphertext(c); err != nil {
+		return err
+	}
+	if err := pk.ValidateCiphertext(product); err != nil {
+		return err
+	}
+	if err := params.ValidateCommitment(commitment); err != nil {
+		return err
+	}
+	if proof == nil || proof.A1 == nil || proof.A2 == nil || proof.Z == nil || proof.T == nil {
+		return ErrInvalidProof
+	}
+	if pk.ValidateCiphertext(&Ciphertext{c: proof.A2}) != nil || !pk.isUnit(proof.U) {
+		return ErrInvalidProof
+	}
+	// Une réponse trop grande permettrait d'utiliser w + kQ au lieu de w
+	if new(big.Int).Abs(proof.Z).BitLen() > ScalarBits+2*challengeBits+1 {
+		return ErrInvalidProof
+	}
+	if proof.T.Sign() < 0 || proof.T.Cmp(params.Q) >= 0 {
+		return ErrInvalidProof
+	}
+
+	e := pk.scalarChallenge(params, c, product, commitment, proof.A1, proof.A2, context)
+
+	// G^z * H^t ≡ a1 * W^e mod P
+	if !params.VerifyResponse(commitment, proof.A1, e, proof.Z, proof.T) {
+		return ErrInvalidProof
+	}
+
+	// c^z * u^N ≡ a2 * c′^e mod N²
+	cz := new(big.Int).Exp(c.c, proof.Z, pk.ciphertextModulus())
+	if cz == nil {
+		return ErrInvalidProof
+	}
+	left := pk.mulMod(cz, new(big.Int).Exp(proof.U, pk.plaintextModulus(), pk.ciphertextModulus()))
+	right := pk.mulMod(proof.A2, new(big.Int).Exp(product.c, e, pk.ciphertextModulus()))
+	if left.Cmp(right) != 0 {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+func (pk *PublicKey) scalarChallenge(params *pedersen.Params, c, product *Ciphertext, commitment *pedersen.Commitment, a1, a2 *big.Int, context []byte) *big.Int {
+	return challenge(scalarDomain,
+		pk.N.Bytes(),
+		params.P.Bytes(),
+		params.G.Bytes(),
+		params.H.Bytes(),
+		commitment.Int().Bytes(),
+		c.c.Bytes(),
+		product.c.Bytes(),
+		a1.Bytes(),
+		a2.Bytes(),
+		context,
+	)
+}
@@ -0,0 +1,135 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"simple/pedersen"
+)
+
+// scalarFixture regroupe un chiffré, son produit par un poids engagé et
+// l'engagement, tels qu'un assureur les soumet.
+type scalarFixture struct {
+	c, product *Ciphertext
+	commitment *pedersen.Commitment
+	w, rho, r  *big.Int
+}
+
+func newScalarFixture(tb testing.TB, sk *PrivateKey, m, w int64) *scalarFixture {
+	params := pedersen.DefaultParams()
+	c := testCiphertext(tb, sk, m)
+
+	commitment, rho, err := params.Commit(rand.Reader, big.NewInt(w))
+	if err != nil {
+		tb.Fatalf("Commit: %v", err)
+	}
+	product, r, err := sk.MulConstRandomized(rand.Reader, c, big.NewInt(w))
+	if err != nil {
+		tb.Fatalf("MulConstRandomized: %v", err)
+	}
+	return &scalarFixture{c: c, product: product, commitment: commitment, w: big.NewInt(w), rho: rho, r: r}
+}
+
+func (f *scalarFixture) prove(tb testing.TB, sk *PrivateKey, context []byte) *CommittedScalarProof {
+	proof, err := sk.ProveCommittedScalar(rand.Reader, pedersen.DefaultParams(), f.c, f.product, f.commitment, f.w, f.rho, f.r, context)
+	if err != nil {
+		tb.Fatalf("ProveCommittedScalar: %v", err)
+	}
+	return proof
+}
+
+func TestCommittedScalarProof(t *testing.T) {
+	sk := testKey(t)
+	params := pedersen.DefaultParams()
+	context := []byte("trip_trip1/speeding/weights1")
+
+	for _, w := range []int64{15, -3, 0} {
+		f := newScalarFixture(t, sk, 7, w)
+		proof := f.prove(t, sk, context)
+		if err := sk.VerifyCommittedScalar(params, f.c, f.product, f.commitment, proof, context); err != nil {
+			t.Fatalf("VerifyCommittedScalar(w = %d): %v", w, err)
+		}
+		if decoded := sk.DecodeSigned(mustDecrypt(t, sk, f.product)); decoded.Cmp(big.NewInt(7*w)) != 0 {
+			t.Fatalf("product decrypts to %s, expected %d", decoded, 7*w)
+		}
+	}
+}
+
+func TestCommittedScalarProofRejects(t *testing.T) {
+	sk := testKey(t)
+	params := pedersen.DefaultParams()
+	context := []byte("trip_trip1/speeding/weights1")
+	f := newScalarFixture(t, sk, 7, 15)
+	proof := f.prove(t, sk, context)
+
+	// Produit calculé avec un autre poids que le poids engagé
+	other := newScalarFixture(t, sk, 7, 16)
+	if err := sk.VerifyCommittedScalar(params, f.c, other.product, f.commitment, proof, context); err != ErrInvalidProof {
+		t.Errorf("other product: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyCommittedScalar(params, f.c, f.product, other.commitment, proof, context); err != ErrInvalidProof {
+		t.Errorf("other commitment: expected ErrInvalidProof, got %v", err)
+	}
+	if err := sk.VerifyCommittedScalar(params, f.c, f.product, f.commitment, proof, []byte("trip_trip2/speeding/weights1")); err != ErrInvalidProof {
+		t.Errorf("other context: expected ErrInvalidProof, got %v", err)
+	}
+
+	tampered := *proof
+	tampered.A1 = params.CommitWithRandom(big.NewInt(1), big.NewInt(1)).Int()
+	if err := sk.VerifyCommittedScalar(params, f.c, f.product, f.commitment, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("tampered commitment a1: expected ErrInvalidProof, got %v", err)
+	}
+
+	tampered = *proof
+	tampered.Z = new(big.Int).Add(proof.Z, one)
+	if err := sk.VerifyCommittedScalar(params, f.c, f.product, f.commitment, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("tampered response z: expected ErrInvalidProof, got %v", err)
+	}
+
+	tampered = *proof
+	tampered.U = new(big.Int).Mod(new(big.Int).Add(proof.U, one), sk.N)
+	if err := sk.VerifyCommittedScalar(params, f.c, f.product, f.commitment, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("tampered response u: expected ErrInvalidProof, got %v", err)
+	}
+
+	// Une réponse z hors borne permettrait d'utiliser w + kQ
+	tampered = *proof
+	tampered.Z = new(big.Int).Add(proof.Z, new(big.Int).Mul(params.Q, big.NewInt(1<<20)))
+	if err := sk.VerifyCommittedScalar(params, f.c, f.product, f.commitment, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("oversized response: expected ErrInvalidProof, got %v", err)
+	}
+
+	tampered = *proof
+	tampered.T = new(big.Int).Add(proof.T, params.Q)
+	if err := sk.VerifyCommittedScalar(params, f.c, f.product, f.commitment, &tampered, context); err != ErrInvalidProof {
+		t.Errorf("unreduced response t: expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestCommittedScalarProofRejectsOutOfRange(t *testing.T) {
+	sk := testKey(t)
+	params := pedersen.DefaultParams()
+	c := testCiphertext(t, sk, 7)
+
+	// Le prouveur refuse un scalaire de plus de ScalarBits bits
+	w := new(big.Int).Lsh(one, ScalarBits)
+	commitment, rho, err := params.Commit(rand.Reader, w)
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	product, r, err := sk.MulConstRandomized(rand.Reader, c, w)
+	if err != nil {
+		t.Fatalf("MulConstRandomized: %v", err)
+	}
+	_, err = sk.ProveCommittedScalar(rand.Reader, params, c, product, commitment, w, rho, r, nil)
+	if err != ErrPlaintextOverflow {
+		t.Errorf("oversized scalar: expected ErrPlaintextOverflow, got %v", err)
+	}
+
+	// Ainsi qu'une ouverture qui ne correspond pas à l'engagement
+	_, err = sk.ProveCommittedScalar(rand.Reader, params, c, product, commitment, big.NewInt(3), rho, r, nil)
+	if err != pedersen.ErrInvalidOpening {
+		t.Errorf("wrong opening: expected ErrInvalidOpening, got %v", err)
+	}
+}
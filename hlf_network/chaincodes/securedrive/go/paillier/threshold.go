@@ -0,0 +1,420 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"sort"
+
+	"simple/compact"
+)
+
+const shareDomain = "securedrive/paillier/threshold-share/v1"
+
+var (
+	// ErrInvalidThreshold est retournée lorsque les paramètres (t, l) du partage sont incohérents.
+	ErrInvalidThreshold = errors.New("paillier: threshold must satisfy 1 <= threshold <= parties")
+	// ErrInvalidShare est retournée lorsqu'une part de déchiffrement ou sa preuve est invalide.
+	ErrInvalidShare = errors.New("paillier: invalid decryption share")
+	// ErrNotEnoughShares est retournée lorsque moins de Threshold parts distinctes sont fournies.
+	ErrNotEnoughShares = errors.New("paillier: not enough decryption shares")
+)
+
+// ThresholdPublicKey est la clé publique d'un Paillier à seuil (Shoup,
+// Damgård–Jurik avec s = 1) : N = PQ avec P = 2P'+1 et Q = 2Q'+1 premiers sûrs,
+// et la clé de déchiffrement d est partagée entre Parties détenteurs dont
+// Threshold suffisent à déchiffrer. Aucun détenteur ne connaît Lambda, mais
+// le distributeur qui a généré la clé (GenerateThresholdKey) a connu la
+// factorisation de N : la garantie ne tient que s'il l'a effectivement oubliée.
+type ThresholdPublicKey struct {
+	PublicKey
+	Threshold        int
+	Parties          int
+	V                *big.Int   // Générateur des clés de vérification (carré de Z*_{N²})
+	VerificationKeys []*big.Int // V_i = V^(Δ s_i) mod N², indexées de 1 à Parties
+}
+
+// KeyShare est la part s_i = f(i) de la clé de déchiffrement du détenteur i.
+type KeyShare struct {
+	Index int
+	Share *big.Int
+}
+
+// DecryptionShare est la part de déchiffrement C_i = C^(2Δ s_i) mod N² du
+// détenteur Index, accompagnée de la preuve qu'elle est calculée avec s_i.
+type DecryptionShare struct {
+	Index int         `json:"index"`
+	Share *big.Int    `json:"share"`
+	Proof *ShareProof `json:"proof"`
+}
+
+// ShareProof est une preuve d'égalité de logarithmes discrets (Chaum–Pedersen
+// dans un groupe d'ordre inconnu) : log_{C^4}(C_i²) = log_V(V_i) = Δ s_i.
+type ShareProof struct {
+	A *big.Int `json:"a"` // a = (C^4)^w mod N²
+	B *big.Int `json:"b"` // b = V^w mod N²
+	Z *big.Int `json:"z"` // z = w + e Δ s_i (dans Z)
+}
+
+// decryptionShareJSON est la forme sérialisée d'une DecryptionShare.
+type decryptionShareJSON struct {
+	Index int             `json:"index"`
+	Share json.RawMessage `json:"share"`
+	Proof *ShareProof     `json:"proof"`
+}
+
+// MarshalJSON encode la part au format compact ProofTag.
+func (s DecryptionShare) MarshalJSON() ([]byte, error) {
+	share, err := compact.MarshalJSON(ProofTag, s.Share)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(decryptionShareJSON{Index: s.Index, Share: share, Proof: s.Proof})
+}
+
+// UnmarshalJSON décode une part écrite au format compact ou, pour les
+// enregistrements antérieurs, en nombres JSON décimaux.
+func (s *DecryptionShare) UnmarshalJSON(data []byte) error {
+	var encoded decryptionShareJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	share, err := compact.UnmarshalJSON(ProofTag, encoded.Share)
+	if err != nil {
+		return errors.New("paillier: failed to unmarshal decryption share: " + err.Error())
+	}
+	s.Index, s.Share, s.Proof = encoded.Index, share, encoded.Proof
+	return nil
+}
+
+// shareProofJSON est la forme sérialisée d'une ShareProof.
+type shareProofJSON struct {
+	A json.RawMessage `json:"a"`
+	B json.RawMessage `json:"b"`
+	Z json.RawMessage `json:"z"`
+}
+
+// MarshalJSON encode les éléments de la preuve au format compact ProofTag.
+func (p ShareProof) MarshalJSON() ([]byte, error) {
+	fields, err := compact.MarshalFields(ProofTag, p.A, p.B, p.Z)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(shareProofJSON{A: fields[0], B: fields[1], Z: fields[2]})
+}
+
+// UnmarshalJSON décode une preuve écrite au format compact ou, pour les
+// enregistrements antérieurs, en nombres JSON décimaux.
+func (p *ShareProof) UnmarshalJSON(data []byte) error {
+	var encoded shareProofJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	values, err := compact.UnmarshalFields(ProofTag, encoded.A, encoded.B, encoded.Z)
+	if err != nil {
+		return errors.New("paillier: failed to unmarshal share proof: " + err.Error())
+	}
+	p.A, p.B, p.Z = values[0], values[1], values[2]
+	return nil
+}
+
+// GenerateThresholdKey génère une clé à seuil de bits bits et ses Parties
+// parts, dont Threshold suffisent à déchiffrer. Le générateur joue le rôle
+// de distributeur de confiance : il connaît P, Q et d, et pourrait donc
+// déchiffrer seul tant qu'il les conserve. Ils ne sont pas retournés, mais
+// ils ont existé dans la mémoire du processus et les parts sont toutes
+// passées par lui : la génération doit se faire dans un environnement
+// éphémère, sans stockage persistant ni réseau, détruit une fois les parts
+// distribuées (voir le README). Aucune génération distribuée de N n'est
+// implémentée.
+func GenerateThresholdKey(random io.Reader, bits, threshold, parties int) (*ThresholdPublicKey, []*KeyShare, error) {
+	if bits < MinModulusBits {
+		return nil, nil, ErrModulusTooSmall
+	}
+	if bits%2 != 0 {
+		return nil, nil, errors.New("paillier: modulus size must be even")
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	for {
+		p, err := safePrime(random, bits/2)
+		if err != nil {
+			return nil, nil, err
+		}
+		q, err := safePrime(random, bits/2)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := checkFactors(p, q); err != nil {
+			continue
+		}
+		if new(big.Int).Mul(p, q).BitLen() != bits {
+			continue
+		}
+
+		return splitKey(random, p, q, threshold, parties)
+	}
+}
+
+// safePrime tire un premier sûr P = 2P'+1 de bits bits.
+func safePrime(random io.Reader, bits int) (*big.Int, error) {
+	for {
+		pPrime, err := rand.Prime(random, bits-1)
+		if err != nil {
+			return nil, err
+		}
+		p := new(big.Int).Lsh(pPrime, 1)
+		p.Add(p, one)
+		if p.BitLen() == bits && p.ProbablyPrime(primeRounds) {
+			return p, nil
+		}
+	}
+}
+
+// splitKey partage d (d ≡ 0 mod P'Q', d ≡ 1 mod N) par un polynôme de Shamir
+// de degré Threshold-1 sur Z_{N P'Q'}.
+func splitKey(random io.Reader, p, q *big.Int, threshold, parties int) (*ThresholdPublicKey, []*KeyShare, error) {
+	if threshold < 1 || threshold > parties {
+		return nil, nil, ErrInvalidThreshold
+	}
+
+	pk, err := NewPublicKey(new(big.Int).Mul(p, q))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// m = P'Q' = (P-1)(Q-1)/4
+	m := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+	m.Rsh(m, 2)
+	mInverse := new(big.Int).ModInverse(m, pk.N)
+	if mInverse == nil {
+		return nil, nil, ErrInvalidPrivateKey
+	}
+	d := new(big.Int).Mul(m, mInverse)
+
+	modulus := new(big.Int).Mul(pk.N, m)
+	coefficients := []*big.Int{d}
+	for i := 1; i < threshold; i++ {
+		a, err := rand.Int(random, modulus)
+		if err != nil {
+			return nil, nil, err
+		}
+		coefficients = append(coefficients, a)
+	}
+
+	// V est un carré aléatoire de Z*_{N²}, générateur du sous-groupe des carrés
+	// avec une probabilité écrasante
+	r, err := pk.randomUnit(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := new(big.Int).Exp(r, big.NewInt(2), pk.NSquare)
+
+	tpk := &ThresholdPublicKey{
+		PublicKey: *pk,
+		Threshold: threshold,
+		Parties:   parties,
+		V:         v,
+	}
+	delta := tpk.delta()
+
+	shares := make([]*KeyShare, parties)
+	for i := 1; i <= parties; i++ {
+		// Évaluation de Horner de f(i) mod N P'Q'
+		x := big.NewInt(int64(i))
+		s := new(big.Int)
+		for j := len(coefficients) - 1; j >= 0; j-- {
+			s.Mul(s, x).Add(s, coefficients[j]).Mod(s, modulus)
+		}
+		shares[i-1] = &KeyShare{Index: i, Share: s}
+
+		exponent := new(big.Int).Mul(delta, s)
+		tpk.VerificationKeys = append(tpk.VerificationKeys, new(big.Int).Exp(v, exponent, pk.NSquare))
+	}
+
+	return tpk, shares, nil
+}
+
+// Validate vérifie la cohérence de la clé publique à seuil.
+func (tpk *ThresholdPublicKey) Validate() error {
+	if err := tpk.PublicKey.Validate(); err != nil {
+		return err
+	}
+	if tpk.Threshold < 1 || tpk.Threshold > tpk.Parties {
+		return ErrInvalidThreshold
+	}
+	// Le partage de Shoup n'est implémenté que pour s = 1
+	if tpk.S > 1 {
+		return ErrInvalidPublicKey
+	}
+	if len(tpk.VerificationKeys) != tpk.Parties {
+		return ErrInvalidPublicKey
+	}
+	if tpk.ValidateCiphertext(&Ciphertext{c: tpk.V}) != nil {
+		return ErrInvalidPublicKey
+	}
+	for _, verificationKey := range tpk.VerificationKeys {
+		if tpk.ValidateCiphertext(&Ciphertext{c: verificationKey}) != nil {
+			return ErrInvalidPublicKey
+		}
+	}
+	return nil
+}
+
+// DecryptShare calcule la part de déchiffrement de c pour le détenteur de
+// share et la preuve associée, liée au contexte fourni.
+func (tpk *ThresholdPublicKey) DecryptShare(random io.Reader, share *KeyShare, c *Ciphertext, context []byte) (*DecryptionShare, error) {
+	if err := tpk.ValidateCiphertext(c); err != nil {
+		return nil, err
+	}
+	if share == nil || share.Share == nil || share.Index < 1 || share.Index > tpk.Parties {
+		return nil, ErrInvalidShare
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	exponent := new(big.Int).Mul(tpk.delta(), share.Share)
+	ci := new(big.Int).Exp(c.c, new(big.Int).Lsh(exponent, 1), tpk.NSquare)
+
+	// w masque Δ s_i : il dépasse |N²| + 2 * challengeBits bits
+	bound := new(big.Int).Lsh(one, uint(tpk.NSquare.BitLen()+2*challengeBits))
+	w, err := rand.Int(random, bound)
+	if err != nil {
+		return nil, err
+	}
+
+	c4 := new(big.Int).Exp(c.c, big.NewInt(4), tpk.NSquare)
+	a := new(big.Int).Exp(c4, w, tpk.NSquare)
+	b := new(big.Int).Exp(tpk.V, w, tpk.NSquare)
+
+	decryptionShare := &DecryptionShare{Index: share.Index, Share: ci}
+	e := tpk.shareChallenge(c, decryptionShare, a, b, context)
+	z := new(big.Int).Mul(e, exponent)
+	z.Add(z, w)
+
+	decryptionShare.Proof = &ShareProof{A: a, B: b, Z: z}
+	return decryptionShare, nil
+}
+
+// VerifyShare vérifie qu'une part de déchiffrement de c a été calculée avec la
+// part de clé correspondant à sa clé de vérification.
+func (tpk *ThresholdPublicKey) VerifyShare(c *Ciphertext, share *DecryptionShare, context []byte) error {
+	if err := tpk.ValidateCiphertext(c); err != nil {
+		return err
+	}
+	if share == nil || share.Proof == nil || share.Index < 1 || share.Index > tpk.Parties || share.Index > len(tpk.VerificationKeys) {
+		return ErrInvalidShare
+	}
+	proof := share.Proof
+	if proof.A == nil || proof.B == nil || proof.Z == nil || proof.Z.Sign() < 0 {
+		return ErrInvalidShare
+	}
+	for _, x := range []*big.Int{share.Share, proof.A, proof.B} {
+		if tpk.ValidateCiphertext(&Ciphertext{c: x}) != nil {
+			return ErrInvalidShare
+		}
+	}
+
+	e := tpk.shareChallenge(c, share, proof.A, proof.B, context)
+
+	// (C^4)^z ≡ a * (C_i²)^e mod N²
+	c4 := new(big.Int).Exp(c.c, big.NewInt(4), tpk.NSquare)
+	ci2 := new(big.Int).Exp(share.Share, big.NewInt(2), tpk.NSquare)
+	left := new(big.Int).Exp(c4, proof.Z, tpk.NSquare)
+	right := tpk.mulMod(proof.A, new(big.Int).Exp(ci2, e, tpk.NSquare))
+	if left.Cmp(right) != 0 {
+		return ErrInvalidShare
+	}
+
+	// V^z ≡ b * V_i^e mod N²
+	vi := tpk.VerificationKeys[share.Index-1]
+	left = new(big.Int).Exp(tpk.V, proof.Z, tpk.NSquare)
+	right = tpk.mulMod(proof.B, new(big.Int).Exp(vi, e, tpk.NSquare))
+	if left.Cmp(right) != 0 {
+		return ErrInvalidShare
+	}
+	return nil
+}
+
+// Combine vérifie les parts de déchiffrement de c et, si au moins Threshold
+// parts distinctes sont valides, retourne le clair
+// m = L(Π C_i^(2 μ_i) mod N²) * (4Δ²)^-1 mod N, μ_i étant les coefficients de
+// Lagrange en 0 multipliés par Δ.
+func (tpk *ThresholdPublicKey) Combine(c *Ciphertext, shares []*DecryptionShare, context []byte) (*big.Int, error) {
+	selected := make(map[int]*DecryptionShare)
+	for _, share := range shares {
+		if err := tpk.VerifyShare(c, share, context); err != nil {
+			return nil, err
+		}
+		selected[share.Index] = share
+	}
+	if len(selected) < tpk.Threshold {
+		return nil, ErrNotEnoughShares
+	}
+
+	// Les Threshold plus petits indices, pour un résultat déterministe
+	indices := make([]int, 0, len(selected))
+	for index := range selected {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	indices = indices[:tpk.Threshold]
+
+	delta := tpk.delta()
+	combined := big.NewInt(1)
+	for _, i := range indices {
+		// μ_i = Δ * Π_{j≠i} j / (j - i), entier car Δ = Parties!
+		numerator := new(big.Int).Set(delta)
+		denominator := big.NewInt(1)
+		for _, j := range indices {
+			if j == i {
+				continue
+			}
+			numerator.Mul(numerator, big.NewInt(int64(j)))
+			denominator.Mul(denominator, big.NewInt(int64(j-i)))
+		}
+		mu := numerator.Quo(numerator, denominator)
+
+		term := new(big.Int).Exp(selected[i].Share, mu.Lsh(mu, 1), tpk.NSquare)
+		if term == nil {
+			return nil, ErrInvalidShare
+		}
+		combined = tpk.mulMod(combined, term)
+	}
+
+	// combined = (1+N)^(4Δ² m) mod N²
+	factor := new(big.Int).Mul(delta, delta)
+	factor.Lsh(factor, 2)
+	factorInverse := new(big.Int).ModInverse(factor, tpk.N)
+	if factorInverse == nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	m := tpk.l(combined)
+	m.Mul(m, factorInverse)
+	return m.Mod(m, tpk.N), nil
+}
+
+// delta retourne Δ = Parties!.
+func (tpk *ThresholdPublicKey) delta() *big.Int {
+	return new(big.Int).MulRange(1, int64(tpk.Parties))
+}
+
+func (tpk *ThresholdPublicKey) shareChallenge(c *Ciphertext, share *DecryptionShare, a, b *big.Int, context []byte) *big.Int {
+	return challenge(shareDomain,
+		tpk.N.Bytes(),
+		tpk.V.Bytes(),
+		tpk.VerificationKeys[share.Index-1].Bytes(),
+		c.c.Bytes(),
+		share.Share.Bytes(),
+		a.Bytes(),
+		b.Bytes(),
+		context,
+	)
+}
@@ -0,0 +1,223 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	thresholdKeyOnce   sync.Once
+	thresholdPublicKey *ThresholdPublicKey
+	thresholdShares    []*KeyShare
+)
+
+// testThresholdKey partage une seule fois une clé 2-sur-3. Les premiers sûrs
+// de taille réelle sont trop longs à générer pour un test unitaire : le module
+// est réduit à 512 bits, ce qui ne change rien aux calculs vérifiés ici.
+func testThresholdKey(tb testing.TB) (*ThresholdPublicKey, []*KeyShare) {
+	thresholdKeyOnce.Do(func() {
+		for {
+			p, err := safePrime(rand.Reader, 256)
+			if err != nil {
+				tb.Fatalf("safePrime: %v", err)
+			}
+			q, err := safePrime(rand.Reader, 256)
+			if err != nil {
+				tb.Fatalf("safePrime: %v", err)
+			}
+			if checkFactors(p, q) != nil {
+				continue
+			}
+			thresholdPublicKey, thresholdShares, err = splitKey(rand.Reader, p, q, 2, 3)
+			if err != nil {
+				tb.Fatalf("splitKey: %v", err)
+			}
+			return
+		}
+	})
+	if thresholdPublicKey == nil {
+		tb.Fatal("no threshold test key")
+	}
+	return thresholdPublicKey, thresholdShares
+}
+
+func decryptShares(tb testing.TB, tpk *ThresholdPublicKey, shares []*KeyShare, c *Ciphertext, context []byte) []*DecryptionShare {
+	decryptionShares := make([]*DecryptionShare, len(shares))
+	for i, share := range shares {
+		var err error
+		decryptionShares[i], err = tpk.DecryptShare(rand.Reader, share, c, context)
+		if err != nil {
+			tb.Fatalf("DecryptShare(%d): %v", share.Index, err)
+		}
+	}
+	return decryptionShares
+}
+
+func TestThresholdCombine(t *testing.T) {
+	tpk, shares := testThresholdKey(t)
+	if err := tpk.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	context := []byte("result_trip1")
+	c, err := tpk.Encrypt(rand.Reader, big.NewInt(987654))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	decryptionShares := decryptShares(t, tpk, shares, c, context)
+	for _, share := range decryptionShares {
+		if err := tpk.VerifyShare(c, share, context); err != nil {
+			t.Fatalf("VerifyShare(%d): %v", share.Index, err)
+		}
+	}
+
+	// Exactement Threshold parts, quelle que soit la paire de détenteurs
+	for _, pair := range [][2]int{{0, 1}, {0, 2}, {1, 2}} {
+		selected := []*DecryptionShare{decryptionShares[pair[0]], decryptionShares[pair[1]]}
+		m, err := tpk.Combine(c, selected, context)
+		if err != nil {
+			t.Fatalf("Combine(%v): %v", pair, err)
+		}
+		if m.Cmp(big.NewInt(987654)) != 0 {
+			t.Fatalf("Combine(%v) returned %s", pair, m)
+		}
+	}
+
+	// Toutes les parts : seules les Threshold premières sont utilisées
+	m, err := tpk.Combine(c, decryptionShares, context)
+	if err != nil || m.Cmp(big.NewInt(987654)) != 0 {
+		t.Fatalf("Combine(all): %v, %v", m, err)
+	}
+}
+
+func TestThresholdCombineNotEnoughShares(t *testing.T) {
+	tpk, shares := testThresholdKey(t)
+	context := []byte("result_trip1")
+	c, err := tpk.Encrypt(rand.Reader, big.NewInt(42))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	decryptionShares := decryptShares(t, tpk, shares, c, context)
+
+	// Threshold - 1 parts
+	if _, err := tpk.Combine(c, decryptionShares[:tpk.Threshold-1], context); err != ErrNotEnoughShares {
+		t.Errorf("Threshold-1 shares: expected ErrNotEnoughShares, got %v", err)
+	}
+
+	// Une même part soumise deux fois ne compte qu'une fois
+	duplicated := []*DecryptionShare{decryptionShares[0], decryptionShares[0]}
+	if _, err := tpk.Combine(c, duplicated, context); err != ErrNotEnoughShares {
+		t.Errorf("duplicated share: expected ErrNotEnoughShares, got %v", err)
+	}
+}
+
+func TestThresholdShareTampered(t *testing.T) {
+	tpk, shares := testThresholdKey(t)
+	context := []byte("result_trip1")
+	c, err := tpk.Encrypt(rand.Reader, big.NewInt(42))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	valid := decryptShares(t, tpk, shares, c, context)
+
+	tamper := func(change func(share *DecryptionShare)) *DecryptionShare {
+		proof := *valid[0].Proof
+		share := &DecryptionShare{Index: valid[0].Index, Share: valid[0].Share, Proof: &proof}
+		change(share)
+		return share
+	}
+	tests := []struct {
+		name  string
+		share *DecryptionShare
+	}{
+		{"tampered share value", tamper(func(s *DecryptionShare) { s.Share = valid[1].Share })},
+		{"tampered index", tamper(func(s *DecryptionShare) { s.Index = 2 })},
+		{"tampered commitment", tamper(func(s *DecryptionShare) { s.Proof.A = tpk.mulMod(s.Proof.A, tpk.V) })},
+		{"tampered response", tamper(func(s *DecryptionShare) { s.Proof.Z = new(big.Int).Add(s.Proof.Z, one) })},
+		{"negative response", tamper(func(s *DecryptionShare) { s.Proof.Z = new(big.Int).Neg(s.Proof.Z) })},
+		{"index out of range", tamper(func(s *DecryptionShare) { s.Index = tpk.Parties + 1 })},
+		{"missing proof", tamper(func(s *DecryptionShare) { s.Proof = nil })},
+	}
+	for _, test := range tests {
+		if err := tpk.VerifyShare(c, test.share, context); err != ErrInvalidShare {
+			t.Errorf("%s: expected ErrInvalidShare, got %v", test.name, err)
+		}
+		if _, err := tpk.Combine(c, []*DecryptionShare{test.share, valid[1]}, context); err != ErrInvalidShare {
+			t.Errorf("%s: Combine expected ErrInvalidShare, got %v", test.name, err)
+		}
+	}
+
+	// La part est liée au contexte et au chiffré
+	if err := tpk.VerifyShare(c, valid[0], []byte("result_trip2")); err != ErrInvalidShare {
+		t.Errorf("other context: expected ErrInvalidShare, got %v", err)
+	}
+	other, err := tpk.Encrypt(rand.Reader, big.NewInt(42))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if err := tpk.VerifyShare(other, valid[0], context); err != ErrInvalidShare {
+		t.Errorf("other ciphertext: expected ErrInvalidShare, got %v", err)
+	}
+}
+
+func TestThresholdKeyValidation(t *testing.T) {
+	if _, _, err := GenerateThresholdKey(rand.Reader, 1024, 2, 3); err != ErrModulusTooSmall {
+		t.Errorf("GenerateThresholdKey(1024): expected ErrModulusTooSmall, got %v", err)
+	}
+
+	tpk, _ := testThresholdKey(t)
+	for _, params := range [][2]int{{0, 3}, {4, 3}} {
+		invalid := *tpk
+		invalid.Threshold, invalid.Parties = params[0], params[1]
+		if err := invalid.Validate(); err != ErrInvalidThreshold {
+			t.Errorf("threshold %d of %d: expected ErrInvalidThreshold, got %v", params[0], params[1], err)
+		}
+	}
+
+	invalid := *tpk
+	invalid.VerificationKeys = tpk.VerificationKeys[:2]
+	if err := invalid.Validate(); err != ErrInvalidPublicKey {
+		t.Errorf("missing verification key: expected ErrInvalidPublicKey, got %v", err)
+	}
+}
+
+func TestDecryptionShareJSON(t *testing.T) {
+	tpk, shares := testThresholdKey(t)
+	context := []byte("result_trip1")
+	c, err := tpk.Encrypt(rand.Reader, big.NewInt(42))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	share := decryptShares(t, tpk, shares[:1], c, context)[0]
+
+	data, err := json.Marshal(share)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, field := range []string{"share", "a", "b", "z"} {
+		if !strings.Contains(string(data), `"`+field+`":"`+ProofTag+`:`) {
+			t.Fatalf("field %s is not in the compact format: %s", field, data)
+		}
+	}
+
+	// Format compact et nombres décimaux des enregistrements antérieurs
+	legacy := fmt.Sprintf(`{"index":%d,"share":%s,"proof":{"a":%s,"b":%s,"z":%s}}`,
+		share.Index, share.Share, share.Proof.A, share.Proof.B, share.Proof.Z)
+	for _, input := range []string{string(data), legacy} {
+		var decoded DecryptionShare
+		if err := json.Unmarshal([]byte(input), &decoded); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if decoded.Index != share.Index {
+			t.Fatalf("decoded index %d, expected %d", decoded.Index, share.Index)
+		}
+		if err := tpk.VerifyShare(c, &decoded, context); err != nil {
+			t.Fatalf("VerifyShare of the decoded share: %v", err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package pedersen
+
+import (
+	"errors"
+	"math/big"
+
+	"simple/compact"
+)
+
+// CommitmentTag est l'étiquette du format compact (voir le paquet compact)
+// des engagements, version 1.
+const CommitmentTag = "pd1"
+
+// Commitment représente un engagement de Pedersen, élément du sous-groupe
+// d'ordre Q de Z*_P.
+type Commitment struct {
+	c *big.Int
+}
+
+// NewCommitment encapsule la valeur entière c d'un engagement.
+func NewCommitment(c *big.Int) *Commitment {
+	return &Commitment{c: new(big.Int).Set(c)}
+}
+
+// ParseCommitment lit un engagement écrit dans le format compact ou en décimal.
+func ParseCommitment(s string) (*Commitment, error) {
+	c, err := compact.Decode(CommitmentTag, s)
+	if err != nil {
+		return nil, ErrInvalidCommitment
+	}
+	return &Commitment{c: c}, nil
+}
+
+// Int retourne une copie de la valeur entière de l'engagement.
+func (c *Commitment) Int() *big.Int {
+	return new(big.Int).Set(c.c)
+}
+
+// String retourne la représentation décimale de l'engagement.
+func (c *Commitment) String() string {
+	if c == nil || c.c == nil {
+		return "<nil>"
+	}
+	return c.c.String()
+}
+
+// Encode retourne la représentation compacte versionnée de l'engagement.
+func (c *Commitment) Encode() string {
+	return compact.Encode(CommitmentTag, c.c)
+}
+
+// MarshalJSON encode l'engagement comme une chaîne JSON au format compact.
+func (c *Commitment) MarshalJSON() ([]byte, error) {
+	if c == nil || c.c == nil {
+		return []byte("null"), nil
+	}
+	return compact.MarshalJSON(CommitmentTag, c.c)
+}
+
+// UnmarshalJSON décode un engagement écrit comme une chaîne JSON (format
+// compact ou décimal) ou, pour les enregistrements antérieurs, comme un nombre
+// JSON décimal.
+func (c *Commitment) UnmarshalJSON(data []byte) error {
+	value, err := compact.UnmarshalJSON(CommitmentTag, data)
+	if err != nil {
+		return errors.New("pedersen: failed to unmarshal commitment: " + err.Error())
+	}
+	if value != nil {
+		c.c = value
+	}
+	return nil
+}
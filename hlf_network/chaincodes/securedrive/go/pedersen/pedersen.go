@@ -0,0 +1,169 @@
+// Package pedersen implémente les engagements de Pedersen utilisés par le
+// chaincode securedrive pour publier des valeurs confidentielles (par exemple
+// les poids de tarification d'un assureur) sans les révéler :
+// C = G^m * H^r mod P, dans le sous-groupe d'ordre premier Q des résidus
+// quadratiques du groupe MODP de 2048 bits de la RFC 3526 (P = 2Q + 1).
+// Un engagement cache parfaitement m et lie son auteur à m tant que log_G(H)
+// est inconnu : H est dérivé d'une empreinte publique, sans trappe.
+package pedersen
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+)
+
+var one = big.NewInt(1)
+
+var (
+	// ErrInvalidCommitment est retournée lorsqu'un engagement n'appartient pas au sous-groupe d'ordre Q.
+	ErrInvalidCommitment = errors.New("pedersen: invalid commitment")
+	// ErrInvalidOpening est retournée lorsqu'un engagement ne s'ouvre pas sur la valeur fournie.
+	ErrInvalidOpening = errors.New("pedersen: commitment does not open to the given value")
+)
+
+// rfc3526Prime2048 est le module du groupe MODP n° 14 de la RFC 3526.
+const rfc3526Prime2048 = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+	"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+	"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+	"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D" +
+	"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F" +
+	"83655D23DCA3AD961C62F356208552BB9ED529077096966D" +
+	"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9" +
+	"DE2BCBF6955817183995497CEA956AE515D2261898FA0510" +
+	"15728E5A8AACAA68FFFFFFFFFFFFFFFF"
+
+// generatorDomain sert à dériver H de façon vérifiable.
+const generatorDomain = "securedrive/pedersen/h/v1"
+
+// Params regroupe les paramètres publics du groupe.
+type Params struct {
+	P *big.Int // Module premier sûr
+	Q *big.Int // Ordre du sous-groupe, (P - 1) / 2
+	G *big.Int // Générateur du sous-groupe
+	H *big.Int // Second générateur, de logarithme discret inconnu
+}
+
+var defaultParams = newDefaultParams()
+
+// DefaultParams retourne les paramètres partagés par tous les engagements du
+// chaincode. Ils ne doivent pas être modifiés.
+func DefaultParams() *Params {
+	return defaultParams
+}
+
+func newDefaultParams() *Params {
+	p, _ := new(big.Int).SetString(rfc3526Prime2048, 16)
+	q := new(big.Int).Rsh(p, 1)
+	return &Params{
+		P: p,
+		Q: q,
+		G: big.NewInt(4), // 2², un carré différent de 1
+		H: deriveGenerator(p, []byte(generatorDomain)),
+	}
+}
+
+// deriveGenerator hache label vers un carré de Z*_P différent de 1
+// (SHA-256 en mode compteur, 128 bits supplémentaires contre le biais).
+func deriveGenerator(p *big.Int, label []byte) *big.Int {
+	size := (p.BitLen() + 128 + 7) / 8
+	for counter := uint32(0); ; counter++ {
+		stream := make([]byte, 0, size+sha256.Size)
+		for block := uint32(0); len(stream) < size; block++ {
+			hash := sha256.New()
+			var header [8]byte
+			binary.BigEndian.PutUint32(header[:4], counter)
+			binary.BigEndian.PutUint32(header[4:], block)
+			hash.Write(header[:])
+			hash.Write(label)
+			stream = hash.Sum(stream)
+		}
+
+		x := new(big.Int).SetBytes(stream[:size])
+		x.Mod(x, p)
+		h := x.Exp(x, big.NewInt(2), p)
+		if h.Cmp(one) > 0 {
+			return h
+		}
+	}
+}
+
+// Commit s'engage sur m avec un aléa r tiré uniformément dans Z_Q, retourné
+// pour permettre l'ouverture de l'engagement.
+func (params *Params) Commit(random io.Reader, m *big.Int) (*Commitment, *big.Int, error) {
+	r, err := params.RandomExponent(random)
+	if err != nil {
+		return nil, nil, err
+	}
+	return params.CommitWithRandom(m, r), r, nil
+}
+
+// CommitWithRandom calcule C = G^m * H^r mod P. m et r sont réduits modulo Q :
+// m peut être négatif.
+func (params *Params) CommitWithRandom(m, r *big.Int) *Commitment {
+	return &Commitment{c: params.mulMod(
+		params.exp(params.G, m),
+		params.exp(params.H, r),
+	)}
+}
+
+// Open vérifie que c s'ouvre sur m avec l'aléa r.
+func (params *Params) Open(c *Commitment, m, r *big.Int) error {
+	if err := params.ValidateCommitment(c); err != nil {
+		return err
+	}
+	if params.CommitWithRandom(m, r).c.Cmp(c.c) != 0 {
+		return ErrInvalidOpening
+	}
+	return nil
+}
+
+// ValidateCommitment vérifie que c est un élément du sous-groupe d'ordre Q.
+func (params *Params) ValidateCommitment(c *Commitment) error {
+	if c == nil || !params.IsElement(c.c) {
+		return ErrInvalidCommitment
+	}
+	return nil
+}
+
+// IsElement indique si x appartient au sous-groupe d'ordre Q de Z*_P.
+func (params *Params) IsElement(x *big.Int) bool {
+	if x == nil || x.Sign() <= 0 || x.Cmp(params.P) >= 0 {
+		return false
+	}
+	return new(big.Int).Exp(x, params.Q, params.P).Cmp(one) == 0
+}
+
+// RandomExponent tire un exposant uniforme de Z_Q.
+func (params *Params) RandomExponent(random io.Reader) (*big.Int, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+	return rand.Int(random, params.Q)
+}
+
+// VerifyResponse vérifie l'équation G^m * H^r ≡ a * c^e mod P d'une preuve
+// de connaissance d'une ouverture de c, les exposants étant réduits modulo Q.
+func (params *Params) VerifyResponse(c *Commitment, a, e, m, r *big.Int) bool {
+	if !params.IsElement(a) {
+		return false
+	}
+	left := params.CommitWithRandom(m, r).c
+	right := params.mulMod(a, params.exp(c.c, e))
+	return left.Cmp(right) == 0
+}
+
+// exp calcule x^(k mod Q) mod P.
+func (params *Params) exp(x, k *big.Int) *big.Int {
+	return new(big.Int).Exp(x, new(big.Int).Mod(k, params.Q), params.P)
+}
+
+func (params *Params) mulMod(a, b *big.Int) *big.Int {
+	result := new(big.Int).Mul(a, b)
+	return result.Mod(result, params.P)
+}
@@ -0,0 +1,160 @@
+package pedersen
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestDefaultParams(t *testing.T) {
+	params := DefaultParams()
+
+	if params.P.BitLen() != 2048 || !params.P.ProbablyPrime(20) || !params.Q.ProbablyPrime(20) {
+		t.Fatal("P is not a 2048-bit safe prime")
+	}
+	if new(big.Int).Add(new(big.Int).Lsh(params.Q, 1), one).Cmp(params.P) != 0 {
+		t.Fatal("P != 2Q + 1")
+	}
+	if !params.IsElement(params.G) || !params.IsElement(params.H) {
+		t.Fatal("G and H must belong to the subgroup of order Q")
+	}
+	if params.G.Cmp(params.H) == 0 || params.H.Cmp(one) == 0 {
+		t.Fatal("H must be a distinct generator")
+	}
+
+	// H est dérivé de façon reproductible de son étiquette
+	if deriveGenerator(params.P, []byte(generatorDomain)).Cmp(params.H) != 0 {
+		t.Fatal("H is not derived from generatorDomain")
+	}
+	if deriveGenerator(params.P, []byte("other label")).Cmp(params.H) == 0 {
+		t.Fatal("different labels derived the same generator")
+	}
+}
+
+func TestCommitOpen(t *testing.T) {
+	params := DefaultParams()
+
+	for _, value := range []int64{0, 42, -7} {
+		m := big.NewInt(value)
+		c, r, err := params.Commit(rand.Reader, m)
+		if err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		if err := params.Open(c, m, r); err != nil {
+			t.Fatalf("Open(%d): %v", value, err)
+		}
+
+		if err := params.Open(c, big.NewInt(value+1), r); err != ErrInvalidOpening {
+			t.Errorf("wrong value: expected ErrInvalidOpening, got %v", err)
+		}
+		if err := params.Open(c, m, new(big.Int).Add(r, one)); err != ErrInvalidOpening {
+			t.Errorf("wrong randomness: expected ErrInvalidOpening, got %v", err)
+		}
+	}
+
+	// Deux engagements de la même valeur sont différents
+	c1, _, err := params.Commit(rand.Reader, big.NewInt(5))
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	c2, _, err := params.Commit(rand.Reader, big.NewInt(5))
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if c1.Int().Cmp(c2.Int()) == 0 {
+		t.Fatal("Commit is deterministic")
+	}
+}
+
+func TestValidateCommitment(t *testing.T) {
+	params := DefaultParams()
+
+	invalid := []*big.Int{
+		big.NewInt(0),
+		new(big.Int).Sub(params.P, one), // -1 n'est pas un carré modulo P
+		params.P,
+		big.NewInt(-4),
+	}
+	for _, x := range invalid {
+		if err := params.ValidateCommitment(NewCommitment(x)); err != ErrInvalidCommitment {
+			t.Errorf("ValidateCommitment(%s): expected ErrInvalidCommitment, got %v", x, err)
+		}
+	}
+	if err := params.ValidateCommitment(nil); err != ErrInvalidCommitment {
+		t.Errorf("ValidateCommitment(nil): expected ErrInvalidCommitment, got %v", err)
+	}
+	if err := params.Open(NewCommitment(big.NewInt(0)), big.NewInt(0), big.NewInt(0)); err != ErrInvalidCommitment {
+		t.Errorf("Open of an invalid commitment: expected ErrInvalidCommitment, got %v", err)
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+	params := DefaultParams()
+	m := big.NewInt(12)
+	c, r, err := params.Commit(rand.Reader, m)
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	// Preuve de connaissance d'une ouverture : a = G^x * H^σ, réponses x + e*m et σ + e*r
+	x, sigma := big.NewInt(987654321), big.NewInt(123456789)
+	a := params.CommitWithRandom(x, sigma).Int()
+	e := big.NewInt(31337)
+	zm := new(big.Int).Add(x, new(big.Int).Mul(e, m))
+	zr := new(big.Int).Add(sigma, new(big.Int).Mul(e, r))
+
+	if !params.VerifyResponse(c, a, e, zm, zr) {
+		t.Fatal("VerifyResponse rejected a valid response")
+	}
+	if params.VerifyResponse(c, a, new(big.Int).Add(e, one), zm, zr) {
+		t.Error("VerifyResponse accepted a tampered challenge")
+	}
+	if params.VerifyResponse(c, a, e, new(big.Int).Add(zm, one), zr) {
+		t.Error("VerifyResponse accepted a tampered response")
+	}
+	if params.VerifyResponse(c, new(big.Int).Sub(params.P, one), e, zm, zr) {
+		t.Error("VerifyResponse accepted a commitment outside the subgroup")
+	}
+}
+
+func TestCommitmentJSON(t *testing.T) {
+	params := DefaultParams()
+	c, _, err := params.Commit(rand.Reader, big.NewInt(42))
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(data) != `"`+c.Encode()+`"` {
+		t.Fatalf("Marshal returned %s, expected %q", data, c.Encode())
+	}
+
+	// Format compact, chaîne décimale et nombre décimal des enregistrements antérieurs
+	for _, input := range []string{string(data), `"` + c.String() + `"`, c.String()} {
+		var decoded Commitment
+		if err := json.Unmarshal([]byte(input), &decoded); err != nil {
+			t.Fatalf("Unmarshal(%.20s…): %v", input, err)
+		}
+		if decoded.Int().Cmp(c.Int()) != 0 {
+			t.Fatalf("Unmarshal(%.20s…) returned another commitment", input)
+		}
+	}
+	for _, s := range []string{c.Encode(), c.String()} {
+		parsed, err := ParseCommitment(s)
+		if err != nil || parsed.Int().Cmp(c.Int()) != 0 {
+			t.Fatalf("ParseCommitment(%.20s…) returned %v", s, err)
+		}
+	}
+	if _, err := ParseCommitment("pc1:AQ"); err != ErrInvalidCommitment {
+		t.Errorf("other tag: expected ErrInvalidCommitment, got %v", err)
+	}
+
+	var decoded Commitment
+	if err := json.Unmarshal([]byte(`"pd1:"`), &decoded); err == nil {
+		t.Error("Unmarshal accepted an empty payload")
+	}
+}
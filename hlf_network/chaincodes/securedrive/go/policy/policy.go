@@ -0,0 +1,42 @@
+// Package policy gère les contrats d'assurance et les poids de tarification
+// des assureurs, publics (CriteriaWeights) ou engagés (ConfidentialWeights).
+package policy
+
+import "simple/pedersen"
+
+// InsuranceContract représente un contrat d'assurance
+type InsuranceContract struct {
+	ContractID        string `json:"contractID"`        // Identifiant unique du contrat
+	OwnerID           string `json:"ownerID"`           // Identifiant du propriétaire
+	VehicleID         string `json:"vehicleID"`         // Identifiant du véhicule
+	CriteriaWeightsID string `json:"criteriaWeightsID"` // Identifiant des critères de pondération
+	StartMonth        int    `json:"startMonth"`        // Mois de début
+	StartYear         int    `json:"startYear"`         // Année de début
+	EndMonth          int    `json:"endMonth"`          // Mois de fin
+	EndYear           int    `json:"endYear"`           // Année de fin
+}
+
+// CriteriaWeights représente les poids des critères
+type CriteriaWeights struct {
+	CriteriaWeightsID  string `json:"criteriaweightID"`
+	WeightTraffic      int    `json:"weight_traffic"`
+	WeightSpeed        int    `json:"weight_speed"`
+	WeightAcceleration int    `json:"weight_acceleration"`
+	WeightBraking      int    `json:"weight_braking"`
+	WeightDistance     int    `json:"weight_distance"`
+	WeightZone         int    `json:"weight_zone"`
+	WeightTime         int    `json:"weight_time"`
+	Alpha              int    `json:"alpha"` // Scalaire pour PAYD
+	Beta               int    `json:"beta"`  // Scalaire pour PHYD
+}
+
+// ConfidentialWeights représente les poids de tarification d'un assureur
+// publiés sous forme d'engagements de Pedersen plutôt qu'en clair. Chaque
+// engagement porte sur le coefficient d'un champ du trajet dans la prime
+// (ex. Alpha pour le kilométrage, Beta * WeightSpeed pour les excès de
+// vitesse, -Beta * WeightTraffic pour le respect des feux), connu du seul assureur
+type ConfidentialWeights struct {
+	WeightsID   string                          `json:"weightsID"`
+	InsurerID   string                          `json:"insurerID"`
+	Commitments map[string]*pedersen.Commitment `json:"commitments"` // Engagement sur le coefficient de chaque champ de telematics.TripFields
+}
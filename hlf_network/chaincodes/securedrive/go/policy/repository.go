@@ -0,0 +1,187 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"simple/ledger"
+)
+
+// ContractRepository conserve les contrats d'assurance. Get retourne nil, sans
+// erreur, pour un contrat absent.
+type ContractRepository interface {
+	Get(contractID string) (*InsuranceContract, error)
+	Put(contract *InsuranceContract) error
+	// ByOwner retourne les contrats d'un propriétaire
+	ByOwner(ownerID string) ([]InsuranceContract, error)
+	// ByOwnerAndVehicle retourne les contrats d'un propriétaire pour un véhicule
+	ByOwnerAndVehicle(ownerID, vehicleID string) ([]InsuranceContract, error)
+	// All retourne tous les contrats
+	All() ([]InsuranceContract, error)
+}
+
+// CriteriaWeightsRepository conserve les poids publics des assureurs. Get
+// retourne nil, sans erreur, pour des poids absents.
+type CriteriaWeightsRepository interface {
+	Get(criteriaWeightsID string) (*CriteriaWeights, error)
+	Put(weights *CriteriaWeights) error
+	All() ([]CriteriaWeights, error)
+}
+
+// ConfidentialWeightsRepository conserve les engagements des poids des
+// assureurs. Get retourne nil, sans erreur, pour des poids absents.
+type ConfidentialWeightsRepository interface {
+	Get(weightsID string) (*ConfidentialWeights, error)
+	Put(weights *ConfidentialWeights) error
+}
+
+// NewContractRepository retourne le dépôt des contrats dans le world state,
+// sous contract_<ContractID>
+func NewContractRepository(state ledger.State) ContractRepository {
+	return &contractStore{state: state}
+}
+
+type contractStore struct {
+	state ledger.State
+}
+
+func (r *contractStore) Get(contractID string) (*InsuranceContract, error) {
+	var contract InsuranceContract
+	found, err := ledger.GetJSON(r.state, "contract_"+contractID, &contract)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve InsuranceContract: %s", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &contract, nil
+}
+
+func (r *contractStore) Put(contract *InsuranceContract) error {
+	err := ledger.PutJSON(r.state, "contract_"+contract.ContractID, contract)
+	if err != nil {
+		return fmt.Errorf("Failed to store InsuranceContract: %s", err)
+	}
+	return nil
+}
+
+func (r *contractStore) ByOwner(ownerID string) ([]InsuranceContract, error) {
+	// Les véhicules et les Verifier portent aussi un ownerID
+	return r.query(fmt.Sprintf(`{"selector":{"ownerID":"%s","contractID":{"$exists":true}}}`, ownerID))
+}
+
+func (r *contractStore) ByOwnerAndVehicle(ownerID, vehicleID string) ([]InsuranceContract, error) {
+	contracts, err := r.query(fmt.Sprintf(`{"selector":{"ownerID":"%s","vehicleID":"%s"}}`, ownerID, vehicleID))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query contracts for vehicle %s: %s", vehicleID, err)
+	}
+
+	// Le véhicule lui-même répond au sélecteur
+	var result []InsuranceContract
+	for _, contract := range contracts {
+		if contract.ContractID != "" {
+			result = append(result, contract)
+		}
+	}
+	return result, nil
+}
+
+func (r *contractStore) All() ([]InsuranceContract, error) {
+	return r.query(`{"selector":{"contractID":{"$exists":true}}}`)
+}
+
+func (r *contractStore) query(query string) ([]InsuranceContract, error) {
+	results, err := r.state.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query insurance contracts: %s", err)
+	}
+
+	var contracts []InsuranceContract
+	for _, result := range results {
+		var contract InsuranceContract
+		err = json.Unmarshal(result.Value, &contract)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal insurance contract: %s", err)
+		}
+		contracts = append(contracts, contract)
+	}
+	return contracts, nil
+}
+
+// NewCriteriaWeightsRepository retourne le dépôt des poids publics dans le
+// world state, sous criteriaweights_<CriteriaWeightsID>
+func NewCriteriaWeightsRepository(state ledger.State) CriteriaWeightsRepository {
+	return &criteriaWeightsStore{state: state}
+}
+
+type criteriaWeightsStore struct {
+	state ledger.State
+}
+
+func (r *criteriaWeightsStore) Get(criteriaWeightsID string) (*CriteriaWeights, error) {
+	var weights CriteriaWeights
+	found, err := ledger.GetJSON(r.state, "criteriaweights_"+criteriaWeightsID, &weights)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve CriteriaWeights: %s", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &weights, nil
+}
+
+func (r *criteriaWeightsStore) Put(weights *CriteriaWeights) error {
+	err := ledger.PutJSON(r.state, "criteriaweights_"+weights.CriteriaWeightsID, weights)
+	if err != nil {
+		return fmt.Errorf("Failed to store CriteriaWeights: %s", err)
+	}
+	return nil
+}
+
+func (r *criteriaWeightsStore) All() ([]CriteriaWeights, error) {
+	results, err := r.state.Query(`{"selector":{"_id":{"$regex":"^criteriaweights_"}}}`)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query all CriteriaWeights: %s", err)
+	}
+
+	var criteriaWeightsList []CriteriaWeights
+	for _, result := range results {
+		var weights CriteriaWeights
+		err = json.Unmarshal(result.Value, &weights)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal CriteriaWeights: %s", err)
+		}
+		criteriaWeightsList = append(criteriaWeightsList, weights)
+	}
+	return criteriaWeightsList, nil
+}
+
+// NewConfidentialWeightsRepository retourne le dépôt des poids engagés dans le
+// world state, sous confidentialweights_<WeightsID>
+func NewConfidentialWeightsRepository(state ledger.State) ConfidentialWeightsRepository {
+	return &confidentialWeightsStore{state: state}
+}
+
+type confidentialWeightsStore struct {
+	state ledger.State
+}
+
+func (r *confidentialWeightsStore) Get(weightsID string) (*ConfidentialWeights, error) {
+	var weights ConfidentialWeights
+	found, err := ledger.GetJSON(r.state, "confidentialweights_"+weightsID, &weights)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve ConfidentialWeights: %s", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &weights, nil
+}
+
+func (r *confidentialWeightsStore) Put(weights *ConfidentialWeights) error {
+	err := ledger.PutJSON(r.state, "confidentialweights_"+weights.WeightsID, weights)
+	if err != nil {
+		return fmt.Errorf("Failed to store ConfidentialWeights: %s", err)
+	}
+	return nil
+}
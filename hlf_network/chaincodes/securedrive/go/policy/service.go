@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"simple/ledger"
+	"simple/pedersen"
+	"simple/telematics"
+)
+
+// Service regroupe l'enregistrement des contrats et des poids. Les dépôts sont
+// exposés afin que les tests puissent les remplacer.
+type Service struct {
+	Contracts           ContractRepository
+	CriteriaWeights     CriteriaWeightsRepository
+	ConfidentialWeights ConfidentialWeightsRepository
+}
+
+// NewService construit le service sur les dépôts du world state
+func NewService(state ledger.State) *Service {
+	return &Service{
+		Contracts:           NewContractRepository(state),
+		CriteriaWeights:     NewCriteriaWeightsRepository(state),
+		ConfidentialWeights: NewConfidentialWeightsRepository(state),
+	}
+}
+
+// AddContract enregistre un nouveau contrat d'assurance
+func (s *Service) AddContract(contract *InsuranceContract) error {
+	existingContract, err := s.Contracts.Get(contract.ContractID)
+	if err != nil {
+		return err
+	}
+	if existingContract != nil {
+		return errors.New("Contract with the given ContractID already exists")
+	}
+	return s.Contracts.Put(contract)
+}
+
+// AddCriteriaWeights enregistre de nouveaux poids publics
+func (s *Service) AddCriteriaWeights(weights *CriteriaWeights) error {
+	existingWeights, err := s.CriteriaWeights.Get(weights.CriteriaWeightsID)
+	if err != nil {
+		return err
+	}
+	if existingWeights != nil {
+		return errors.New("Criteria weights with this ID already exists")
+	}
+	return s.CriteriaWeights.Put(weights)
+}
+
+// AddConfidentialWeights enregistre les engagements d'un assureur sur les
+// coefficients de sa tarification, un par champ du trajet
+func (s *Service) AddConfidentialWeights(weights *ConfidentialWeights) error {
+	existingWeights, err := s.ConfidentialWeights.Get(weights.WeightsID)
+	if err != nil {
+		return err
+	}
+	if existingWeights != nil {
+		return errors.New("Confidential weights with this ID already exist")
+	}
+
+	params := pedersen.DefaultParams()
+	for field := range weights.Commitments {
+		if !telematics.IsTripField(field) {
+			return fmt.Errorf("Unknown field '%s' in commitments", field)
+		}
+	}
+	for _, field := range telematics.TripFields {
+		err = params.ValidateCommitment(weights.Commitments[field])
+		if err != nil {
+			return fmt.Errorf("Invalid commitment for field '%s': %s", field, err)
+		}
+	}
+
+	return s.ConfidentialWeights.Put(weights)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// newChaincodeServer construit, à partir de l'environnement, le serveur du
+// chaincode exécuté comme service (builder externe "ccaas"). Il retourne nil
+// si CHAINCODE_SERVER_ADDRESS n'est pas défini.
+//
+//   - CHAINCODE_SERVER_ADDRESS : adresse d'écoute, par exemple 0.0.0.0:9999
+//   - CHAINCODE_ID : identifiant du paquet retourné par "peer lifecycle chaincode install"
+//   - CHAINCODE_TLS_DISABLED : "true" (par défaut) ou "false"
+//   - CHAINCODE_TLS_KEY, CHAINCODE_TLS_CERT : fichiers PEM de la paire de clés du serveur
+//   - CHAINCODE_CLIENT_CA_CERT : fichier PEM facultatif, active le TLS mutuel
+func newChaincodeServer(cc shim.Chaincode) (*shim.ChaincodeServer, error) {
+	address := os.Getenv("CHAINCODE_SERVER_ADDRESS")
+	if address == "" {
+		return nil, nil
+	}
+
+	ccid := os.Getenv("CHAINCODE_ID")
+	if ccid == "" {
+		return nil, errors.New("CHAINCODE_ID must be set when CHAINCODE_SERVER_ADDRESS is set")
+	}
+
+	tlsProps, err := tlsProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	return &shim.ChaincodeServer{
+		CCID:     ccid,
+		Address:  address,
+		CC:       cc,
+		TLSProps: tlsProps,
+	}, nil
+}
+
+// tlsProperties lit la configuration TLS du serveur dans l'environnement
+func tlsProperties() (shim.TLSProperties, error) {
+	disabled := true
+	if value := os.Getenv("CHAINCODE_TLS_DISABLED"); value != "" {
+		var err error
+		disabled, err = strconv.ParseBool(value)
+		if err != nil {
+			return shim.TLSProperties{}, fmt.Errorf("Invalid CHAINCODE_TLS_DISABLED value '%s'", value)
+		}
+	}
+	if disabled {
+		return shim.TLSProperties{Disabled: true}, nil
+	}
+
+	key, err := readPEM("CHAINCODE_TLS_KEY", true)
+	if err != nil {
+		return shim.TLSProperties{}, err
+	}
+	cert, err := readPEM("CHAINCODE_TLS_CERT", true)
+	if err != nil {
+		return shim.TLSProperties{}, err
+	}
+	clientCACerts, err := readPEM("CHAINCODE_CLIENT_CA_CERT", false)
+	if err != nil {
+		return shim.TLSProperties{}, err
+	}
+
+	return shim.TLSProperties{
+		Key:           key,
+		Cert:          cert,
+		ClientCACerts: clientCACerts,
+	}, nil
+}
+
+// readPEM lit le fichier désigné par la variable d'environnement name
+func readPEM(name string, required bool) ([]byte, error) {
+	path := os.Getenv(name)
+	if path == "" {
+		if required {
+			return nil, fmt.Errorf("%s must be set when TLS is enabled", name)
+		}
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", name, err)
+	}
+	return data, nil
+}
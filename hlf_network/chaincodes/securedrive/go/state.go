@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"simple/billing"
+	"simple/crypto"
+	"simple/ledger"
+	"simple/policy"
+	"simple/telematics"
+)
+
+// stubState implémente ledger.State au-dessus du stub de la transaction
+type stubState struct {
+	shim.ChaincodeStubInterface
+}
+
+// Query exécute une requête riche CouchDB et en parcourt tous les résultats
+func (s stubState) Query(query string) ([]ledger.KV, error) {
+	resultsIterator, err := s.GetQueryResult(query)
+	if err != nil {
+		return nil, err
+	}
+	return collect(resultsIterator)
+}
+
+// Range parcourt les clés comprises entre startKey (inclus) et endKey (exclu).
+// Contrairement à Query, il est pris en charge par LevelDB comme par CouchDB
+func (s stubState) Range(startKey, endKey string) ([]ledger.KV, error) {
+	resultsIterator, err := s.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return collect(resultsIterator)
+}
+
+// collect parcourt tous les résultats d'un itérateur puis le ferme
+func collect(resultsIterator shim.StateQueryIteratorInterface) ([]ledger.KV, error) {
+	defer resultsIterator.Close()
+
+	var results []ledger.KV
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to iterate over query results: %s", err)
+		}
+		results = append(results, ledger.KV{Key: queryResponse.Key, Value: queryResponse.Value})
+	}
+	return results, nil
+}
+
+// services regroupe les services métier d'une transaction, construits sur son
+// world state
+type services struct {
+	crypto     *crypto.Service
+	telematics *telematics.Service
+	policy     *policy.Service
+	billing    *billing.Service
+}
+
+func newServices(ctx contractapi.TransactionContextInterface) *services {
+	state := stubState{ctx.GetStub()}
+
+	keys := crypto.NewService(state)
+	telematicsService := telematics.NewService(state, keys)
+	policyService := policy.NewService(state)
+
+	return &services{
+		crypto:     keys,
+		telematics: telematicsService,
+		policy:     policyService,
+		billing:    billing.NewService(state, telematicsService, policyService),
+	}
+}
+
+// clientCaller identifie le client qui soumet la transaction. Son nom
+// d'enrôlement est l'attribut hf.EnrollmentID inscrit par Fabric CA dans le
+// certificat, à défaut le CN du certificat
+func clientCaller(ctx contractapi.TransactionContextInterface) (crypto.Caller, error) {
+	identity := ctx.GetClientIdentity()
+	if identity == nil {
+		return crypto.Caller{}, errors.New("Failed to get client identity")
+	}
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return crypto.Caller{}, fmt.Errorf("Failed to get client MSPID: %s", err)
+	}
+	id, err := identity.GetID()
+	if err != nil {
+		return crypto.Caller{}, fmt.Errorf("Failed to get client ID: %s", err)
+	}
+
+	enrollmentID, found, err := identity.GetAttributeValue("hf.EnrollmentID")
+	if err != nil {
+		return crypto.Caller{}, fmt.Errorf("Failed to get client enrollment ID: %s", err)
+	}
+	if !found {
+		certificate, err := identity.GetX509Certificate()
+		if err != nil {
+			return crypto.Caller{}, fmt.Errorf("Failed to get client certificate: %s", err)
+		}
+		if certificate != nil {
+			enrollmentID = certificate.Subject.CommonName
+		}
+	}
+
+	return crypto.Caller{ID: mspID + "/" + id, MSPID: mspID, EnrollmentID: enrollmentID}, nil
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+
+	"simple/ledger"
+	"simple/ledger/ledgertest"
+	"simple/policy"
+)
+
+// queryStub complète le MockStub, qui n'implémente pas GetQueryResult, par
+// l'évaluation des sélecteurs Mango sur son état en mémoire
+type queryStub struct {
+	*shimtest.MockStub
+	levelDB bool // Refuse les requêtes riches comme un peer sur LevelDB
+}
+
+func newQueryStub(name string) *queryStub {
+	return &queryStub{MockStub: shimtest.NewMockStub(name, nil)}
+}
+
+// GetQueryResult exécute la requête riche comme CouchDB sur le world state
+func (s *queryStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	if s.levelDB {
+		return nil, errors.New("ExecuteQuery not supported for leveldb")
+	}
+	results, err := ledgertest.Select(s.State, query)
+	if err != nil {
+		return nil, err
+	}
+	return &queryIterator{results: results}, nil
+}
+
+// queryIterator parcourt les résultats d'une requête riche
+type queryIterator struct {
+	results []ledger.KV
+	next    int
+}
+
+func (it *queryIterator) HasNext() bool {
+	return it.next < len(it.results)
+}
+
+func (it *queryIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, errors.New("No more query results")
+	}
+	result := it.results[it.next]
+	it.next++
+	return &queryresult.KV{Key: result.Key, Value: result.Value}, nil
+}
+
+func (it *queryIterator) Close() error {
+	return nil
+}
+
+func TestStubStateQuery(t *testing.T) {
+	n, _, _ := newPricedNetwork(t)
+	state := stubState{n.stub}
+
+	results, err := state.Query(`{"selector":{"vehicleID":"vehicle1"}}`)
+	n.must("Query", err)
+	var keys []string
+	for _, result := range results {
+		keys = append(keys, result.Key)
+	}
+	if want := []string{"trip_trip1", "vehicle_vehicle1"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("selected %v, expected %v", keys, want)
+	}
+
+	_, err = state.Query(`{"selector":{"vehicleID":{"$elemMatch":{}}}}`)
+	checkError(t, err, "Unsupported selector operator '$elemMatch'")
+}
+
+// newOwnersNetwork enregistre deux propriétaires, leurs véhicules, des trajets
+// sur deux mois et les contrats qui les lient à des poids publics
+func newOwnersNetwork(t *testing.T) *testNetwork {
+	sk := ownerKey(t, 0)
+	n, _, _ := newPricedNetwork(t)
+	n.addVerifier("owner2", sk)
+	n.addVehicle("vehicle2", "owner1", &sk.PublicKey, testVehicle)
+	n.addVehicle("vehicle3", "owner2", &sk.PublicKey, testVehicle)
+	n.addTrip("vehicle1", "trip2", "2024-03-28", "owner1", &sk.PublicKey, testTrip)
+	n.addTrip("vehicle1", "trip3", "2024-04-02", "owner1", &sk.PublicKey, testTrip)
+	n.addTrip("vehicle3", "trip4", "2024-03-10", "owner2", &sk.PublicKey, testTrip)
+
+	weights := testWeights
+	weights.CriteriaWeightsID = "weights2"
+	weights.Alpha = 4
+	n.addWeights(weights)
+
+	for _, contract := range []policy.InsuranceContract{
+		{ContractID: "contract1", OwnerID: "owner1", VehicleID: "vehicle1", CriteriaWeightsID: "weights1", StartMonth: 1, StartYear: 2024, EndMonth: 12, EndYear: 2024},
+		{ContractID: "contract2", OwnerID: "owner1", VehicleID: "vehicle2", CriteriaWeightsID: "weights2", StartMonth: 6, StartYear: 2024, EndMonth: 5, EndYear: 2025},
+		{ContractID: "contract3", OwnerID: "owner2", VehicleID: "vehicle3", CriteriaWeightsID: "weights1", StartMonth: 1, StartYear: 2024, EndMonth: 12, EndYear: 2024},
+	} {
+		n.must("AddInsuranceContract", n.contract.AddInsuranceContract(n.begin(nil), contract.ContractID, contract.OwnerID, contract.VehicleID,
+			contract.CriteriaWeightsID, contract.StartMonth, contract.StartYear, contract.EndMonth, contract.EndYear))
+	}
+	return n
+}
+
+func TestQueryVehiclesByOwner(t *testing.T) {
+	n := newOwnersNetwork(t)
+
+	tests := []struct {
+		ownerID        string
+		wantVehicleIDs []string
+	}{
+		// Les contrats et le Verifier du propriétaire portent aussi son ownerID
+		{"owner1", []string{"vehicle1", "vehicle2"}},
+		{"owner2", []string{"vehicle3"}},
+		{"owner3", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ownerID, func(t *testing.T) {
+			vehicles, err := n.contract.QueryVehiclesByOwner(n.begin(nil), tt.ownerID)
+			n.must("QueryVehiclesByOwner", err)
+
+			var vehicleIDs []string
+			for _, vehicle := range vehicles {
+				if vehicle.VehicleDetails.VehicleID != vehicle.VehicleID || vehicle.VehicleDetails.OwnerID != tt.ownerID {
+					t.Fatalf("unexpected vehicle %+v", vehicle)
+				}
+				vehicleIDs = append(vehicleIDs, vehicle.VehicleID)
+			}
+			if !reflect.DeepEqual(vehicleIDs, tt.wantVehicleIDs) {
+				t.Fatalf("vehicles %v, expected %v", vehicleIDs, tt.wantVehicleIDs)
+			}
+		})
+	}
+}
+
+func TestQueryOwnerDetails(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n := newOwnersNetwork(t)
+	premium := n.decrypt(n.calculate("vehicle1", "trip1", "weights1"), sk)
+	n.calculate("vehicle1", "trip2", "weights1")
+
+	details, err := n.contract.QueryOwnerDetails(n.begin(nil), "owner1")
+	n.must("QueryOwnerDetails", err)
+
+	if len(details.Vehicles) != 2 || details.Vehicles[0].VehicleID != "vehicle1" || details.Vehicles[1].VehicleID != "vehicle2" {
+		t.Fatalf("unexpected vehicles %+v", details.Vehicles)
+	}
+	contracts := details.Vehicles[1].Contracts
+	if len(contracts) != 1 || contracts[0].ContractID != "contract2" || contracts[0].StartDate != "06-2024" || contracts[0].EndDate != "05-2025" {
+		t.Fatalf("unexpected contracts of vehicle2 %+v", contracts)
+	}
+	if weights := contracts[0].CriteriaWeights; weights == nil || weights.Alpha != 4 || weights.WeightSpeed != testWeights.WeightSpeed {
+		t.Fatalf("unexpected criteria weights %+v", contracts[0].CriteriaWeights)
+	}
+
+	// Seule la prime déchiffrée est exposée
+	if len(details.Primes) != 1 || details.Primes[0].TripID != "trip1" || details.Primes[0].Prime != premium || details.Primes[0].Date != "2024-03-15" {
+		t.Fatalf("unexpected primes %+v", details.Primes)
+	}
+
+	details, err = n.contract.QueryOwnerDetails(n.begin(nil), "owner3")
+	n.must("QueryOwnerDetails", err)
+	if len(details.Vehicles) != 0 || len(details.Primes) != 0 {
+		t.Fatalf("unexpected details of an unknown owner %+v", details)
+	}
+}
+
+func TestQueryMultipleOwnerDetails(t *testing.T) {
+	n := newOwnersNetwork(t)
+
+	details, err := n.contract.QueryMultipleOwnerDetails(n.begin(nil), []string{"owner1", "", "owner2"})
+	n.must("QueryMultipleOwnerDetails", err)
+
+	if len(details) != 2 || len(details[0].Vehicles) != 2 || len(details[1].Vehicles) != 1 || details[1].Vehicles[0].VehicleID != "vehicle3" {
+		t.Fatalf("unexpected details %+v", details)
+	}
+}
+
+func TestQueryTripsByVehicleID(t *testing.T) {
+	n := newOwnersNetwork(t)
+
+	tests := []struct {
+		vehicleID   string
+		wantTripIDs []string
+	}{
+		// Le véhicule et ses contrats portent aussi le vehicleID
+		{"vehicle1", []string{"trip1", "trip2", "trip3"}},
+		{"vehicle2", nil},
+		{"vehicle3", []string{"trip4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.vehicleID, func(t *testing.T) {
+			trips, err := n.contract.QueryTripsByVehicleID(n.begin(nil), tt.vehicleID)
+			n.must("QueryTripsByVehicleID", err)
+
+			var tripIDs []string
+			for _, trip := range trips {
+				if trip.VehicleID != tt.vehicleID {
+					t.Fatalf("trip %s belongs to %s", trip.TripID, trip.VehicleID)
+				}
+				tripIDs = append(tripIDs, trip.TripID)
+			}
+			if !reflect.DeepEqual(tripIDs, tt.wantTripIDs) {
+				t.Fatalf("trips %v, expected %v", tripIDs, tt.wantTripIDs)
+			}
+		})
+	}
+}
+
+func TestQueryAllCriteriaWeights(t *testing.T) {
+	n := newTestNetwork(t)
+	all, err := n.contract.QueryAllCriteriaWeights(n.begin(nil))
+	n.must("QueryAllCriteriaWeights", err)
+	if len(all) != 0 {
+		t.Fatalf("unexpected CriteriaWeights %+v", all)
+	}
+
+	n = newOwnersNetwork(t)
+	all, err = n.contract.QueryAllCriteriaWeights(n.begin(nil))
+	n.must("QueryAllCriteriaWeights", err)
+
+	// Les contrats, qui portent un criteriaWeightsID, ne sont pas des poids
+	if len(all) != 2 || all[0] != testWeights || all[1].CriteriaWeightsID != "weights2" || all[1].Alpha != 4 {
+		t.Fatalf("unexpected CriteriaWeights %+v", all)
+	}
+}
+
+func TestQueryInsuranceContracts(t *testing.T) {
+	n := newOwnersNetwork(t)
+
+	contractIDs := func(contracts []policy.InsuranceContract) []string {
+		var ids []string
+		for _, contract := range contracts {
+			ids = append(ids, contract.ContractID)
+		}
+		return ids
+	}
+
+	byOwner, err := n.contract.QueryInsuranceContractsByOwner(n.begin(nil), "owner1")
+	n.must("QueryInsuranceContractsByOwner", err)
+	if ids := contractIDs(byOwner); !reflect.DeepEqual(ids, []string{"contract1", "contract2"}) {
+		t.Fatalf("contracts of owner1 %v", ids)
+	}
+
+	all, err := n.contract.QueryAllInsuranceContracts(n.begin(nil))
+	n.must("QueryAllInsuranceContracts", err)
+	if ids := contractIDs(all); !reflect.DeepEqual(ids, []string{"contract1", "contract2", "contract3"}) {
+		t.Fatalf("all contracts %v", ids)
+	}
+}
+
+func TestQueryPrimesAndResultsByVehicleID(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n := newOwnersNetwork(t)
+	premium := n.decrypt(n.calculate("vehicle1", "trip1", "weights1"), sk)
+	n.calculate("vehicle1", "trip3", "weights2")
+	n.calculate("vehicle3", "trip4", "weights1")
+
+	primes, err := n.contract.QueryPrimesByVehicleID(n.begin(nil), "vehicle1")
+	n.must("QueryPrimesByVehicleID", err)
+	if len(primes) != 1 || primes[0].TripID != "trip1" || primes[0].Prime != premium {
+		t.Fatalf("unexpected primes %+v", primes)
+	}
+
+	results, err := n.contract.QueryEncryptedCalculationResultsByVehicleID(n.begin(nil), "vehicle1")
+	n.must("QueryEncryptedCalculationResultsByVehicleID", err)
+	if len(results) != 2 || results[0].ResultID != "result_trip1" || results[1].ResultID != "result_trip3" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestMonthlyPremiumAggregation(t *testing.T) {
+	sk := ownerKey(t, 0)
+	n := newOwnersNetwork(t)
+
+	_, err := n.contract.AggregateMonthlyPremium(n.begin(nil), "vehicle1", 3, 2024)
+	checkError(t, err, "No EncryptedCalculationResult found for the given VehicleID, Month, and Year")
+
+	// Les trajets de mars sont agrégés, celui d'avril est ignoré
+	for _, tripID := range []string{"trip1", "trip2", "trip3"} {
+		n.calculate("vehicle1", tripID, "weights1")
+	}
+	monthPrime, err := n.contract.AggregateMonthlyPremium(n.begin(nil), "vehicle1", 3, 2024)
+	n.must("AggregateMonthlyPremium", err)
+	if !reflect.DeepEqual(monthPrime.ResultIDs, []string{"result_trip1", "result_trip2"}) {
+		t.Fatalf("aggregated results %v", monthPrime.ResultIDs)
+	}
+
+	r, ok := new(big.Int).SetString(monthPrime.R, 10)
+	if !ok {
+		t.Fatalf("invalid R %q", monthPrime.R)
+	}
+	rPrime, err := sk.ComputeRPrime(r)
+	n.must("ComputeRPrime", err)
+
+	// Seul le total d'un mois agrégé se déchiffre : la prime d'avril reste déchiffrable
+	result, err := n.contract.QueryEncryptedCalculationResult(n.begin(nil), "result_trip1")
+	n.must("QueryEncryptedCalculationResult", err)
+	tripRPrime, err := sk.ComputeRPrime(decodeResult(t, result).R)
+	n.must("ComputeRPrime", err)
+	_, err = n.contract.DecryptInsurancePremiumAndUpdate(n.begin(nil), "trip1", tripRPrime.String())
+	checkError(t, err, "Trip premiums of an aggregated month can only be decrypted through the monthly total")
+	april, err := n.contract.QueryEncryptedCalculationResult(n.begin(nil), "result_trip3")
+	n.must("QueryEncryptedCalculationResult", err)
+	n.decrypt(decodeResult(t, april), sk)
+
+	decrypted, err := n.contract.DecryptMonthlyPremium(n.begin(nil), "vehicle1", 3, 2024, rPrime.String())
+	n.must("DecryptMonthlyPremium", err)
+	if want := 2 * referencePremium(t, testVehicle, testTrip, testWeights); decrypted.DecryptedPrime != want {
+		t.Fatalf("monthly premium %d, expected %d", decrypted.DecryptedPrime, want)
+	}
+
+	_, err = n.contract.AggregateMonthlyPremium(n.begin(nil), "vehicle1", 3, 2024)
+	checkError(t, err, "MonthPrime for this VehicleID, Month, and Year already exists")
+}
+
+func TestRemoveAgeFromEncryptedVehicleData(t *testing.T) {
+	n := newOwnersNetwork(t)
+
+	// Données enregistrées par une version du chaincode qui stockait l'âge
+	var legacy map[string]interface{}
+	n.must("Unmarshal vehicle", json.Unmarshal(n.stub.State["vehicle_vehicle2"], &legacy))
+	legacy["age"] = "pc1:AQ"
+	legacyJSON, err := json.Marshal(legacy)
+	n.must("Marshal vehicle", err)
+	n.begin(nil)
+	n.must("PutState", n.stub.PutState("vehicle_vehicle2", legacyJSON))
+
+	_, err = n.contract.RemoveAgeFromEncryptedVehicleData(n.begin(nil))
+	n.must("RemoveAgeFromEncryptedVehicleData", err)
+	if strings.Contains(string(n.stub.State["vehicle_vehicle2"]), `"age"`) {
+		t.Fatal("age still stored in EncryptedVehicleData")
+	}
+
+	vehicle, err := n.contract.QueryVehicleData(n.begin(nil), "vehicle2")
+	n.must("QueryVehicleData", err)
+	if vehicle.OwnerID != "owner1" {
+		t.Fatalf("unexpected VehicleData %+v", vehicle)
+	}
+}
+
+func TestPurgeDecryptors(t *testing.T) {
+	n := newOwnersNetwork(t)
+	n.begin(nil)
+	for _, key := range []string{"decryptor_owner1", "decryptor_owner2"} {
+		n.must("PutState", n.stub.PutState(key, []byte(`{"lambda":"1","mu":"1"}`)))
+	}
+
+	// La migration doit aussi s'exécuter sur les peers LevelDB
+	n.stub.levelDB = true
+	response, err := n.contract.PurgeDecryptors(n.begin(nil))
+	n.must("PurgeDecryptors", err)
+	if response != "2 Decryptor assets purged successfully. "+purgedKeysWarning {
+		t.Fatalf("unexpected response %s", response)
+	}
+	for key := range n.stub.State {
+		if strings.HasPrefix(key, "decryptor_") {
+			t.Fatalf("%s was not purged", key)
+		}
+	}
+	if _, ok := n.stub.State["verifier_owner1"]; !ok {
+		t.Fatal("Verifier purged with the Decryptors")
+	}
+}
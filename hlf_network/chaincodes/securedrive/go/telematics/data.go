@@ -0,0 +1,236 @@
+// Package telematics gère les données chiffrées des véhicules et des trajets,
+// les bornes déclarées des champs télématiques (FieldSpec) et les preuves
+// jointes aux chiffrés soumis.
+package telematics
+
+import (
+	"errors"
+
+	"simple/paillier"
+	"simple/pedersen"
+)
+
+// EncryptedVehicleData représente les données du véhicule chiffrées
+type EncryptedVehicleData struct {
+	VehicleID       string               `json:"vehicleID"`        // Identifiant unique du véhicule
+	VehicleType     *paillier.Ciphertext `json:"vehicle_type"`     // Chiffré
+	PurchaseMileage *paillier.Ciphertext `json:"purchase_mileage"` // Chiffré
+	Year            *paillier.Ciphertext `json:"year"`             // Chiffré
+	OwnerID         string               `json:"ownerID"`
+	KeyVersion      int                  `json:"key_version,omitempty"`     // Version de la clé du propriétaire utilisée pour chiffrer
+	KeyFingerprint  string               `json:"key_fingerprint,omitempty"` // Empreinte SHA-256 de cette clé
+	Bounded         bool                 `json:"bounded,omitempty"`         // Chaque clair respecte les bornes de son FieldSpec
+	Scales          map[string]int64     `json:"scales,omitempty"`          // Échelle de virgule fixe de chaque champ à l'enregistrement
+}
+
+// EncryptedTripData représente les données d'un trajet chiffrées
+type EncryptedTripData struct {
+	VehicleID               string               `json:"vehicleID"`                 // Identifiant du véhicule
+	TripID                  string               `json:"tripID"`                    // Identifiant unique du trajet
+	Date                    string               `json:"date"`                      // Date du trajet (format ISO 8601 : YYYY-MM-DD)
+	Speeding                *paillier.Ciphertext `json:"speeding"`                  // Chiffré
+	HardAccelerations       *paillier.Ciphertext `json:"hard_accelerations"`        // Chiffré
+	EmergencyBrakes         *paillier.Ciphertext `json:"emergency_brakes"`          // Chiffré
+	UnsafeDistance          *paillier.Ciphertext `json:"unsafe_distance"`           // Chiffré
+	HighRiskZones           *paillier.Ciphertext `json:"high_risk_zones"`           // Chiffré
+	TrafficSignalCompliance *paillier.Ciphertext `json:"traffic_signal_compliance"` // Chiffré
+	NightDriving            *paillier.Ciphertext `json:"night_driving"`             // Chiffré
+	Mileage                 *paillier.Ciphertext `json:"mileage"`                   // Chiffré
+	KeyVersion              int                  `json:"key_version,omitempty"`     // Version de la clé du propriétaire utilisée pour chiffrer
+	KeyFingerprint          string               `json:"key_fingerprint,omitempty"` // Empreinte SHA-256 de cette clé
+	Bounded                 bool                 `json:"bounded,omitempty"`         // Chaque clair respecte les bornes de son FieldSpec
+	Scales                  map[string]int64     `json:"scales,omitempty"`          // Échelle de virgule fixe de chaque champ à l'enregistrement
+
+	// Encodage packé optionnel : les métriques sont regroupées, dans l'ordre de
+	// TripFields, dans les slots de SlotBits bits d'un seul chiffré et les
+	// champs individuels sont vides
+	Packed   *paillier.Ciphertext `json:"packed,omitempty"`    // Chiffré packé
+	SlotBits int                  `json:"slot_bits,omitempty"` // Largeur d'un slot en bits
+
+	// Engagements de Pedersen optionnels sur les clairs, liés aux chiffrés par
+	// une preuve à l'enregistrement. Le conducteur peut les ouvrir champ par
+	// champ devant un arbitre en cas de contestation d'une prime
+	Commitments map[string]*pedersen.Commitment `json:"commitments,omitempty"`
+}
+
+// TripFieldOpening atteste qu'un conducteur a ouvert devant un arbitre
+// l'engagement d'un champ d'un trajet. La valeur et l'aléa, transmis par le
+// transient map, sont enregistrés chiffrés sous la clé de l'arbitre, qui
+// peut les déchiffrer et vérifier lui-même l'ouverture
+type TripFieldOpening struct {
+	TripID       string               `json:"tripID"`
+	Field        string               `json:"field"`
+	ArbitratorID string               `json:"arbitratorID"`
+	Commitment   *pedersen.Commitment `json:"commitment"` // Engagement ouvert
+	TxID         string               `json:"txID"`       // Transaction de l'ouverture
+
+	// Valeur engagée et aléa (représentant centré modulo Q), chiffrés sous la
+	// version KeyVersion de la clé de l'arbitre
+	Value          *paillier.Ciphertext `json:"value,omitempty"`
+	Opening        *paillier.Ciphertext `json:"opening,omitempty"`
+	KeyVersion     int                  `json:"key_version,omitempty"`
+	KeyFingerprint string               `json:"key_fingerprint,omitempty"`
+}
+
+// Arbitrator est un arbitre devant lequel les conducteurs peuvent ouvrir les
+// engagements de leurs trajets. Les ouvertures sont chiffrées sous la clé
+// (Verifier) enregistrée pour ArbitratorID
+type Arbitrator struct {
+	ArbitratorID string `json:"arbitratorID"`
+}
+
+// FieldSpec décrit l'encodage et les bornes déclarées d'un champ télématique
+// chiffré. Les valeurs décimales sont encodées en virgule fixe :
+// clair = round(valeur * Scale) ; Min et Max portent sur ce clair encodé
+type FieldSpec struct {
+	Field string `json:"field"`           // Nom JSON du champ chiffré (ex. "mileage")
+	Min   int64  `json:"min"`             // Borne inférieure du clair
+	Max   int64  `json:"max"`             // Borne supérieure du clair
+	Scale int64  `json:"scale,omitempty"` // Échelle de virgule fixe (1 par défaut)
+}
+
+// EncryptedFieldProofs regroupe les preuves optionnelles jointes aux champs
+// chiffrés d'un trajet, indexées par nom de champ
+type EncryptedFieldProofs struct {
+	Range     map[string]*paillier.RangeProof     `json:"range,omitempty"`     // Preuves d'appartenance aux bornes du FieldSpec
+	Knowledge map[string]*paillier.KnowledgeProof `json:"knowledge,omitempty"` // Preuves de connaissance du clair sous la clé du propriétaire
+
+	// Engagements de Pedersen sur les clairs et preuves qu'ils portent sur la
+	// même valeur que le chiffré (trajets uniquement)
+	Commitments map[string]*pedersen.Commitment          `json:"commitments,omitempty"`
+	Link        map[string]*paillier.CommitmentLinkProof `json:"link,omitempty"`
+}
+
+// Erreurs retournées pour un véhicule, un trajet ou un arbitre absent
+var (
+	ErrVehicleNotFound    = errors.New("Vehicle data not found for the given VehicleID")
+	ErrTripNotFound       = errors.New("Trip data not found for the given TripID")
+	ErrArbitratorNotFound = errors.New("Arbitrator not registered for the given ArbitratorID")
+)
+
+// TripFields liste les champs chiffrés d'un trajet
+var TripFields = []string{
+	"speeding",
+	"hard_accelerations",
+	"emergency_brakes",
+	"unsafe_distance",
+	"high_risk_zones",
+	"traffic_signal_compliance",
+	"night_driving",
+	"mileage",
+}
+
+// VehicleFields liste les champs chiffrés d'un véhicule
+var VehicleFields = []string{
+	"vehicle_type",
+	"purchase_mileage",
+	"year",
+}
+
+// PackedField est le nom du chiffré unique d'un trajet packé
+const PackedField = "packed"
+
+// VehicleKey retourne la clé du véhicule dans le world state, à laquelle les
+// preuves sur ses chiffrés sont liées
+func VehicleKey(vehicleID string) string {
+	return "vehicle_" + vehicleID
+}
+
+// TripKey retourne la clé du trajet dans le world state, à laquelle les
+// preuves sur ses chiffrés sont liées
+func TripKey(tripID string) string {
+	return "trip_" + tripID
+}
+
+// Fields retourne les noms des chiffrés stockés pour le trajet
+func (t *EncryptedTripData) Fields() []string {
+	if t.Packed != nil {
+		return []string{PackedField}
+	}
+	return TripFields
+}
+
+// Ciphertexts retourne les champs chiffrés du trajet indexés par leur nom JSON
+func (t *EncryptedTripData) Ciphertexts() map[string]*paillier.Ciphertext {
+	if t.Packed != nil {
+		return map[string]*paillier.Ciphertext{PackedField: t.Packed}
+	}
+	return map[string]*paillier.Ciphertext{
+		"speeding":                  t.Speeding,
+		"hard_accelerations":        t.HardAccelerations,
+		"emergency_brakes":          t.EmergencyBrakes,
+		"unsafe_distance":           t.UnsafeDistance,
+		"high_risk_zones":           t.HighRiskZones,
+		"traffic_signal_compliance": t.TrafficSignalCompliance,
+		"night_driving":             t.NightDriving,
+		"mileage":                   t.Mileage,
+	}
+}
+
+// SetCiphertext remplace le champ chiffré du trajet désigné par son nom JSON
+func (t *EncryptedTripData) SetCiphertext(field string, c *paillier.Ciphertext) {
+	switch field {
+	case "speeding":
+		t.Speeding = c
+	case "hard_accelerations":
+		t.HardAccelerations = c
+	case "emergency_brakes":
+		t.EmergencyBrakes = c
+	case "unsafe_distance":
+		t.UnsafeDistance = c
+	case "high_risk_zones":
+		t.HighRiskZones = c
+	case "traffic_signal_compliance":
+		t.TrafficSignalCompliance = c
+	case "night_driving":
+		t.NightDriving = c
+	case "mileage":
+		t.Mileage = c
+	case PackedField:
+		t.Packed = c
+	}
+}
+
+// Ciphertexts retourne les champs chiffrés du véhicule indexés par leur nom JSON
+func (v *EncryptedVehicleData) Ciphertexts() map[string]*paillier.Ciphertext {
+	return map[string]*paillier.Ciphertext{
+		"vehicle_type":     v.VehicleType,
+		"purchase_mileage": v.PurchaseMileage,
+		"year":             v.Year,
+	}
+}
+
+// SetCiphertext remplace le champ chiffré du véhicule désigné par son nom JSON
+func (v *EncryptedVehicleData) SetCiphertext(field string, c *paillier.Ciphertext) {
+	switch field {
+	case "vehicle_type":
+		v.VehicleType = c
+	case "purchase_mileage":
+		v.PurchaseMileage = c
+	case "year":
+		v.Year = c
+	}
+}
+
+// IsTripField indique si field est un champ chiffré d'un trajet
+func IsTripField(field string) bool {
+	for _, name := range TripFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEncryptedField indique si field est un champ chiffré d'un trajet ou d'un véhicule
+func IsEncryptedField(field string) bool {
+	if IsTripField(field) {
+		return true
+	}
+	for _, name := range VehicleFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
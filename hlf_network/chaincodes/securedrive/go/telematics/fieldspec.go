@@ -0,0 +1,185 @@
+package telematics
+
+import (
+	"fmt"
+	"math/big"
+
+	"simple/crypto"
+	"simple/paillier"
+	"simple/pedersen"
+)
+
+// Scales retourne l'échelle de virgule fixe de chaque champ (1 si aucun
+// FieldSpec n'est déclaré)
+func (s *Service) Scales(fields []string) (map[string]int64, error) {
+	scales := make(map[string]int64, len(fields))
+	for _, field := range fields {
+		fieldSpec, err := s.FieldSpecs.Get(field)
+		if err != nil {
+			return nil, err
+		}
+		scales[field] = 1
+		if fieldSpec != nil && fieldSpec.Scale > 0 {
+			scales[field] = fieldSpec.Scale
+		}
+	}
+	return scales, nil
+}
+
+// MaxAbs retourne max(|Min|, |Max|) pour le FieldSpec d'un champ, qui doit être déclaré
+func (s *Service) MaxAbs(field string) (*big.Int, error) {
+	fieldSpec, err := s.FieldSpecs.Get(field)
+	if err != nil {
+		return nil, err
+	}
+	if fieldSpec == nil {
+		return nil, fmt.Errorf("FieldSpec for field %s must be declared to bound the premium", field)
+	}
+
+	maxAbs := new(big.Int).Abs(big.NewInt(fieldSpec.Min))
+	if max := new(big.Int).Abs(big.NewInt(fieldSpec.Max)); max.Cmp(maxAbs) > 0 {
+		maxAbs = max
+	}
+	return maxAbs, nil
+}
+
+// FixedPointValues encode en virgule fixe, selon l'échelle de son FieldSpec,
+// la valeur de chacun des champs attendus. Chaque valeur doit respecter les
+// bornes de son FieldSpec, dont dépend la borne de la prime
+func (s *Service) FixedPointValues(fields []string, values map[string]string) (map[string]*big.Int, error) {
+	if len(values) != len(fields) {
+		return nil, fmt.Errorf("Expecting exactly %d fields: %v", len(fields), fields)
+	}
+
+	fixedPoints := make(map[string]*big.Int, len(fields))
+	for _, field := range fields {
+		value, ok := values[field]
+		if !ok {
+			return nil, fmt.Errorf("Missing field '%s'", field)
+		}
+
+		fieldSpec, err := s.FieldSpecs.Get(field)
+		if err != nil {
+			return nil, err
+		}
+		if fieldSpec == nil {
+			return nil, fmt.Errorf("No FieldSpec declared for field '%s'", field)
+		}
+
+		scale := fieldSpec.Scale
+		if scale <= 0 {
+			scale = 1
+		}
+		fixedPoint, err := paillier.EncodeFixedPoint(value, scale)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to convert %s: %s", field, err)
+		}
+		if fixedPoint.Cmp(big.NewInt(fieldSpec.Min)) < 0 || fixedPoint.Cmp(big.NewInt(fieldSpec.Max)) > 0 {
+			return nil, fmt.Errorf("Value of %s is outside the declared FieldSpec range", field)
+		}
+		fixedPoints[field] = fixedPoint
+	}
+	return fixedPoints, nil
+}
+
+// rangeProven indique si proofs contient une preuve d'intervalle pour chacun
+// des champs. Vérifiées par VerifyEncryptedFields, elles bornent tous les
+// clairs de l'enregistrement
+func rangeProven(fields []string, proofs *EncryptedFieldProofs) bool {
+	if proofs == nil {
+		return false
+	}
+	for _, field := range fields {
+		if _, ok := proofs.Range[field]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckSlotBits vérifie que les slots d'un trajet packé tiennent dans la
+// moitié de l'espace des clairs de la clé, l'autre moitié absorbant les
+// diagonales du produit par les coefficients. Une clé de Damgård–Jurik
+// (s > 1) admet des slots plus larges
+func CheckSlotBits(publicKey *paillier.PublicKey, slotBits int) error {
+	limit := publicKey.PlaintextBits() / 2 / len(TripFields)
+	if slotBits < 2 || slotBits > limit {
+		return fmt.Errorf("SlotBits must be between 2 and %d", limit)
+	}
+	return nil
+}
+
+// VerifyEncryptedFields vérifie que chaque chiffré appartient à Z*_{N²} sous
+// la clé du propriétaire, puis vérifie les preuves optionnelles jointes aux
+// champs. key identifie l'actif (ex. "trip_<TripID>") auquel les preuves sont liées
+func (s *Service) VerifyEncryptedFields(publicKey *paillier.PublicKey, key string, fields []string, ciphertexts map[string]*paillier.Ciphertext, proofs *EncryptedFieldProofs) error {
+	for _, field := range fields {
+		err := publicKey.ValidateCiphertext(ciphertexts[field])
+		if err != nil {
+			return fmt.Errorf("Invalid ciphertext for field '%s': %s", field, err)
+		}
+	}
+
+	if proofs == nil {
+		return nil
+	}
+
+	for field := range proofs.Range {
+		if _, ok := ciphertexts[field]; !ok {
+			return fmt.Errorf("Unknown field '%s' in range proofs", field)
+		}
+	}
+	for field := range proofs.Knowledge {
+		if _, ok := ciphertexts[field]; !ok {
+			return fmt.Errorf("Unknown field '%s' in knowledge proofs", field)
+		}
+	}
+	for field := range proofs.Commitments {
+		if _, ok := ciphertexts[field]; !ok {
+			return fmt.Errorf("Unknown field '%s' in commitments", field)
+		}
+		if _, ok := proofs.Link[field]; !ok {
+			return fmt.Errorf("Missing link proof for the commitment of field '%s'", field)
+		}
+	}
+	for field := range proofs.Link {
+		if _, ok := proofs.Commitments[field]; !ok {
+			return fmt.Errorf("Link proof for field '%s' has no commitment", field)
+		}
+	}
+
+	for _, field := range fields {
+		if proof, ok := proofs.Knowledge[field]; ok {
+			err := publicKey.VerifyKnowledge(ciphertexts[field], proof, crypto.FieldContext(key, field))
+			if err != nil {
+				return fmt.Errorf("Knowledge proof for field '%s' failed to verify: %s", field, err)
+			}
+		}
+
+		if commitment, ok := proofs.Commitments[field]; ok {
+			err := publicKey.VerifyCommitmentLink(pedersen.DefaultParams(), ciphertexts[field], commitment, proofs.Link[field], crypto.FieldContext(key, field))
+			if err != nil {
+				return fmt.Errorf("Commitment link proof for field '%s' failed to verify: %s", field, err)
+			}
+		}
+
+		proof, ok := proofs.Range[field]
+		if !ok {
+			continue
+		}
+
+		fieldSpec, err := s.FieldSpecs.Get(field)
+		if err != nil {
+			return err
+		}
+		if fieldSpec == nil {
+			return fmt.Errorf("No FieldSpec declared for field '%s'", field)
+		}
+
+		err = publicKey.VerifyRange(ciphertexts[field], big.NewInt(fieldSpec.Min), big.NewInt(fieldSpec.Max), proof, crypto.FieldContext(key, field))
+		if err != nil {
+			return fmt.Errorf("Range proof for field '%s' failed to verify: %s", field, err)
+		}
+	}
+	return nil
+}
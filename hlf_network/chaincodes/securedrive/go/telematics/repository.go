@@ -0,0 +1,309 @@
+package telematics
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"simple/ledger"
+)
+
+// VehicleRepository conserve les données chiffrées des véhicules. Get retourne
+// nil, sans erreur, pour un véhicule absent.
+type VehicleRepository interface {
+	Get(vehicleID string) (*EncryptedVehicleData, error)
+	Put(vehicle *EncryptedVehicleData) error
+	// ByOwner retourne les véhicules d'un propriétaire
+	ByOwner(ownerID string) ([]EncryptedVehicleData, error)
+	// RemoveField supprime un champ obsolète des actifs portant un vehicleID
+	// et retourne les identifiants des véhicules modifiés
+	RemoveField(field string) ([]string, error)
+	// HasField indique si un véhicule enregistré porte le champ field
+	HasField(field string) (bool, error)
+}
+
+// TripRepository conserve les données chiffrées des trajets. Get retourne nil,
+// sans erreur, pour un trajet absent.
+type TripRepository interface {
+	Get(tripID string) (*EncryptedTripData, error)
+	Put(trip *EncryptedTripData) error
+	Delete(tripID string) error
+	// ByVehicle retourne les trajets d'un véhicule
+	ByVehicle(vehicleID string) ([]EncryptedTripData, error)
+	// HasField indique si un trajet enregistré porte le champ field, vide
+	// pour un trajet packé
+	HasField(field string) (bool, error)
+}
+
+// FieldSpecRepository conserve les bornes déclarées des champs chiffrés. Get
+// retourne nil, sans erreur, pour un champ sans FieldSpec.
+type FieldSpecRepository interface {
+	Get(field string) (*FieldSpec, error)
+	Put(fieldSpec *FieldSpec) error
+}
+
+// OpeningRepository conserve les attestations d'ouverture des engagements des
+// trajets. Get retourne nil, sans erreur, pour une attestation absente.
+type OpeningRepository interface {
+	Get(tripID, field, arbitratorID string) (*TripFieldOpening, error)
+	Put(opening *TripFieldOpening) error
+}
+
+// ArbitratorRepository conserve les arbitres enregistrés. Get retourne nil,
+// sans erreur, pour un arbitre absent.
+type ArbitratorRepository interface {
+	Get(arbitratorID string) (*Arbitrator, error)
+	Put(arbitrator *Arbitrator) error
+}
+
+// NewVehicleRepository retourne le dépôt des véhicules dans le world state,
+// sous vehicle_<VehicleID>
+func NewVehicleRepository(state ledger.State) VehicleRepository {
+	return &vehicleStore{state: state}
+}
+
+type vehicleStore struct {
+	state ledger.State
+}
+
+func (r *vehicleStore) Get(vehicleID string) (*EncryptedVehicleData, error) {
+	var vehicle EncryptedVehicleData
+	found, err := ledger.GetJSON(r.state, VehicleKey(vehicleID), &vehicle)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get EncryptedVehicleData: %s", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &vehicle, nil
+}
+
+func (r *vehicleStore) Put(vehicle *EncryptedVehicleData) error {
+	err := ledger.PutJSON(r.state, VehicleKey(vehicle.VehicleID), vehicle)
+	if err != nil {
+		return fmt.Errorf("Failed to store EncryptedVehicleData: %s", err)
+	}
+	return nil
+}
+
+func (r *vehicleStore) ByOwner(ownerID string) ([]EncryptedVehicleData, error) {
+	// Les contrats portent aussi un ownerID : ils sont exclus par contractID,
+	// les Verifier par l'absence de vehicleID
+	results, err := r.state.Query(fmt.Sprintf(`{"selector":{"ownerID":"%s", "contractID":{"$exists":false}}}`, ownerID))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query vehicles by OwnerID: %s", err)
+	}
+
+	var vehicles []EncryptedVehicleData
+	for _, result := range results {
+		var vehicle EncryptedVehicleData
+		err = json.Unmarshal(result.Value, &vehicle)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal vehicle data: %s", err)
+		}
+		if vehicle.VehicleID == "" || vehicle.OwnerID != ownerID {
+			continue
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+	return vehicles, nil
+}
+
+func (r *vehicleStore) RemoveField(field string) ([]string, error) {
+	results, err := r.state.Query(`{"selector":{"vehicleID":{"$exists":true}}}`)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query EncryptedVehicleData: %s", err)
+	}
+
+	var updated []string
+	for _, result := range results {
+		var vehicle map[string]interface{}
+		err = json.Unmarshal(result.Value, &vehicle)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal EncryptedVehicleData: %s", err)
+		}
+
+		if _, exists := vehicle[field]; !exists {
+			continue
+		}
+		delete(vehicle, field)
+
+		err = ledger.PutJSON(r.state, result.Key, vehicle)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to update EncryptedVehicleData: %s", err)
+		}
+		updated = append(updated, fmt.Sprint(vehicle["vehicleID"]))
+	}
+	return updated, nil
+}
+
+func (r *vehicleStore) HasField(field string) (bool, error) {
+	// Les cumuls mensuels portent aussi un vehicleID et un champ year : ils
+	// sont exclus par l'absence d'ownerID, les contrats par contractID
+	results, err := r.state.Query(fmt.Sprintf(`{"selector":{"vehicleID":{"$exists":true}, "ownerID":{"$exists":true}, "contractID":{"$exists":false}, "%s":{"$exists":true}}}`, field))
+	if err != nil {
+		return false, fmt.Errorf("Failed to query EncryptedVehicleData: %s", err)
+	}
+	return len(results) > 0, nil
+}
+
+// NewTripRepository retourne le dépôt des trajets dans le world state, sous
+// trip_<TripID>
+func NewTripRepository(state ledger.State) TripRepository {
+	return &tripStore{state: state}
+}
+
+type tripStore struct {
+	state ledger.State
+}
+
+func (r *tripStore) Get(tripID string) (*EncryptedTripData, error) {
+	var trip EncryptedTripData
+	found, err := ledger.GetJSON(r.state, TripKey(tripID), &trip)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get EncryptedTripData: %s", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &trip, nil
+}
+
+func (r *tripStore) Put(trip *EncryptedTripData) error {
+	err := ledger.PutJSON(r.state, TripKey(trip.TripID), trip)
+	if err != nil {
+		return fmt.Errorf("Failed to store EncryptedTripData: %s", err)
+	}
+	return nil
+}
+
+func (r *tripStore) Delete(tripID string) error {
+	err := r.state.DelState(TripKey(tripID))
+	if err != nil {
+		return fmt.Errorf("Failed to delete EncryptedTripData: %s", err)
+	}
+	return nil
+}
+
+func (r *tripStore) ByVehicle(vehicleID string) ([]EncryptedTripData, error) {
+	results, err := r.state.Query(fmt.Sprintf(`{"selector":{"vehicleID":"%s"}}`, vehicleID))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query trips by VehicleID: %s", err)
+	}
+
+	var trips []EncryptedTripData
+	for _, result := range results {
+		var trip EncryptedTripData
+		err = json.Unmarshal(result.Value, &trip)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal trip data: %s", err)
+		}
+		// Le véhicule et ses contrats portent aussi le vehicleID
+		if trip.TripID == "" {
+			continue
+		}
+		trips = append(trips, trip)
+	}
+	return trips, nil
+}
+
+func (r *tripStore) HasField(field string) (bool, error) {
+	results, err := r.state.Query(fmt.Sprintf(`{"selector":{"tripID":{"$exists":true}, "%s":{"$exists":true}}}`, field))
+	if err != nil {
+		return false, fmt.Errorf("Failed to query EncryptedTripData: %s", err)
+	}
+	return len(results) > 0, nil
+}
+
+// NewFieldSpecRepository retourne le dépôt des FieldSpec dans le world state,
+// sous fieldspec_<champ>
+func NewFieldSpecRepository(state ledger.State) FieldSpecRepository {
+	return &fieldSpecStore{state: state}
+}
+
+type fieldSpecStore struct {
+	state ledger.State
+}
+
+func (r *fieldSpecStore) Get(field string) (*FieldSpec, error) {
+	var fieldSpec FieldSpec
+	found, err := ledger.GetJSON(r.state, "fieldspec_"+field, &fieldSpec)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get FieldSpec for field %s", field)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &fieldSpec, nil
+}
+
+func (r *fieldSpecStore) Put(fieldSpec *FieldSpec) error {
+	err := ledger.PutJSON(r.state, "fieldspec_"+fieldSpec.Field, fieldSpec)
+	if err != nil {
+		return fmt.Errorf("Failed to store FieldSpec: %s", err)
+	}
+	return nil
+}
+
+// NewOpeningRepository retourne le dépôt des attestations d'ouverture dans le
+// world state, sous tripopening_<TripID>_<champ>_<ArbitratorID>
+func NewOpeningRepository(state ledger.State) OpeningRepository {
+	return &openingStore{state: state}
+}
+
+type openingStore struct {
+	state ledger.State
+}
+
+func openingKey(tripID, field, arbitratorID string) string {
+	return "tripopening_" + tripID + "_" + field + "_" + arbitratorID
+}
+
+func (r *openingStore) Get(tripID, field, arbitratorID string) (*TripFieldOpening, error) {
+	var opening TripFieldOpening
+	found, err := ledger.GetJSON(r.state, openingKey(tripID, field, arbitratorID), &opening)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve TripFieldOpening: %s", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &opening, nil
+}
+
+func (r *openingStore) Put(opening *TripFieldOpening) error {
+	err := ledger.PutJSON(r.state, openingKey(opening.TripID, opening.Field, opening.ArbitratorID), opening)
+	if err != nil {
+		return fmt.Errorf("Failed to store TripFieldOpening: %s", err)
+	}
+	return nil
+}
+
+// NewArbitratorRepository retourne le dépôt des arbitres dans le world state,
+// sous arbitrator_<ArbitratorID>
+func NewArbitratorRepository(state ledger.State) ArbitratorRepository {
+	return &arbitratorStore{state: state}
+}
+
+type arbitratorStore struct {
+	state ledger.State
+}
+
+func (r *arbitratorStore) Get(arbitratorID string) (*Arbitrator, error) {
+	var arbitrator Arbitrator
+	found, err := ledger.GetJSON(r.state, "arbitrator_"+arbitratorID, &arbitrator)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get Arbitrator: %s", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &arbitrator, nil
+}
+
+func (r *arbitratorStore) Put(arbitrator *Arbitrator) error {
+	err := ledger.PutJSON(r.state, "arbitrator_"+arbitrator.ArbitratorID, arbitrator)
+	if err != nil {
+		return fmt.Errorf("Failed to store Arbitrator: %s", err)
+	}
+	return nil
+}
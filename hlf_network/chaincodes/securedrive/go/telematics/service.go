@@ -0,0 +1,665 @@
+package telematics
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"simple/crypto"
+	"simple/ledger"
+	"simple/paillier"
+	"simple/pedersen"
+)
+
+// Service regroupe l'enregistrement et la maintenance des données chiffrées
+// des véhicules et des trajets. Les dépôts sont exposés afin que les tests
+// puissent les remplacer.
+type Service struct {
+	Vehicles    VehicleRepository
+	Trips       TripRepository
+	FieldSpecs  FieldSpecRepository
+	Openings    OpeningRepository
+	Arbitrators ArbitratorRepository
+	Keys        *crypto.Service
+}
+
+// NewService construit le service sur les dépôts du world state
+func NewService(state ledger.State, keys *crypto.Service) *Service {
+	return &Service{
+		Vehicles:    NewVehicleRepository(state),
+		Trips:       NewTripRepository(state),
+		FieldSpecs:  NewFieldSpecRepository(state),
+		Openings:    NewOpeningRepository(state),
+		Arbitrators: NewArbitratorRepository(state),
+		Keys:        keys,
+	}
+}
+
+// Vehicle charge les données chiffrées d'un véhicule
+func (s *Service) Vehicle(vehicleID string) (*EncryptedVehicleData, error) {
+	vehicle, err := s.Vehicles.Get(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	if vehicle == nil {
+		return nil, ErrVehicleNotFound
+	}
+	return vehicle, nil
+}
+
+// Trip charge les données chiffrées d'un trajet
+func (s *Service) Trip(tripID string) (*EncryptedTripData, error) {
+	trip, err := s.Trips.Get(tripID)
+	if err != nil {
+		return nil, err
+	}
+	if trip == nil {
+		return nil, ErrTripNotFound
+	}
+	return trip, nil
+}
+
+// OwnerVerifier charge la version active de la clé du propriétaire d'un véhicule
+func (s *Service) OwnerVerifier(vehicleID string) (*crypto.Verifier, error) {
+	vehicle, err := s.Vehicle(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Keys.Verifier(vehicle.OwnerID)
+}
+
+// AddEncryptedVehicleData enregistre un véhicule chiffré hors chaîne sous la
+// clé de son propriétaire, après vérification des chiffrés et des preuves
+// optionnelles. Sans preuve d'intervalle pour chaque champ, le véhicule n'est
+// pas marqué Bounded et ne peut pas être tarifé
+func (s *Service) AddEncryptedVehicleData(vehicle *EncryptedVehicleData, proofs *EncryptedFieldProofs) error {
+	verifier, err := s.Keys.Verifier(vehicle.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	fingerprint, err := verifier.Fingerprint()
+	if err != nil {
+		return err
+	}
+
+	err = s.checkNewVehicle(vehicle.VehicleID)
+	if err != nil {
+		return err
+	}
+
+	if proofs != nil && (len(proofs.Commitments) > 0 || len(proofs.Link) > 0) {
+		return errors.New("Commitments are only supported on trip data")
+	}
+
+	vehicle.KeyVersion = verifier.Version()
+	vehicle.KeyFingerprint = fingerprint
+
+	err = s.VerifyEncryptedFields(publicKey, VehicleKey(vehicle.VehicleID), VehicleFields, vehicle.Ciphertexts(), proofs)
+	if err != nil {
+		return err
+	}
+	vehicle.Bounded = rangeProven(VehicleFields, proofs)
+	vehicle.Scales, err = s.Scales(VehicleFields)
+	if err != nil {
+		return err
+	}
+
+	return s.Vehicles.Put(vehicle)
+}
+
+// AddEncryptedTripData enregistre un trajet chiffré hors chaîne sous la clé du
+// propriétaire du véhicule. Les engagements joints aux preuves sont conservés
+// avec le trajet, qui n'est tarifable que si chaque champ a une preuve
+// d'intervalle. Une graine seed non nulle re-randomise les chiffrés avant
+// stockage : elle conserve les clairs, les engagements restent donc valables.
+// Les chiffrés soumis restant dans les arguments de la transaction, elle ne
+// rend pas le trajet stocké non reliable à la soumission : le client qui le
+// souhaite re-randomise lui-même ses chiffrés (paillier.Rerandomize)
+func (s *Service) AddEncryptedTripData(trip *EncryptedTripData, proofs *EncryptedFieldProofs, seed []byte) error {
+	err := s.checkNewTrip(trip.TripID)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := s.OwnerVerifier(trip.VehicleID)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	fingerprint, err := verifier.Fingerprint()
+	if err != nil {
+		return err
+	}
+	trip.KeyVersion = verifier.Version()
+	trip.KeyFingerprint = fingerprint
+
+	key := TripKey(trip.TripID)
+	err = s.VerifyEncryptedFields(publicKey, key, TripFields, trip.Ciphertexts(), proofs)
+	if err != nil {
+		return err
+	}
+	trip.Bounded = rangeProven(TripFields, proofs)
+	trip.Scales, err = s.Scales(TripFields)
+	if err != nil {
+		return err
+	}
+	if proofs != nil && len(proofs.Commitments) > 0 {
+		trip.Commitments = proofs.Commitments
+	}
+
+	if seed != nil {
+		rerandomized, err := s.Keys.Rerandomize(publicKey, key, TripFields, trip.Ciphertexts(), seed)
+		if err != nil {
+			return err
+		}
+		for field, c := range rerandomized {
+			trip.SetCiphertext(field, c)
+		}
+	}
+
+	return s.Trips.Put(trip)
+}
+
+// AddPackedTripData enregistre un trajet dont les métriques ont été packées et
+// chiffrées hors chaîne, dans l'ordre de TripFields, dans un seul chiffré. Le
+// client joint le chiffré de chaque métrique et sa preuve d'appartenance aux
+// bornes du FieldSpec : le chiffré packé doit être exactement leur packing
+// homomorphe, de sorte que chaque slot respecte ses bornes
+func (s *Service) AddPackedTripData(trip *EncryptedTripData, slots map[string]*paillier.Ciphertext, proofs *EncryptedFieldProofs) error {
+	verifier, err := s.OwnerVerifier(trip.VehicleID)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	fingerprint, err := verifier.Fingerprint()
+	if err != nil {
+		return err
+	}
+
+	err = CheckSlotBits(publicKey, trip.SlotBits)
+	if err != nil {
+		return err
+	}
+
+	err = publicKey.ValidateCiphertext(trip.Packed)
+	if err != nil {
+		return fmt.Errorf("Invalid packed ciphertext: %s", err)
+	}
+
+	err = s.checkNewTrip(trip.TripID)
+	if err != nil {
+		return err
+	}
+
+	err = s.verifyPackedSlots(publicKey, trip, slots, proofs)
+	if err != nil {
+		return err
+	}
+	if len(proofs.Commitments) > 0 {
+		trip.Commitments = proofs.Commitments
+	}
+
+	trip.KeyVersion = verifier.Version()
+	trip.KeyFingerprint = fingerprint
+	trip.Bounded = true
+	trip.Scales, err = s.Scales(TripFields)
+	if err != nil {
+		return err
+	}
+	return s.Trips.Put(trip)
+}
+
+// verifyPackedSlots vérifie les chiffrés des métriques d'un trajet packé et
+// leurs preuves d'intervalle, obligatoires, puis que le chiffré packé du
+// trajet en est le packing homomorphe
+func (s *Service) verifyPackedSlots(publicKey *paillier.PublicKey, trip *EncryptedTripData, slots map[string]*paillier.Ciphertext, proofs *EncryptedFieldProofs) error {
+	if len(slots) != len(TripFields) {
+		return fmt.Errorf("Expecting exactly %d slot ciphertexts: %v", len(TripFields), TripFields)
+	}
+	if proofs == nil {
+		return errors.New("Packed trip data requires a range proof for each slot")
+	}
+
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(trip.SlotBits-1))
+	ordered := make([]*paillier.Ciphertext, len(TripFields))
+	for j, field := range TripFields {
+		c, ok := slots[field]
+		if !ok {
+			return fmt.Errorf("Missing slot ciphertext for field '%s'", field)
+		}
+		if _, ok := proofs.Range[field]; !ok {
+			return fmt.Errorf("Missing range proof for field '%s'", field)
+		}
+
+		// Les bornes du FieldSpec doivent tenir dans un slot équilibré
+		maxAbs, err := s.MaxAbs(field)
+		if err != nil {
+			return err
+		}
+		if maxAbs.Cmp(limit) >= 0 {
+			return fmt.Errorf("FieldSpec range of field %s does not fit in a slot of %d bits", field, trip.SlotBits)
+		}
+		ordered[j] = c
+	}
+
+	// Le packing, peu coûteux, est vérifié avant les preuves d'intervalle
+	packed, err := publicKey.PackCiphertexts(ordered, uint(trip.SlotBits))
+	if err != nil {
+		return fmt.Errorf("Invalid slot ciphertexts: %s", err)
+	}
+	if packed.Int().Cmp(trip.Packed.Int()) != 0 {
+		return errors.New("Packed ciphertext does not match the packing of the slot ciphertexts")
+	}
+
+	return s.VerifyEncryptedFields(publicKey, TripKey(trip.TripID), TripFields, slots, proofs)
+}
+
+// AddVehicleData chiffre sur la chaîne les données d'un véhicule avec la clé
+// de ownerID. Chaque champ est chiffré avec un aléa distinct dérivé de la
+// graine nonce, qui ne peut servir qu'une fois
+func (s *Service) AddVehicleData(vehicleID, ownerID string, values map[string]string, nonce []byte) error {
+	err := s.checkNewVehicle(vehicleID)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := s.Keys.Verifier(ownerID)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	fingerprint, err := verifier.Fingerprint()
+	if err != nil {
+		return err
+	}
+
+	fixedPoints, err := s.FixedPointValues(VehicleFields, values)
+	if err != nil {
+		return err
+	}
+	scales, err := s.Scales(VehicleFields)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := s.Keys.EncryptFields(publicKey, VehicleKey(vehicleID), VehicleFields, fixedPoints, nonce)
+	if err != nil {
+		return err
+	}
+
+	return s.Vehicles.Put(&EncryptedVehicleData{
+		VehicleID:       vehicleID,
+		VehicleType:     encrypted["vehicle_type"],
+		PurchaseMileage: encrypted["purchase_mileage"],
+		Year:            encrypted["year"],
+		OwnerID:         ownerID,
+		KeyVersion:      verifier.Version(),
+		KeyFingerprint:  fingerprint,
+		Bounded:         true,
+		Scales:          scales,
+	})
+}
+
+// AddTripData chiffre sur la chaîne les données d'un trajet avec la clé de
+// ownerID. Avec un slotBits non nul, les métriques sont packées dans un seul
+// chiffré
+func (s *Service) AddTripData(vehicleID, tripID, date, ownerID string, values map[string]string, nonce []byte, slotBits int) error {
+	err := s.checkNewTrip(tripID)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := s.Keys.Verifier(ownerID)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	fingerprint, err := verifier.Fingerprint()
+	if err != nil {
+		return err
+	}
+
+	trip := &EncryptedTripData{
+		VehicleID:      vehicleID,
+		TripID:         tripID,
+		Date:           date,
+		KeyVersion:     verifier.Version(),
+		KeyFingerprint: fingerprint,
+		Bounded:        true,
+	}
+
+	fixedPoints, err := s.FixedPointValues(TripFields, values)
+	if err != nil {
+		return err
+	}
+	trip.Scales, err = s.Scales(TripFields)
+	if err != nil {
+		return err
+	}
+
+	fields := TripFields
+	if slotBits != 0 {
+		err = CheckSlotBits(publicKey, slotBits)
+		if err != nil {
+			return err
+		}
+
+		packed, err := pack(fixedPoints, slotBits)
+		if err != nil {
+			return err
+		}
+		fields = []string{PackedField}
+		fixedPoints = map[string]*big.Int{PackedField: packed}
+		trip.SlotBits = slotBits
+	}
+
+	encrypted, err := s.Keys.EncryptFields(publicKey, TripKey(tripID), fields, fixedPoints, nonce)
+	if err != nil {
+		return err
+	}
+	for field, c := range encrypted {
+		trip.SetCiphertext(field, c)
+	}
+
+	return s.Trips.Put(trip)
+}
+
+// pack regroupe les métriques d'un trajet, dans l'ordre de TripFields, dans
+// les slots de slotBits bits d'un seul clair. FixedPointValues a vérifié que
+// chaque métrique respecte les bornes de son FieldSpec
+func pack(fixedPoints map[string]*big.Int, slotBits int) (*big.Int, error) {
+	slots := make([]*big.Int, len(TripFields))
+	for j, field := range TripFields {
+		slots[j] = fixedPoints[field]
+	}
+
+	packed, err := paillier.Pack(slots, uint(slotBits))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pack trip data: %s", err)
+	}
+	return packed, nil
+}
+
+// ReencryptVehicleData remplace les chiffrés d'un véhicule enregistrés sous une
+// clé retirée par des chiffrés sous la clé active, preuves d'égalité à
+// l'appui. caller doit pouvoir agir pour le propriétaire. Retourne la version
+// de la clé active
+func (s *Service) ReencryptVehicleData(caller crypto.Caller, vehicleID string, ciphertexts map[string]*paillier.Ciphertext, proofs map[string]*paillier.EqualityProof) (int, error) {
+	vehicle, err := s.Vehicle(vehicleID)
+	if err != nil {
+		return 0, err
+	}
+	err = s.Keys.Authorize(caller, vehicle.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+
+	version, fingerprint, err := s.Keys.Reencrypt(vehicle.OwnerID, VehicleKey(vehicleID), vehicle.KeyVersion, vehicle.KeyFingerprint, VehicleFields, vehicle.Ciphertexts(), ciphertexts, proofs)
+	if err != nil {
+		return 0, err
+	}
+
+	for field, c := range ciphertexts {
+		vehicle.SetCiphertext(field, c)
+	}
+	vehicle.KeyVersion = version
+	vehicle.KeyFingerprint = fingerprint
+
+	return version, s.Vehicles.Put(vehicle)
+}
+
+// ReencryptTripData remplace les chiffrés d'un trajet enregistrés sous une clé
+// retirée par des chiffrés sous la clé active, preuves d'égalité à l'appui.
+// caller doit pouvoir agir pour le propriétaire. Retourne la version de la clé
+// active
+func (s *Service) ReencryptTripData(caller crypto.Caller, tripID string, ciphertexts map[string]*paillier.Ciphertext, proofs map[string]*paillier.EqualityProof) (int, error) {
+	trip, err := s.Trip(tripID)
+	if err != nil {
+		return 0, err
+	}
+
+	verifier, err := s.OwnerVerifier(trip.VehicleID)
+	if err != nil {
+		return 0, err
+	}
+	err = s.Keys.Authorize(caller, verifier.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+
+	version, fingerprint, err := s.Keys.Reencrypt(verifier.OwnerID, TripKey(tripID), trip.KeyVersion, trip.KeyFingerprint, trip.Fields(), trip.Ciphertexts(), ciphertexts, proofs)
+	if err != nil {
+		return 0, err
+	}
+
+	for field, c := range ciphertexts {
+		trip.SetCiphertext(field, c)
+	}
+	trip.KeyVersion = version
+	trip.KeyFingerprint = fingerprint
+
+	return version, s.Trips.Put(trip)
+}
+
+// AddArbitrator enregistre un arbitre. Sa clé (Verifier), sous laquelle les
+// ouvertures lui sont chiffrées, doit être enregistrée, et caller doit pouvoir
+// agir pour elle
+func (s *Service) AddArbitrator(caller crypto.Caller, arbitratorID string) error {
+	err := s.Keys.Authorize(caller, arbitratorID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.Arbitrators.Get(arbitratorID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errors.New("Arbitrator with this ArbitratorID already exists")
+	}
+
+	return s.Arbitrators.Put(&Arbitrator{ArbitratorID: arbitratorID})
+}
+
+// OpenTripCommitment vérifie l'ouverture (value, opening) de l'engagement d'un
+// champ d'un trajet, puis l'enregistre chiffrée sous la clé de l'arbitre, avec
+// un aléa dérivé de la graine nonce. caller doit pouvoir agir pour le
+// propriétaire du véhicule, et l'arbitre être enregistré
+func (s *Service) OpenTripCommitment(caller crypto.Caller, tripID, field, arbitratorID string, value, opening *big.Int, nonce []byte, txID string) error {
+	trip, err := s.Trip(tripID)
+	if err != nil {
+		return err
+	}
+	vehicle, err := s.Vehicle(trip.VehicleID)
+	if err != nil {
+		return err
+	}
+	err = s.Keys.Authorize(caller, vehicle.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	arbitrator, err := s.Arbitrators.Get(arbitratorID)
+	if err != nil {
+		return err
+	}
+	if arbitrator == nil {
+		return ErrArbitratorNotFound
+	}
+
+	commitment, ok := trip.Commitments[field]
+	if !ok {
+		return fmt.Errorf("No commitment recorded for field '%s' of this trip", field)
+	}
+
+	// La preuve de lien borne les valeurs engagées à ScalarBits bits
+	if new(big.Int).Abs(value).BitLen() > paillier.ScalarBits {
+		return errors.New("Opened value is out of range")
+	}
+	params := pedersen.DefaultParams()
+	err = params.Open(commitment, value, opening)
+	if err != nil {
+		return fmt.Errorf("Opening does not match the commitment for field '%s'", field)
+	}
+
+	verifier, err := s.Keys.Verifier(arbitratorID)
+	if err != nil {
+		return err
+	}
+	publicKey, err := verifier.PublicKey()
+	if err != nil {
+		return fmt.Errorf("Invalid Verifier public key: %s", err)
+	}
+	fingerprint, err := verifier.Fingerprint()
+	if err != nil {
+		return err
+	}
+
+	// L'aléa est réduit à son représentant centré modulo Q, de valeur absolue
+	// inférieure à N/2, afin d'être chiffré comme un entier signé
+	centered := new(big.Int).Mod(opening, params.Q)
+	if centered.Cmp(new(big.Int).Rsh(params.Q, 1)) > 0 {
+		centered.Sub(centered, params.Q)
+	}
+
+	key := openingKey(tripID, field, arbitratorID)
+	encrypted, err := s.Keys.EncryptFields(publicKey, key, []string{"value", "opening"}, map[string]*big.Int{
+		"value":   value,
+		"opening": centered,
+	}, nonce)
+	if err != nil {
+		return err
+	}
+
+	return s.Openings.Put(&TripFieldOpening{
+		TripID:         tripID,
+		Field:          field,
+		ArbitratorID:   arbitratorID,
+		Commitment:     commitment,
+		TxID:           txID,
+		Value:          encrypted["value"],
+		Opening:        encrypted["opening"],
+		KeyVersion:     verifier.Version(),
+		KeyFingerprint: fingerprint,
+	})
+}
+
+// AddFieldSpec déclare les bornes et l'échelle de virgule fixe d'un champ
+// chiffré. Une échelle nulle désigne l'échelle par défaut. Seul l'assureur
+// peut déclarer un FieldSpec, et seulement avant l'enregistrement de données
+// pour ce champ : elles ont été encodées et bornées sans lui
+func (s *Service) AddFieldSpec(caller crypto.Caller, field string, min, max, scale int64) error {
+	if !caller.IsInsurer() {
+		return errors.New("Only the insurer can declare a FieldSpec")
+	}
+
+	if !IsEncryptedField(field) {
+		return fmt.Errorf("Unknown encrypted field '%s'", field)
+	}
+
+	if min > max {
+		return errors.New("Min must be lower than or equal to Max")
+	}
+
+	if scale == 0 {
+		scale = 1
+	}
+	if scale < 0 {
+		return errors.New("Invalid Scale value. Expecting a positive integer")
+	}
+
+	existingSpec, err := s.FieldSpecs.Get(field)
+	if err != nil {
+		return err
+	}
+	if existingSpec != nil {
+		return errors.New("FieldSpec for this field already exists")
+	}
+
+	hasData := s.Vehicles.HasField
+	if IsTripField(field) {
+		hasData = s.Trips.HasField
+	}
+	exists, err := hasData(field)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New("Data has already been recorded for this field")
+	}
+
+	return s.FieldSpecs.Put(&FieldSpec{
+		Field: field,
+		Min:   min,
+		Max:   max,
+		Scale: scale,
+	})
+}
+
+// SetVehicleOwner ajoute ou remplace le propriétaire d'un véhicule
+func (s *Service) SetVehicleOwner(vehicleID, ownerID string) error {
+	vehicle, err := s.Vehicle(vehicleID)
+	if err != nil {
+		return err
+	}
+
+	vehicle.OwnerID = ownerID
+	return s.Vehicles.Put(vehicle)
+}
+
+// DeleteTrip supprime les données chiffrées d'un trajet
+func (s *Service) DeleteTrip(tripID string) error {
+	trip, err := s.Trips.Get(tripID)
+	if err != nil {
+		return err
+	}
+	if trip == nil {
+		return errors.New("Trip data with this TripID does not exist")
+	}
+	return s.Trips.Delete(tripID)
+}
+
+// checkNewVehicle vérifie qu'aucun véhicule n'est enregistré sous vehicleID
+func (s *Service) checkNewVehicle(vehicleID string) error {
+	existingVehicle, err := s.Vehicles.Get(vehicleID)
+	if err != nil {
+		return err
+	}
+	if existingVehicle != nil {
+		return errors.New("Vehicle data with this VehicleID already exists")
+	}
+	return nil
+}
+
+// checkNewTrip vérifie qu'aucun trajet n'est enregistré sous tripID
+func (s *Service) checkNewTrip(tripID string) error {
+	existingTrip, err := s.Trips.Get(tripID)
+	if err != nil {
+		return err
+	}
+	if existingTrip != nil {
+		return errors.New("Trip data with this TripID already exists")
+	}
+	return nil
+}